@@ -3,23 +3,43 @@ package main
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/dowglassantana/golang-with-dynamodb/internal/fieldcrypt"
 	"github.com/dowglassantana/golang-with-dynamodb/internal/handler"
+	"github.com/dowglassantana/golang-with-dynamodb/internal/logging"
+	"github.com/dowglassantana/golang-with-dynamodb/internal/migrate"
 	"github.com/dowglassantana/golang-with-dynamodb/internal/repository"
+	"github.com/dowglassantana/golang-with-dynamodb/internal/requestctx"
 	"github.com/dowglassantana/golang-with-dynamodb/internal/service"
+	"github.com/dowglassantana/golang-with-dynamodb/internal/stream"
+	"github.com/dowglassantana/golang-with-dynamodb/internal/telemetry"
 	"github.com/dowglassantana/golang-with-dynamodb/pkg/dynamo"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
 func main() {
 	ctx := context.Background()
 
+	// Logs em JSON no stdout, um objeto por linha. LOG_LEVEL=debug|info|warn|error
+	// define o nivel minimo (padrao info). O log.Printf de outros pacotes tambem passa
+	// por este logger.
+	logger, err := logging.FromEnv()
+	if err != nil {
+		fatal("configuracao de log invalida", "error", err)
+	}
+	slog.SetDefault(logger)
+
 	env := os.Getenv("ENV")
 	if env == "" {
 		env = "local"
@@ -30,65 +50,345 @@ func main() {
 		tableName = "Users"
 	}
 
-	var (
-		client *dynamodb.Client
-		err    error
-	)
+	// Tracing: OTEL_TRACES_EXPORTER=stdout|otlp liga a exportacao dos spans — veja
+	// telemetry.Setup. O padrao (none) so repassa o traceparent recebido.
+	shutdownTracing, err := telemetry.Setup(ctx, "golang-with-dynamodb")
+	if err != nil {
+		fatal("configuracao de tracing invalida", "error", err)
+	}
+
+	var repoOpts []repository.Option
+	if secret := os.Getenv("CURSOR_SECRET"); secret != "" {
+		repoOpts = append(repoOpts, repository.WithCursorSecret([]byte(secret)))
+	} else {
+		slog.Warn("CURSOR_SECRET nao definido, cursores de paginacao serao invalidados ao reiniciar")
+	}
 
-	switch env {
-	case "aws":
-		region := os.Getenv("AWS_REGION")
-		if region == "" {
-			region = "us-east-1"
+	if raw := os.Getenv("SOFT_DELETE_RETENTION"); raw != "" {
+		retention, err := time.ParseDuration(raw)
+		if err != nil {
+			fatal("SOFT_DELETE_RETENTION invalido", "error", err)
 		}
-		client, err = dynamo.NewClient(ctx, region)
-	default:
-		client, err = dynamo.NewLocalClient(ctx)
+		repoOpts = append(repoOpts, repository.WithRetention(retention))
 	}
 
-	if err != nil {
-		log.Fatalf("erro ao criar client DynamoDB: %v", err)
+	// STRICT_DELETE=true faz o DELETE responder 404 para usuarios inexistentes
+	// (o padrao e 204, como um DeleteItem idempotente).
+	if os.Getenv("STRICT_DELETE") == "true" {
+		repoOpts = append(repoOpts, repository.WithStrictDelete())
 	}
 
-	repo := repository.NewUserRepository(client, tableName)
+	var repo repository.UserRepository
+	// tableCheck e a verificacao do /readyz; fica nil em ENV=memory.
+	var tableCheck handler.TableCheck
+
+	// O consumidor do stream e a rotacao de chaves rodam em background e sao
+	// cancelados no shutdown.
+	backgroundCtx, stopBackground := context.WithCancel(ctx)
+	defer stopBackground()
+
+	// ENV=memory dispensa o DynamoDB: os dados ficam em memoria e somem ao reiniciar.
+	// Util para desenvolvimento rapido sem subir o docker-compose.
+	if env == "memory" {
+		repo = repository.NewMemoryUserRepository(repoOpts...)
+	} else {
+		// As opcoes do client (endpoint, credenciais, retries, pool HTTP) vem das
+		// variaveis DYNAMO_* — veja dynamo.FromEnv.
+		dynamoOpts, err := dynamo.FromEnv(env)
+		if err != nil {
+			fatal("configuracao do DynamoDB invalida", "error", err)
+		}
+		// Latencia, erros, throttles e capacidade consumida de cada operacao vao
+		// para o /metrics. O client do stream usa as mesmas opcoes e as mesmas metricas.
+		dynamoOpts = append(dynamoOpts, dynamo.WithMetrics(dynamo.NewMetrics(prometheus.DefaultRegisterer)))
+		// Cada operacao vira um span filho do span da requisicao.
+		dynamoOpts = append(dynamoOpts, dynamo.WithTracing())
+
+		client, err := dynamo.New(ctx, dynamoOpts...)
+		if err != nil {
+			fatal("erro ao criar client DynamoDB", "error", err)
+		}
+
+		// O email e cifrado antes de ir para a tabela, com as chaves do arquivo
+		// ENCRYPTION_KEYFILE — veja fieldcrypt.FromEnv.
+		sealer, err := fieldcrypt.FromEnv(ctx, env)
+		if err != nil {
+			fatal("configuracao de criptografia invalida", "error", err)
+		}
+
+		// O schema da tabela e gerenciado pelas migracoes de internal/migrate.
+		// Em desenvolvimento elas rodam no boot; na AWS o padrao e rodar
+		// "go run ./cmd/migrate up" no deploy e a API apenas avisa se ha pendencias.
+		// AUTO_MIGRATE=true|false sobrescreve o padrao.
+		migrator := migrate.New(client, tableName, migrate.WithSealer(sealer))
+		autoMigrate := env != "aws"
+		if raw := os.Getenv("AUTO_MIGRATE"); raw != "" {
+			autoMigrate = raw == "true"
+		}
+
+		if autoMigrate {
+			if _, err := migrator.Up(ctx); err != nil {
+				fatal("erro ao aplicar migracoes", "error", err)
+			}
+		} else {
+			pending, err := migrator.Plan(ctx)
+			if err != nil {
+				fatal("erro ao consultar migracoes", "error", err)
+			}
+			if len(pending) > 0 {
+				slog.Warn("ha migracoes pendentes, rode cmd/migrate up", "pending", len(pending))
+			}
+		}
+
+		dynamoRepo := repository.NewUserRepository(client, tableName, sealer, repoOpts...)
+		repo = dynamoRepo
+		tableCheck = dynamoRepo.TableStatus
 
-	if err := repo.CreateTable(ctx); err != nil {
-		log.Printf("aviso ao criar tabela (pode ja existir): %v", err)
+		// A varredura re-cifra os itens que ainda usam uma chave mestra antiga.
+		// KEY_ROTATION_INTERVAL=0 a desliga (a re-cifragem na leitura continua).
+		rotationInterval := time.Hour
+		if raw := os.Getenv("KEY_ROTATION_INTERVAL"); raw != "" {
+			rotationInterval, err = time.ParseDuration(raw)
+			if err != nil || rotationInterval < 0 {
+				fatal("KEY_ROTATION_INTERVAL invalido", "value", raw)
+			}
+		}
+		if rotationInterval > 0 {
+			go dynamoRepo.RunKeyRotation(backgroundCtx, rotationInterval)
+		}
+
+		// STREAM_CONSUMER=true roda o consumidor do DynamoDB Streams dentro da API.
+		// Alternativa: rodar o binario cmd/streamer separado. Use apenas um dos dois.
+		if os.Getenv("STREAM_CONSUMER") == "true" {
+			go runStreamConsumer(backgroundCtx, dynamoOpts, client, tableName, sealer)
+		}
+	}
+
+	// O span do repository fica por dentro do cache: um hit nao chega ao repository.
+	repo = repository.NewTracedUserRepository(repo)
+
+	// O cache de GetByID e opcional (CACHE_SIZE > 0): ele e por processo, entao com
+	// varias tasks uma leitura pode ver um usuario ate CACHE_TTL atrasado. Os contadores
+	// ficam no /metrics.
+	cacheOpts, cacheEnabled, err := cacheOptionsFromEnv()
+	if err != nil {
+		fatal("configuracao do cache invalida", "error", err)
+	}
+	var cached *repository.CachedUserRepository
+	if cacheEnabled {
+		cached = repository.NewCachedUserRepository(repo, cacheOpts...)
+		repo = cached
+		registerCacheMetrics(prometheus.DefaultRegisterer, cached)
 	}
 
-	svc := service.NewUserService(repo)
+	svc := service.NewTracedUserService(service.NewUserService(repo))
 	userHandler := handler.NewUserHandler(svc)
 
 	mux := http.NewServeMux()
 	userHandler.RegisterRoutes(mux)
 
+	// /admin/export e /admin/import exigem "Authorization: Bearer $ADMIN_TOKEN". Sem a
+	// variavel, as rotas ficam desligadas (404).
+	adminToken := os.Getenv("ADMIN_TOKEN")
+	if adminToken == "" {
+		slog.Info("ADMIN_TOKEN nao definido, rotas /admin desabilitadas")
+	}
+	userHandler.RegisterAdminRoutes(mux, adminToken)
+
 	addr := ":8080"
+	// Cada requisicao carrega o tenant do header X-Tenant-ID. Sem o header, vale
+	// DEFAULT_TENANT; com TENANT_REQUIRED=true o header e obrigatorio.
+	defaultTenant := os.Getenv("DEFAULT_TENANT")
+	if defaultTenant == "" {
+		defaultTenant = requestctx.DefaultTenant
+	}
+	if !requestctx.ValidTenant(defaultTenant) {
+		fatal("DEFAULT_TENANT invalido", "value", defaultTenant)
+	}
+	if os.Getenv("TENANT_REQUIRED") == "true" {
+		defaultTenant = ""
+	}
+
+	// O otelhttp envolve o proprio mux para que o span receba o nome da rota
+	// ("GET /users/{id}"): o mux grava o padrao em r.Pattern, e os outros middlewares
+	// trocam o *http.Request ao mudar o contexto.
+	api := handler.TenantMiddleware(defaultTenant)(handler.ActorMiddleware(otelhttp.NewHandler(mux, "http.server")))
+	api = handler.MetricsMiddleware(prometheus.DefaultRegisterer, mux)(api)
+	// O request id e o log de acesso ficam por fora de tudo: ate o 400 de um
+	// X-Tenant-ID invalido sai no log, com o id que volta para o cliente.
+	api = handler.RequestIDMiddleware(logger)(handler.AccessLogMiddleware(api))
+
+	// /readyz reaproveita o DescribeTable por READINESS_CACHE_TTL (padrao 5s).
+	var readinessTTL time.Duration
+	if raw := os.Getenv("READINESS_CACHE_TTL"); raw != "" {
+		readinessTTL, err = time.ParseDuration(raw)
+		if err != nil || readinessTTL <= 0 {
+			fatal("READINESS_CACHE_TTL invalido", "value", raw)
+		}
+	}
+	healthHandler := handler.NewHealthHandler(tableName, tableCheck, readinessTTL)
+
+	// Tempo entre o /readyz passar a falhar e o server.Shutdown, para o load balancer
+	// perceber e parar de mandar requisicoes. Na AWS o padrao e 10s (o ECS da 30s entre
+	// o SIGTERM e o SIGKILL); localmente o encerramento e imediato.
+	drainDelay := time.Duration(0)
+	if env == "aws" {
+		drainDelay = 10 * time.Second
+	}
+	if raw := os.Getenv("SHUTDOWN_DRAIN_DELAY"); raw != "" {
+		drainDelay, err = time.ParseDuration(raw)
+		if err != nil || drainDelay < 0 {
+			fatal("SHUTDOWN_DRAIN_DELAY invalido", "value", raw)
+		}
+	}
+
+	// O /metrics (formato texto do Prometheus) e os health checks ficam fora dos
+	// middlewares da API: nem o scraper nem o load balancer mandam X-Tenant-ID, e as
+	// consultas deles nao entram nas metricas nem no log de acesso.
+	root := http.NewServeMux()
+	root.Handle("/", api)
+	root.Handle("GET /metrics", promhttp.Handler())
+	healthHandler.RegisterRoutes(root)
+
 	server := &http.Server{
 		Addr:    addr,
-		Handler: mux,
+		Handler: root,
 	}
 
-	// Graceful shutdown: ao receber SIGINT ou SIGTERM, o servidor para de aceitar
-	// novas conexoes e aguarda ate 10 segundos para as requests em andamento finalizarem.
+	// Graceful shutdown: ao receber SIGINT ou SIGTERM, o /readyz passa a responder 503
+	// e, depois de drainDelay, o servidor para de aceitar novas conexoes e aguarda ate
+	// 10 segundos para as requests em andamento finalizarem. Durante o drainDelay a API
+	// continua atendendo normalmente: so o load balancer deixa de escolher esta task.
 	// No ECS Fargate, o container recebe SIGTERM antes de ser encerrado.
+	// Depois do servidor, os spans que ainda estao no buffer sao enviados.
+	shutdownDone := make(chan struct{})
 	go func() {
+		defer close(shutdownDone)
+
 		sigChan := make(chan os.Signal, 1)
 		signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 		sig := <-sigChan
-		log.Printf("sinal recebido: %v. Encerrando servidor...", sig)
+		slog.Info("sinal recebido, encerrando servidor", "signal", sig.String(), "drain_delay", drainDelay.String())
+		healthHandler.Drain()
+		time.Sleep(drainDelay)
+		stopBackground()
 
 		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
 
 		if err := server.Shutdown(shutdownCtx); err != nil {
-			log.Printf("erro ao encerrar servidor: %v", err)
+			slog.Error("erro ao encerrar servidor", "error", err)
+		}
+		if err := shutdownTracing(shutdownCtx); err != nil {
+			slog.Error("erro ao enviar os ultimos spans", "error", err)
 		}
 	}()
 
-	fmt.Printf("Servidor rodando em http://localhost%s (env=%s)\n", addr, env)
+	slog.Info("servidor iniciado", "addr", addr, "env", env, "table", tableName)
 	if err := server.ListenAndServe(); err != http.ErrServerClosed {
-		log.Fatalf("erro no servidor: %v", err)
+		fatal("erro no servidor", "error", err)
+	}
+
+	// ListenAndServe retorna assim que o Shutdown comeca; espera as requisicoes em
+	// andamento e o envio dos spans.
+	<-shutdownDone
+	slog.Info("servidor encerrado com sucesso")
+}
+
+// fatal registra a falha de inicializacao e encerra o processo.
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+func runStreamConsumer(ctx context.Context, dynamoOpts []dynamo.Option, client *dynamodb.Client, tableName string, sealer *fieldcrypt.Sealer) {
+	consumer, err := newStreamConsumer(ctx, dynamoOpts, client, tableName, sealer)
+	if err != nil {
+		slog.Error("erro ao iniciar consumidor do stream", "error", err)
+		return
+	}
+
+	if err := consumer.Run(ctx); err != nil {
+		slog.Error("erro no consumidor do stream", "error", err)
+	}
+}
+
+func newStreamConsumer(ctx context.Context, dynamoOpts []dynamo.Option, client *dynamodb.Client, tableName string, sealer *fieldcrypt.Sealer) (*stream.Consumer, error) {
+	streamsClient, err := dynamo.NewStreams(ctx, dynamoOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao criar client do DynamoDB Streams: %w", err)
+	}
+
+	checkpointTable := os.Getenv("STREAM_CHECKPOINT_TABLE")
+	if checkpointTable == "" {
+		checkpointTable = tableName + "-stream-checkpoints"
+	}
+	checkpoints := stream.NewDynamoCheckpointStore(client, checkpointTable)
+	if err := checkpoints.CreateTable(ctx); err != nil {
+		return nil, err
+	}
+
+	dispatcher := stream.NewDispatcher()
+	stream.LogEvents(dispatcher)
+
+	return stream.NewConsumer(client, streamsClient, tableName, sealer, checkpoints, dispatcher), nil
+}
+
+// registerCacheMetrics expoe os contadores do cache no /metrics. Os valores sao lidos
+// de cached.Stats() a cada coleta, sem contadores duplicados.
+func registerCacheMetrics(reg prometheus.Registerer, cached *repository.CachedUserRepository) {
+	factory := promauto.With(reg)
+	factory.NewCounterFunc(prometheus.CounterOpts{
+		Name: "user_cache_hits_total",
+		Help: "Leituras de GetByID atendidas pelo cache.",
+	}, func() float64 { return float64(cached.Stats().Hits) })
+	factory.NewCounterFunc(prometheus.CounterOpts{
+		Name: "user_cache_misses_total",
+		Help: "Leituras de GetByID que foram ao repository.",
+	}, func() float64 { return float64(cached.Stats().Misses) })
+	factory.NewCounterFunc(prometheus.CounterOpts{
+		Name: "user_cache_evictions_total",
+		Help: "Entradas descartadas pelo LRU.",
+	}, func() float64 { return float64(cached.Stats().Evictions) })
+	factory.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "user_cache_entries",
+		Help: "Entradas no cache.",
+	}, func() float64 { return float64(cached.Stats().Size) })
+}
+
+// cacheOptionsFromEnv le CACHE_SIZE, CACHE_TTL e CACHE_NEGATIVE_TTL. O segundo
+// retorno e false quando o cache esta desligado (CACHE_SIZE ausente ou 0).
+func cacheOptionsFromEnv() ([]repository.CacheOption, bool, error) {
+	var opts []repository.CacheOption
+
+	raw := os.Getenv("CACHE_SIZE")
+	if raw == "" {
+		return nil, false, nil
+	}
+	size, err := strconv.Atoi(raw)
+	if err != nil || size < 0 {
+		return nil, false, fmt.Errorf("CACHE_SIZE invalido: %q", raw)
+	}
+	if size == 0 {
+		return nil, false, nil
+	}
+	opts = append(opts, repository.WithCacheSize(size))
+
+	if raw := os.Getenv("CACHE_TTL"); raw != "" {
+		ttl, err := time.ParseDuration(raw)
+		if err != nil || ttl <= 0 {
+			return nil, false, fmt.Errorf("CACHE_TTL invalido: %q", raw)
+		}
+		opts = append(opts, repository.WithCacheTTL(ttl))
+	}
+
+	if raw := os.Getenv("CACHE_NEGATIVE_TTL"); raw != "" {
+		ttl, err := time.ParseDuration(raw)
+		if err != nil || ttl < 0 {
+			return nil, false, fmt.Errorf("CACHE_NEGATIVE_TTL invalido: %q", raw)
+		}
+		opts = append(opts, repository.WithCacheNegativeTTL(ttl))
 	}
 
-	log.Println("servidor encerrado com sucesso")
+	return opts, true, nil
 }
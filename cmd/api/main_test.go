@@ -0,0 +1,49 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCacheOptionsFromEnv(t *testing.T) {
+	tests := []struct {
+		name        string
+		size        string
+		ttl         string
+		negativeTTL string
+		wantEnabled bool
+		wantOpts    int
+		wantErr     string
+	}{
+		{name: "sem CACHE_SIZE"},
+		{name: "CACHE_SIZE zero", size: "0", ttl: "1m"},
+		{name: "so tamanho", size: "100", wantEnabled: true, wantOpts: 1},
+		{name: "com TTLs", size: "100", ttl: "1m", negativeTTL: "0s", wantEnabled: true, wantOpts: 3},
+		{name: "tamanho texto", size: "cem", wantErr: "CACHE_SIZE"},
+		{name: "tamanho negativo", size: "-1", wantErr: "CACHE_SIZE"},
+		{name: "TTL zero", size: "100", ttl: "0s", wantErr: "CACHE_TTL"},
+		{name: "TTL sem unidade", size: "100", ttl: "60", wantErr: "CACHE_TTL"},
+		{name: "TTL negativo invalido", size: "100", negativeTTL: "-1s", wantErr: "CACHE_NEGATIVE_TTL"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CACHE_SIZE", tt.size)
+			t.Setenv("CACHE_TTL", tt.ttl)
+			t.Setenv("CACHE_NEGATIVE_TTL", tt.negativeTTL)
+
+			opts, enabled, err := cacheOptionsFromEnv()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("erro = %v, quer mencao a %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("cacheOptionsFromEnv: %v", err)
+			}
+			if enabled != tt.wantEnabled || len(opts) != tt.wantOpts {
+				t.Errorf("enabled = %v com %d opcoes, quer %v com %d", enabled, len(opts), tt.wantEnabled, tt.wantOpts)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+
+	"github.com/dowglassantana/golang-with-dynamodb/internal/fieldcrypt"
+	"github.com/dowglassantana/golang-with-dynamodb/internal/migrate"
+	"github.com/dowglassantana/golang-with-dynamodb/pkg/dynamo"
+)
+
+const usage = `uso: migrate <comando>
+
+comandos:
+  up      aplica as migracoes pendentes
+  status  lista todas as migracoes e quando foram aplicadas
+  plan    lista as migracoes que o "up" aplicaria, sem alterar nada
+
+variaveis de ambiente: ENV (local|aws), DYNAMO_TABLE, AWS_REGION, DYNAMO_*,
+ENCRYPTION_KEYFILE, ENCRYPTION_KEYFILE_AUTOCREATE`
+
+func main() {
+	if len(os.Args) != 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	env := os.Getenv("ENV")
+	if env == "" {
+		env = "local"
+	}
+
+	tableName := os.Getenv("DYNAMO_TABLE")
+	if tableName == "" {
+		tableName = "Users"
+	}
+
+	dynamoOpts, err := dynamo.FromEnv(env)
+	if err != nil {
+		log.Fatalf("configuracao do DynamoDB invalida: %v", err)
+	}
+
+	client, err := dynamo.New(ctx, dynamoOpts...)
+	if err != nil {
+		log.Fatalf("erro ao criar client DynamoDB: %v", err)
+	}
+
+	// A migracao 0009_encrypt_email cifra os emails existentes com as chaves de
+	// ENCRYPTION_KEYFILE, entao o "up" precisa das mesmas chaves da API.
+	var migrateOpts []migrate.Option
+	if os.Args[1] == "up" {
+		sealer, err := fieldcrypt.FromEnv(ctx, env)
+		if err != nil {
+			log.Fatalf("configuracao de criptografia invalida: %v", err)
+		}
+		migrateOpts = append(migrateOpts, migrate.WithSealer(sealer))
+	}
+
+	migrator := migrate.New(client, tableName, migrateOpts...)
+
+	switch os.Args[1] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, m := range applied {
+			fmt.Printf("aplicada: %s\n", m.ID)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(applied) == 0 {
+			fmt.Println("nenhuma migracao pendente")
+		}
+
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tSTATUS\tAPLICADA EM\tDESCRICAO")
+		for _, s := range statuses {
+			status, appliedAt := "pendente", "-"
+			if s.Applied() {
+				status, appliedAt = "aplicada", s.AppliedAt
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", s.ID, status, appliedAt, s.Description)
+		}
+		w.Flush()
+
+	case "plan":
+		pending, err := migrator.Plan(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(pending) == 0 {
+			fmt.Println("nenhuma migracao pendente")
+			return
+		}
+		for _, m := range pending {
+			fmt.Printf("%s  %s\n", m.ID, m.Description)
+		}
+
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
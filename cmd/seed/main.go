@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/dowglassantana/golang-with-dynamodb/internal/fieldcrypt"
+	"github.com/dowglassantana/golang-with-dynamodb/internal/repository"
+	"github.com/dowglassantana/golang-with-dynamodb/internal/requestctx"
+	"github.com/dowglassantana/golang-with-dynamodb/internal/seed"
+	"github.com/dowglassantana/golang-with-dynamodb/pkg/dynamo"
+)
+
+const usage = `uso: seed [opcoes]
+
+grava N usuarios ficticios e deterministicos na tabela DYNAMO_TABLE; com -wipe,
+exclui o mesmo conjunto (mesmos -n e -seed).
+
+opcoes:
+  -n N           quantidade de usuarios (padrao 1000)
+  -seed S        semente; a mesma semente gera sempre os mesmos usuarios (padrao 1)
+  -from DATA     inicio do intervalo de created_at, AAAA-MM-DD (padrao 2024-01-01)
+  -to DATA       fim do intervalo de created_at, exclusivo (padrao 2025-01-01)
+  -workers N     lotes gravados em paralelo (padrao 4)
+  -tenant T      tenant dos usuarios (padrao DEFAULT_TENANT ou "default")
+  -wipe          exclui os usuarios gerados em vez de grava-los
+
+variaveis de ambiente: ENV (local|aws), DYNAMO_TABLE, AWS_REGION, DYNAMO_*,
+ENCRYPTION_KEYFILE, ENCRYPTION_KEYFILE_AUTOCREATE`
+
+const dateLayout = "2006-01-02"
+
+// seed popula a tabela com usuarios ficticios para demonstracoes e testes de carga,
+// e remove esses usuarios depois com -wipe.
+func main() {
+	defaultTenant := os.Getenv("DEFAULT_TENANT")
+	if defaultTenant == "" {
+		defaultTenant = requestctx.DefaultTenant
+	}
+
+	flag.Usage = func() { fmt.Fprintln(os.Stderr, usage) }
+	n := flag.Int("n", 1000, "quantidade de usuarios")
+	seedValue := flag.Uint64("seed", 1, "semente")
+	from := flag.String("from", "2024-01-01", "inicio do intervalo de created_at")
+	to := flag.String("to", "2025-01-01", "fim do intervalo de created_at")
+	workers := flag.Int("workers", 4, "lotes gravados em paralelo")
+	tenant := flag.String("tenant", defaultTenant, "tenant dos usuarios")
+	wipe := flag.Bool("wipe", false, "exclui os usuarios gerados")
+	flag.Parse()
+
+	if flag.NArg() > 0 || *n <= 0 || *workers <= 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if !requestctx.ValidTenant(*tenant) {
+		log.Fatalf("tenant invalido: %q", *tenant)
+	}
+
+	gen := seed.Generator{Seed: *seedValue, From: parseDate("-from", *from), To: parseDate("-to", *to)}
+	if !gen.To.After(gen.From) {
+		log.Fatalf("-to (%s) deve ser depois de -from (%s)", *to, *from)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	ctx = requestctx.WithTenant(ctx, *tenant)
+	ctx = requestctx.WithActor(ctx, "seed")
+
+	repo := newRepository(ctx)
+	start := time.Now()
+
+	if *wipe {
+		stats, err := seed.Wipe(ctx, repo, gen, *n, *workers)
+		fmt.Printf("%d usuarios excluidos, %d falharam (%s)\n",
+			stats.Written, stats.Failed, time.Since(start).Round(time.Millisecond))
+		if err != nil {
+			log.Fatalf("wipe interrompido: %v", err)
+		}
+		return
+	}
+
+	stats, err := seed.Load(ctx, repo, gen, *n, *workers)
+	fmt.Printf("%d usuarios criados, %d ja existiam, %d falharam (%s)\n",
+		stats.Written, stats.Skipped, stats.Failed, time.Since(start).Round(time.Millisecond))
+	if err != nil {
+		log.Fatalf("seed interrompido: %v", err)
+	}
+}
+
+func parseDate(flagName, value string) time.Time {
+	t, err := time.Parse(dateLayout, value)
+	if err != nil {
+		log.Fatalf("%s invalido: %q (use AAAA-MM-DD)", flagName, value)
+	}
+	return t
+}
+
+// newRepository monta o repository como a API: mesmo client e mesmas chaves de
+// criptografia, para que os usuarios gerados sejam lidos normalmente por ela.
+func newRepository(ctx context.Context) *repository.DynamoUserRepository {
+	env := os.Getenv("ENV")
+	if env == "" {
+		env = "local"
+	}
+	if env == "memory" {
+		log.Fatalf("ENV=memory nao tem tabela para o seed")
+	}
+
+	tableName := os.Getenv("DYNAMO_TABLE")
+	if tableName == "" {
+		tableName = "Users"
+	}
+
+	dynamoOpts, err := dynamo.FromEnv(env)
+	if err != nil {
+		log.Fatalf("configuracao do DynamoDB invalida: %v", err)
+	}
+	client, err := dynamo.New(ctx, dynamoOpts...)
+	if err != nil {
+		log.Fatalf("erro ao criar client DynamoDB: %v", err)
+	}
+	sealer, err := fieldcrypt.FromEnv(ctx, env)
+	if err != nil {
+		log.Fatalf("configuracao de criptografia invalida: %v", err)
+	}
+
+	return repository.NewUserRepository(client, tableName, sealer)
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dowglassantana/golang-with-dynamodb/internal/dynamotest"
+)
+
+// TestMain roda o proprio binario de teste como o comando seed quando SEED_MAIN=1,
+// para os testes verem o codigo de saida e a saida de main.
+func TestMain(m *testing.M) {
+	if os.Getenv("SEED_MAIN") == "1" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// run executa "seed args..." com o DynamoDB falso em endpoint e as variaveis extras
+// de env, e devolve a saida e o codigo de saida.
+func run(t *testing.T, endpoint string, env []string, args ...string) (string, int) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Env = append(os.Environ(),
+		"SEED_MAIN=1",
+		"ENV=local",
+		"DYNAMO_TABLE=Users",
+		"DYNAMO_ENDPOINT="+endpoint,
+		"DYNAMO_MAX_ATTEMPTS=1",
+		"DEFAULT_TENANT=",
+	)
+	cmd.Env = append(cmd.Env, env...)
+	out, err := cmd.CombinedOutput()
+	var exit *exec.ExitError
+	if errors.As(err, &exit) {
+		return string(out), exit.ExitCode()
+	}
+	if err != nil {
+		t.Fatalf("erro ao executar seed: %v", err)
+	}
+	return string(out), 0
+}
+
+func TestFlagErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		env     []string
+		want    int
+		wantOut string
+	}{
+		{"n zero", []string{"-n", "0"}, nil, 2, "uso: seed"},
+		{"workers zero", []string{"-workers", "0"}, nil, 2, "uso: seed"},
+		{"argumento solto", []string{"extra"}, nil, 2, "uso: seed"},
+		{"flag desconhecida", []string{"-quantidade", "3"}, nil, 2, "uso: seed"},
+		{"from invalido", []string{"-from", "01/01/2024"}, nil, 1, `-from invalido: "01/01/2024"`},
+		{"to invalido", []string{"-to", "2025-13-01"}, nil, 1, `-to invalido`},
+		{"to antes de from", []string{"-from", "2024-06-01", "-to", "2024-06-01"}, nil, 1, "deve ser depois de -from"},
+		{"tenant invalido", []string{"-tenant", "com espaco"}, nil, 1, "tenant invalido"},
+		{"tenant padrao invalido", nil, []string{"DEFAULT_TENANT=a/b"}, 1, "tenant invalido"},
+		{"ENV=memory", nil, []string{"ENV=memory"}, 1, "ENV=memory"},
+		{"sem chaves", nil, []string{"ENCRYPTION_KEYFILE=", "ENCRYPTION_KEYFILE_AUTOCREATE="}, 1, "ENCRYPTION_KEYFILE obrigatorio"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, code := run(t, "", tt.env, tt.args...)
+			if code != tt.want || !strings.Contains(out, tt.wantOut) {
+				t.Errorf("saida %d, quer %d com %q\n%s", code, tt.want, tt.wantOut, out)
+			}
+		})
+	}
+}
+
+func TestSeedAndWipe(t *testing.T) {
+	srv := dynamotest.NewServer(t)
+	table := dynamotest.NewTable(srv, "Users")
+	table.Seed()
+	env := []string{"ENCRYPTION_KEYFILE=" + filepath.Join(t.TempDir(), "keys.json"), "ENCRYPTION_KEYFILE_AUTOCREATE=true"}
+	args := []string{"-n", "5", "-seed", "3", "-tenant", "acme", "-from", "2024-03-01", "-to", "2024-04-01"}
+
+	out, code := run(t, srv.URL(), env, args...)
+	if code != 0 || !strings.Contains(out, "5 usuarios criados, 0 ja existiam, 0 falharam") {
+		t.Fatalf("seed: saida %d\n%s", code, out)
+	}
+	users := 0
+	for id, item := range table.Items() {
+		if strings.HasPrefix(id, "TENANT#acme#USER#") {
+			users++
+			if got := dynamotest.S(item, "created_at"); got < "2024-03-01" || got >= "2024-04-01" {
+				t.Errorf("created_at = %s, fora de -from/-to", got)
+			}
+		}
+	}
+	if users != 5 {
+		t.Errorf("%d usuarios no tenant acme, quer 5", users)
+	}
+
+	out, code = run(t, srv.URL(), env, args...)
+	if code != 0 || !strings.Contains(out, "0 usuarios criados, 5 ja existiam") {
+		t.Errorf("seed repetido: saida %d\n%s", code, out)
+	}
+
+	out, code = run(t, srv.URL(), env, append(args, "-wipe")...)
+	if code != 0 || !strings.Contains(out, "5 usuarios excluidos, 0 falharam") {
+		t.Errorf("wipe: saida %d\n%s", code, out)
+	}
+}
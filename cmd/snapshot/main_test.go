@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dowglassantana/golang-with-dynamodb/internal/dynamotest"
+)
+
+// TestMain roda o proprio binario de teste como o comando snapshot quando
+// SNAPSHOT_MAIN=1, para os testes verem o codigo de saida e a saida de main.
+func TestMain(m *testing.M) {
+	if os.Getenv("SNAPSHOT_MAIN") == "1" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// run executa "snapshot args..." com o DynamoDB falso em endpoint e devolve a saida e
+// o codigo de saida.
+func run(t *testing.T, endpoint string, args ...string) (string, int) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Env = append(os.Environ(),
+		"SNAPSHOT_MAIN=1",
+		"ENV=local",
+		"DYNAMO_TABLE=Users",
+		"DYNAMO_ENDPOINT="+endpoint,
+		"DYNAMO_MAX_ATTEMPTS=1",
+	)
+	out, err := cmd.CombinedOutput()
+	var exit *exec.ExitError
+	if errors.As(err, &exit) {
+		return string(out), exit.ExitCode()
+	}
+	if err != nil {
+		t.Fatalf("erro ao executar snapshot: %v", err)
+	}
+	return string(out), 0
+}
+
+func TestCreateVerifyRestore(t *testing.T) {
+	src := dynamotest.NewServer(t)
+	srcTable := dynamotest.NewTable(src, "Users")
+	for _, id := range []string{"a", "b", "c"} {
+		srcTable.Seed(map[string]any{"id": map[string]any{"S": id}, "version": map[string]any{"N": "1"}})
+	}
+	dir := filepath.Join(t.TempDir(), "snap")
+
+	out, code := run(t, src.URL(), "create", "-dir", dir, "-segments", "2")
+	if code != 0 || !strings.Contains(out, "3 itens em 2 arquivos") {
+		t.Fatalf("create: saida %d\n%s", code, out)
+	}
+
+	out, code = run(t, "", "verify", "-dir", dir)
+	if code != 0 || !strings.Contains(out, "snapshot ok: Users, 3 itens") {
+		t.Errorf("verify: saida %d\n%s", code, out)
+	}
+
+	dst := dynamotest.NewServer(t)
+	dstTable := dynamotest.NewTable(dst, "Copia")
+	out, code = run(t, dst.URL(), "restore", "-dir", dir, "-table", "Copia", "-workers", "2")
+	if code != 0 || !strings.Contains(out, "3 itens restaurados em Copia") {
+		t.Fatalf("restore: saida %d\n%s", code, out)
+	}
+	if !reflect.DeepEqual(dstTable.Items(), srcTable.Items()) {
+		t.Error("itens restaurados diferentes da origem")
+	}
+
+	// O destino agora tem dados: o restore falha e informa quantos itens gravou.
+	out, code = run(t, dst.URL(), "restore", "-dir", dir, "-table", "Copia")
+	if code != 1 || !strings.Contains(out, "restore interrompido apos 0 itens") {
+		t.Errorf("restore em tabela com dados: saida %d\n%s", code, out)
+	}
+}
+
+func TestUsageErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want int
+	}{
+		{"sem comando", nil, 2},
+		{"comando desconhecido", []string{"backup"}, 2},
+		{"verify sem dir", []string{"verify"}, 2},
+		{"restore sem dir", []string{"restore"}, 2},
+		{"flag desconhecida", []string{"create", "-segmentos", "2"}, 2},
+		{"verify sem manifesto", []string{"verify", "-dir", t.TempDir()}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if out, code := run(t, "", tt.args...); code != tt.want {
+				t.Errorf("saida %d, quer %d\n%s", code, tt.want, out)
+			}
+		})
+	}
+}
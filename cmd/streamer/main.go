@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/dowglassantana/golang-with-dynamodb/internal/fieldcrypt"
+	"github.com/dowglassantana/golang-with-dynamodb/internal/logging"
+	"github.com/dowglassantana/golang-with-dynamodb/internal/stream"
+	"github.com/dowglassantana/golang-with-dynamodb/pkg/dynamo"
+)
+
+// streamer consome o DynamoDB Stream da tabela de usuarios em um processo separado
+// da API. Usa as mesmas variaveis de ambiente (ENV, DYNAMO_TABLE, DYNAMO_*) e
+// STREAM_CHECKPOINT_TABLE para a tabela de checkpoints.
+//
+// Rode apenas uma instancia: o consumidor nao coordena shards entre processos.
+func main() {
+	// O contexto e cancelado no SIGINT/SIGTERM; o consumidor termina o lote atual e sai.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// Logs em JSON, como os da API (LOG_LEVEL define o nivel minimo): shard, tenant e id
+	// de cada evento saem como atributos consultaveis.
+	logger, err := logging.FromEnv()
+	if err != nil {
+		fatal("configuracao de log invalida", "error", err)
+	}
+	slog.SetDefault(logger)
+
+	env := os.Getenv("ENV")
+	if env == "" {
+		env = "local"
+	}
+
+	tableName := os.Getenv("DYNAMO_TABLE")
+	if tableName == "" {
+		tableName = "Users"
+	}
+
+	checkpointTable := os.Getenv("STREAM_CHECKPOINT_TABLE")
+	if checkpointTable == "" {
+		checkpointTable = tableName + "-stream-checkpoints"
+	}
+
+	if env == "memory" {
+		fatal("ENV=memory nao tem DynamoDB Stream para consumir")
+	}
+
+	dynamoOpts, err := dynamo.FromEnv(env)
+	if err != nil {
+		fatal("configuracao do DynamoDB invalida", "error", err)
+	}
+
+	client, err := dynamo.New(ctx, dynamoOpts...)
+	if err != nil {
+		fatal("erro ao criar client DynamoDB", "error", err)
+	}
+
+	streamsClient, err := dynamo.NewStreams(ctx, dynamoOpts...)
+	if err != nil {
+		fatal("erro ao criar client do DynamoDB Streams", "error", err)
+	}
+
+	// As imagens do stream trazem o email cifrado; as chaves sao as mesmas da API.
+	sealer, err := fieldcrypt.FromEnv(ctx, env)
+	if err != nil {
+		fatal("configuracao de criptografia invalida", "error", err)
+	}
+
+	checkpoints := stream.NewDynamoCheckpointStore(client, checkpointTable)
+	if err := checkpoints.CreateTable(ctx); err != nil {
+		fatal("erro ao preparar checkpoints", "error", err)
+	}
+
+	dispatcher := stream.NewDispatcher()
+	stream.LogEvents(dispatcher)
+
+	consumer := stream.NewConsumer(client, streamsClient, tableName, sealer, checkpoints, dispatcher)
+
+	slog.Info("streamer iniciado", "env", env, "table", tableName)
+	if err := consumer.Run(ctx); err != nil {
+		fatal("erro no consumidor do stream", "error", err)
+	}
+
+	slog.Info("streamer encerrado com sucesso")
+}
+
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/dowglassantana/golang-with-dynamodb/internal/fieldcrypt"
+	"github.com/dowglassantana/golang-with-dynamodb/internal/model"
+	"github.com/dowglassantana/golang-with-dynamodb/internal/repository"
+	"github.com/dowglassantana/golang-with-dynamodb/internal/service"
+	"github.com/dowglassantana/golang-with-dynamodb/pkg/dynamo"
+)
+
+// backend e o que os comandos usam para falar com o servico. Ha duas implementacoes:
+//   - localBackend: acessa a tabela direto, pelo mesmo repository e service da API;
+//   - remoteBackend: chama a API HTTP (--remote).
+//
+// Nos dois casos as regras sao as mesmas (validacao, email unico, versao, historico).
+type backend interface {
+	Create(ctx context.Context, name, email string) (userView, error)
+	Get(ctx context.Context, id string) (userView, error)
+	List(ctx context.Context, input listInput) (listView, error)
+	Update(ctx context.Context, id string, input updateInput) (userView, error)
+	Delete(ctx context.Context, id string) error
+	FindByEmail(ctx context.Context, email string) (userView, error)
+	Count(ctx context.Context, includeDeleted bool) (int64, error)
+}
+
+type listInput struct {
+	Limit          int32
+	Cursor         string
+	IncludeDeleted bool
+}
+
+// updateInput altera apenas os campos informados (nil = nao muda). IfMatch > 0
+// condiciona a alteracao a versao atual do usuario, como o header If-Match.
+type updateInput struct {
+	Name    *string
+	Email   *string
+	IfMatch int64
+}
+
+// errNotFound e o "nao encontrado" dos dois backends.
+var errNotFound = errors.New("usuario nao encontrado")
+
+// localBackend usa o service da API sobre o DynamoUserRepository, com as mesmas
+// variaveis de ambiente da API (ENV, DYNAMO_TABLE, DYNAMO_*, ENCRYPTION_KEYFILE,
+// CURSOR_SECRET).
+type localBackend struct {
+	svc service.UserService
+}
+
+func newLocalBackend(ctx context.Context) (*localBackend, error) {
+	env := os.Getenv("ENV")
+	if env == "" {
+		env = "local"
+	}
+	if env == "memory" {
+		return nil, errors.New("ENV=memory nao tem tabela para o userctl; use --remote")
+	}
+
+	tableName := os.Getenv("DYNAMO_TABLE")
+	if tableName == "" {
+		tableName = "Users"
+	}
+
+	// Com o mesmo CURSOR_SECRET da API, os cursores do list valem nos dois lados.
+	var repoOpts []repository.Option
+	if secret := os.Getenv("CURSOR_SECRET"); secret != "" {
+		repoOpts = append(repoOpts, repository.WithCursorSecret([]byte(secret)))
+	}
+
+	dynamoOpts, err := dynamo.FromEnv(env)
+	if err != nil {
+		return nil, fmt.Errorf("configuracao do DynamoDB invalida: %w", err)
+	}
+	client, err := dynamo.New(ctx, dynamoOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao criar client DynamoDB: %w", err)
+	}
+	sealer, err := fieldcrypt.FromEnv(ctx, env)
+	if err != nil {
+		return nil, fmt.Errorf("configuracao de criptografia invalida: %w", err)
+	}
+
+	repo := repository.NewUserRepository(client, tableName, sealer, repoOpts...)
+	return &localBackend{svc: service.NewUserService(repo)}, nil
+}
+
+func (b *localBackend) Create(ctx context.Context, name, email string) (userView, error) {
+	user, err := b.svc.Create(ctx, model.CreateUserInput{Name: name, Email: email})
+	if err != nil {
+		return userView{}, localError(err)
+	}
+	return toUserView(*user), nil
+}
+
+func (b *localBackend) Get(ctx context.Context, id string) (userView, error) {
+	user, err := b.svc.GetByID(ctx, id)
+	if err != nil {
+		return userView{}, localError(err)
+	}
+	return toUserView(*user), nil
+}
+
+func (b *localBackend) List(ctx context.Context, input listInput) (listView, error) {
+	page, err := b.svc.GetAll(ctx, model.ListUsersInput{
+		Limit:          input.Limit,
+		Cursor:         input.Cursor,
+		IncludeDeleted: input.IncludeDeleted,
+	})
+	if err != nil {
+		return listView{}, localError(err)
+	}
+
+	list := listView{Users: make([]userView, len(page.Users)), NextCursor: page.NextCursor}
+	for i, u := range page.Users {
+		list.Users[i] = toUserView(u)
+	}
+	return list, nil
+}
+
+// Update e um PATCH: so os campos informados mudam.
+func (b *localBackend) Update(ctx context.Context, id string, input updateInput) (userView, error) {
+	var patch model.PatchUserInput
+	if input.Name != nil {
+		patch.Name = model.Nullable[string]{Value: *input.Name, Set: true}
+	}
+	if input.Email != nil {
+		patch.Email = model.Nullable[string]{Value: *input.Email, Set: true}
+	}
+	if input.IfMatch > 0 {
+		patch.ExpectedVersion = &input.IfMatch
+	}
+
+	user, err := b.svc.Patch(ctx, id, patch)
+	if err != nil {
+		return userView{}, localError(err)
+	}
+	return toUserView(*user), nil
+}
+
+func (b *localBackend) Delete(ctx context.Context, id string) error {
+	return localError(b.svc.Delete(ctx, id))
+}
+
+func (b *localBackend) FindByEmail(ctx context.Context, email string) (userView, error) {
+	user, err := b.svc.GetByEmail(ctx, email)
+	if err != nil {
+		return userView{}, localError(err)
+	}
+	return toUserView(*user), nil
+}
+
+func (b *localBackend) Count(ctx context.Context, includeDeleted bool) (int64, error) {
+	count, err := b.svc.Count(ctx, includeDeleted)
+	return count, localError(err)
+}
+
+// localError troca o ErrUserNotFound do service pelo errNotFound do userctl, para
+// que os comandos tratem os dois backends do mesmo jeito.
+func localError(err error) error {
+	if errors.Is(err, service.ErrUserNotFound) {
+		return errNotFound
+	}
+	return err
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+
+	"github.com/dowglassantana/golang-with-dynamodb/internal/requestctx"
+	"github.com/spf13/cobra"
+)
+
+// userctl e a ferramenta de linha de comando da equipe de operacao: substitui o curl e
+// a postman_collection.json nas tarefas do dia a dia.
+//
+// Sem --remote, o userctl acessa a tabela direto (mesmas variaveis de ambiente da
+// API). Com --remote (ou USERCTL_REMOTE), ele chama a API HTTP.
+//
+// Completion: "userctl completion bash|zsh|fish|powershell" gera o script do shell.
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// Os erros sao impressos aqui, e nao pelo cobra, para mostrar "usuario nao
+	// encontrado" sem o texto de uso.
+	if err := newRootCommand().ExecuteContext(ctx); err != nil {
+		fmt.Fprintln(os.Stderr, "erro:", err)
+		os.Exit(1)
+	}
+}
+
+// options sao as flags globais.
+type options struct {
+	output string
+	remote string
+	tenant string
+	actor  string
+}
+
+func newRootCommand() *cobra.Command {
+	opts := &options{}
+
+	root := &cobra.Command{
+		Use:           "userctl",
+		Short:         "Administra os usuarios do servico",
+		Long:          "userctl administra os usuarios direto na tabela do DynamoDB ou, com --remote, pela API HTTP.",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if !slices.Contains(outputFormats, opts.output) {
+				return fmt.Errorf("--output invalido: %q (use %s)", opts.output, strings.Join(outputFormats, ", "))
+			}
+			if !requestctx.ValidTenant(opts.tenant) {
+				return fmt.Errorf("--tenant invalido: %q", opts.tenant)
+			}
+			return nil
+		},
+	}
+
+	defaultTenant := os.Getenv("DEFAULT_TENANT")
+	if defaultTenant == "" {
+		defaultTenant = requestctx.DefaultTenant
+	}
+
+	flags := root.PersistentFlags()
+	flags.StringVarP(&opts.output, "output", "o", outputTable, "formato de saida: "+strings.Join(outputFormats, ", "))
+	flags.StringVar(&opts.remote, "remote", os.Getenv("USERCTL_REMOTE"), "URL da API (ex: http://localhost:8080); sem ela, acessa a tabela direto")
+	flags.StringVar(&opts.tenant, "tenant", defaultTenant, "tenant dos usuarios")
+	flags.StringVar(&opts.actor, "actor", defaultActor(), "ator gravado no historico")
+
+	root.RegisterFlagCompletionFunc("output", func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+		return outputFormats, cobra.ShellCompDirectiveNoFileComp
+	})
+
+	root.AddCommand(
+		newCreateCommand(opts),
+		newGetCommand(opts),
+		newListCommand(opts),
+		newUpdateCommand(opts),
+		newDeleteCommand(opts),
+		newFindCommand(opts),
+		newCountCommand(opts),
+	)
+	return root
+}
+
+// defaultActor identifica quem rodou o comando no historico: "userctl:<usuario do SO>".
+func defaultActor() string {
+	if user := os.Getenv("USER"); user != "" {
+		return "userctl:" + user
+	}
+	return "userctl"
+}
+
+// session prepara o backend e o contexto (tenant e ator) de um comando.
+func (o *options) session(cmd *cobra.Command) (context.Context, backend, printer, error) {
+	ctx := requestctx.WithTenant(cmd.Context(), o.tenant)
+	ctx = requestctx.WithActor(ctx, o.actor)
+	p := printer{out: cmd.OutOrStdout(), errOut: cmd.ErrOrStderr(), format: o.output}
+
+	if o.remote != "" {
+		b, err := newRemoteBackend(o.remote, o.tenant, o.actor)
+		return ctx, b, p, err
+	}
+	b, err := newLocalBackend(ctx)
+	return ctx, b, p, err
+}
+
+func newCreateCommand(opts *options) *cobra.Command {
+	var name, email string
+	cmd := &cobra.Command{
+		Use:   "create --name NOME --email EMAIL",
+		Short: "Cria um usuario",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, b, p, err := opts.session(cmd)
+			if err != nil {
+				return err
+			}
+			user, err := b.Create(ctx, name, email)
+			if err != nil {
+				return err
+			}
+			return p.user(user)
+		},
+	}
+	cmd.Flags().StringVar(&name, "name", "", "nome do usuario")
+	cmd.Flags().StringVar(&email, "email", "", "email do usuario")
+	cmd.MarkFlagRequired("name")
+	cmd.MarkFlagRequired("email")
+	return cmd
+}
+
+func newGetCommand(opts *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "get ID",
+		Short: "Mostra um usuario pelo id",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, b, p, err := opts.session(cmd)
+			if err != nil {
+				return err
+			}
+			user, err := b.Get(ctx, args[0])
+			if err != nil {
+				return err
+			}
+			return p.user(user)
+		},
+	}
+}
+
+func newListCommand(opts *options) *cobra.Command {
+	var input listInput
+	cmd := &cobra.Command{
+		Use:   "list [--limit N] [--cursor CURSOR]",
+		Short: "Lista os usuarios, uma pagina por vez",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if input.Limit <= 0 {
+				return errors.New("--limit deve ser maior que zero")
+			}
+			ctx, b, p, err := opts.session(cmd)
+			if err != nil {
+				return err
+			}
+			list, err := b.List(ctx, input)
+			if err != nil {
+				return err
+			}
+			return p.list(list)
+		},
+	}
+	cmd.Flags().Int32Var(&input.Limit, "limit", 20, "usuarios por pagina (maximo 100)")
+	cmd.Flags().StringVar(&input.Cursor, "cursor", "", "cursor da proxima pagina, devolvido pela listagem anterior")
+	cmd.Flags().BoolVar(&input.IncludeDeleted, "include-deleted", false, "inclui usuarios excluidos")
+	return cmd
+}
+
+func newUpdateCommand(opts *options) *cobra.Command {
+	var (
+		name, email string
+		ifMatch     int64
+	)
+	cmd := &cobra.Command{
+		Use:   "update ID [--name NOME] [--email EMAIL]",
+		Short: "Altera o nome e/ou o email de um usuario",
+		Long:  "Altera apenas os campos informados. Com --if-match, a alteracao so e aplicada se o usuario ainda estiver na versao informada.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			input := updateInput{IfMatch: ifMatch}
+			if cmd.Flags().Changed("name") {
+				input.Name = &name
+			}
+			if cmd.Flags().Changed("email") {
+				input.Email = &email
+			}
+			if input.Name == nil && input.Email == nil {
+				return errors.New("informe --name e/ou --email")
+			}
+
+			ctx, b, p, err := opts.session(cmd)
+			if err != nil {
+				return err
+			}
+			user, err := b.Update(ctx, args[0], input)
+			if err != nil {
+				return err
+			}
+			return p.user(user)
+		},
+	}
+	cmd.Flags().StringVar(&name, "name", "", "novo nome")
+	cmd.Flags().StringVar(&email, "email", "", "novo email")
+	cmd.Flags().Int64Var(&ifMatch, "if-match", 0, "versao esperada do usuario")
+	return cmd
+}
+
+func newDeleteCommand(opts *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "delete ID",
+		Short: "Exclui um usuario (soft delete)",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, b, _, err := opts.session(cmd)
+			if err != nil {
+				return err
+			}
+			if err := b.Delete(ctx, args[0]); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.ErrOrStderr(), "usuario %s excluido\n", args[0])
+			return nil
+		},
+	}
+}
+
+func newFindCommand(opts *options) *cobra.Command {
+	var email string
+	cmd := &cobra.Command{
+		Use:   "find --email EMAIL",
+		Short: "Busca um usuario pelo email",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, b, p, err := opts.session(cmd)
+			if err != nil {
+				return err
+			}
+			user, err := b.FindByEmail(ctx, email)
+			if err != nil {
+				return err
+			}
+			return p.user(user)
+		},
+	}
+	cmd.Flags().StringVar(&email, "email", "", "email do usuario")
+	cmd.MarkFlagRequired("email")
+	return cmd
+}
+
+func newCountCommand(opts *options) *cobra.Command {
+	var includeDeleted bool
+	cmd := &cobra.Command{
+		Use:   "count",
+		Short: "Conta os usuarios do tenant",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, b, p, err := opts.session(cmd)
+			if err != nil {
+				return err
+			}
+			count, err := b.Count(ctx, includeDeleted)
+			if err != nil {
+				return err
+			}
+			return p.count(count)
+		},
+	}
+	cmd.Flags().BoolVar(&includeDeleted, "include-deleted", false, "inclui usuarios excluidos")
+	return cmd
+}
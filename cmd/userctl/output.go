@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/dowglassantana/golang-with-dynamodb/internal/model"
+	"gopkg.in/yaml.v3"
+)
+
+// Formatos de saida (--output).
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+)
+
+var outputFormats = []string{outputTable, outputJSON, outputYAML}
+
+// userView e o usuario como o userctl o mostra. Os campos JSON sao os mesmos da API,
+// entao o remoteBackend decodifica as respostas direto nele.
+type userView struct {
+	ID        string `json:"id" yaml:"id"`
+	Name      string `json:"name" yaml:"name"`
+	Email     string `json:"email" yaml:"email"`
+	CreatedAt string `json:"created_at" yaml:"created_at"`
+	Version   int64  `json:"version" yaml:"version"`
+	DeletedAt string `json:"deleted_at,omitempty" yaml:"deleted_at,omitempty"`
+}
+
+func toUserView(u model.User) userView {
+	return userView{
+		ID:        u.ID,
+		Name:      u.Name,
+		Email:     u.Email,
+		CreatedAt: u.CreatedAt,
+		Version:   u.Version,
+		DeletedAt: u.DeletedAt,
+	}
+}
+
+// listView e uma pagina da listagem.
+type listView struct {
+	Users      []userView `json:"users" yaml:"users"`
+	NextCursor string     `json:"next_cursor,omitempty" yaml:"next_cursor,omitempty"`
+}
+
+// printer escreve o resultado de um comando no formato escolhido.
+type printer struct {
+	out    io.Writer
+	errOut io.Writer
+	format string
+}
+
+func (p printer) user(u userView) error {
+	if p.format == outputTable {
+		return p.table([]userView{u})
+	}
+	return p.encode(u)
+}
+
+// list mostra a pagina. Na tabela, o cursor da proxima pagina vai para stderr, para
+// nao misturar com as linhas quando a saida e redirecionada.
+func (p printer) list(l listView) error {
+	if p.format != outputTable {
+		return p.encode(l)
+	}
+	if err := p.table(l.Users); err != nil {
+		return err
+	}
+	if l.NextCursor != "" {
+		fmt.Fprintf(p.errOut, "\nproxima pagina: --cursor %s\n", l.NextCursor)
+	}
+	return nil
+}
+
+func (p printer) count(n int64) error {
+	if p.format == outputTable {
+		_, err := fmt.Fprintln(p.out, n)
+		return err
+	}
+	return p.encode(map[string]int64{"count": n})
+}
+
+func (p printer) table(users []userView) error {
+	w := tabwriter.NewWriter(p.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tEMAIL\tCREATED AT\tVERSION\tDELETED AT")
+	for _, u := range users {
+		deletedAt := u.DeletedAt
+		if deletedAt == "" {
+			deletedAt = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", u.ID, u.Name, u.Email, u.CreatedAt, strconv.FormatInt(u.Version, 10), deletedAt)
+	}
+	return w.Flush()
+}
+
+func (p printer) encode(v any) error {
+	switch p.format {
+	case outputJSON:
+		enc := json.NewEncoder(p.out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	case outputYAML:
+		enc := yaml.NewEncoder(p.out)
+		enc.SetIndent(2)
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+		return enc.Close()
+	default:
+		return fmt.Errorf("formato de saida invalido: %q", p.format)
+	}
+}
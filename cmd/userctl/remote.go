@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// remoteBackend fala com a API HTTP. O tenant e o ator vao nos headers X-Tenant-ID e
+// X-Actor, como em qualquer outro cliente.
+type remoteBackend struct {
+	baseURL string
+	tenant  string
+	actor   string
+	http    *http.Client
+}
+
+func newRemoteBackend(baseURL, tenant, actor string) (*remoteBackend, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("--remote invalido: %q (use http://host:porta)", baseURL)
+	}
+	return &remoteBackend{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		tenant:  tenant,
+		actor:   actor,
+		http:    &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// apiError e uma resposta de erro da API ({"error": "..."}).
+type apiError struct {
+	Status  int
+	Message string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("%s (HTTP %d)", e.Message, e.Status)
+}
+
+// do envia a requisicao e decodifica a resposta em out (quando out != nil). Um 404
+// vira errNotFound; os demais status de erro, *apiError.
+func (b *remoteBackend) do(ctx context.Context, method, path string, header http.Header, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, b.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if body != nil && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("X-Tenant-ID", b.tenant)
+	req.Header.Set("X-Actor", b.actor)
+
+	resp, err := b.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("erro ao chamar a API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return errNotFound
+	}
+	if resp.StatusCode >= 400 {
+		var payload struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&payload)
+		if payload.Error == "" {
+			payload.Error = http.StatusText(resp.StatusCode)
+		}
+		return &apiError{Status: resp.StatusCode, Message: payload.Error}
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("resposta invalida da API: %w", err)
+	}
+	return nil
+}
+
+func (b *remoteBackend) Create(ctx context.Context, name, email string) (userView, error) {
+	var user userView
+	err := b.do(ctx, http.MethodPost, "/users", nil, map[string]string{"name": name, "email": email}, &user)
+	return user, err
+}
+
+func (b *remoteBackend) Get(ctx context.Context, id string) (userView, error) {
+	var user userView
+	err := b.do(ctx, http.MethodGet, "/users/"+url.PathEscape(id), nil, nil, &user)
+	return user, err
+}
+
+func (b *remoteBackend) List(ctx context.Context, input listInput) (listView, error) {
+	query := url.Values{}
+	if input.Limit > 0 {
+		query.Set("limit", strconv.Itoa(int(input.Limit)))
+	}
+	if input.Cursor != "" {
+		query.Set("cursor", input.Cursor)
+	}
+	if input.IncludeDeleted {
+		query.Set("include_deleted", "true")
+	}
+
+	var list listView
+	err := b.do(ctx, http.MethodGet, "/users?"+query.Encode(), nil, nil, &list)
+	return list, err
+}
+
+// Update envia um JSON Merge Patch so com os campos informados.
+func (b *remoteBackend) Update(ctx context.Context, id string, input updateInput) (userView, error) {
+	patch := map[string]string{}
+	if input.Name != nil {
+		patch["name"] = *input.Name
+	}
+	if input.Email != nil {
+		patch["email"] = *input.Email
+	}
+
+	header := http.Header{"Content-Type": {"application/merge-patch+json"}}
+	if input.IfMatch > 0 {
+		header.Set("If-Match", strconv.Quote(strconv.FormatInt(input.IfMatch, 10)))
+	}
+
+	var user userView
+	err := b.do(ctx, http.MethodPatch, "/users/"+url.PathEscape(id), header, patch, &user)
+	return user, err
+}
+
+func (b *remoteBackend) Delete(ctx context.Context, id string) error {
+	return b.do(ctx, http.MethodDelete, "/users/"+url.PathEscape(id), nil, nil, nil)
+}
+
+// FindByEmail usa GET /users?email=, que responde com uma lista de zero ou um usuario.
+func (b *remoteBackend) FindByEmail(ctx context.Context, email string) (userView, error) {
+	var list listView
+	if err := b.do(ctx, http.MethodGet, "/users?"+url.Values{"email": {email}}.Encode(), nil, nil, &list); err != nil {
+		return userView{}, err
+	}
+	if len(list.Users) == 0 {
+		return userView{}, errNotFound
+	}
+	return list.Users[0], nil
+}
+
+func (b *remoteBackend) Count(ctx context.Context, includeDeleted bool) (int64, error) {
+	path := "/users:count"
+	if includeDeleted {
+		path += "?include_deleted=true"
+	}
+
+	var payload struct {
+		Count int64 `json:"count"`
+	}
+	err := b.do(ctx, http.MethodGet, path, nil, nil, &payload)
+	return payload.Count, err
+}
@@ -0,0 +1,226 @@
+// Package dynamotest sobe um DynamoDB falso (httptest) para os testes dos pacotes que
+// recebem um *dynamodb.Client, como o repository e as migracoes.
+//
+// O servidor fala o protocolo JSON do DynamoDB: cada requisicao e um POST com o nome
+// da operacao no header X-Amz-Target ("DynamoDB_20120810.GetItem") e o corpo em JSON,
+// com os atributos no formato do DynamoDB ({"S": "..."}). O teste registra um Handler
+// por operacao; uma operacao sem handler falha o teste.
+//
+//	srv := dynamotest.NewServer(t)
+//	srv.Handle("GetItem", func(req dynamotest.Request) (any, error) {
+//		return map[string]any{"Item": map[string]any{"id": map[string]any{"S": "1"}}}, nil
+//	})
+//	repo := repository.NewUserRepository(srv.Client(), "Users", sealer)
+package dynamotest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Request e o corpo JSON de uma chamada, ja decodificado.
+type Request map[string]any
+
+// Handler responde a uma operacao. O valor devolvido vira o corpo JSON da resposta; um
+// *Error vira a resposta de erro do DynamoDB.
+type Handler func(req Request) (any, error)
+
+// Error e uma excecao do DynamoDB. Type e o nome da excecao
+// ("ConditionalCheckFailedException"); Fields entra no corpo junto com a mensagem
+// (ex: CancellationReasons de uma TransactionCanceledException).
+type Error struct {
+	Type    string
+	Message string
+	Fields  map[string]any
+}
+
+func (e *Error) Error() string {
+	return e.Type + ": " + e.Message
+}
+
+// TransactionCanceled monta a TransactionCanceledException com um motivo por item da
+// transacao ("None", "ConditionalCheckFailed", ...).
+func TransactionCanceled(codes ...string) *Error {
+	reasons := make([]map[string]any, len(codes))
+	for i, code := range codes {
+		reasons[i] = map[string]any{"Code": code}
+	}
+	return &Error{
+		Type:    "TransactionCanceledException",
+		Message: "Transaction cancelled, please refer cancellation reasons for specific reasons",
+		Fields:  map[string]any{"CancellationReasons": reasons},
+	}
+}
+
+// Server e o DynamoDB falso. E seguro para chamadas concorrentes.
+type Server struct {
+	t   testing.TB
+	srv *httptest.Server
+
+	mu       sync.Mutex
+	handlers map[string]Handler
+	calls    map[string][]Request
+}
+
+// NewServer sobe o servidor; ele e encerrado no fim do teste.
+func NewServer(t testing.TB) *Server {
+	s := &Server{t: t, handlers: make(map[string]Handler), calls: make(map[string][]Request)}
+	s.srv = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	t.Cleanup(s.srv.Close)
+	return s
+}
+
+// Handle registra (ou troca) o handler de uma operacao.
+func (s *Server) Handle(op string, h Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[op] = h
+}
+
+// Calls devolve os corpos das chamadas recebidas para a operacao, em ordem de chegada.
+func (s *Server) Calls(op string) []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.calls[op]...)
+}
+
+// Client cria um *dynamodb.Client apontado para o servidor, com credenciais fixas e
+// sem novas tentativas automaticas do SDK: cada chamada do codigo testado chega uma
+// vez so ao handler.
+func (s *Server) Client() *dynamodb.Client {
+	return dynamodb.New(dynamodb.Options{
+		Region:           "us-east-1",
+		BaseEndpoint:     aws.String(s.srv.URL),
+		Credentials:      credentials.NewStaticCredentialsProvider("test", "test", ""),
+		RetryMaxAttempts: 1,
+	})
+}
+
+// URL devolve o endereco do servidor, para os testes que configuram o client pelo
+// ambiente (DYNAMO_ENDPOINT).
+func (s *Server) URL() string {
+	return s.srv.URL
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	_, op, _ := strings.Cut(r.Header.Get("X-Amz-Target"), ".")
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req := Request{}
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	s.mu.Lock()
+	h, ok := s.handlers[op]
+	s.calls[op] = append(s.calls[op], req)
+	s.mu.Unlock()
+
+	if !ok {
+		s.t.Errorf("dynamotest: operacao %s sem handler", op)
+		writeError(w, &Error{Type: "UnknownOperationException", Message: op})
+		return
+	}
+
+	resp, err := h(req)
+	if err != nil {
+		var dynErr *Error
+		if !errors.As(err, &dynErr) {
+			dynErr = &Error{Type: "InternalServerError", Message: err.Error()}
+		}
+		writeError(w, dynErr)
+		return
+	}
+	if resp == nil {
+		resp = map[string]any{}
+	}
+	w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func writeError(w http.ResponseWriter, e *Error) {
+	body := map[string]any{
+		"__type":  "com.amazonaws.dynamodb.v20120810#" + e.Type,
+		"message": e.Message,
+	}
+	for k, v := range e.Fields {
+		body[k] = v
+	}
+
+	status := http.StatusBadRequest
+	if e.Type == "InternalServerError" {
+		status = http.StatusInternalServerError
+	}
+	w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// S le o valor de um atributo String de um item ({"id": {"S": "..."}}).
+func S(item any, attr string) string {
+	m, _ := item.(map[string]any)
+	av, _ := m[attr].(map[string]any)
+	v, _ := av["S"].(string)
+	return v
+}
+
+// Item converte um item do SDK para o formato JSON do protocolo, para o handler
+// devolver itens montados pelo proprio codigo testado (ex: um usuario com o email
+// cifrado pelo Sealer).
+func Item(item map[string]types.AttributeValue) map[string]any {
+	out := make(map[string]any, len(item))
+	for k, v := range item {
+		out[k] = attributeValue(v)
+	}
+	return out
+}
+
+func attributeValue(av types.AttributeValue) map[string]any {
+	switch v := av.(type) {
+	case *types.AttributeValueMemberS:
+		return map[string]any{"S": v.Value}
+	case *types.AttributeValueMemberN:
+		return map[string]any{"N": v.Value}
+	case *types.AttributeValueMemberB:
+		return map[string]any{"B": v.Value} // []byte vira base64 no JSON, como no protocolo
+	case *types.AttributeValueMemberBOOL:
+		return map[string]any{"BOOL": v.Value}
+	case *types.AttributeValueMemberNULL:
+		return map[string]any{"NULL": v.Value}
+	case *types.AttributeValueMemberSS:
+		return map[string]any{"SS": v.Value}
+	case *types.AttributeValueMemberNS:
+		return map[string]any{"NS": v.Value}
+	case *types.AttributeValueMemberBS:
+		return map[string]any{"BS": v.Value}
+	case *types.AttributeValueMemberM:
+		return map[string]any{"M": Item(v.Value)}
+	case *types.AttributeValueMemberL:
+		list := make([]any, len(v.Value))
+		for i, e := range v.Value {
+			list[i] = attributeValue(e)
+		}
+		return map[string]any{"L": list}
+	default:
+		panic(fmt.Sprintf("dynamotest: tipo de atributo %T nao suportado", av))
+	}
+}
@@ -0,0 +1,809 @@
+package dynamotest
+
+import (
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Table e uma tabela em memoria servida pelo Server, para os testes que precisam que as
+// escritas fiquem gravadas (ex: rodar as migracoes duas vezes). Ela responde a
+// CreateTable, DescribeTable, UpdateTable, DescribeTimeToLive, UpdateTimeToLive,
+// GetItem, PutItem, UpdateItem, DeleteItem, Scan, BatchWriteItem e TransactWriteItems.
+//
+// As expressoes sao avaliadas no formato que o pacote expression do SDK gera:
+//
+//	condicao/filtro: attribute_exists(p), attribute_not_exists(p), begins_with(p, :v),
+//	                 p = :v, AND, OR e parenteses
+//	update:          SET p = :v | p | if_not_exists(p, :v) | list_append(a, b), com + e -
+//	                 entre numeros; REMOVE p
+//
+// onde p e um nome ("id", "#0") ou um caminho em Maps ("#0.#1"). Os itens ficam no
+// formato JSON do protocolo ({"S": "..."}). O Scan percorre os itens em ordem de id e
+// respeita Limit, ExclusiveStartKey e Segment/TotalSegments (o item na posicao i fica no
+// segmento i % TotalSegments).
+type Table struct {
+	name string
+
+	mu      sync.Mutex
+	exists  bool
+	items   map[string]map[string]any // por valor (S) do atributo id
+	indexes []string
+	ttl     string
+	stream  string
+}
+
+// NewTable registra a tabela (ainda nao criada) no servidor. A chave primaria e o
+// atributo "id", do tipo String, como na tabela de usuarios.
+func NewTable(srv *Server, name string) *Table {
+	t := &Table{name: name, items: make(map[string]map[string]any)}
+	handlers := map[string]func(Request) (any, error){
+		"CreateTable":        t.createTable,
+		"DescribeTable":      t.describeTable,
+		"UpdateTable":        t.updateTable,
+		"DescribeTimeToLive": t.describeTimeToLive,
+		"UpdateTimeToLive":   t.updateTimeToLive,
+		"GetItem":            t.getItem,
+		"PutItem":            t.putItem,
+		"UpdateItem":         t.updateItem,
+		"DeleteItem":         t.deleteItem,
+		"Scan":               t.scan,
+		"BatchWriteItem":     t.batchWriteItem,
+		"TransactWriteItems": t.transactWriteItems,
+	}
+	for op, h := range handlers {
+		srv.Handle(op, func(req Request) (any, error) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			return h(req)
+		})
+	}
+	return t
+}
+
+// Seed grava itens direto na tabela, criando-a se preciso (uma tabela que ja existia
+// antes do codigo testado).
+func (t *Table) Seed(items ...map[string]any) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.exists = true
+	for _, item := range items {
+		t.items[S(item, "id")] = clone(item)
+	}
+}
+
+// Item devolve uma copia do item com o id informado, ou nil.
+func (t *Table) Item(id string) map[string]any {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if item, ok := t.items[id]; ok {
+		return clone(item)
+	}
+	return nil
+}
+
+// Items devolve uma copia de todos os itens, por id.
+func (t *Table) Items() map[string]map[string]any {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	items := make(map[string]map[string]any, len(t.items))
+	for id, item := range t.items {
+		items[id] = clone(item)
+	}
+	return items
+}
+
+// Delete apaga um item direto na tabela.
+func (t *Table) Delete(id string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.items, id)
+}
+
+// Indexes devolve os nomes dos GSIs, na ordem de criacao.
+func (t *Table) Indexes() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return slices.Clone(t.indexes)
+}
+
+// TTL devolve o atributo de TTL habilitado ("" quando desligado).
+func (t *Table) TTL() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.ttl
+}
+
+// StreamViewType devolve o view type do stream ("" quando desligado).
+func (t *Table) StreamViewType() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.stream
+}
+
+func (t *Table) check(req Request) error {
+	if name, _ := req["TableName"].(string); name != t.name || !t.exists {
+		return &Error{Type: "ResourceNotFoundException", Message: "Requested resource not found"}
+	}
+	return nil
+}
+
+func (t *Table) createTable(req Request) (any, error) {
+	if t.exists {
+		return nil, &Error{Type: "ResourceInUseException", Message: "Table already exists: " + t.name}
+	}
+	t.exists = true
+	gsis, _ := req["GlobalSecondaryIndexes"].([]any)
+	for _, gsi := range gsis {
+		if name, ok := gsi.(map[string]any)["IndexName"].(string); ok {
+			t.indexes = append(t.indexes, name)
+		}
+	}
+	if spec, ok := req["StreamSpecification"].(map[string]any); ok {
+		t.stream, _ = spec["StreamViewType"].(string)
+	}
+	return map[string]any{"TableDescription": t.description()}, nil
+}
+
+func (t *Table) describeTable(req Request) (any, error) {
+	if err := t.check(req); err != nil {
+		return nil, err
+	}
+	return map[string]any{"Table": t.description()}, nil
+}
+
+func (t *Table) description() map[string]any {
+	desc := map[string]any{
+		"TableName":            t.name,
+		"TableStatus":          "ACTIVE",
+		"KeySchema":            []any{map[string]any{"AttributeName": "id", "KeyType": "HASH"}},
+		"AttributeDefinitions": []any{map[string]any{"AttributeName": "id", "AttributeType": "S"}},
+	}
+	if len(t.indexes) > 0 {
+		gsis := make([]any, len(t.indexes))
+		for i, name := range t.indexes {
+			gsis[i] = map[string]any{"IndexName": name, "IndexStatus": "ACTIVE"}
+		}
+		desc["GlobalSecondaryIndexes"] = gsis
+	}
+	if t.stream != "" {
+		desc["StreamSpecification"] = map[string]any{"StreamEnabled": true, "StreamViewType": t.stream}
+	}
+	return desc
+}
+
+func (t *Table) updateTable(req Request) (any, error) {
+	if err := t.check(req); err != nil {
+		return nil, err
+	}
+	updates, _ := req["GlobalSecondaryIndexUpdates"].([]any)
+	for _, u := range updates {
+		create, _ := u.(map[string]any)["Create"].(map[string]any)
+		if name, ok := create["IndexName"].(string); ok {
+			if slices.Contains(t.indexes, name) {
+				return nil, &Error{Type: "ValidationException", Message: "index already exists: " + name}
+			}
+			t.indexes = append(t.indexes, name)
+		}
+	}
+	if spec, ok := req["StreamSpecification"].(map[string]any); ok {
+		if t.stream != "" {
+			return nil, &Error{Type: "ValidationException", Message: "stream already enabled"}
+		}
+		t.stream, _ = spec["StreamViewType"].(string)
+	}
+	return map[string]any{"TableDescription": t.description()}, nil
+}
+
+func (t *Table) describeTimeToLive(req Request) (any, error) {
+	if err := t.check(req); err != nil {
+		return nil, err
+	}
+	desc := map[string]any{"TimeToLiveStatus": "DISABLED"}
+	if t.ttl != "" {
+		desc = map[string]any{"TimeToLiveStatus": "ENABLED", "AttributeName": t.ttl}
+	}
+	return map[string]any{"TimeToLiveDescription": desc}, nil
+}
+
+func (t *Table) updateTimeToLive(req Request) (any, error) {
+	if err := t.check(req); err != nil {
+		return nil, err
+	}
+	if t.ttl != "" {
+		return nil, &Error{Type: "ValidationException", Message: "TimeToLive is already enabled"}
+	}
+	spec, _ := req["TimeToLiveSpecification"].(map[string]any)
+	t.ttl, _ = spec["AttributeName"].(string)
+	return map[string]any{"TimeToLiveSpecification": spec}, nil
+}
+
+func (t *Table) getItem(req Request) (any, error) {
+	if err := t.check(req); err != nil {
+		return nil, err
+	}
+	if item, ok := t.items[S(req["Key"], "id")]; ok {
+		return map[string]any{"Item": clone(item)}, nil
+	}
+	return nil, nil
+}
+
+func (t *Table) putItem(req Request) (any, error) {
+	if err := t.check(req); err != nil {
+		return nil, err
+	}
+	w, err := t.prepare("Put", req)
+	if err != nil {
+		return nil, err
+	}
+	if !w.ok {
+		return nil, conditionFailed()
+	}
+	w.apply(t)
+	return nil, nil
+}
+
+func (t *Table) updateItem(req Request) (any, error) {
+	if err := t.check(req); err != nil {
+		return nil, err
+	}
+	w, err := t.prepare("Update", req)
+	if err != nil {
+		return nil, err
+	}
+	if !w.ok {
+		return nil, conditionFailed()
+	}
+	w.apply(t)
+	return nil, nil
+}
+
+func (t *Table) deleteItem(req Request) (any, error) {
+	if err := t.check(req); err != nil {
+		return nil, err
+	}
+	w, err := t.prepare("Delete", req)
+	if err != nil {
+		return nil, err
+	}
+	if !w.ok {
+		return nil, conditionFailed()
+	}
+	w.apply(t)
+	return nil, nil
+}
+
+func (t *Table) scan(req Request) (any, error) {
+	if err := t.check(req); err != nil {
+		return nil, err
+	}
+	e := newEvaluator(req)
+	filter, _ := req["FilterExpression"].(string)
+	segment, total := intOf(req["Segment"]), max(intOf(req["TotalSegments"]), 1)
+	limit := intOf(req["Limit"])
+	start := S(req["ExclusiveStartKey"], "id")
+
+	var items []any
+	scanned := 0
+	for i, id := range slices.Sorted(maps.Keys(t.items)) {
+		if i%total != segment || (start != "" && id <= start) {
+			continue
+		}
+		if limit > 0 && scanned == limit {
+			return map[string]any{
+				"Items":            items,
+				"Count":            len(items),
+				"ScannedCount":     scanned,
+				"LastEvaluatedKey": map[string]any{"id": map[string]any{"S": start}},
+			}, nil
+		}
+		scanned++
+		start = id
+
+		item := t.items[id]
+		if filter != "" {
+			ok, err := e.condition(filter, item)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				continue
+			}
+		}
+		items = append(items, clone(item))
+	}
+	if items == nil {
+		items = []any{}
+	}
+	return map[string]any{"Items": items, "Count": len(items), "ScannedCount": scanned}, nil
+}
+
+// batchWriteItem grava todos os PutRequest e DeleteRequest da tabela, sem
+// UnprocessedItems.
+func (t *Table) batchWriteItem(req Request) (any, error) {
+	tables, _ := req["RequestItems"].(map[string]any)
+	for name, requests := range tables {
+		if err := t.check(Request{"TableName": name}); err != nil {
+			return nil, err
+		}
+		for _, r := range requests.([]any) {
+			r := r.(map[string]any)
+			if put, ok := r["PutRequest"].(map[string]any); ok {
+				item := put["Item"].(map[string]any)
+				t.items[S(item, "id")] = clone(item)
+			}
+			if del, ok := r["DeleteRequest"].(map[string]any); ok {
+				delete(t.items, S(del["Key"], "id"))
+			}
+		}
+	}
+	return map[string]any{"UnprocessedItems": map[string]any{}}, nil
+}
+
+// transactWriteItems avalia todas as condicoes antes de gravar: se alguma falhar,
+// nada e gravado e a resposta traz um motivo por item, como no DynamoDB.
+func (t *Table) transactWriteItems(req Request) (any, error) {
+	raw, _ := req["TransactItems"].([]any)
+	writes := make([]write, len(raw))
+	codes := make([]string, len(raw))
+	failed := false
+	for i, r := range raw {
+		for op, body := range r.(map[string]any) {
+			body := Request(body.(map[string]any))
+			if err := t.check(body); err != nil {
+				return nil, err
+			}
+			w, err := t.prepare(op, body)
+			if err != nil {
+				return nil, err
+			}
+			writes[i] = w
+		}
+		codes[i] = "None"
+		if !writes[i].ok {
+			codes[i] = "ConditionalCheckFailed"
+			failed = true
+		}
+	}
+	if failed {
+		return nil, TransactionCanceled(codes...)
+	}
+	for _, w := range writes {
+		w.apply(t)
+	}
+	return nil, nil
+}
+
+// write e uma escrita ja avaliada: ok diz se a condicao passou e item e o estado final
+// (nil para apagar). ConditionCheck nao grava nada.
+type write struct {
+	id    string
+	ok    bool
+	item  map[string]any
+	check bool
+}
+
+func (w write) apply(t *Table) {
+	switch {
+	case w.check:
+	case w.item == nil:
+		delete(t.items, w.id)
+	default:
+		t.items[w.id] = w.item
+	}
+}
+
+func (t *Table) prepare(op string, req Request) (write, error) {
+	var id string
+	if op == "Put" {
+		id = S(req["Item"], "id")
+	} else {
+		id = S(req["Key"], "id")
+	}
+	current := t.items[id]
+
+	e := newEvaluator(req)
+	w := write{id: id, ok: true}
+	if cond, _ := req["ConditionExpression"].(string); cond != "" {
+		ok, err := e.condition(cond, current)
+		if err != nil {
+			return write{}, err
+		}
+		w.ok = ok
+	}
+
+	switch op {
+	case "Put":
+		w.item = clone(req["Item"].(map[string]any))
+	case "Delete":
+	case "ConditionCheck":
+		w.check = true
+	case "Update":
+		item := clone(current)
+		if item == nil {
+			item = clone(req["Key"].(map[string]any))
+		}
+		if expr, _ := req["UpdateExpression"].(string); expr != "" {
+			if err := e.update(expr, item); err != nil {
+				return write{}, err
+			}
+		}
+		w.item = item
+	default:
+		return write{}, fmt.Errorf("dynamotest: operacao %s nao suportada em TransactWriteItems", op)
+	}
+	return w, nil
+}
+
+func conditionFailed() *Error {
+	return &Error{Type: "ConditionalCheckFailedException", Message: "The conditional request failed"}
+}
+
+func clone(item map[string]any) map[string]any {
+	if item == nil {
+		return nil
+	}
+	out := make(map[string]any, len(item))
+	for k, v := range item {
+		out[k] = cloneValue(v)
+	}
+	return out
+}
+
+func cloneValue(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		return clone(v)
+	case []any:
+		out := make([]any, len(v))
+		for i, e := range v {
+			out[i] = cloneValue(e)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// evaluator avalia as expressoes de uma requisicao, resolvendo os placeholders de
+// ExpressionAttributeNames e ExpressionAttributeValues.
+type evaluator struct {
+	names  map[string]any
+	values map[string]any
+
+	tokens []string
+	pos    int
+}
+
+func newEvaluator(req Request) *evaluator {
+	names, _ := req["ExpressionAttributeNames"].(map[string]any)
+	values, _ := req["ExpressionAttributeValues"].(map[string]any)
+	return &evaluator{names: names, values: values}
+}
+
+func (e *evaluator) reset(expr string) {
+	e.tokens = tokenize(expr)
+	e.pos = 0
+}
+
+func tokenize(expr string) []string {
+	var tokens []string
+	start := -1
+	flush := func(i int) {
+		if start >= 0 {
+			tokens = append(tokens, expr[start:i])
+			start = -1
+		}
+	}
+	for i, r := range expr {
+		switch {
+		case strings.ContainsRune("(),=+-", r):
+			flush(i)
+			tokens = append(tokens, string(r))
+		case r == ' ' || r == '\n' || r == '\t':
+			flush(i)
+		default:
+			if start < 0 {
+				start = i
+			}
+		}
+	}
+	flush(len(expr))
+	return tokens
+}
+
+func (e *evaluator) peek() string {
+	if e.pos < len(e.tokens) {
+		return e.tokens[e.pos]
+	}
+	return ""
+}
+
+func (e *evaluator) next() string {
+	tok := e.peek()
+	e.pos++
+	return tok
+}
+
+func (e *evaluator) expect(tok string) error {
+	if got := e.next(); got != tok {
+		return fmt.Errorf("dynamotest: esperava %q, veio %q em %v", tok, got, e.tokens)
+	}
+	return nil
+}
+
+// condition avalia uma ConditionExpression/FilterExpression sobre o item (nil quando
+// ele nao existe).
+func (e *evaluator) condition(expr string, item map[string]any) (bool, error) {
+	e.reset(expr)
+	ok, err := e.or(item)
+	if err != nil {
+		return false, err
+	}
+	if e.pos != len(e.tokens) {
+		return false, fmt.Errorf("dynamotest: sobra na expressao %q", expr)
+	}
+	return ok, nil
+}
+
+func (e *evaluator) or(item map[string]any) (bool, error) {
+	ok, err := e.and(item)
+	for err == nil && e.peek() == "OR" {
+		e.next()
+		var right bool
+		right, err = e.and(item)
+		ok = ok || right
+	}
+	return ok, err
+}
+
+func (e *evaluator) and(item map[string]any) (bool, error) {
+	ok, err := e.term(item)
+	for err == nil && e.peek() == "AND" {
+		e.next()
+		var right bool
+		right, err = e.term(item)
+		ok = ok && right
+	}
+	return ok, err
+}
+
+func (e *evaluator) term(item map[string]any) (bool, error) {
+	switch tok := e.peek(); tok {
+	case "(":
+		e.next()
+		ok, err := e.or(item)
+		if err != nil {
+			return false, err
+		}
+		return ok, e.expect(")")
+	case "NOT":
+		e.next()
+		ok, err := e.term(item)
+		return !ok, err
+	case "attribute_exists", "attribute_not_exists", "begins_with":
+		e.next()
+		if err := e.expect("("); err != nil {
+			return false, err
+		}
+		v := get(item, e.path(e.next()))
+		var ok bool
+		switch tok {
+		case "attribute_exists":
+			ok = v != nil
+		case "attribute_not_exists":
+			ok = v == nil
+		case "begins_with":
+			if err := e.expect(","); err != nil {
+				return false, err
+			}
+			prefix := e.values[e.next()]
+			ok = v != nil && strings.HasPrefix(stringOf(v), stringOf(prefix))
+		}
+		return ok, e.expect(")")
+	default:
+		left, err := e.operand(item)
+		if err != nil {
+			return false, err
+		}
+		if err := e.expect("="); err != nil {
+			return false, err
+		}
+		right, err := e.operand(item)
+		if err != nil {
+			return false, err
+		}
+		return left != nil && reflect.DeepEqual(left, right), nil
+	}
+}
+
+// update aplica uma UpdateExpression (secoes SET e REMOVE) ao item.
+func (e *evaluator) update(expr string, item map[string]any) error {
+	e.reset(expr)
+	for e.pos < len(e.tokens) {
+		switch section := e.next(); section {
+		case "SET":
+			for {
+				p := e.path(e.next())
+				if err := e.expect("="); err != nil {
+					return err
+				}
+				v, err := e.operand(item)
+				if err != nil {
+					return err
+				}
+				set(item, p, v)
+				if e.peek() != "," {
+					break
+				}
+				e.next()
+			}
+		case "REMOVE":
+			for {
+				remove(item, e.path(e.next()))
+				if e.peek() != "," {
+					break
+				}
+				e.next()
+			}
+		default:
+			return fmt.Errorf("dynamotest: secao %q nao suportada em %q", section, expr)
+		}
+	}
+	return nil
+}
+
+// operand avalia um valor: placeholder, caminho ou funcao, com + ou - entre numeros.
+func (e *evaluator) operand(item map[string]any) (any, error) {
+	v, err := e.atom(item)
+	if err != nil {
+		return nil, err
+	}
+	for e.peek() == "+" || e.peek() == "-" {
+		op := e.next()
+		right, err := e.atom(item)
+		if err != nil {
+			return nil, err
+		}
+		a, errA := strconv.ParseInt(numberOf(v), 10, 64)
+		b, errB := strconv.ParseInt(numberOf(right), 10, 64)
+		if errA != nil || errB != nil {
+			return nil, &Error{Type: "ValidationException", Message: "An operand in the update expression has an incorrect data type"}
+		}
+		if op == "-" {
+			b = -b
+		}
+		v = map[string]any{"N": strconv.FormatInt(a+b, 10)}
+	}
+	return v, nil
+}
+
+func (e *evaluator) atom(item map[string]any) (any, error) {
+	tok := e.next()
+	switch {
+	case strings.HasPrefix(tok, ":"):
+		v, ok := e.values[tok]
+		if !ok {
+			return nil, fmt.Errorf("dynamotest: valor %s sem definicao", tok)
+		}
+		return cloneValue(v), nil
+	case tok == "if_not_exists":
+		if err := e.expect("("); err != nil {
+			return nil, err
+		}
+		current := get(item, e.path(e.next()))
+		if err := e.expect(","); err != nil {
+			return nil, err
+		}
+		fallback, err := e.operand(item)
+		if err != nil {
+			return nil, err
+		}
+		if current == nil {
+			current = fallback
+		}
+		return cloneValue(current), e.expect(")")
+	case tok == "list_append":
+		if err := e.expect("("); err != nil {
+			return nil, err
+		}
+		a, err := e.operand(item)
+		if err != nil {
+			return nil, err
+		}
+		if err := e.expect(","); err != nil {
+			return nil, err
+		}
+		b, err := e.operand(item)
+		if err != nil {
+			return nil, err
+		}
+		listA, _ := a.(map[string]any)["L"].([]any)
+		listB, _ := b.(map[string]any)["L"].([]any)
+		return map[string]any{"L": append(slices.Clone(listA), listB...)}, e.expect(")")
+	default:
+		return cloneValue(get(item, e.path(tok))), nil
+	}
+}
+
+// path resolve "#0.#1" (ou "id") na lista de atributos aninhados.
+func (e *evaluator) path(tok string) []string {
+	parts := strings.Split(tok, ".")
+	for i, p := range parts {
+		if strings.HasPrefix(p, "#") {
+			if name, ok := e.names[p].(string); ok {
+				parts[i] = name
+			}
+		}
+	}
+	return parts
+}
+
+func stringOf(v any) string {
+	m, _ := v.(map[string]any)
+	s, _ := m["S"].(string)
+	return s
+}
+
+// intOf le um numero da requisicao (o JSON decodifica numeros como float64); 0 quando
+// ausente.
+func intOf(v any) int {
+	f, _ := v.(float64)
+	return int(f)
+}
+
+func numberOf(v any) string {
+	m, _ := v.(map[string]any)
+	n, _ := m["N"].(string)
+	return n
+}
+
+func get(item map[string]any, path []string) any {
+	var v any = item
+	for i, p := range path {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil
+		}
+		if i > 0 {
+			// Dentro de um Map, os atributos ficam em {"M": {...}}.
+			if m, ok = m["M"].(map[string]any); !ok {
+				return nil
+			}
+		}
+		if v, ok = m[p]; !ok {
+			return nil
+		}
+	}
+	return v
+}
+
+func set(item map[string]any, path []string, v any) {
+	parent := item
+	for _, p := range path[:len(path)-1] {
+		child, _ := parent[p].(map[string]any)
+		m, ok := child["M"].(map[string]any)
+		if !ok {
+			return
+		}
+		parent = m
+	}
+	parent[path[len(path)-1]] = v
+}
+
+func remove(item map[string]any, path []string) {
+	parent := item
+	for _, p := range path[:len(path)-1] {
+		child, _ := parent[p].(map[string]any)
+		m, ok := child["M"].(map[string]any)
+		if !ok {
+			return
+		}
+		parent = m
+	}
+	delete(parent, path[len(path)-1])
+}
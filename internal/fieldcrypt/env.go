@@ -0,0 +1,54 @@
+package fieldcrypt
+
+import (
+	"context"
+	"errors"
+	"os"
+
+	"github.com/dowglassantana/golang-with-dynamodb/internal/logging"
+)
+
+// defaultKeyFile e o arquivo de chaves criado com ENCRYPTION_KEYFILE_AUTOCREATE=true
+// quando ENCRYPTION_KEYFILE nao e informado.
+const defaultKeyFile = "keys.local.json"
+
+// FromEnv cria o Sealer a partir das variaveis de ambiente.
+//
+//	ENCRYPTION_KEYFILE             arquivo de chaves (veja KeyFile)
+//	ENCRYPTION_KEYFILE_AUTOCREATE  "true" cria o arquivo com chaves novas se ele nao existir
+//
+// O arquivo e obrigatorio: gerar chaves em silencio seria perigoso, porque um container
+// que reinicia sem o arquivo (ou em outro diretorio) criaria chaves novas e nenhum email
+// ja gravado poderia mais ser decifrado. A criacao automatica e so para desenvolvimento
+// (sem ENCRYPTION_KEYFILE, o arquivo e keys.local.json) e e recusada com ENV=aws, onde o
+// arquivo deve vir de um secret.
+func FromEnv(ctx context.Context, env string) (*Sealer, error) {
+	path := os.Getenv("ENCRYPTION_KEYFILE")
+	autoCreate := os.Getenv("ENCRYPTION_KEYFILE_AUTOCREATE") == "true"
+
+	var (
+		kf  *KeyFile
+		err error
+	)
+	switch {
+	case autoCreate && env == "aws":
+		return nil, errors.New("ENCRYPTION_KEYFILE_AUTOCREATE nao e permitido com ENV=aws")
+	case autoCreate:
+		if path == "" {
+			path = defaultKeyFile
+		}
+		var created bool
+		kf, created, err = LoadOrCreateKeyFile(path)
+		if created {
+			logging.FromContext(ctx).WarnContext(ctx, "arquivo de chaves criado; guarde-o junto com os dados", "path", path)
+		}
+	case path != "":
+		kf, err = LoadKeyFile(path)
+	default:
+		return nil, errors.New("ENCRYPTION_KEYFILE obrigatorio (em desenvolvimento, ENCRYPTION_KEYFILE_AUTOCREATE=true cria keys.local.json)")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return NewSealer(ctx, kf)
+}
@@ -0,0 +1,251 @@
+package fieldcrypt
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Criptografia de campos no lado do cliente (envelope encryption).
+//
+// Campos sensiveis (o email, por exemplo) sao cifrados pela aplicacao ANTES de irem
+// para o DynamoDB. A criptografia em repouso do proprio DynamoDB protege os discos da
+// AWS, mas qualquer um com permissao de leitura na tabela — um console, um backup,
+// um export para o S3 — ve o valor em claro. Com a cifragem no cliente, a tabela so
+// guarda bytes opacos.
+//
+// O esquema e o de "envelope":
+//
+//	chave mestra (KeyProvider)  --cifra-->  chave de dados (32 bytes, AES-256)
+//	chave de dados              --cifra-->  valor do campo (AES-GCM)
+//
+// O item guarda o valor cifrado, a chave de dados cifrada e o id da chave mestra
+// (Envelope). A chave mestra nunca sai do KeyProvider — em producao ela fica em um
+// KMS, que so sabe gerar e decifrar chaves de dados. Trocar a chave mestra (rotacao)
+// significa apenas cifrar chaves de dados novas com ela; os itens antigos continuam
+// legiveis enquanto a chave mestra antiga existir, e sao re-cifrados aos poucos.
+//
+// AES-GCM e autenticado: um byte alterado no item faz Open falhar em vez de devolver
+// lixo. O parametro aad (additional authenticated data) amarra o valor cifrado ao
+// item dono dele — copiar o campo de um usuario para outro tambem faz Open falhar.
+//
+// Um valor cifrado nao pode ser buscado nem comparado. Para isso existe o blind
+// index (BlindIndex): um HMAC-SHA256 do valor com uma chave secreta. O mesmo email
+// sempre gera o mesmo HMAC, entao da para usa-lo em chaves e indices, mas sem a
+// chave nao da para voltar ao email nem testar palpites.
+
+// DataKey e uma chave de dados gerada pelo KeyProvider: em claro, para cifrar agora, e
+// cifrada pela chave mestra KeyID, para ser guardada junto do dado.
+type DataKey struct {
+	KeyID     string
+	Plaintext []byte
+	Encrypted []byte
+}
+
+// KeyProvider guarda as chaves mestras. A interface segue o modelo do AWS KMS
+// (GenerateDataKey/Decrypt), entao um provider de KMS e uma implementacao direta.
+type KeyProvider interface {
+	// GenerateDataKey cria uma chave de dados de 32 bytes cifrada pela chave mestra ativa.
+	GenerateDataKey(ctx context.Context) (DataKey, error)
+
+	// DecryptDataKey decifra uma chave de dados cifrada pela chave mestra keyID.
+	DecryptDataKey(ctx context.Context, keyID string, encrypted []byte) ([]byte, error)
+
+	// ActiveKeyID e o id da chave mestra usada por GenerateDataKey.
+	ActiveKeyID() string
+
+	// IndexKey e a chave HMAC do blind index. Ela nao roda junto com as chaves
+	// mestras: trocar a chave do indice muda o HMAC de todos os itens.
+	IndexKey(ctx context.Context) ([]byte, error)
+}
+
+// Envelope e um campo cifrado como e guardado no item.
+type Envelope struct {
+	KeyID      string // chave mestra que cifrou DataKey
+	DataKey    []byte // chave de dados cifrada
+	Ciphertext []byte // nonce (12 bytes) + valor cifrado + tag do GCM
+}
+
+// ErrDecrypt indica um campo que nao pode ser decifrado: chave errada, dado alterado
+// ou valor copiado de outro item.
+var ErrDecrypt = errors.New("falha ao decifrar campo")
+
+const (
+	// dataKeyTTL e dataKeyMaxUses limitam o reuso da chave de dados em Seal. Com
+	// nonces aleatorios de 96 bits, o GCM so e seguro para ate ~2^32 mensagens por
+	// chave; o limite aqui fica muito abaixo disso.
+	dataKeyTTL     = 5 * time.Minute
+	dataKeyMaxUses = 1 << 20
+
+	// openCacheSize limita quantas chaves de dados decifradas ficam em memoria.
+	openCacheSize = 1024
+)
+
+// Sealer cifra e decifra campos com as chaves de um KeyProvider.
+//
+// Gerar e decifrar chaves de dados custa uma chamada ao KMS, entao o Sealer guarda as
+// chaves em memoria: Seal reusa a mesma chave de dados por alguns minutos (cada valor
+// continua com seu proprio nonce) e Open lembra as chaves de dados ja decifradas.
+// E seguro para uso concorrente.
+type Sealer struct {
+	provider KeyProvider
+	indexKey []byte
+
+	mu      sync.Mutex
+	current *cachedDataKey
+	opened  map[string][]byte // chave de dados cifrada -> em claro
+}
+
+type cachedDataKey struct {
+	key     DataKey
+	aead    cipher.AEAD
+	expires time.Time
+	uses    int
+}
+
+// NewSealer cria o Sealer e le a chave do blind index do provider.
+func NewSealer(ctx context.Context, provider KeyProvider) (*Sealer, error) {
+	indexKey, err := provider.IndexKey(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao obter chave do blind index: %w", err)
+	}
+	if len(indexKey) < 32 {
+		return nil, errors.New("chave do blind index deve ter pelo menos 32 bytes")
+	}
+	return &Sealer{
+		provider: provider,
+		indexKey: indexKey,
+		opened:   make(map[string][]byte),
+	}, nil
+}
+
+// ActiveKeyID e o id da chave mestra usada pelos novos envelopes.
+func (s *Sealer) ActiveKeyID() string {
+	return s.provider.ActiveKeyID()
+}
+
+// Seal cifra plaintext. aad deve identificar o item dono do campo e ser informado de
+// novo, igual, em Open.
+func (s *Sealer) Seal(ctx context.Context, plaintext, aad []byte) (Envelope, error) {
+	key, aead, err := s.dataKey(ctx)
+	if err != nil {
+		return Envelope{}, err
+	}
+
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return Envelope{}, fmt.Errorf("erro ao gerar nonce: %w", err)
+	}
+
+	return Envelope{
+		KeyID:      key.KeyID,
+		DataKey:    key.Encrypted,
+		Ciphertext: aead.Seal(nonce, nonce, plaintext, aad),
+	}, nil
+}
+
+// Open decifra um envelope criado por Seal com o mesmo aad.
+func (s *Sealer) Open(ctx context.Context, env Envelope, aad []byte) ([]byte, error) {
+	plainKey, err := s.openDataKey(ctx, env)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := newAEAD(plainKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(env.Ciphertext) < aead.NonceSize() {
+		return nil, ErrDecrypt
+	}
+
+	nonce, ciphertext := env.Ciphertext[:aead.NonceSize()], env.Ciphertext[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, aad)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
+
+// Stale indica se o envelope foi cifrado com uma chave mestra que nao e mais a ativa
+// e deve ser re-cifrado.
+func (s *Sealer) Stale(env Envelope) bool {
+	return env.KeyID != s.provider.ActiveKeyID()
+}
+
+// BlindIndex calcula o HMAC-SHA256 das partes (separadas por um byte zero, para que
+// ("ab", "c") e ("a", "bc") nao colidam), em base64 URL-safe.
+func (s *Sealer) BlindIndex(parts ...string) string {
+	mac := hmac.New(sha256.New, s.indexKey)
+	for i, p := range parts {
+		if i > 0 {
+			mac.Write([]byte{0})
+		}
+		mac.Write([]byte(p))
+	}
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// dataKey devolve a chave de dados em uso, gerando uma nova quando ela expira, atinge
+// o limite de usos ou quando a chave mestra ativa muda.
+func (s *Sealer) dataKey(ctx context.Context) (DataKey, cipher.AEAD, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c := s.current
+	if c == nil || c.uses >= dataKeyMaxUses || time.Now().After(c.expires) || c.key.KeyID != s.provider.ActiveKeyID() {
+		key, err := s.provider.GenerateDataKey(ctx)
+		if err != nil {
+			return DataKey{}, nil, fmt.Errorf("erro ao gerar chave de dados: %w", err)
+		}
+		aead, err := newAEAD(key.Plaintext)
+		if err != nil {
+			return DataKey{}, nil, err
+		}
+		c = &cachedDataKey{key: key, aead: aead, expires: time.Now().Add(dataKeyTTL)}
+		s.current = c
+	}
+	c.uses++
+	return c.key, c.aead, nil
+}
+
+// openDataKey decifra a chave de dados do envelope, consultando antes o cache.
+func (s *Sealer) openDataKey(ctx context.Context, env Envelope) ([]byte, error) {
+	cacheKey := env.KeyID + "\x00" + string(env.DataKey)
+
+	s.mu.Lock()
+	plainKey, ok := s.opened[cacheKey]
+	s.mu.Unlock()
+	if ok {
+		return plainKey, nil
+	}
+
+	plainKey, err := s.provider.DecryptDataKey(ctx, env.KeyID, env.DataKey)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao decifrar chave de dados: %w", err)
+	}
+
+	s.mu.Lock()
+	if len(s.opened) >= openCacheSize {
+		clear(s.opened)
+	}
+	s.opened[cacheKey] = plainKey
+	s.mu.Unlock()
+	return plainKey, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("chave de dados invalida: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,209 @@
+package fieldcrypt
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+// newTestKeyFile monta um KeyFile em memoria com as chaves mestras ids; a ativa e active.
+func newTestKeyFile(t *testing.T, active string, ids ...string) *KeyFile {
+	t.Helper()
+	kf := &KeyFile{active: active, masters: make(map[string][]byte), index: randomKey()}
+	for _, id := range ids {
+		kf.masters[id] = randomKey()
+	}
+	if err := kf.validate(); err != nil {
+		t.Fatalf("KeyFile invalido: %v", err)
+	}
+	return kf
+}
+
+func newTestSealer(t *testing.T, kf *KeyFile) *Sealer {
+	t.Helper()
+	sealer, err := NewSealer(context.Background(), kf)
+	if err != nil {
+		t.Fatalf("NewSealer: %v", err)
+	}
+	return sealer
+}
+
+func TestSealOpen(t *testing.T) {
+	ctx := context.Background()
+	sealer := newTestSealer(t, newTestKeyFile(t, "k1", "k1"))
+	aad := []byte("TENANT#default#USER#1")
+
+	env, err := sealer.Seal(ctx, []byte("ana@email.com"), aad)
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+	if env.KeyID != "k1" {
+		t.Errorf("KeyID = %q, quer k1", env.KeyID)
+	}
+	if bytes.Contains(env.Ciphertext, []byte("ana@email.com")) {
+		t.Error("ciphertext contem o valor em claro")
+	}
+
+	got, err := sealer.Open(ctx, env, aad)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if string(got) != "ana@email.com" {
+		t.Errorf("Open = %q, quer ana@email.com", got)
+	}
+}
+
+func TestOpenRejectsTamperedEnvelope(t *testing.T) {
+	ctx := context.Background()
+	sealer := newTestSealer(t, newTestKeyFile(t, "k1", "k1"))
+	aad := []byte("TENANT#default#USER#1")
+
+	env, err := sealer.Seal(ctx, []byte("ana@email.com"), aad)
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+
+	tampered := env
+	tampered.Ciphertext = bytes.Clone(env.Ciphertext)
+	tampered.Ciphertext[len(tampered.Ciphertext)-1] ^= 1
+
+	tests := []struct {
+		name string
+		env  Envelope
+		aad  []byte
+	}{
+		{"aad de outro item", env, []byte("TENANT#default#USER#2")},
+		{"ciphertext alterado", tampered, aad},
+		{"ciphertext truncado", Envelope{KeyID: env.KeyID, DataKey: env.DataKey, Ciphertext: env.Ciphertext[:4]}, aad},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := sealer.Open(ctx, tt.env, tt.aad); !errors.Is(err, ErrDecrypt) {
+				t.Errorf("Open = %v, quer ErrDecrypt", err)
+			}
+		})
+	}
+}
+
+// TestRotation segue a rotacao descrita em KeyFile: a chave nova vira a ativa, os
+// envelopes antigos continuam legiveis e sao marcados como Stale ate serem re-cifrados.
+func TestRotation(t *testing.T) {
+	ctx := context.Background()
+	aad := []byte("TENANT#default#USER#1")
+
+	before := newTestKeyFile(t, "k1", "k1")
+	old, err := newTestSealer(t, before).Seal(ctx, []byte("ana@email.com"), aad)
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+
+	rotated := &KeyFile{
+		active:  "k2",
+		masters: map[string][]byte{"k1": before.masters["k1"], "k2": randomKey()},
+		index:   before.index,
+	}
+	sealer := newTestSealer(t, rotated)
+
+	if !sealer.Stale(old) {
+		t.Error("envelope da chave antiga deveria estar Stale")
+	}
+	got, err := sealer.Open(ctx, old, aad)
+	if err != nil || string(got) != "ana@email.com" {
+		t.Fatalf("Open do envelope antigo = %q, %v", got, err)
+	}
+
+	resealed, err := sealer.Seal(ctx, got, aad)
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+	if resealed.KeyID != "k2" || sealer.Stale(resealed) {
+		t.Errorf("envelope re-cifrado com %q (stale=%t), quer k2", resealed.KeyID, sealer.Stale(resealed))
+	}
+
+	// Sem a chave antiga no arquivo, o envelope que nao foi re-cifrado fica ilegivel.
+	dropped := newTestSealer(t, &KeyFile{active: "k2", masters: map[string][]byte{"k2": rotated.masters["k2"]}, index: before.index})
+	if _, err := dropped.Open(ctx, old, aad); err == nil {
+		t.Error("Open sem a chave mestra antiga deveria falhar")
+	}
+}
+
+func TestBlindIndex(t *testing.T) {
+	kf := newTestKeyFile(t, "k1", "k1")
+	sealer := newTestSealer(t, kf)
+
+	if sealer.BlindIndex("default", "ana@email.com") != sealer.BlindIndex("default", "ana@email.com") {
+		t.Error("BlindIndex deveria ser deterministico")
+	}
+	if sealer.BlindIndex("ab", "c") == sealer.BlindIndex("a", "bc") {
+		t.Error("partes diferentes nao deveriam colidir")
+	}
+
+	// A rotacao das chaves mestras nao muda o indice.
+	rotated := newTestSealer(t, &KeyFile{active: "k2", masters: map[string][]byte{"k2": randomKey()}, index: kf.index})
+	if sealer.BlindIndex("x") != rotated.BlindIndex("x") {
+		t.Error("BlindIndex mudou com a chave mestra")
+	}
+}
+
+func TestKeyFileRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+
+	created, err := CreateKeyFile(path)
+	if err != nil {
+		t.Fatalf("CreateKeyFile: %v", err)
+	}
+	if _, err := CreateKeyFile(path); err == nil {
+		t.Error("CreateKeyFile deveria recusar sobrescrever o arquivo")
+	}
+
+	loaded, err := LoadKeyFile(path)
+	if err != nil {
+		t.Fatalf("LoadKeyFile: %v", err)
+	}
+	if loaded.ActiveKeyID() != created.ActiveKeyID() || !bytes.Equal(loaded.index, created.index) {
+		t.Error("arquivo lido difere do criado")
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("sem arquivo", func(t *testing.T) {
+		t.Setenv("ENCRYPTION_KEYFILE", "")
+		t.Setenv("ENCRYPTION_KEYFILE_AUTOCREATE", "")
+		if _, err := FromEnv(ctx, "local"); err == nil {
+			t.Error("FromEnv sem ENCRYPTION_KEYFILE deveria falhar")
+		}
+	})
+
+	t.Run("arquivo inexistente sem opt-in", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "keys.json")
+		t.Setenv("ENCRYPTION_KEYFILE", path)
+		t.Setenv("ENCRYPTION_KEYFILE_AUTOCREATE", "")
+		if _, err := FromEnv(ctx, "local"); err == nil {
+			t.Error("FromEnv deveria falhar sem criar o arquivo")
+		}
+	})
+
+	t.Run("criacao automatica", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "keys.json")
+		t.Setenv("ENCRYPTION_KEYFILE", path)
+		t.Setenv("ENCRYPTION_KEYFILE_AUTOCREATE", "true")
+		if _, err := FromEnv(ctx, "local"); err != nil {
+			t.Fatalf("FromEnv: %v", err)
+		}
+		if _, err := LoadKeyFile(path); err != nil {
+			t.Errorf("arquivo nao foi criado: %v", err)
+		}
+	})
+
+	t.Run("criacao automatica recusada na aws", func(t *testing.T) {
+		t.Setenv("ENCRYPTION_KEYFILE", filepath.Join(t.TempDir(), "keys.json"))
+		t.Setenv("ENCRYPTION_KEYFILE_AUTOCREATE", "true")
+		if _, err := FromEnv(ctx, "aws"); err == nil {
+			t.Error("FromEnv com ENV=aws deveria recusar a criacao automatica")
+		}
+	})
+}
@@ -0,0 +1,175 @@
+package fieldcrypt
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// KeyFile e um KeyProvider que le as chaves mestras de um arquivo JSON local. Serve
+// para desenvolvimento e testes; em producao as chaves mestras devem ficar em um KMS.
+//
+// Formato do arquivo (chaves de 32 bytes em base64):
+//
+//	{
+//	  "active_key": "2024-06",
+//	  "master_keys": {
+//	    "2024-01": "q8v0...",
+//	    "2024-06": "Zt3x..."
+//	  },
+//	  "index_key": "m2Yk..."
+//	}
+//
+// Para rodar a chave mestra, acrescente uma chave nova em master_keys e aponte
+// active_key para ela. As chaves antigas precisam continuar no arquivo ate que todos
+// os itens tenham sido re-cifrados.
+//
+// O arquivo e lido uma vez; depois de editar o arquivo, reinicie o processo.
+type KeyFile struct {
+	active  string
+	masters map[string][]byte
+	index   []byte
+}
+
+// keyFileJSON e o formato do arquivo. encoding/json grava []byte em base64.
+type keyFileJSON struct {
+	ActiveKey  string            `json:"active_key"`
+	MasterKeys map[string][]byte `json:"master_keys"`
+	IndexKey   []byte            `json:"index_key"`
+}
+
+var _ KeyProvider = (*KeyFile)(nil)
+
+// LoadKeyFile le e valida o arquivo de chaves.
+func LoadKeyFile(path string) (*KeyFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler arquivo de chaves: %w", err)
+	}
+
+	var raw keyFileJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("arquivo de chaves invalido: %w", err)
+	}
+
+	kf := &KeyFile{active: raw.ActiveKey, masters: raw.MasterKeys, index: raw.IndexKey}
+	if err := kf.validate(); err != nil {
+		return nil, fmt.Errorf("arquivo de chaves invalido: %w", err)
+	}
+	return kf, nil
+}
+
+// CreateKeyFile gera um arquivo de chaves novo, com uma chave mestra e a chave do
+// blind index. Falha se o arquivo ja existe, para nunca sobrescrever chaves em uso.
+func CreateKeyFile(path string) (*KeyFile, error) {
+	active := time.Now().UTC().Format("2006-01")
+	raw := keyFileJSON{
+		ActiveKey:  active,
+		MasterKeys: map[string][]byte{active: randomKey()},
+		IndexKey:   randomKey(),
+	}
+
+	data, err := json.MarshalIndent(raw, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("erro ao serializar arquivo de chaves: %w", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao criar arquivo de chaves: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return nil, fmt.Errorf("erro ao gravar arquivo de chaves: %w", err)
+	}
+	return &KeyFile{active: raw.ActiveKey, masters: raw.MasterKeys, index: raw.IndexKey}, nil
+}
+
+// LoadOrCreateKeyFile le o arquivo de chaves e, se ele ainda nao existir, o cria.
+func LoadOrCreateKeyFile(path string) (kf *KeyFile, created bool, err error) {
+	kf, err = LoadKeyFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		kf, err = CreateKeyFile(path)
+		return kf, err == nil, err
+	}
+	return kf, false, err
+}
+
+func (kf *KeyFile) validate() error {
+	if len(kf.index) < 32 {
+		return errors.New("index_key deve ter pelo menos 32 bytes")
+	}
+	if _, ok := kf.masters[kf.active]; !ok {
+		return fmt.Errorf("active_key %q nao esta em master_keys", kf.active)
+	}
+	for id, key := range kf.masters {
+		if len(key) != 32 {
+			return fmt.Errorf("master_keys[%q] deve ter 32 bytes", id)
+		}
+	}
+	return nil
+}
+
+// GenerateDataKey cria uma chave de dados e a cifra com AES-GCM usando a chave mestra
+// ativa. O id da chave mestra entra como aad: uma chave de dados nao decifra com o id
+// de outra chave mestra.
+func (kf *KeyFile) GenerateDataKey(ctx context.Context) (DataKey, error) {
+	aead, err := newAEAD(kf.masters[kf.active])
+	if err != nil {
+		return DataKey{}, err
+	}
+
+	plaintext := randomKey()
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return DataKey{}, fmt.Errorf("erro ao gerar nonce: %w", err)
+	}
+
+	return DataKey{
+		KeyID:     kf.active,
+		Plaintext: plaintext,
+		Encrypted: aead.Seal(nonce, nonce, plaintext, []byte(kf.active)),
+	}, nil
+}
+
+// DecryptDataKey decifra uma chave de dados gerada por GenerateDataKey.
+func (kf *KeyFile) DecryptDataKey(ctx context.Context, keyID string, encrypted []byte) ([]byte, error) {
+	master, ok := kf.masters[keyID]
+	if !ok {
+		return nil, fmt.Errorf("chave mestra %q nao encontrada", keyID)
+	}
+
+	aead, err := newAEAD(master)
+	if err != nil {
+		return nil, err
+	}
+	if len(encrypted) < aead.NonceSize() {
+		return nil, ErrDecrypt
+	}
+
+	nonce, ciphertext := encrypted[:aead.NonceSize()], encrypted[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, []byte(keyID))
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
+
+func (kf *KeyFile) ActiveKeyID() string {
+	return kf.active
+}
+
+func (kf *KeyFile) IndexKey(ctx context.Context) ([]byte, error) {
+	return kf.index, nil
+}
+
+func randomKey() []byte {
+	key := make([]byte, 32)
+	rand.Read(key)
+	return key
+}
@@ -0,0 +1,40 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// AdminAuthMiddleware protege as rotas de administracao (/admin/*) com um token fixo,
+// enviado como "Authorization: Bearer <token>". O export devolve os emails decifrados
+// de todo o tenant e o import grava em massa, entao essas rotas nao podem ficar tao
+// abertas quanto o resto da API.
+//
+// Com token vazio as rotas ficam desligadas e respondem 404. Um token ausente ou
+// errado responde 401.
+//
+// Os dois tokens sao comparados pelo hash SHA-256 com subtle.ConstantTimeCompare: o
+// tempo da comparacao nao depende de quantos caracteres batem nem do tamanho do token
+// enviado.
+func AdminAuthMiddleware(token string) func(http.Handler) http.Handler {
+	expected := sha256.Sum256([]byte(token))
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if token == "" {
+				writeJSON(w, http.StatusNotFound, map[string]string{"error": "rotas de administracao desabilitadas"})
+				return
+			}
+
+			got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			sum := sha256.Sum256([]byte(got))
+			if !ok || subtle.ConstantTimeCompare(sum[:], expected[:]) != 1 {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+				writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "token de administracao invalido"})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
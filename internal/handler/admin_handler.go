@@ -0,0 +1,407 @@
+package handler
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/dowglassantana/golang-with-dynamodb/internal/logging"
+	"github.com/dowglassantana/golang-with-dynamodb/internal/model"
+)
+
+// Exportacao e importacao em massa, para migrar usuarios para dentro ou para fora do
+// servico sem scripts avulsos contra POST /users.
+//
+// Os dois endpoints trabalham em fluxo: o export escreve cada usuario assim que ele e
+// lido da tabela e o import le o upload linha a linha, gravando em lotes. Nenhum dos
+// dois guarda o arquivo inteiro em memoria.
+//
+// Formatos:
+//   - ndjson: um objeto JSON por linha, no mesmo formato de GET /users/{id}.
+//   - csv: cabecalho id,name,email,created_at,version,deleted_at. No import apenas as
+//     colunas name e email sao obrigatorias, em qualquer ordem.
+//
+// O arquivo exportado pode ser importado de volta, em outro tenant ou ambiente. O
+// import preserva id e created_at quando presentes (veja service.Import) e ignora
+// version e deleted_at: cada usuario importado comeca ativo, na versao 1.
+
+const (
+	formatNDJSON = "ndjson"
+	formatCSV    = "csv"
+
+	// exportFlushEvery define a cada quantos usuarios o export envia o que esta no
+	// buffer, para o cliente receber os dados aos poucos.
+	exportFlushEvery = 100
+
+	// importChunkSize e quantas linhas vao em cada BatchCreate do import.
+	importChunkSize = 100
+	// importMaxBytes limita o tamanho do upload e importMaxLine o de uma linha.
+	importMaxBytes = 64 << 20
+	importMaxLine  = 64 << 10
+)
+
+var csvHeader = []string{"id", "name", "email", "created_at", "version", "deleted_at"}
+
+// Export atende GET /admin/export?format=ndjson|csv&include_deleted=true.
+//
+// O status 200 e enviado junto com o primeiro usuario. Se a leitura falhar antes
+// disso, a resposta e o erro de sempre; depois, a conexao e abortada, para que o
+// cliente veja a falha em vez de um arquivo truncado que parece completo.
+func (h *UserHandler) Export(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	format := query.Get("format")
+	if format == "" {
+		format = formatNDJSON
+	}
+
+	var enc userEncoder
+	switch format {
+	case formatNDJSON:
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		enc = newNDJSONEncoder(w)
+	case formatCSV:
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		enc = newCSVEncoder(w)
+	default:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "format invalido: use ndjson ou csv"})
+		return
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="users.%s"`, format))
+
+	rc := http.NewResponseController(w)
+	written := 0
+	err := h.service.Export(r.Context(), query.Get("include_deleted") == "true", func(u model.User) error {
+		if err := enc.Encode(u); err != nil {
+			return err
+		}
+		written++
+		if written%exportFlushEvery == 0 {
+			if err := enc.Flush(); err != nil {
+				return err
+			}
+			return rc.Flush()
+		}
+		return nil
+	})
+
+	if err != nil && written == 0 {
+		w.Header().Del("Content-Disposition")
+		writeError(w, err)
+		return
+	}
+	if err == nil {
+		err = enc.Flush()
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).ErrorContext(r.Context(), "erro ao exportar usuarios",
+			"lines_written", written, "error", err)
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// userEncoder escreve usuarios em um dos formatos do export.
+type userEncoder interface {
+	Encode(u model.User) error
+	Flush() error
+}
+
+type ndjsonEncoder struct {
+	enc *json.Encoder
+}
+
+func newNDJSONEncoder(w io.Writer) *ndjsonEncoder {
+	return &ndjsonEncoder{enc: json.NewEncoder(w)}
+}
+
+// Encode escreve o usuario seguido de "\n" — json.Encoder ja termina cada valor assim.
+func (e *ndjsonEncoder) Encode(u model.User) error {
+	return e.enc.Encode(toUserResponse(u))
+}
+
+func (e *ndjsonEncoder) Flush() error {
+	return nil
+}
+
+type csvEncoder struct {
+	w      *csv.Writer
+	header bool
+}
+
+func newCSVEncoder(w io.Writer) *csvEncoder {
+	return &csvEncoder{w: csv.NewWriter(w)}
+}
+
+func (e *csvEncoder) Encode(u model.User) error {
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+	return e.w.Write([]string{u.ID, u.Name, u.Email, u.CreatedAt, strconv.FormatInt(u.Version, 10), u.DeletedAt})
+}
+
+// Flush garante o cabecalho mesmo em um export vazio.
+func (e *csvEncoder) Flush() error {
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e *csvEncoder) writeHeader() error {
+	if e.header {
+		return nil
+	}
+	e.header = true
+	return e.w.Write(csvHeader)
+}
+
+// Import atende POST /admin/import. O formato vem de ?format= ou, na falta dele, do
+// Content-Type (application/x-ndjson ou text/csv).
+//
+// Cada linha passa pelas mesmas regras de POST /users:batchCreate (validacao do
+// service, email unico no tenant), mais as de id e created_at, e as linhas validas
+// sao gravadas em lotes de importChunkSize. Uma linha com erro nao impede as demais: a resposta e sempre 200
+// com o relatorio por linha (ImportResponse). Um corpo acima de importMaxBytes ou uma
+// linha acima de importMaxLine interrompem a leitura, e o relatorio indica em que
+// linha ela parou.
+func (h *UserHandler) Import(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		switch mediaType(r.Header.Get("Content-Type")) {
+		case "", "application/x-ndjson", "application/ndjson":
+			format = formatNDJSON
+		case "text/csv":
+			format = formatCSV
+		default:
+			writeJSON(w, http.StatusUnsupportedMediaType, map[string]string{"error": "use Content-Type application/x-ndjson ou text/csv"})
+			return
+		}
+	}
+
+	body := http.MaxBytesReader(w, r.Body, importMaxBytes)
+	var rows rowReader
+	switch format {
+	case formatNDJSON:
+		rows = newNDJSONRows(body)
+	case formatCSV:
+		csvRows, err := newCSVRows(body)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		rows = csvRows
+	default:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "format invalido: use ndjson ou csv"})
+		return
+	}
+
+	report := ImportResponse{Errors: []ImportErrorResponse{}}
+	chunk := make([]importRow, 0, importChunkSize)
+
+	flush := func() {
+		if len(chunk) == 0 {
+			return
+		}
+		inputs := make([]model.ImportUserInput, len(chunk))
+		for i, row := range chunk {
+			inputs[i] = row.input
+		}
+
+		results, err := h.service.Import(r.Context(), inputs)
+		for i, row := range chunk {
+			switch {
+			case err != nil:
+				report.addError(row.line, err)
+			case results[i].Err != nil:
+				report.addError(row.line, results[i].Err)
+			default:
+				report.Created++
+			}
+		}
+		chunk = chunk[:0]
+	}
+
+	for r.Context().Err() == nil {
+		row, err := rows.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		var rowErr *rowError
+		if errors.As(err, &rowErr) {
+			report.Total++
+			report.Errors = append(report.Errors, ImportErrorResponse{Line: row.line, Status: http.StatusBadRequest, Error: rowErr.msg})
+			continue
+		}
+		if err != nil {
+			// Erro de leitura do corpo: nao da para continuar de onde parou.
+			report.Errors = append(report.Errors, ImportErrorResponse{Line: row.line, Status: readErrorStatus(err), Error: err.Error()})
+			report.Aborted = true
+			break
+		}
+
+		report.Total++
+		chunk = append(chunk, row)
+		if len(chunk) == importChunkSize {
+			flush()
+		}
+	}
+	flush()
+
+	// Erros de formato sao registrados na leitura e os de gravacao so quando o lote
+	// e enviado; o relatorio sai na ordem do arquivo.
+	slices.SortStableFunc(report.Errors, func(a, b ImportErrorResponse) int {
+		return cmp.Compare(a.Line, b.Line)
+	})
+	report.Failed = len(report.Errors)
+	writeJSON(w, http.StatusOK, report)
+}
+
+// readErrorStatus classifica uma falha ao ler o corpo do import.
+func readErrorStatus(err error) int {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) || errors.Is(err, bufio.ErrTooLong) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
+
+// importRow e uma linha do upload ja convertida.
+type importRow struct {
+	line  int
+	input model.ImportUserInput
+}
+
+// rowError e uma linha mal formada. Diferente de um erro de leitura, a leitura segue
+// na proxima linha.
+type rowError struct {
+	msg string
+}
+
+func (e *rowError) Error() string {
+	return e.msg
+}
+
+// rowReader le as linhas do upload. Next devolve io.EOF no fim; a linha devolvida
+// junto com um erro traz o numero da linha que falhou.
+type rowReader interface {
+	Next() (importRow, error)
+}
+
+type ndjsonRows struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func newNDJSONRows(r io.Reader) *ndjsonRows {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 4096), importMaxLine)
+	return &ndjsonRows{scanner: scanner}
+}
+
+// Next pula linhas em branco. Campos alem de id, name, email e created_at (como
+// version e deleted_at do export) sao ignorados.
+func (n *ndjsonRows) Next() (importRow, error) {
+	for n.scanner.Scan() {
+		n.line++
+		text := strings.TrimSpace(n.scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		row := importRow{line: n.line}
+		if err := json.Unmarshal([]byte(text), &row.input); err != nil {
+			return row, &rowError{msg: "json invalido"}
+		}
+		return row, nil
+	}
+	if err := n.scanner.Err(); err != nil {
+		return importRow{line: n.line + 1}, fmt.Errorf("erro ao ler linha %d: %w", n.line+1, err)
+	}
+	return importRow{}, io.EOF
+}
+
+type csvRows struct {
+	reader    *csv.Reader
+	id        int
+	name      int
+	email     int
+	createdAt int
+	line      int
+}
+
+// newCSVRows le o cabecalho e localiza as colunas name e email e, se existirem, id e
+// created_at.
+func newCSVRows(r io.Reader) (*csvRows, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, errors.New("csv sem cabecalho")
+	}
+
+	rows := &csvRows{reader: reader, id: -1, name: -1, email: -1, createdAt: -1}
+	for i, col := range header {
+		switch strings.ToLower(strings.TrimSpace(strings.TrimPrefix(col, "\ufeff"))) {
+		case "id":
+			rows.id = i
+		case "created_at":
+			rows.createdAt = i
+		case "name":
+			rows.name = i
+		case "email":
+			rows.email = i
+		}
+	}
+	if rows.name < 0 || rows.email < 0 {
+		return nil, errors.New("cabecalho do csv precisa das colunas name e email")
+	}
+	return rows, nil
+}
+
+func (c *csvRows) Next() (importRow, error) {
+	record, err := c.reader.Read()
+	if errors.Is(err, io.EOF) {
+		return importRow{}, io.EOF
+	}
+
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return importRow{line: parseErr.StartLine}, &rowError{msg: "csv invalido: " + parseErr.Err.Error()}
+	}
+	if err != nil {
+		return importRow{line: c.line + 1}, fmt.Errorf("erro ao ler csv: %w", err)
+	}
+
+	c.line, _ = c.reader.FieldPos(0)
+	row := importRow{line: c.line}
+	if c.name >= len(record) || c.email >= len(record) {
+		return row, &rowError{msg: "linha sem as colunas name e email"}
+	}
+	row.input = model.ImportUserInput{
+		ID:        optionalField(record, c.id),
+		Name:      record[c.name],
+		Email:     record[c.email],
+		CreatedAt: optionalField(record, c.createdAt),
+	}
+	return row, nil
+}
+
+// optionalField devolve a coluna i da linha, ou "" se o cabecalho nao tem a coluna
+// (i < 0) ou a linha e mais curta.
+func optionalField(record []string, i int) string {
+	if i < 0 || i >= len(record) {
+		return ""
+	}
+	return record[i]
+}
@@ -0,0 +1,246 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/dowglassantana/golang-with-dynamodb/internal/model"
+	"github.com/dowglassantana/golang-with-dynamodb/internal/repository"
+	"github.com/dowglassantana/golang-with-dynamodb/internal/requestctx"
+	"github.com/dowglassantana/golang-with-dynamodb/internal/service"
+)
+
+const testAdminToken = "token-de-teste"
+
+func TestAdminAuth(t *testing.T) {
+	tests := []struct {
+		name          string
+		token         string
+		authorization string
+		want          int
+	}{
+		{"desligado", "", "Bearer " + testAdminToken, http.StatusNotFound},
+		{"desligado sem header", "", "", http.StatusNotFound},
+		{"sem header", testAdminToken, "", http.StatusUnauthorized},
+		{"token errado", testAdminToken, "Bearer outro", http.StatusUnauthorized},
+		{"prefixo do token", testAdminToken, "Bearer " + testAdminToken[:5], http.StatusUnauthorized},
+		{"sem Bearer", testAdminToken, testAdminToken, http.StatusUnauthorized},
+		{"token certo", testAdminToken, "Bearer " + testAdminToken, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newTestServer(t, tt.token)
+			for _, route := range []struct{ method, path string }{
+				{http.MethodGet, "/admin/export"},
+				{http.MethodPost, "/admin/import"},
+			} {
+				resp, data := do(t, srv, route.method, route.path, "", "Authorization", tt.authorization)
+				if resp.StatusCode != tt.want {
+					t.Errorf("%s %s: status %d (%s), quer %d", route.method, route.path, resp.StatusCode, data, tt.want)
+				}
+				if tt.want == http.StatusUnauthorized && resp.Header.Get("WWW-Authenticate") == "" {
+					t.Errorf("%s %s: 401 sem WWW-Authenticate", route.method, route.path)
+				}
+			}
+		})
+	}
+}
+
+// TestExportImport exporta um tenant e importa o arquivo em outro: ids e created_at
+// sao mantidos, e importar de novo no mesmo tenant responde 409 por linha.
+func TestExportImport(t *testing.T) {
+	srv := newTestServer(t, testAdminToken)
+	auth := "Bearer " + testAdminToken
+
+	ana := createUser(t, srv, "Ana", "ana@email.com", "X-Tenant-ID", "origem")
+	bia := createUser(t, srv, "Bia", "bia@email.com", "X-Tenant-ID", "origem")
+
+	for _, format := range []string{formatNDJSON, formatCSV} {
+		t.Run(format, func(t *testing.T) {
+			resp, export := do(t, srv, http.MethodGet, "/admin/export?format="+format, "",
+				"Authorization", auth, "X-Tenant-ID", "origem")
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("export: %d %s", resp.StatusCode, export)
+			}
+
+			contentType := "application/x-ndjson"
+			if format == formatCSV {
+				contentType = "text/csv"
+			}
+			destino := "destino-" + format
+
+			resp, data := do(t, srv, http.MethodPost, "/admin/import", string(export),
+				"Authorization", auth, "X-Tenant-ID", destino, "Content-Type", contentType)
+			report := decode[ImportResponse](t, data)
+			if resp.StatusCode != http.StatusOK || report.Total != 2 || report.Created != 2 {
+				t.Fatalf("import: %d %+v", resp.StatusCode, report)
+			}
+
+			for _, want := range []UserResponse{ana, bia} {
+				resp, data = do(t, srv, http.MethodGet, "/users/"+want.ID, "", "X-Tenant-ID", destino)
+				if resp.StatusCode != http.StatusOK {
+					t.Fatalf("GET do importado: %d %s", resp.StatusCode, data)
+				}
+				if got := decode[UserResponse](t, data); got != want {
+					t.Errorf("importado = %+v, quer %+v", got, want)
+				}
+			}
+
+			_, data = do(t, srv, http.MethodPost, "/admin/import", string(export),
+				"Authorization", auth, "X-Tenant-ID", destino, "Content-Type", contentType)
+			report = decode[ImportResponse](t, data)
+			if report.Created != 0 || report.Failed != 2 || report.Errors[0].Status != http.StatusConflict {
+				t.Errorf("segundo import: %+v", report)
+			}
+		})
+	}
+}
+
+// TestImportActiveID importa uma linha com o id de um usuario ativo, mas com outro
+// email: o id ja esta em uso (ErrIDTaken) e o usuario existente nao muda.
+func TestImportActiveID(t *testing.T) {
+	srv := newTestServer(t, testAdminToken)
+	ana := createUser(t, srv, "Ana", "ana@email.com")
+
+	body := `{"id":"` + ana.ID + `","name":"Outra","email":"outra@email.com"}`
+	_, data := do(t, srv, http.MethodPost, "/admin/import", body,
+		"Authorization", "Bearer "+testAdminToken, "Content-Type", "application/x-ndjson")
+	report := decode[ImportResponse](t, data)
+	if report.Failed != 1 || report.Errors[0].Status != http.StatusConflict || report.Errors[0].Line != 1 {
+		t.Errorf("relatorio: %+v, quer 409 na linha 1", report)
+	}
+
+	_, data = do(t, srv, http.MethodGet, "/users/"+ana.ID, "")
+	if got := decode[UserResponse](t, data); got != ana {
+		t.Errorf("usuario ativo = %+v, quer %+v", got, ana)
+	}
+}
+
+func TestImportRowErrors(t *testing.T) {
+	srv := newTestServer(t, testAdminToken)
+
+	body := strings.Join([]string{
+		`{"name":"Ana","email":"ana@email.com"}`,
+		`{nao e json`,
+		``,
+		`{"id":"abc","name":"Bia","email":"bia@email.com"}`,
+		`{"name":"Caio","email":"caio@email.com","created_at":"ontem"}`,
+		`{"name":"","email":"dani@email.com"}`,
+		`["lista"]`,
+		`   `,
+		`{"name":"Edu","email":"edu@email.com","version":9,"deleted_at":"2024-01-01T00:00:00Z"}`,
+	}, "\n")
+
+	resp, data := do(t, srv, http.MethodPost, "/admin/import", body,
+		"Authorization", "Bearer "+testAdminToken, "Content-Type", "application/x-ndjson")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("import: %d %s", resp.StatusCode, data)
+	}
+
+	report := decode[ImportResponse](t, data)
+	if report.Total != 7 || report.Created != 2 || report.Failed != 5 || report.Aborted {
+		t.Errorf("relatorio: %+v", report)
+	}
+	wantLines := []int{2, 4, 5, 6, 7}
+	for i, e := range report.Errors {
+		if i >= len(wantLines) || e.Line != wantLines[i] || e.Status != http.StatusBadRequest {
+			t.Errorf("erro %d: %+v, quer linha %d com 400", i, e, wantLines[min(i, len(wantLines)-1)])
+		}
+	}
+}
+
+func TestImportReadErrors(t *testing.T) {
+	auth := "Bearer " + testAdminToken
+	tests := []struct {
+		name        string
+		body        string
+		contentType string
+		want        int
+		wantReport  ImportResponse
+	}{
+		{
+			name:        "linha grande demais",
+			body:        `{"name":"Ana","email":"ana@email.com"}` + "\n" + `{"name":"` + strings.Repeat("a", importMaxLine) + `"}`,
+			contentType: "application/x-ndjson",
+			want:        http.StatusOK,
+			wantReport:  ImportResponse{Total: 1, Created: 1, Failed: 1, Aborted: true},
+		},
+		{name: "csv sem cabecalho", contentType: "text/csv", want: http.StatusBadRequest},
+		{name: "csv sem email", body: "id,name\n1,Ana\n", contentType: "text/csv", want: http.StatusBadRequest},
+		{name: "content-type", body: "{}", contentType: "application/json", want: http.StatusUnsupportedMediaType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newTestServer(t, testAdminToken)
+			resp, data := do(t, srv, http.MethodPost, "/admin/import", tt.body, "Authorization", auth, "Content-Type", tt.contentType)
+			if resp.StatusCode != tt.want {
+				t.Fatalf("status %d (%s), quer %d", resp.StatusCode, data, tt.want)
+			}
+			if tt.want != http.StatusOK {
+				return
+			}
+			report := decode[ImportResponse](t, data)
+			if report.Total != tt.wantReport.Total || report.Created != tt.wantReport.Created ||
+				report.Failed != tt.wantReport.Failed || report.Aborted != tt.wantReport.Aborted {
+				t.Errorf("relatorio: %+v, quer %+v", report, tt.wantReport)
+			}
+			if len(report.Errors) > 0 && (report.Errors[0].Line != 2 || report.Errors[0].Status != http.StatusRequestEntityTooLarge) {
+				t.Errorf("erro: %+v, quer 413 na linha 2", report.Errors[0])
+			}
+		})
+	}
+}
+
+// chunkRecorder anota o tamanho de cada chamada Import recebida pelo service.
+type chunkRecorder struct {
+	service.UserService
+
+	mu     sync.Mutex
+	chunks []int
+}
+
+func (s *chunkRecorder) Import(ctx context.Context, inputs []model.ImportUserInput) ([]model.BatchResult, error) {
+	s.mu.Lock()
+	s.chunks = append(s.chunks, len(inputs))
+	s.mu.Unlock()
+	return s.UserService.Import(ctx, inputs)
+}
+
+// TestImportChunks importa 250 linhas: elas vao ao service em lotes de
+// importChunkSize, e um erro de gravacao em um lote posterior ainda aponta a linha
+// certa do arquivo.
+func TestImportChunks(t *testing.T) {
+	svc := &chunkRecorder{UserService: service.NewUserService(repository.NewMemoryUserRepository())}
+	mux := http.NewServeMux()
+	NewUserHandler(svc).RegisterAdminRoutes(mux, testAdminToken)
+	srv := httptest.NewServer(TenantMiddleware(requestctx.DefaultTenant)(mux))
+	t.Cleanup(srv.Close)
+
+	lines := make([]string, 250)
+	for i := range lines {
+		lines[i] = fmt.Sprintf(`{"name":"Usuario %d","email":"usuario%d@email.com"}`, i+1, i+1)
+	}
+	lines[149] = `{"name":"Repetido","email":"usuario1@email.com"}` // linha 150, segundo lote
+
+	resp, data := do(t, srv, http.MethodPost, "/admin/import", strings.Join(lines, "\n"),
+		"Authorization", "Bearer "+testAdminToken, "Content-Type", "application/x-ndjson")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("import: %d %s", resp.StatusCode, data)
+	}
+
+	report := decode[ImportResponse](t, data)
+	if report.Total != 250 || report.Created != 249 || report.Failed != 1 {
+		t.Errorf("relatorio: %+v", report)
+	}
+	if len(report.Errors) != 1 || report.Errors[0].Line != 150 || report.Errors[0].Status != http.StatusConflict {
+		t.Errorf("erros: %+v, quer 409 na linha 150", report.Errors)
+	}
+	if fmt.Sprint(svc.chunks) != "[100 100 50]" {
+		t.Errorf("lotes = %v, quer [100 100 50]", svc.chunks)
+	}
+}
@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/dowglassantana/golang-with-dynamodb/internal/model"
+)
+
+// Endpoints em lote. A resposta e sempre 200 com um resultado por item: um item
+// invalido ou duplicado nao impede que os demais sejam processados.
+
+func (h *UserHandler) BatchCreate(w http.ResponseWriter, r *http.Request) {
+	var input model.BatchCreateInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "corpo da requisicao invalido"})
+		return
+	}
+
+	results, err := h.service.BatchCreate(r.Context(), input.Users)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, toBatchResponse(results, http.StatusCreated))
+}
+
+func (h *UserHandler) BatchGet(w http.ResponseWriter, r *http.Request) {
+	var input model.BatchIDsInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "corpo da requisicao invalido"})
+		return
+	}
+
+	results, err := h.service.BatchGet(r.Context(), input.IDs)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, toBatchResponse(results, http.StatusOK))
+}
+
+func (h *UserHandler) BatchDelete(w http.ResponseWriter, r *http.Request) {
+	var input model.BatchIDsInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "corpo da requisicao invalido"})
+		return
+	}
+
+	results, err := h.service.BatchDelete(r.Context(), input.IDs)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, toBatchResponse(results, http.StatusNoContent))
+}
@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/dowglassantana/golang-with-dynamodb/internal/service"
+)
+
+// errorStatus traduz os erros do service (e a taxonomia do repository, que o service
+// reexporta) para o status HTTP correspondente.
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrInvalidInput),
+		errors.Is(err, service.ErrInvalidID),
+		errors.Is(err, service.ErrInvalidCreatedAt),
+		errors.Is(err, service.ErrInvalidCursor),
+		errors.Is(err, service.ErrValidation):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrUserNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrEmailTaken),
+		errors.Is(err, service.ErrIDTaken),
+		errors.Is(err, service.ErrConditionFailed):
+		return http.StatusConflict
+	case errors.Is(err, service.ErrStaleVersion):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, service.ErrBatchTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, service.ErrThrottled):
+		return http.StatusTooManyRequests
+	case errors.Is(err, service.ErrTransactionConflict),
+		errors.Is(err, service.ErrUnprocessed):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// writeError responde com o status de errorStatus. Para 429 e 503 a falha e
+// passageira, entao o header Retry-After sugere ao cliente tentar de novo em 1 segundo.
+func writeError(w http.ResponseWriter, err error) {
+	status := errorStatus(err)
+	if status == http.StatusTooManyRequests || status == http.StatusServiceUnavailable {
+		w.Header().Set("Retry-After", "1")
+	}
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var (
+	errInvalidIfMatch = errors.New("header If-Match invalido")
+	errWeakIfMatch    = errors.New("If-Match exige comparacao forte: ETag fraca (W/) nunca corresponde")
+)
+
+// ETag e If-Match implementam o controle de concorrencia otimista no HTTP.
+//
+// GET /users/{id} devolve a versao do usuario no header ETag (ex: "3").
+// O cliente reenvia esse valor em If-Match no PUT; se o usuario mudou nesse
+// meio tempo, a API responde 412 Precondition Failed em vez de sobrescrever.
+
+func setETag(w http.ResponseWriter, version int64) {
+	w.Header().Set("ETag", strconv.Quote(strconv.FormatInt(version, 10)))
+}
+
+// parseIfMatch le o header If-Match. Retorna nil quando o header esta ausente
+// ou e "*" (qualquer versao serve).
+//
+// If-Match usa comparacao forte (RFC 7232, secao 3.1): uma ETag fraca (W/"3") nunca
+// corresponde, entao ela devolve errWeakIfMatch, que vira 412.
+func parseIfMatch(r *http.Request) (*int64, error) {
+	raw := strings.TrimSpace(r.Header.Get("If-Match"))
+	if raw == "" || raw == "*" {
+		return nil, nil
+	}
+
+	if strings.HasPrefix(raw, "W/") {
+		return nil, errWeakIfMatch
+	}
+	unquoted, err := strconv.Unquote(raw)
+	if err != nil {
+		return nil, errInvalidIfMatch
+	}
+
+	version, err := strconv.ParseInt(unquoted, 10, 64)
+	if err != nil || version < 0 {
+		return nil, errInvalidIfMatch
+	}
+	return &version, nil
+}
+
+// writeIfMatchError responde ao erro de parseIfMatch: 412 para uma ETag fraca (a
+// precondicao simplesmente falhou) e 400 para um header mal formado.
+func writeIfMatchError(w http.ResponseWriter, err error) {
+	status := http.StatusBadRequest
+	if errors.Is(err, errWeakIfMatch) {
+		status = http.StatusPreconditionFailed
+	}
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseIfMatch(t *testing.T) {
+	version := func(v int64) *int64 { return &v }
+
+	tests := []struct {
+		header  string
+		want    *int64
+		wantErr error
+	}{
+		{"", nil, nil},
+		{"*", nil, nil},
+		{`"3"`, version(3), nil},
+		{` "0" `, version(0), nil},
+		{`W/"3"`, nil, errWeakIfMatch},
+		{`3`, nil, errInvalidIfMatch},
+		{`"abc"`, nil, errInvalidIfMatch},
+		{`"-1"`, nil, errInvalidIfMatch},
+		{`"3", "4"`, nil, errInvalidIfMatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPut, "/users/1", nil)
+			if tt.header != "" {
+				r.Header.Set("If-Match", tt.header)
+			}
+
+			got, err := parseIfMatch(r)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("erro = %v, quer %v", err, tt.wantErr)
+			}
+			switch {
+			case tt.want == nil && got != nil:
+				t.Errorf("versao = %d, quer nil", *got)
+			case tt.want != nil && (got == nil || *got != *tt.want):
+				t.Errorf("versao = %v, quer %d", got, *tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteIfMatchError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{errWeakIfMatch, http.StatusPreconditionFailed},
+		{errInvalidIfMatch, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		writeIfMatchError(rec, tt.err)
+		if rec.Code != tt.want {
+			t.Errorf("%v: status %d, quer %d", tt.err, rec.Code, tt.want)
+		}
+	}
+}
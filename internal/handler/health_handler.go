@@ -0,0 +1,148 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Tempos padrao da verificacao de prontidao.
+const (
+	// defaultReadinessTTL e por quanto tempo o resultado do DescribeTable e reaproveitado:
+	// varios load balancers e o ECS consultando /readyz nao viram uma chamada cada.
+	defaultReadinessTTL = 5 * time.Second
+	// readinessTimeout limita cada DescribeTable. Acima disso a tabela conta como
+	// inacessivel — o health check do ALB desiste em 5 segundos por padrao.
+	readinessTimeout = 2 * time.Second
+)
+
+// TableCheck devolve o status da tabela (ex: DynamoUserRepository.TableStatus).
+type TableCheck func(ctx context.Context) (string, error)
+
+// HealthHandler atende as verificacoes de saude da API:
+//
+//	GET /healthz   liveness: o processo esta de pe e atendendo HTTP (sempre 200)
+//	GET /readyz    readiness: a API consegue chegar ao DynamoDB (200 ou 503)
+//
+// O liveness nao olha o DynamoDB de proposito: se a tabela ficar inacessivel, reiniciar
+// o container nao resolve, so tirar a task do load balancer (o que o readiness faz).
+type HealthHandler struct {
+	table string
+	check TableCheck
+	ttl   time.Duration
+
+	draining atomic.Bool
+
+	mu        sync.Mutex
+	last      readiness
+	checkedAt time.Time
+}
+
+// readiness e o corpo de /readyz.
+type readiness struct {
+	Status      string  `json:"status"`
+	Table       string  `json:"table,omitempty"`
+	TableStatus string  `json:"table_status,omitempty"`
+	LatencyMS   float64 `json:"latency_ms,omitempty"`
+	CheckedAt   string  `json:"checked_at,omitempty"`
+	Cached      bool    `json:"cached,omitempty"`
+	Error       string  `json:"error,omitempty"`
+}
+
+// Valores de readiness.Status.
+const (
+	readinessOK          = "ok"
+	readinessUnavailable = "unavailable"
+	readinessDraining    = "draining"
+)
+
+// NewHealthHandler cria o handler das verificacoes de saude. check consulta a tabela
+// table; com check nil (ENV=memory) a API esta sempre pronta. ttl <= 0 usa o padrao
+// de 5 segundos.
+func NewHealthHandler(table string, check TableCheck, ttl time.Duration) *HealthHandler {
+	if ttl <= 0 {
+		ttl = defaultReadinessTTL
+	}
+	return &HealthHandler{table: table, check: check, ttl: ttl}
+}
+
+func (h *HealthHandler) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /healthz", h.Liveness)
+	mux.HandleFunc("GET /readyz", h.Readiness)
+}
+
+// Drain faz o /readyz passar a responder 503, sem volta. E chamado no inicio do
+// graceful shutdown, antes do server.Shutdown: o load balancer ve a task falhar no
+// health check e para de mandar requisicoes enquanto ela ainda atende as que chegam.
+func (h *HealthHandler) Drain() {
+	h.draining.Store(true)
+}
+
+func (h *HealthHandler) Liveness(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": readinessOK})
+}
+
+// Readiness responde 200 quando o ultimo DescribeTable (de no maximo ttl atras)
+// encontrou a tabela ACTIVE ou UPDATING — uma tabela criando um GSI continua aceitando
+// leituras e escritas. Qualquer outra situacao responde 503.
+func (h *HealthHandler) Readiness(w http.ResponseWriter, r *http.Request) {
+	if h.draining.Load() {
+		writeJSON(w, http.StatusServiceUnavailable, readiness{Status: readinessDraining})
+		return
+	}
+
+	result := h.readiness(r.Context())
+	status := http.StatusOK
+	if result.Status != readinessOK {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, result)
+}
+
+// readiness devolve o resultado em cache ou, se ele expirou, consulta a tabela. O
+// mutex faz as consultas simultaneas esperarem a mesma chamada.
+func (h *HealthHandler) readiness(ctx context.Context) readiness {
+	if h.check == nil {
+		return readiness{Status: readinessOK}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !h.checkedAt.IsZero() && time.Since(h.checkedAt) < h.ttl {
+		cached := h.last
+		cached.Cached = true
+		return cached
+	}
+
+	// O resultado vale para as proximas consultas, entao nao depende de quem perguntou
+	// primeiro desistir da requisicao.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), readinessTimeout)
+	defer cancel()
+
+	start := time.Now()
+	tableStatus, err := h.check(ctx)
+	result := readiness{
+		Status:      readinessOK,
+		Table:       h.table,
+		TableStatus: tableStatus,
+		LatencyMS:   float64(time.Since(start).Microseconds()) / 1000,
+		CheckedAt:   start.UTC().Format(time.RFC3339),
+	}
+	switch {
+	case err != nil:
+		// O detalhe do erro fica no log (DynamoUserRepository.TableStatus); o /readyz
+		// e publico atras do load balancer.
+		result.Status = readinessUnavailable
+		result.Error = "tabela inacessivel"
+	case tableStatus != "ACTIVE" && tableStatus != "UPDATING":
+		result.Status = readinessUnavailable
+		result.Error = "tabela nao esta ativa"
+	}
+
+	h.last = result
+	h.checkedAt = start
+	return result
+}
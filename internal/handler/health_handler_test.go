@@ -0,0 +1,152 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newHealthServer sobe o HealthHandler com um check que devolve status e err e conta
+// as chamadas.
+func newHealthServer(t *testing.T, status string, err error, ttl time.Duration) (*httptest.Server, *HealthHandler, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	h := NewHealthHandler("Users", func(ctx context.Context) (string, error) {
+		calls.Add(1)
+		return status, err
+	}, ttl)
+
+	mux := http.NewServeMux()
+	h.RegisterRoutes(mux)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv, h, &calls
+}
+
+func TestReadiness(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     string
+		err        error
+		want       int
+		wantStatus string
+	}{
+		{"ativa", "ACTIVE", nil, http.StatusOK, readinessOK},
+		{"criando GSI", "UPDATING", nil, http.StatusOK, readinessOK},
+		{"criando tabela", "CREATING", nil, http.StatusServiceUnavailable, readinessUnavailable},
+		{"erro", "", errors.New("ResourceNotFoundException: tabela Users"), http.StatusServiceUnavailable, readinessUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, _, _ := newHealthServer(t, tt.status, tt.err, 0)
+			resp, data := do(t, srv, http.MethodGet, "/readyz", "")
+			got := decode[readiness](t, data)
+			if resp.StatusCode != tt.want || got.Status != tt.wantStatus {
+				t.Errorf("readyz = %d %s, quer %d %s", resp.StatusCode, data, tt.want, tt.wantStatus)
+			}
+			// O detalhe do erro fica no log, nunca na resposta publica.
+			if strings.Contains(string(data), "ResourceNotFound") {
+				t.Errorf("readyz expos o erro do DynamoDB: %s", data)
+			}
+		})
+	}
+}
+
+func TestReadinessWithoutCheck(t *testing.T) {
+	mux := http.NewServeMux()
+	NewHealthHandler("", nil, 0).RegisterRoutes(mux)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	if resp, data := do(t, srv, http.MethodGet, "/readyz", ""); resp.StatusCode != http.StatusOK {
+		t.Errorf("readyz sem check (ENV=memory) = %d %s, quer 200", resp.StatusCode, data)
+	}
+}
+
+func TestReadinessCache(t *testing.T) {
+	srv, _, calls := newHealthServer(t, "ACTIVE", nil, time.Minute)
+
+	_, data := do(t, srv, http.MethodGet, "/readyz", "")
+	if decode[readiness](t, data).Cached {
+		t.Error("primeira consulta marcada como cache")
+	}
+	_, data = do(t, srv, http.MethodGet, "/readyz", "")
+	if !decode[readiness](t, data).Cached {
+		t.Error("segunda consulta dentro do ttl deveria vir do cache")
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("%d chamadas ao DescribeTable, quer 1", n)
+	}
+
+	// Com ttl minimo o resultado expira logo e cada consulta chega a tabela.
+	srv, _, calls = newHealthServer(t, "ACTIVE", nil, time.Nanosecond)
+	do(t, srv, http.MethodGet, "/readyz", "")
+	do(t, srv, http.MethodGet, "/readyz", "")
+	if n := calls.Load(); n != 2 {
+		t.Errorf("%d chamadas com o cache expirado, quer 2", n)
+	}
+}
+
+// TestReadinessConcurrent confere que consultas simultaneas esperam o mesmo
+// DescribeTable em vez de fazer uma chamada cada.
+func TestReadinessConcurrent(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	h := NewHealthHandler("Users", func(ctx context.Context) (string, error) {
+		calls.Add(1)
+		<-release
+		return "ACTIVE", nil
+	}, time.Minute)
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if r := h.readiness(context.Background()); r.Status != readinessOK {
+				t.Errorf("readiness = %+v", r)
+			}
+		}()
+	}
+	for calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+
+	if n := calls.Load(); n != 1 {
+		t.Errorf("%d chamadas ao DescribeTable, quer 1", n)
+	}
+}
+
+func TestDrain(t *testing.T) {
+	srv, h, calls := newHealthServer(t, "ACTIVE", nil, time.Nanosecond)
+
+	if resp, data := do(t, srv, http.MethodGet, "/readyz", ""); resp.StatusCode != http.StatusOK {
+		t.Fatalf("readyz antes do Drain = %d %s", resp.StatusCode, data)
+	}
+
+	h.Drain()
+	before := calls.Load()
+	for range 2 {
+		resp, data := do(t, srv, http.MethodGet, "/readyz", "")
+		if got := decode[readiness](t, data); resp.StatusCode != http.StatusServiceUnavailable || got.Status != readinessDraining {
+			t.Errorf("readyz depois do Drain = %d %s, quer 503 draining", resp.StatusCode, data)
+		}
+	}
+	if n := calls.Load(); n != before {
+		t.Errorf("readyz consultou a tabela %d vezes depois do Drain", n-before)
+	}
+
+	// O liveness continua 200: o processo esta de pe e terminando as requisicoes.
+	resp, data := do(t, srv, http.MethodGet, "/healthz", "")
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(data), readinessOK) {
+		t.Errorf("healthz depois do Drain = %d %s, quer 200", resp.StatusCode, data)
+	}
+}
@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/dowglassantana/golang-with-dynamodb/internal/logging"
+	"github.com/dowglassantana/golang-with-dynamodb/internal/requestctx"
+	"github.com/google/uuid"
+)
+
+// maxRequestIDLength limita o tamanho do X-Request-ID aceito do cliente.
+const maxRequestIDLength = 128
+
+// RequestIDMiddleware identifica cada requisicao. Um X-Request-ID valido recebido do
+// cliente (ou do load balancer) e mantido; sem ele, ou com um valor invalido, um UUID
+// novo e gerado. O id volta no header X-Request-ID da resposta e vai para o contexto,
+// junto com um logger derivado de logger que ja traz o atributo request_id — toda
+// linha escrita com logging.FromContext durante a requisicao carrega o mesmo id.
+func RequestIDMiddleware(logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get("X-Request-ID")
+			if !validRequestID(id) {
+				id = uuid.NewString()
+			}
+			w.Header().Set("X-Request-ID", id)
+
+			ctx := requestctx.WithRequestID(r.Context(), id)
+			ctx = logging.NewContext(ctx, logger.With(slog.String("request_id", id)))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// validRequestID aceita so caracteres imprimiveis seguros: o id e ecoado no header da
+// resposta e gravado nos logs, entao nao pode carregar quebras de linha nem aspas.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9',
+			c == '-', c == '_', c == '.', c == ':', c == '/', c == '+', c == '=':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// AccessLogMiddleware escreve uma linha por requisicao atendida, com o logger do
+// contexto (e, portanto, o request_id):
+//
+//	{"level":"INFO","msg":"requisicao","request_id":"...","method":"GET","path":"/users/123",
+//	 "status":200,"bytes":187,"duration_ms":3.2,"remote_addr":"...","user_agent":"..."}
+//
+// Respostas 5xx saem com nivel ERROR. A query string fica de fora: ela pode trazer
+// emails (GET /users?email=...).
+func AccessLogMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &statusRecorder{ResponseWriter: w}
+		start := time.Now()
+
+		// Como nas metricas, o defer tambem registra o export interrompido no meio.
+		defer func() {
+			status := rec.statusCode()
+			level := slog.LevelInfo
+			if status >= http.StatusInternalServerError {
+				level = slog.LevelError
+			}
+			logging.FromContext(r.Context()).LogAttrs(r.Context(), level, "requisicao",
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.Int("status", status),
+				slog.Int64("bytes", rec.bytes),
+				slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+				slog.String("remote_addr", r.RemoteAddr),
+				slog.String("user_agent", r.UserAgent()),
+			)
+		}()
+
+		next.ServeHTTP(rec, r)
+	})
+}
@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// unmatchedRoute e o label das requisicoes que nao casaram com nenhuma rota (404 e
+// 405 do mux). Usar o path cru criaria uma serie por URL digitada.
+const unmatchedRoute = "unmatched"
+
+// MetricsMiddleware mede cada requisicao HTTP e registra as metricas em reg:
+//
+//	http_requests_total{route, status}               requisicoes atendidas
+//	http_request_duration_seconds{route, status}     latencia (histograma)
+//	http_requests_in_flight                          requisicoes em andamento
+//
+// O label route e o padrao registrado no mux ("GET /users/{id}"), e nao o path da
+// requisicao: assim todos os ids caem na mesma serie. O padrao e descoberto com
+// routes.Handler antes de atender, porque o middleware fica por fora do
+// TenantMiddleware — uma requisicao recusada com 400 por falta de tenant tambem
+// aparece nas metricas, com a rota que ela pediu.
+func MetricsMiddleware(reg prometheus.Registerer, routes *http.ServeMux) func(http.Handler) http.Handler {
+	factory := promauto.With(reg)
+	requests := factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "Requisicoes HTTP atendidas, por rota e status.",
+	}, []string{"route", "status"})
+	duration := factory.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Latencia das requisicoes HTTP, por rota e status.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "status"})
+	inFlight := factory.NewGauge(prometheus.GaugeOpts{
+		Name: "http_requests_in_flight",
+		Help: "Requisicoes HTTP em andamento.",
+	})
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, route := routes.Handler(r)
+			if route == "" {
+				route = unmatchedRoute
+			}
+
+			inFlight.Inc()
+			rec := &statusRecorder{ResponseWriter: w}
+			start := time.Now()
+
+			// O defer tambem mede o export interrompido no meio (panic com
+			// http.ErrAbortHandler), com o status que ja tinha sido enviado.
+			defer func() {
+				inFlight.Dec()
+				status := strconv.Itoa(rec.statusCode())
+				requests.WithLabelValues(route, status).Inc()
+				duration.WithLabelValues(route, status).Observe(time.Since(start).Seconds())
+			}()
+
+			next.ServeHTTP(rec, r)
+		})
+	}
+}
+
+// statusRecorder guarda o status e o numero de bytes enviados pelo handler. Unwrap deixa o
+// http.ResponseController (usado pelo export para o Flush) chegar ao
+// ResponseWriter original.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += int64(n)
+	return n, err
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// statusCode e o status enviado; 200 quando o handler nao escreveu nada.
+func (r *statusRecorder) statusCode() int {
+	if r.status == 0 {
+		return http.StatusOK
+	}
+	return r.status
+}
@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/dowglassantana/golang-with-dynamodb/internal/logging"
+	"github.com/dowglassantana/golang-with-dynamodb/internal/requestctx"
+)
+
+// maxActorLength limita o tamanho do ator gravado no historico.
+const maxActorLength = 128
+
+// ActorMiddleware le o header X-Actor e coloca o ator no contexto da requisicao.
+// O repository grava esse valor em cada evento de historico; sem o header, o
+// evento registra requestctx.AnonymousActor.
+func ActorMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actor := strings.TrimSpace(r.Header.Get("X-Actor"))
+		if len(actor) > maxActorLength {
+			actor = actor[:maxActorLength]
+		}
+		if actor != "" {
+			r = r.WithContext(requestctx.WithActor(r.Context(), actor))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// TenantMiddleware le o header X-Tenant-ID e coloca o tenant no contexto da
+// requisicao. Todas as chaves do repository sao montadas a partir dele, entao uma
+// requisicao nunca le nem lista usuarios de outro tenant.
+//
+// Sem o header, a requisicao usa defaultTenant; com defaultTenant vazio o header e
+// obrigatorio. Um tenant invalido (veja requestctx.ValidTenant) responde 400.
+//
+// Quando a API tiver autenticacao, o tenant deve vir de uma claim do token ja
+// validado, e nao de um header que o cliente controla: o middleware de
+// autenticacao chama requestctx.WithTenant e substitui este.
+func TenantMiddleware(defaultTenant string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tenant := strings.TrimSpace(r.Header.Get("X-Tenant-ID"))
+			if tenant == "" {
+				tenant = defaultTenant
+			}
+			if tenant == "" {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "header X-Tenant-ID obrigatorio"})
+				return
+			}
+			if !requestctx.ValidTenant(tenant) {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "X-Tenant-ID invalido"})
+				return
+			}
+
+			// As linhas de log escritas daqui para dentro tambem trazem o tenant.
+			ctx := requestctx.WithTenant(r.Context(), tenant)
+			ctx = logging.NewContext(ctx, logging.FromContext(ctx).With(slog.String("tenant", tenant)))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
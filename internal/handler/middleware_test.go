@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dowglassantana/golang-with-dynamodb/internal/repository"
+	"github.com/dowglassantana/golang-with-dynamodb/internal/service"
+)
+
+func TestTenantHeader(t *testing.T) {
+	srv := newTestServer(t, "")
+	user := createUser(t, srv, "Ana", "ana@email.com", "X-Tenant-ID", "acme")
+
+	if resp, _ := do(t, srv, http.MethodGet, "/users/"+user.ID, "", "X-Tenant-ID", "globex"); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("GET em outro tenant: %d, quer 404", resp.StatusCode)
+	}
+	if resp, _ := do(t, srv, http.MethodGet, "/users/"+user.ID, "", "X-Tenant-ID", "acme"); resp.StatusCode != http.StatusOK {
+		t.Errorf("GET no mesmo tenant: %d, quer 200", resp.StatusCode)
+	}
+	// Sem o header, a requisicao cai no tenant padrao, que tambem nao ve acme.
+	if resp, _ := do(t, srv, http.MethodGet, "/users/"+user.ID, ""); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("GET no tenant padrao: %d, quer 404", resp.StatusCode)
+	}
+	if resp, _ := do(t, srv, http.MethodGet, "/users", "", "X-Tenant-ID", "com espaco"); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("tenant invalido: %d, quer 400", resp.StatusCode)
+	}
+}
+
+func TestTenantHeaderRequired(t *testing.T) {
+	h := NewUserHandler(service.NewUserService(repository.NewMemoryUserRepository()))
+	mux := http.NewServeMux()
+	h.RegisterRoutes(mux)
+	srv := httptest.NewServer(TenantMiddleware("")(mux))
+	t.Cleanup(srv.Close)
+
+	if resp, _ := do(t, srv, http.MethodGet, "/users", ""); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("sem X-Tenant-ID: %d, quer 400", resp.StatusCode)
+	}
+	if resp, data := do(t, srv, http.MethodGet, "/users", "", "X-Tenant-ID", "acme"); resp.StatusCode != http.StatusOK {
+		t.Errorf("com X-Tenant-ID: %d %s, quer 200", resp.StatusCode, data)
+	}
+}
+
+// TestTenantIsolation cria usuarios em dois tenants e confere que nenhuma rota de
+// leitura ou escrita de um tenant alcanca os usuarios do outro.
+func TestTenantIsolation(t *testing.T) {
+	srv := newTestServer(t, "")
+	acme := []string{"X-Tenant-ID", "acme"}
+	globex := []string{"X-Tenant-ID", "globex"}
+
+	ana := createUser(t, srv, "Ana", "ana@email.com", acme...)
+	createUser(t, srv, "Andre", "andre@email.com", acme...)
+	bia := createUser(t, srv, "Bia", "bia@email.com", globex...)
+	// O mesmo email pode existir em tenants diferentes.
+	createUser(t, srv, "Ana", "ana@email.com", globex...)
+
+	lists := []struct {
+		name string
+		path string
+	}{
+		{"listagem", "/users"},
+		{"prefixo do nome", "/users?name_prefix=an"},
+		{"periodo", "/users?created_after=2000-01-01"},
+		{"ordem", "/users?order=desc"},
+		{"com excluidos", "/users?include_deleted=true"},
+	}
+	for _, tt := range lists {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, data := do(t, srv, http.MethodGet, tt.path, "", globex...)
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("status %d (%s)", resp.StatusCode, data)
+			}
+			for _, u := range decode[UserListResponse](t, data).Users {
+				if u.ID == ana.ID || u.Email == "andre@email.com" {
+					t.Errorf("globex listou o usuario de acme %+v", u)
+				}
+			}
+		})
+	}
+
+	resp, data := do(t, srv, http.MethodGet, "/users:count", "", acme...)
+	if got := decode[map[string]int64](t, data)["count"]; resp.StatusCode != http.StatusOK || got != 2 {
+		t.Errorf("count em acme = %d (%d), quer 2", got, resp.StatusCode)
+	}
+	if _, data := do(t, srv, http.MethodGet, "/users?email=bia@email.com", "", acme...); len(decode[UserListResponse](t, data).Users) != 0 {
+		t.Errorf("acme encontrou o email de globex: %s", data)
+	}
+
+	// DELETE e idempotente e o historico de um id desconhecido e vazio: nos dois casos
+	// a resposta e a mesma de um id que nao existe em nenhum tenant.
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		body    string
+		headers []string
+		want    int
+	}{
+		{"GET", http.MethodGet, "/users/" + bia.ID, "", nil, http.StatusNotFound},
+		{"PUT", http.MethodPut, "/users/" + bia.ID, `{"name":"Outra","email":"bia@email.com"}`, nil, http.StatusNotFound},
+		{"PATCH", http.MethodPatch, "/users/" + bia.ID, `{"name":"Outra"}`, []string{"Content-Type", "application/merge-patch+json"}, http.StatusNotFound},
+		{"restore", http.MethodPost, "/users/" + bia.ID + "/restore", "", nil, http.StatusNotFound},
+		{"DELETE", http.MethodDelete, "/users/" + bia.ID, "", nil, http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, data := do(t, srv, tt.method, tt.path, tt.body, append(tt.headers, acme...)...)
+			if resp.StatusCode != tt.want {
+				t.Errorf("status %d (%s), quer %d", resp.StatusCode, data, tt.want)
+			}
+		})
+	}
+
+	resp, data = do(t, srv, http.MethodGet, "/users/"+bia.ID+"/history", "", acme...)
+	if resp.StatusCode != http.StatusOK || len(decode[UserHistoryResponse](t, data).Events) != 0 {
+		t.Errorf("historico em acme = %d %s, quer vazio", resp.StatusCode, data)
+	}
+	resp, data = do(t, srv, http.MethodPost, "/users:batchGet", `{"ids":["`+bia.ID+`"]}`, acme...)
+	if got := decode[BatchResponse](t, data); resp.StatusCode != http.StatusOK || len(got.Results) != 1 || got.Results[0].User != nil {
+		t.Errorf("batchGet em acme = %d %s, quer o usuario de globex ausente", resp.StatusCode, data)
+	}
+
+	// Nada do que acme tentou alterou o usuario de globex.
+	resp, data = do(t, srv, http.MethodGet, "/users/"+bia.ID, "", globex...)
+	if got := decode[UserResponse](t, data); resp.StatusCode != http.StatusOK || got != bia {
+		t.Errorf("usuario de globex = %+v (%d), quer %+v", got, resp.StatusCode, bia)
+	}
+}
@@ -9,6 +9,8 @@ type UserResponse struct {
 	Name      string `json:"name"`
 	Email     string `json:"email"`
 	CreatedAt string `json:"created_at"`
+	Version   int64  `json:"version"`
+	DeletedAt string `json:"deleted_at,omitempty"`
 }
 
 func toUserResponse(u model.User) UserResponse {
@@ -17,6 +19,8 @@ func toUserResponse(u model.User) UserResponse {
 		Name:      u.Name,
 		Email:     u.Email,
 		CreatedAt: u.CreatedAt,
+		Version:   u.Version,
+		DeletedAt: u.DeletedAt,
 	}
 }
 
@@ -27,3 +31,127 @@ func toUserResponseList(users []model.User) []UserResponse {
 	}
 	return res
 }
+
+// UserListResponse e o DTO de saida da listagem paginada.
+// NextCursor deve ser enviado no parametro ?cursor= para buscar a proxima pagina.
+type UserListResponse struct {
+	Users      []UserResponse `json:"users"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
+
+func toUserListResponse(page model.UserPage) UserListResponse {
+	return UserListResponse{
+		Users:      toUserResponseList(page.Users),
+		NextCursor: page.NextCursor,
+	}
+}
+
+// BatchItemResponse e o resultado de um item em uma operacao em lote.
+// Index e a posicao do item no corpo da requisicao; Status segue os codigos HTTP
+// que o endpoint unitario equivalente retornaria.
+type BatchItemResponse struct {
+	Index  int           `json:"index"`
+	ID     string        `json:"id,omitempty"`
+	Status int           `json:"status"`
+	User   *UserResponse `json:"user,omitempty"`
+	Error  string        `json:"error,omitempty"`
+}
+
+// BatchResponse e o DTO de saida dos endpoints em lote.
+type BatchResponse struct {
+	Results []BatchItemResponse `json:"results"`
+}
+
+func toBatchResponse(results []model.BatchResult, successStatus int) BatchResponse {
+	res := BatchResponse{Results: make([]BatchItemResponse, len(results))}
+	for i, r := range results {
+		item := BatchItemResponse{Index: i, ID: r.ID, Status: successStatus}
+		if r.Err != nil {
+			item.Status = errorStatus(r.Err)
+			item.Error = r.Err.Error()
+		}
+		if r.User != nil {
+			u := toUserResponse(*r.User)
+			item.User = &u
+		}
+		res.Results[i] = item
+	}
+	return res
+}
+
+// ImportResponse e o relatorio de POST /admin/import. Total conta as linhas lidas
+// (linhas em branco do ndjson nao contam); Aborted indica que a leitura parou antes
+// do fim do arquivo, na linha do ultimo erro.
+type ImportResponse struct {
+	Total   int                   `json:"total"`
+	Created int                   `json:"created"`
+	Failed  int                   `json:"failed"`
+	Aborted bool                  `json:"aborted,omitempty"`
+	Errors  []ImportErrorResponse `json:"errors"`
+}
+
+// ImportErrorResponse e uma linha do upload que nao foi importada. Status segue o
+// codigo HTTP que POST /users retornaria para ela.
+type ImportErrorResponse struct {
+	Line   int    `json:"line"`
+	Status int    `json:"status"`
+	Error  string `json:"error"`
+}
+
+func (r *ImportResponse) addError(line int, err error) {
+	r.Errors = append(r.Errors, ImportErrorResponse{Line: line, Status: errorStatus(err), Error: err.Error()})
+}
+
+// UserEventResponse e o DTO de saida de um evento do historico.
+type UserEventResponse struct {
+	ID     string                `json:"id"`
+	Action string                `json:"action"`
+	Actor  string                `json:"actor"`
+	At     string                `json:"at"`
+	Before *UserSnapshotResponse `json:"before,omitempty"`
+	After  *UserSnapshotResponse `json:"after,omitempty"`
+}
+
+// UserSnapshotResponse e o estado de um usuario registrado em um evento.
+type UserSnapshotResponse struct {
+	Name      string `json:"name"`
+	Email     string `json:"email"`
+	Version   int64  `json:"version"`
+	DeletedAt string `json:"deleted_at,omitempty"`
+}
+
+// UserHistoryResponse e o DTO de saida de GET /users/{id}/history.
+type UserHistoryResponse struct {
+	Events     []UserEventResponse `json:"events"`
+	NextCursor string              `json:"next_cursor,omitempty"`
+}
+
+func toUserHistoryResponse(page model.UserEventPage) UserHistoryResponse {
+	res := UserHistoryResponse{
+		Events:     make([]UserEventResponse, len(page.Events)),
+		NextCursor: page.NextCursor,
+	}
+	for i, e := range page.Events {
+		res.Events[i] = UserEventResponse{
+			ID:     e.ID,
+			Action: e.Action,
+			Actor:  e.Actor,
+			At:     e.At,
+			Before: toUserSnapshotResponse(e.Before),
+			After:  toUserSnapshotResponse(e.After),
+		}
+	}
+	return res
+}
+
+func toUserSnapshotResponse(s *model.UserSnapshot) *UserSnapshotResponse {
+	if s == nil {
+		return nil
+	}
+	return &UserSnapshotResponse{
+		Name:      s.Name,
+		Email:     s.Email,
+		Version:   s.Version,
+		DeletedAt: s.DeletedAt,
+	}
+}
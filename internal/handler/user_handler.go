@@ -1,12 +1,16 @@
 package handler
 
 import (
+	"bytes"
 	"embed"
 	"encoding/json"
 	"errors"
-	"log"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/dowglassantana/golang-with-dynamodb/internal/logging"
 	"github.com/dowglassantana/golang-with-dynamodb/internal/model"
 	"github.com/dowglassantana/golang-with-dynamodb/internal/service"
 )
@@ -26,7 +30,7 @@ func (h *UserHandler) RegisterRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("GET /", func(w http.ResponseWriter, r *http.Request) {
 		data, err := indexHTML.ReadFile("static/index.html")
 		if err != nil {
-			log.Printf("erro ao ler index.html embutido: %v", err)
+			logging.FromContext(r.Context()).ErrorContext(r.Context(), "erro ao ler index.html embutido", "error", err)
 			http.Error(w, "erro interno", http.StatusInternalServerError)
 			return
 		}
@@ -35,9 +39,25 @@ func (h *UserHandler) RegisterRoutes(mux *http.ServeMux) {
 	})
 	mux.HandleFunc("POST /users", h.Create)
 	mux.HandleFunc("GET /users", h.GetAll)
+	mux.HandleFunc("GET /users:count", h.Count)
 	mux.HandleFunc("GET /users/{id}", h.GetByID)
 	mux.HandleFunc("PUT /users/{id}", h.Update)
+	mux.HandleFunc("PATCH /users/{id}", h.Patch)
 	mux.HandleFunc("DELETE /users/{id}", h.Delete)
+	mux.HandleFunc("POST /users/{id}/restore", h.Restore)
+	mux.HandleFunc("GET /users/{id}/history", h.History)
+	mux.HandleFunc("POST /users:batchCreate", h.BatchCreate)
+	mux.HandleFunc("POST /users:batchGet", h.BatchGet)
+	mux.HandleFunc("POST /users:batchDelete", h.BatchDelete)
+}
+
+// RegisterAdminRoutes registra o export e o import, protegidos por adminToken (veja
+// AdminAuthMiddleware). As rotas sao registradas mesmo com o token vazio: sem elas, o
+// "GET /" da pagina inicial responderia o /admin/export.
+func (h *UserHandler) RegisterAdminRoutes(mux *http.ServeMux, adminToken string) {
+	auth := AdminAuthMiddleware(adminToken)
+	mux.Handle("GET /admin/export", auth(http.HandlerFunc(h.Export)))
+	mux.Handle("POST /admin/import", auth(http.HandlerFunc(h.Import)))
 }
 
 func (h *UserHandler) Create(w http.ResponseWriter, r *http.Request) {
@@ -49,14 +69,11 @@ func (h *UserHandler) Create(w http.ResponseWriter, r *http.Request) {
 
 	user, err := h.service.Create(r.Context(), input)
 	if err != nil {
-		if errors.Is(err, service.ErrInvalidInput) {
-			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
-			return
-		}
-		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		writeError(w, err)
 		return
 	}
 
+	setETag(w, user.Version)
 	writeJSON(w, http.StatusCreated, toUserResponse(*user))
 }
 
@@ -65,25 +82,107 @@ func (h *UserHandler) GetByID(w http.ResponseWriter, r *http.Request) {
 
 	user, err := h.service.GetByID(r.Context(), id)
 	if err != nil {
-		if errors.Is(err, service.ErrUserNotFound) {
-			writeJSON(w, http.StatusNotFound, map[string]string{"error": "usuario nao encontrado"})
-			return
-		}
-		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		writeError(w, err)
 		return
 	}
 
+	setETag(w, user.Version)
 	writeJSON(w, http.StatusOK, toUserResponse(*user))
 }
 
 func (h *UserHandler) GetAll(w http.ResponseWriter, r *http.Request) {
-	users, err := h.service.GetAll(r.Context())
+	query := r.URL.Query()
+
+	if query.Has("email") {
+		h.getByEmail(w, r, query.Get("email"))
+		return
+	}
+	input := model.ListUsersInput{
+		Cursor:         query.Get("cursor"),
+		IncludeDeleted: query.Get("include_deleted") == "true",
+		NamePrefix:     query.Get("name_prefix"),
+		Order:          query.Get("order"),
+	}
+
+	if input.Order != "" && input.Order != model.OrderAsc && input.Order != model.OrderDesc {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "order invalido: use asc ou desc"})
+		return
+	}
+
+	var ok bool
+	if input.CreatedAfter, ok = parseCreatedAt(query.Get("created_after")); !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "created_after invalido"})
+		return
+	}
+	if input.CreatedBefore, ok = parseCreatedAt(query.Get("created_before")); !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "created_before invalido"})
+		return
+	}
+	if input.CreatedAfter != "" && input.CreatedBefore != "" && input.CreatedAfter > input.CreatedBefore {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "created_after maior que created_before"})
+		return
+	}
+
+	if raw := query.Get("limit"); raw != "" {
+		limit, err := strconv.ParseInt(raw, 10, 32)
+		if err != nil || limit <= 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "limit invalido"})
+			return
+		}
+		input.Limit = int32(limit)
+	}
+
+	page, err := h.service.GetAll(r.Context(), input)
 	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		writeError(w, err)
 		return
 	}
 
-	writeJSON(w, http.StatusOK, toUserResponseList(users))
+	writeJSON(w, http.StatusOK, toUserListResponse(*page))
+}
+
+// Count atende GET /users:count. Conta os usuarios do tenant; com
+// ?include_deleted=true, os excluidos tambem.
+func (h *UserHandler) Count(w http.ResponseWriter, r *http.Request) {
+	count, err := h.service.Count(r.Context(), r.URL.Query().Get("include_deleted") == "true")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]int64{"count": count})
+}
+
+// parseCreatedAt aceita um instante RFC3339 ("2024-05-01T12:00:00-03:00") ou uma data
+// ("2024-05-01", meia-noite UTC) e devolve o valor em RFC3339 UTC, o mesmo formato
+// gravado em created_at. Vazio significa filtro ausente.
+func parseCreatedAt(raw string) (string, bool) {
+	if raw == "" {
+		return "", true
+	}
+	t, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		if t, err = time.Parse(time.DateOnly, raw); err != nil {
+			return "", false
+		}
+	}
+	return t.UTC().Format(time.RFC3339), true
+}
+
+// getByEmail atende GET /users?email=. Responde no mesmo formato da listagem,
+// com zero ou um usuario, para que o cliente trate as duas buscas igualmente.
+func (h *UserHandler) getByEmail(w http.ResponseWriter, r *http.Request, email string) {
+	user, err := h.service.GetByEmail(r.Context(), email)
+	if err != nil {
+		if errors.Is(err, service.ErrUserNotFound) {
+			writeJSON(w, http.StatusOK, toUserListResponse(model.UserPage{}))
+			return
+		}
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, toUserListResponse(model.UserPage{Users: []model.User{*user}}))
 }
 
 func (h *UserHandler) Update(w http.ResponseWriter, r *http.Request) {
@@ -95,29 +194,123 @@ func (h *UserHandler) Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	expectedVersion, err := parseIfMatch(r)
+	if err != nil {
+		writeIfMatchError(w, err)
+		return
+	}
+	input.ExpectedVersion = expectedVersion
+
 	if err := h.service.Update(r.Context(), id, input); err != nil {
-		if errors.Is(err, service.ErrInvalidInput) {
-			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
-			return
-		}
-		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		writeError(w, err)
 		return
 	}
 
 	writeJSON(w, http.StatusOK, map[string]string{"message": "usuario atualizado com sucesso"})
 }
 
+// Patch aplica um JSON Merge Patch (RFC 7396): apenas os campos enviados mudam.
+//
+// O corpo precisa ser um objeto JSON. Aceitamos os Content-Types
+// application/merge-patch+json (o registrado pela RFC) e application/json.
+func (h *UserHandler) Patch(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	switch mediaType(r.Header.Get("Content-Type")) {
+	case "", "application/merge-patch+json", "application/json":
+	default:
+		writeJSON(w, http.StatusUnsupportedMediaType, map[string]string{"error": "use Content-Type application/merge-patch+json"})
+		return
+	}
+
+	var raw json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&raw); err != nil || !bytes.HasPrefix(bytes.TrimSpace(raw), []byte("{")) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "corpo da requisicao deve ser um objeto JSON"})
+		return
+	}
+
+	var input model.PatchUserInput
+	if err := json.Unmarshal(raw, &input); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "corpo da requisicao invalido"})
+		return
+	}
+
+	expectedVersion, err := parseIfMatch(r)
+	if err != nil {
+		writeIfMatchError(w, err)
+		return
+	}
+	input.ExpectedVersion = expectedVersion
+
+	user, err := h.service.Patch(r.Context(), id, input)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	setETag(w, user.Version)
+	writeJSON(w, http.StatusOK, toUserResponse(*user))
+}
+
+// mediaType extrai o tipo de um header Content-Type, sem parametros como charset.
+func mediaType(contentType string) string {
+	t, _, _ := strings.Cut(contentType, ";")
+	return strings.ToLower(strings.TrimSpace(t))
+}
+
 func (h *UserHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 
 	if err := h.service.Delete(r.Context(), id); err != nil {
-		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		writeError(w, err)
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// Restore desfaz um soft delete enquanto o usuario ainda esta na janela de retencao.
+func (h *UserHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	user, err := h.service.Restore(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, service.ErrUserNotFound) {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "usuario nao encontrado ou fora da janela de retencao"})
+			return
+		}
+		writeError(w, err)
+		return
+	}
+
+	setETag(w, user.Version)
+	writeJSON(w, http.StatusOK, toUserResponse(*user))
+}
+
+// History lista o historico de alteracoes do usuario, do evento mais recente ao mais antigo.
+func (h *UserHandler) History(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	query := r.URL.Query()
+	input := model.ListHistoryInput{Cursor: query.Get("cursor")}
+
+	if raw := query.Get("limit"); raw != "" {
+		limit, err := strconv.ParseInt(raw, 10, 32)
+		if err != nil || limit <= 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "limit invalido"})
+			return
+		}
+		input.Limit = int32(limit)
+	}
+
+	page, err := h.service.History(r.Context(), id, input)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, toUserHistoryResponse(*page))
+}
+
 func writeJSON(w http.ResponseWriter, status int, data any) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
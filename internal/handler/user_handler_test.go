@@ -0,0 +1,207 @@
+package handler
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dowglassantana/golang-with-dynamodb/internal/repository"
+	"github.com/dowglassantana/golang-with-dynamodb/internal/requestctx"
+	"github.com/dowglassantana/golang-with-dynamodb/internal/service"
+)
+
+// newTestServer monta a API como o cmd/api, sobre o MemoryUserRepository.
+func newTestServer(t *testing.T, adminToken string) *httptest.Server {
+	t.Helper()
+	h := NewUserHandler(service.NewUserService(repository.NewMemoryUserRepository()))
+
+	mux := http.NewServeMux()
+	h.RegisterRoutes(mux)
+	h.RegisterAdminRoutes(mux, adminToken)
+
+	srv := httptest.NewServer(TenantMiddleware(requestctx.DefaultTenant)(ActorMiddleware(mux)))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// do envia a requisicao e devolve a resposta com o corpo ja lido.
+func do(t *testing.T, srv *httptest.Server, method, path, body string, headers ...string) (*http.Response, []byte) {
+	t.Helper()
+	req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	if body != "" && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, data
+}
+
+func decode[T any](t *testing.T, data []byte) T {
+	t.Helper()
+	var v T
+	if err := json.Unmarshal(data, &v); err != nil {
+		t.Fatalf("resposta invalida %s: %v", data, err)
+	}
+	return v
+}
+
+func createUser(t *testing.T, srv *httptest.Server, name, email string, headers ...string) UserResponse {
+	t.Helper()
+	resp, data := do(t, srv, http.MethodPost, "/users", `{"name":"`+name+`","email":"`+email+`"}`, headers...)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("POST /users: %d %s", resp.StatusCode, data)
+	}
+	return decode[UserResponse](t, data)
+}
+
+func TestCreateAndGet(t *testing.T) {
+	srv := newTestServer(t, "")
+
+	user := createUser(t, srv, "Ana", "ana@email.com")
+	if user.ID == "" || user.Version != 1 {
+		t.Fatalf("usuario criado: %+v", user)
+	}
+
+	resp, data := do(t, srv, http.MethodGet, "/users/"+user.ID, "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET: %d %s", resp.StatusCode, data)
+	}
+	if etag := resp.Header.Get("ETag"); etag != `"1"` {
+		t.Errorf("ETag = %s, quer \"1\"", etag)
+	}
+	if got := decode[UserResponse](t, data); got != user {
+		t.Errorf("GET = %+v, quer %+v", got, user)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		want   int
+	}{
+		{"email repetido", http.MethodPost, "/users", `{"name":"Outra","email":"ANA@email.com"}`, http.StatusConflict},
+		{"sem nome", http.MethodPost, "/users", `{"email":"b@email.com"}`, http.StatusBadRequest},
+		{"json invalido", http.MethodPost, "/users", `{`, http.StatusBadRequest},
+		{"inexistente", http.MethodGet, "/users/nao-existe", "", http.StatusNotFound},
+		{"limit invalido", http.MethodGet, "/users?limit=0", "", http.StatusBadRequest},
+		{"cursor adulterado", http.MethodGet, "/users?cursor=abc", "", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if resp, data := do(t, srv, tt.method, tt.path, tt.body); resp.StatusCode != tt.want {
+				t.Errorf("status %d (%s), quer %d", resp.StatusCode, data, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateIfMatch(t *testing.T) {
+	srv := newTestServer(t, "")
+	user := createUser(t, srv, "Ana", "ana@email.com")
+	body := `{"name":"Ana B","email":"ana@email.com"}`
+
+	tests := []struct {
+		ifMatch string
+		want    int
+	}{
+		{`"7"`, http.StatusPreconditionFailed},
+		{`W/"1"`, http.StatusPreconditionFailed},
+		{`1`, http.StatusBadRequest},
+		{`"1"`, http.StatusOK},
+		{`"1"`, http.StatusPreconditionFailed}, // a versao agora e 2
+	}
+	for _, tt := range tests {
+		resp, data := do(t, srv, http.MethodPut, "/users/"+user.ID, body, "If-Match", tt.ifMatch)
+		if resp.StatusCode != tt.want {
+			t.Errorf("If-Match %s: status %d (%s), quer %d", tt.ifMatch, resp.StatusCode, data, tt.want)
+		}
+	}
+}
+
+func TestPatch(t *testing.T) {
+	srv := newTestServer(t, "")
+	user := createUser(t, srv, "Ana", "ana@email.com")
+	mergePatch := "application/merge-patch+json"
+
+	resp, data := do(t, srv, http.MethodPatch, "/users/"+user.ID, `{"name":"Ana Paula"}`, "Content-Type", mergePatch)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("PATCH: %d %s", resp.StatusCode, data)
+	}
+	got := decode[UserResponse](t, data)
+	if got.Name != "Ana Paula" || got.Email != "ana@email.com" || got.Version != 2 {
+		t.Errorf("PATCH = %+v", got)
+	}
+	if etag := resp.Header.Get("ETag"); etag != `"2"` {
+		t.Errorf("ETag = %s, quer \"2\"", etag)
+	}
+
+	tests := []struct {
+		name        string
+		body        string
+		contentType string
+		want        int
+	}{
+		{"null", `{"email":null}`, mergePatch, http.StatusBadRequest},
+		{"nao e objeto", `["name"]`, mergePatch, http.StatusBadRequest},
+		{"content-type", `{"name":"x"}`, "text/plain", http.StatusUnsupportedMediaType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, data := do(t, srv, http.MethodPatch, "/users/"+user.ID, tt.body, "Content-Type", tt.contentType)
+			if resp.StatusCode != tt.want {
+				t.Errorf("status %d (%s), quer %d", resp.StatusCode, data, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeleteAndRestore(t *testing.T) {
+	srv := newTestServer(t, "")
+	user := createUser(t, srv, "Ana", "ana@email.com")
+
+	if resp, _ := do(t, srv, http.MethodDelete, "/users/"+user.ID, ""); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("DELETE: %d", resp.StatusCode)
+	}
+	if resp, _ := do(t, srv, http.MethodGet, "/users/"+user.ID, ""); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("GET de excluido: %d, quer 404", resp.StatusCode)
+	}
+	if resp, data := do(t, srv, http.MethodPost, "/users/"+user.ID+"/restore", ""); resp.StatusCode != http.StatusOK {
+		t.Errorf("restore: %d %s", resp.StatusCode, data)
+	}
+}
+
+func TestBatchCreate(t *testing.T) {
+	srv := newTestServer(t, "")
+
+	resp, data := do(t, srv, http.MethodPost, "/users:batchCreate",
+		`{"users":[{"name":"Ana","email":"ana@email.com"},{"name":"","email":"x@email.com"},{"name":"Ana 2","email":"ana@email.com"}]}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("batchCreate: %d %s", resp.StatusCode, data)
+	}
+
+	got := decode[BatchResponse](t, data)
+	want := []int{http.StatusCreated, http.StatusBadRequest, http.StatusConflict}
+	for i, item := range got.Results {
+		if item.Index != i || item.Status != want[i] {
+			t.Errorf("item %d: %+v, quer status %d", i, item, want[i])
+		}
+	}
+}
@@ -0,0 +1,107 @@
+// Package logging monta o logger estruturado (log/slog, JSON) dos binarios de
+// servico e o carrega no context.Context.
+//
+// Cada linha e um objeto JSON com time, level, msg e atributos:
+//
+//	{"time":"...","level":"INFO","msg":"requisicao","request_id":"9f2c...","method":"GET","status":200}
+//
+// O logger de uma requisicao ja traz o request_id (e o tenant), entao qualquer camada
+// que use FromContext(ctx) escreve linhas ligadas a requisicao. Quando o contexto tem
+// um span do OpenTelemetry, trace_id e span_id tambem entram na linha.
+//
+// Emails sao mascarados em qualquer mensagem ou atributo antes da escrita (veja
+// RedactEmails): um erro do DynamoDB que cite um email nao vaza o endereco no log.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// New cria um logger JSON que escreve em w, a partir do nivel level.
+func New(w io.Writer, level slog.Leveler) *slog.Logger {
+	handler := slog.NewJSONHandler(w, &slog.HandlerOptions{
+		Level:       level,
+		ReplaceAttr: redactAttr,
+	})
+	return slog.New(traceHandler{handler})
+}
+
+// FromEnv cria o logger do processo em stdout, com o nivel de LOG_LEVEL
+// (debug, info, warn ou error; padrao info).
+func FromEnv() (*slog.Logger, error) {
+	var level slog.Level
+	if raw := os.Getenv("LOG_LEVEL"); raw != "" {
+		if err := level.UnmarshalText([]byte(raw)); err != nil {
+			return nil, fmt.Errorf("LOG_LEVEL invalido: %q (use debug, info, warn ou error)", raw)
+		}
+	}
+	return New(os.Stdout, level), nil
+}
+
+type loggerKey struct{}
+
+// NewContext retorna um contexto que carrega o logger.
+func NewContext(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// FromContext devolve o logger do contexto, ou slog.Default() se nenhum foi definido
+// (ex: nos binarios de linha de comando e nas goroutines de background).
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// redactAttr mascara emails nos valores de texto, nos erros e em qualquer valor com
+// String(). Tambem e chamado para a mensagem (msg). Structs e mapas sao serializados
+// pelo handler sem passar por aqui; para eles, implemente slog.LogValuer (como
+// model.User faz).
+func redactAttr(_ []string, a slog.Attr) slog.Attr {
+	switch a.Value.Kind() {
+	case slog.KindString:
+		if s := a.Value.String(); strings.Contains(s, "@") {
+			a.Value = slog.StringValue(RedactEmails(s))
+		}
+	case slog.KindAny:
+		switch v := a.Value.Any().(type) {
+		case error:
+			a.Value = slog.StringValue(RedactEmails(v.Error()))
+		case fmt.Stringer:
+			a.Value = slog.StringValue(RedactEmails(v.String()))
+		}
+	}
+	return a
+}
+
+// traceHandler acrescenta trace_id e span_id do span que estiver no contexto. So
+// funciona com os metodos que recebem o contexto (InfoContext, ErrorContext, LogAttrs).
+type traceHandler struct {
+	slog.Handler
+}
+
+func (h traceHandler) Handle(ctx context.Context, r slog.Record) error {
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(
+			slog.String("trace_id", sc.TraceID().String()),
+			slog.String("span_id", sc.SpanID().String()),
+		)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h traceHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return traceHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h traceHandler) WithGroup(name string) slog.Handler {
+	return traceHandler{h.Handler.WithGroup(name)}
+}
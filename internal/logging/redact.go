@@ -0,0 +1,14 @@
+package logging
+
+import "regexp"
+
+// emailPattern reconhece enderecos de email dentro de um texto. E mais permissivo
+// que a validacao da API de proposito: na duvida, mascara.
+var emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@([A-Za-z0-9\-]+(\.[A-Za-z0-9\-]+)+)`)
+
+// RedactEmails troca a parte local de cada email do texto por "***", mantendo o
+// dominio: "joao.silva@email.com ja cadastrado" vira "***@email.com ja cadastrado".
+// O dominio ajuda a investigar (ex: problema com um provedor) sem identificar a pessoa.
+func RedactEmails(s string) string {
+	return emailPattern.ReplaceAllString(s, "***@$1")
+}
@@ -0,0 +1,46 @@
+package logging
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestRedactEmails(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"joao.silva@email.com ja cadastrado", "***@email.com ja cadastrado"},
+		{"de a+b@x.com.br para c_d@y.io", "de ***@x.com.br para ***@y.io"},
+		{"sem email aqui", "sem email aqui"},
+		{"usuario@localhost", "usuario@localhost"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := RedactEmails(tt.in); got != tt.want {
+			t.Errorf("RedactEmails(%q) = %q, quer %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// TestLoggerRedacts confere que o logger de New mascara a mensagem, os atributos de
+// texto e os erros.
+func TestLoggerRedacts(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, slog.LevelInfo)
+
+	logger.Info("falha para ana@email.com",
+		"email", "ana@email.com",
+		"error", errors.New("email ana@email.com ja cadastrado"),
+	)
+
+	out := buf.String()
+	if strings.Contains(out, "ana@email.com") {
+		t.Errorf("email em claro no log: %s", out)
+	}
+	if strings.Count(out, "***@email.com") != 3 {
+		t.Errorf("esperava 3 emails mascarados: %s", out)
+	}
+}
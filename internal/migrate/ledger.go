@@ -0,0 +1,130 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// O ledger e um unico item na tabela de usuarios com a lista das migracoes aplicadas:
+//
+//	{ "id": "MIGRATION#LEDGER", "item_type": "migration_ledger", "version": 3,
+//	  "applied": [{"id": "0001_...", "description": "...", "applied_at": "..."}, ...] }
+//
+// Assim como os sentinelas de email, o atributo item_type o esconde da listagem e
+// das leituras por id. Guardar o ledger na propria tabela dispensa uma tabela extra
+// e garante que ele some junto com a tabela.
+//
+// Cada registro e condicionado ao "version" lido: se duas execucoes do migrate
+// rodarem ao mesmo tempo, a segunda a gravar recebe ErrLedgerConflict.
+
+const (
+	ledgerID       = "MIGRATION#LEDGER"
+	itemTypeLedger = "migration_ledger"
+)
+
+// ErrLedgerConflict indica que outra execucao alterou o ledger durante esta.
+var ErrLedgerConflict = errors.New("ledger de migracoes alterado por outra execucao")
+
+type ledgerDynamo struct {
+	ID       string          `dynamodbav:"id"`
+	ItemType string          `dynamodbav:"item_type"`
+	Version  int64           `dynamodbav:"version"`
+	Applied  []appliedDynamo `dynamodbav:"applied"`
+}
+
+type appliedDynamo struct {
+	ID          string `dynamodbav:"id"`
+	Description string `dynamodbav:"description"`
+	AppliedAt   string `dynamodbav:"applied_at"`
+}
+
+func (l ledgerDynamo) appliedAt(id string) string {
+	for _, a := range l.Applied {
+		if a.ID == id {
+			return a.AppliedAt
+		}
+	}
+	return ""
+}
+
+// loadLedger le o ledger. Uma tabela que ainda nao existe (ResourceNotFoundException)
+// e tratada como um ledger vazio: nenhuma migracao foi aplicada.
+func (m *Migrator) loadLedger(ctx context.Context) (ledgerDynamo, error) {
+	output, err := m.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(m.tableName),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: ledgerID},
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		var notFound *types.ResourceNotFoundException
+		if errors.As(err, &notFound) {
+			return ledgerDynamo{}, nil
+		}
+		return ledgerDynamo{}, fmt.Errorf("erro ao ler ledger de migracoes: %w", err)
+	}
+
+	var ledger ledgerDynamo
+	if err := attributevalue.UnmarshalMap(output.Item, &ledger); err != nil {
+		return ledgerDynamo{}, fmt.Errorf("erro ao desserializar ledger de migracoes: %w", err)
+	}
+	return ledger, nil
+}
+
+// record acrescenta a migracao ao ledger com list_append, condicionado a versao lida.
+func (m *Migrator) record(ctx context.Context, ledger *ledgerDynamo, mig Migration) error {
+	entry := appliedDynamo{
+		ID:          mig.ID,
+		Description: mig.Description,
+		AppliedAt:   time.Now().UTC().Format(time.RFC3339),
+	}
+
+	update := expression.
+		Set(expression.Name("item_type"), expression.Value(itemTypeLedger)).
+		Set(expression.Name("applied"), expression.ListAppend(
+			expression.IfNotExists(expression.Name("applied"), expression.Value([]appliedDynamo{})),
+			expression.Value([]appliedDynamo{entry}),
+		)).
+		Set(expression.Name("version"), expression.Value(ledger.Version+1))
+
+	condition := expression.AttributeNotExists(expression.Name("version"))
+	if ledger.Version > 0 {
+		condition = expression.Name("version").Equal(expression.Value(ledger.Version))
+	}
+
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(condition).Build()
+	if err != nil {
+		return fmt.Errorf("erro ao construir expressao: %w", err)
+	}
+
+	_, err = m.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(m.tableName),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: ledgerID},
+		},
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	})
+	if err != nil {
+		var ccf *types.ConditionalCheckFailedException
+		if errors.As(err, &ccf) {
+			return ErrLedgerConflict
+		}
+		return fmt.Errorf("erro ao registrar migracao %s: %w", mig.ID, err)
+	}
+
+	ledger.Version++
+	ledger.Applied = append(ledger.Applied, entry)
+	return nil
+}
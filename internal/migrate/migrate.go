@@ -0,0 +1,198 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/dowglassantana/golang-with-dynamodb/internal/fieldcrypt"
+	"github.com/dowglassantana/golang-with-dynamodb/internal/logging"
+)
+
+// Migracoes versionadas do schema da tabela de usuarios.
+//
+// O DynamoDB nao tem schema para os atributos comuns, mas a tabela tem: chave
+// primaria, indices (GSI), TTL, stream. Mudancas nesses recursos sao operacoes
+// assincronas (UpdateTable, UpdateTimeToLive) que precisam ser feitas em ordem —
+// o DynamoDB recusa um UpdateTable enquanto outro indice ainda esta em CREATING.
+//
+// Cada Migration e um passo ordenado e idempotente: ela confere o estado atual
+// (DescribeTable) antes de alterar, entao rodar de novo uma migracao ja aplicada
+// nao tem efeito. As migracoes aplicadas ficam registradas em um item "ledger" na
+// propria tabela (veja ledger.go), e o Migrator so executa as pendentes.
+//
+// Uma migracao publicada nunca deve ser editada: mudancas de schema entram como
+// uma nova migracao no fim da lista. Por isso as definicoes em migrations.go usam
+// os nomes literais dos atributos, e nao as constantes do repository.
+
+// Migration e um passo do schema. ID define a ordem e nunca muda depois de publicado.
+type Migration struct {
+	ID          string
+	Description string
+	Up          func(ctx context.Context, m *Migrator) error
+}
+
+// Status descreve uma migracao e quando ela foi aplicada (vazio quando pendente).
+type Status struct {
+	ID          string
+	Description string
+	AppliedAt   string
+}
+
+func (s Status) Applied() bool {
+	return s.AppliedAt != ""
+}
+
+// Migrator aplica as migracoes na tabela informada.
+type Migrator struct {
+	client     *dynamodb.Client
+	tableName  string
+	sealer     *fieldcrypt.Sealer
+	migrations []Migration
+	timeout    time.Duration
+}
+
+// Option configura parametros opcionais do Migrator.
+type Option func(*Migrator)
+
+// WithSealer informa as chaves usadas pelas migracoes que cifram dados existentes
+// (0009_encrypt_email). Sem ele, essas migracoes falham.
+func WithSealer(sealer *fieldcrypt.Sealer) Option {
+	return func(m *Migrator) {
+		m.sealer = sealer
+	}
+}
+
+// waitTimeout limita quanto tempo esperamos a tabela e os indices ficarem ACTIVE.
+// A construcao de um GSI em uma tabela grande pode levar bem mais que alguns minutos.
+const waitTimeout = 30 * time.Minute
+
+// indexPollInterval e o intervalo entre consultas ao status dos indices.
+const indexPollInterval = 5 * time.Second
+
+func New(client *dynamodb.Client, tableName string, opts ...Option) *Migrator {
+	m := &Migrator{
+		client:     client,
+		tableName:  tableName,
+		migrations: migrations,
+		timeout:    waitTimeout,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// Status lista todas as migracoes conhecidas, em ordem, com a data de aplicacao.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	ledger, err := m.loadLedger(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, len(m.migrations))
+	for i, mig := range m.migrations {
+		statuses[i] = Status{
+			ID:          mig.ID,
+			Description: mig.Description,
+			AppliedAt:   ledger.appliedAt(mig.ID),
+		}
+	}
+	return statuses, nil
+}
+
+// Plan retorna as migracoes pendentes, na ordem em que Up as aplicaria.
+func (m *Migrator) Plan(ctx context.Context) ([]Migration, error) {
+	ledger, err := m.loadLedger(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return m.pending(ledger), nil
+}
+
+// Up aplica as migracoes pendentes, uma de cada vez. Depois de cada passo espera a
+// tabela e os indices ficarem ACTIVE e so entao registra a migracao no ledger — se o
+// processo cair no meio, a migracao volta a rodar na proxima execucao, o que e
+// seguro porque todas sao idempotentes.
+//
+// Retorna as migracoes aplicadas nesta execucao.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	ledger, err := m.loadLedger(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []Migration
+	for _, mig := range m.pending(ledger) {
+		logging.FromContext(ctx).InfoContext(ctx, "aplicando migracao", "migration", mig.ID, "description", mig.Description)
+
+		if err := mig.Up(ctx, m); err != nil {
+			return applied, fmt.Errorf("erro na migracao %s: %w", mig.ID, err)
+		}
+		if err := m.waitActive(ctx); err != nil {
+			return applied, fmt.Errorf("erro na migracao %s: %w", mig.ID, err)
+		}
+		if err := m.record(ctx, &ledger, mig); err != nil {
+			return applied, err
+		}
+
+		applied = append(applied, mig)
+	}
+	return applied, nil
+}
+
+func (m *Migrator) pending(ledger ledgerDynamo) []Migration {
+	var pending []Migration
+	for _, mig := range m.migrations {
+		if ledger.appliedAt(mig.ID) == "" {
+			pending = append(pending, mig)
+		}
+	}
+	return pending
+}
+
+// waitActive espera a tabela e todos os seus GSIs ficarem ACTIVE.
+//
+// dynamodb.NewTableExistsWaiter consulta DescribeTable ate o status da tabela ser
+// ACTIVE. Ele nao olha os indices: durante a construcao de um GSI a tabela continua
+// ACTIVE e o indice fica em CREATING, entao consultamos o IndexStatus a parte.
+func (m *Migrator) waitActive(ctx context.Context) error {
+	input := &dynamodb.DescribeTableInput{TableName: aws.String(m.tableName)}
+
+	waiter := dynamodb.NewTableExistsWaiter(m.client)
+	if err := waiter.Wait(ctx, input, m.timeout); err != nil {
+		return fmt.Errorf("erro ao aguardar tabela ficar ativa: %w", err)
+	}
+
+	deadline := time.Now().Add(m.timeout)
+	for {
+		desc, err := m.client.DescribeTable(ctx, input)
+		if err != nil {
+			return fmt.Errorf("erro ao descrever tabela: %w", err)
+		}
+
+		building := ""
+		for _, gsi := range desc.Table.GlobalSecondaryIndexes {
+			if gsi.IndexStatus != types.IndexStatusActive {
+				building = aws.ToString(gsi.IndexName)
+				break
+			}
+		}
+		if building == "" {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("indice %s nao ficou ativo em %s", building, m.timeout)
+		}
+
+		logging.FromContext(ctx).InfoContext(ctx, "aguardando indice ficar ativo", "table", m.tableName, "index", building)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(indexPollInterval):
+		}
+	}
+}
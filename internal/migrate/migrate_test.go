@@ -0,0 +1,287 @@
+package migrate
+
+import (
+	"context"
+	"encoding/base64"
+	"maps"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/dowglassantana/golang-with-dynamodb/internal/dynamotest"
+	"github.com/dowglassantana/golang-with-dynamodb/internal/fieldcrypt"
+)
+
+func newTestMigrator(t *testing.T) (*Migrator, *dynamotest.Table, *fieldcrypt.Sealer) {
+	t.Helper()
+	kf, err := fieldcrypt.CreateKeyFile(filepath.Join(t.TempDir(), "keys.json"))
+	if err != nil {
+		t.Fatalf("CreateKeyFile: %v", err)
+	}
+	sealer, err := fieldcrypt.NewSealer(context.Background(), kf)
+	if err != nil {
+		t.Fatalf("NewSealer: %v", err)
+	}
+	srv := dynamotest.NewServer(t)
+	table := dynamotest.NewTable(srv, "Users")
+	return New(srv.Client(), "Users", WithSealer(sealer)), table, sealer
+}
+
+func s(v string) map[string]any { return map[string]any{"S": v} }
+func n(v string) map[string]any { return map[string]any{"N": v} }
+
+func ids(migs []Migration) []string {
+	out := make([]string, len(migs))
+	for i, mig := range migs {
+		out[i] = mig.ID
+	}
+	return out
+}
+
+// ledgerIDs le a lista applied do ledger gravado na tabela.
+func ledgerIDs(t *testing.T, table *dynamotest.Table) []string {
+	t.Helper()
+	ledger := table.Item(ledgerID)
+	if ledger == nil {
+		t.Fatal("ledger nao foi gravado")
+	}
+	applied, _ := ledger["applied"].(map[string]any)["L"].([]any)
+	out := make([]string, len(applied))
+	for i, entry := range applied {
+		out[i] = dynamotest.S(entry.(map[string]any)["M"], "id")
+	}
+	return out
+}
+
+func TestUpAppliesInOrderAndRecordsLedger(t *testing.T) {
+	m, table, _ := newTestMigrator(t)
+	ctx := context.Background()
+
+	plan, err := m.Plan(ctx)
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+	if !reflect.DeepEqual(ids(plan), ids(migrations)) {
+		t.Fatalf("Plan = %v, quer todas as migracoes", ids(plan))
+	}
+
+	applied, err := m.Up(ctx)
+	if err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if !reflect.DeepEqual(ids(applied), ids(migrations)) {
+		t.Errorf("Up aplicou %v, quer %v", ids(applied), ids(migrations))
+	}
+	if got := ledgerIDs(t, table); !reflect.DeepEqual(got, ids(migrations)) {
+		t.Errorf("ledger = %v, quer %v na ordem", got, ids(migrations))
+	}
+	if version := table.Item(ledgerID)["version"]; !reflect.DeepEqual(version, n("9")) {
+		t.Errorf("version do ledger = %v, quer 9 (uma escrita por migracao)", version)
+	}
+
+	wantIndexes := []string{"email-index", "history-index", "tenant-index", "created-index", "name-index", "email-hash-index"}
+	if got := table.Indexes(); !reflect.DeepEqual(got, wantIndexes) {
+		t.Errorf("indices = %v, quer %v", got, wantIndexes)
+	}
+	if table.TTL() != "expires_at" || table.StreamViewType() != "NEW_AND_OLD_IMAGES" {
+		t.Errorf("TTL = %q, stream = %q", table.TTL(), table.StreamViewType())
+	}
+
+	// Com o ledger completo, uma nova execucao nao tem o que aplicar.
+	applied, err = m.Up(ctx)
+	if err != nil || len(applied) != 0 {
+		t.Errorf("segundo Up = %v, %v; quer nada aplicado", ids(applied), err)
+	}
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	for _, st := range statuses {
+		if !st.Applied() {
+			t.Errorf("%s nao aparece como aplicada", st.ID)
+		}
+	}
+}
+
+// TestUpIsIdempotent apaga o ledger e roda tudo de novo, como uma execucao que caiu
+// antes de registrar as migracoes: nenhum passo pode alterar a tabela outra vez.
+func TestUpIsIdempotent(t *testing.T) {
+	m, table, _ := newTestMigrator(t)
+	ctx := context.Background()
+	table.Seed(legacyItems()...)
+
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	before := table.Items()
+	delete(before, ledgerID)
+	indexes := table.Indexes()
+
+	table.Delete(ledgerID)
+	applied, err := m.Up(ctx)
+	if err != nil {
+		t.Fatalf("Up sem ledger: %v", err)
+	}
+	if len(applied) != len(migrations) {
+		t.Errorf("Up sem ledger aplicou %d migracoes, quer %d", len(applied), len(migrations))
+	}
+
+	after := table.Items()
+	delete(after, ledgerID)
+	if !reflect.DeepEqual(after, before) {
+		t.Errorf("reaplicar as migracoes alterou os itens:\nantes  %v\ndepois %v", before, after)
+	}
+	if got := table.Indexes(); !reflect.DeepEqual(got, indexes) {
+		t.Errorf("indices = %v, quer %v", got, indexes)
+	}
+	if got := ledgerIDs(t, table); !reflect.DeepEqual(got, ids(migrations)) {
+		t.Errorf("ledger = %v, quer %v", got, ids(migrations))
+	}
+}
+
+// legacyItems e uma tabela anterior as migracoes de tenant e de criptografia: um
+// usuario ativo com sentinela, um excluido e um evento de historico, todos com a chave
+// sem tenant e o email em claro.
+func legacyItems() []map[string]any {
+	return []map[string]any{
+		{
+			"id":         s("u1"),
+			"name":       s("Ána  Paula"),
+			"email":      s("Ana@Email.com"),
+			"created_at": s("2024-01-02T00:04:05-03:00"),
+			"version":    n("2"),
+		},
+		{
+			"id":        s("EMAIL#ana@email.com"),
+			"item_type": s("email_lock"),
+			"user_id":   s("u1"),
+		},
+		{
+			"id":         s("u2"),
+			"name":       s("Bia"),
+			"email":      s("bia@email.com"),
+			"created_at": s("2024-01-03T00:00:00Z"),
+			"deleted_at": s("2024-02-01T00:00:00Z"),
+			"version":    n("3"),
+		},
+		{
+			"id":         s("EVT#1"),
+			"item_type":  s("event"),
+			"history_pk": s("USER#u1"),
+			"history_sk": s("EVT#2024-01-02T03:04:05Z#1"),
+			"user_id":    s("u1"),
+			"action":     s("created"),
+			"after": map[string]any{"M": map[string]any{
+				"name":    s("Ána  Paula"),
+				"email":   s("Ana@Email.com"),
+				"version": n("1"),
+			}},
+		},
+	}
+}
+
+// openEmail decifra o email_enc gravado pela migracao, com o AAD do dono.
+func openEmail(t *testing.T, sealer *fieldcrypt.Sealer, enc any, owner string) string {
+	t.Helper()
+	m, _ := enc.(map[string]any)["M"].(map[string]any)
+	bytesOf := func(attr string) []byte {
+		raw, _ := m[attr].(map[string]any)["B"].(string)
+		b, err := base64.StdEncoding.DecodeString(raw)
+		if err != nil {
+			t.Fatalf("%s invalido: %v", attr, err)
+		}
+		return b
+	}
+	env := fieldcrypt.Envelope{KeyID: dynamotest.S(m, "key_id"), DataKey: bytesOf("data_key"), Ciphertext: bytesOf("ciphertext")}
+	plain, err := sealer.Open(context.Background(), env, []byte(owner+"#email"))
+	if err != nil {
+		t.Fatalf("email_enc nao abre com o AAD de %s: %v", owner, err)
+	}
+	return string(plain)
+}
+
+func TestUpMigratesLegacyData(t *testing.T) {
+	m, table, sealer := newTestMigrator(t)
+	ctx := context.Background()
+	table.Seed(legacyItems()...)
+
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+
+	// 0006: os usuarios sao movidos para a chave do tenant default.
+	for _, old := range []string{"u1", "u2", "EMAIL#ana@email.com", "TENANT#default#EMAIL#ana@email.com"} {
+		if table.Item(old) != nil {
+			t.Errorf("item %s deveria ter sido removido", old)
+		}
+	}
+	const key = "TENANT#default#USER#u1"
+	user := table.Item(key)
+	if user == nil {
+		t.Fatalf("usuario nao foi movido para %s; itens: %v", key, slices.Sorted(maps.Keys(table.Items())))
+	}
+	want := map[string]string{
+		"user_id":         "u1",
+		"tenant_id":       "default",
+		"tenant_pk":       "TENANT#default",
+		"tenant_sk":       "USER#u1",
+		"migrated_from":   "u1",
+		"created_at":      "2024-01-02T03:04:05Z", // 0007: em UTC
+		"name_normalized": "ana paula",            // 0008
+	}
+	for attr, v := range want {
+		if got := dynamotest.S(user, attr); got != v {
+			t.Errorf("%s = %q, quer %q", attr, got, v)
+		}
+	}
+	if table.Item("TENANT#default#USER#u2") == nil {
+		t.Error("usuario excluido tambem deveria ser movido")
+	}
+
+	// 0009: o email vira email_enc + email_hash, e o sentinela passa a usar o hash.
+	if _, ok := user["email"]; ok {
+		t.Error("email em claro continua no usuario")
+	}
+	if _, ok := user["email_normalized"]; ok {
+		t.Error("email_normalized continua no usuario")
+	}
+	if got := openEmail(t, sealer, user["email_enc"], key); got != "Ana@Email.com" {
+		t.Errorf("email decifrado = %q", got)
+	}
+	hash := sealer.BlindIndex("default", "ana@email.com")
+	if got := dynamotest.S(user, "email_hash"); got != hash {
+		t.Errorf("email_hash = %q, quer %q", got, hash)
+	}
+	lock := table.Item("TENANT#default#EMAIL#" + hash)
+	if dynamotest.S(lock, "user_id") != "u1" {
+		t.Errorf("sentinela com hash = %v", lock)
+	}
+
+	// Eventos: history_pk com tenant (0006) e email do snapshot cifrado (0009).
+	event := table.Item("EVT#1")
+	if got := dynamotest.S(event, "history_pk"); got != key {
+		t.Errorf("history_pk = %q, quer %q", got, key)
+	}
+	after, _ := event["after"].(map[string]any)["M"].(map[string]any)
+	if _, ok := after["email"]; ok {
+		t.Error("email em claro continua no snapshot do evento")
+	}
+	if got := openEmail(t, sealer, after["email_enc"], key); got != "Ana@Email.com" {
+		t.Errorf("email do evento decifrado = %q", got)
+	}
+}
+
+func TestEncryptEmailRequiresSealer(t *testing.T) {
+	srv := dynamotest.NewServer(t)
+	dynamotest.NewTable(srv, "Users")
+	m := New(srv.Client(), "Users")
+
+	applied, err := m.Up(context.Background())
+	if err == nil {
+		t.Fatal("Up sem Sealer deveria falhar no 0009")
+	}
+	if got := ids(applied); len(got) != 8 || got[7] != "0008_name_index" {
+		t.Errorf("aplicadas antes do erro = %v, quer ate 0008", got)
+	}
+}
@@ -0,0 +1,752 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// migrations e a lista ordenada de migracoes da tabela de usuarios.
+// Novas migracoes entram SEMPRE no fim, com o proximo numero.
+var migrations = []Migration{
+	{
+		ID:          "0001_create_users_table",
+		Description: "cria a tabela de usuarios com partition key id",
+		Up:          createUsersTable,
+	},
+	{
+		ID:          "0002_email_index",
+		Description: "cria o GSI email-index e preenche email_normalized",
+		Up:          createEmailIndex,
+	},
+	{
+		ID:          "0003_soft_delete_ttl",
+		Description: "habilita o TTL no atributo expires_at",
+		Up: func(ctx context.Context, m *Migrator) error {
+			return m.enableTTL(ctx, "expires_at")
+		},
+	},
+	{
+		ID:          "0004_history_index",
+		Description: "cria o GSI history-index (history_pk, history_sk)",
+		Up:          createHistoryIndex,
+	},
+	{
+		ID:          "0005_stream",
+		Description: "habilita o DynamoDB Stream com NEW_AND_OLD_IMAGES",
+		Up: func(ctx context.Context, m *Migrator) error {
+			return m.enableStream(ctx, types.StreamViewTypeNewAndOldImages)
+		},
+	},
+	{
+		ID:          "0006_tenant_keys",
+		Description: "cria o GSI tenant-index e move os dados existentes para o tenant default",
+		Up:          migrateTenantKeys,
+	},
+	{
+		ID:          "0007_created_index",
+		Description: "cria o GSI created-index (tenant_pk, created_at) e converte created_at para UTC",
+		Up:          createCreatedIndex,
+	},
+	{
+		ID:          "0008_name_index",
+		Description: "cria o GSI name-index (tenant_pk, name_normalized) e preenche name_normalized",
+		Up:          createNameIndex,
+	},
+	{
+		ID:          "0009_encrypt_email",
+		Description: "cria o GSI email-hash-index e cifra o email dos usuarios e do historico",
+		Up:          encryptEmails,
+	},
+}
+
+// createUsersTable cria a tabela base.
+//
+// No DynamoDB, toda tabela precisa de pelo menos uma chave primaria (partition key).
+// Aqui usamos o campo "id" como partition key (HASH), o que significa que cada item
+// na tabela sera identificado unicamente pelo seu "id".
+//
+// KeySchema define a estrutura da chave:
+//   - HASH = partition key (obrigatoria) — distribui os dados entre as particoes internas.
+//
+// AttributeDefinitions descreve o tipo do atributo usado na chave:
+//   - "S" = String, "N" = Number, "B" = Binary.
+//
+// BillingMode PAY_PER_REQUEST = modo sob demanda (sem necessidade de provisionar capacidade).
+// Ideal para desenvolvimento local e cargas imprevisiveis.
+func createUsersTable(ctx context.Context, m *Migrator) error {
+	return m.createTable(ctx, &dynamodb.CreateTableInput{
+		KeySchema: []types.KeySchemaElement{
+			{
+				AttributeName: aws.String("id"),
+				KeyType:       types.KeyTypeHash,
+			},
+		},
+		AttributeDefinitions: []types.AttributeDefinition{
+			{
+				AttributeName: aws.String("id"),
+				AttributeType: types.ScalarAttributeTypeS,
+			},
+		},
+		BillingMode: types.BillingModePayPerRequest,
+	})
+}
+
+// createEmailIndex cria o GSI por email.
+//
+// A partition key do indice e email_normalized (o email em minusculas), o que
+// permite buscar um usuario por email com Query em vez de Scan. O indice e esparso:
+// so entram nele os itens que possuem o atributo, entao os sentinelas EMAIL#...
+// nunca aparecem nas buscas. ProjectionType ALL copia todos os atributos do item
+// para o indice, para que a Query devolva o usuario completo.
+//
+// Usuarios gravados antes do atributo existir ficariam fora do indice, entao, quando
+// o indice acaba de ser criado, preenchemos email_normalized neles.
+func createEmailIndex(ctx context.Context, m *Migrator) error {
+	created, err := m.createIndex(ctx, types.GlobalSecondaryIndex{
+		IndexName: aws.String("email-index"),
+		KeySchema: []types.KeySchemaElement{
+			{
+				AttributeName: aws.String("email_normalized"),
+				KeyType:       types.KeyTypeHash,
+			},
+		},
+		Projection: &types.Projection{
+			ProjectionType: types.ProjectionTypeAll,
+		},
+	})
+	if err != nil || !created {
+		return err
+	}
+	return backfillEmailNormalized(ctx, m)
+}
+
+// backfillEmailNormalized grava email_normalized nos usuarios que ainda nao tem o atributo.
+//
+// NewScanPaginator cuida do LastEvaluatedKey para nos: cada NextPage continua de onde
+// a pagina anterior parou, ate HasMorePages retornar false.
+//
+// A condicao "email = :email" evita sobrescrever um usuario cujo email mudou entre o
+// Scan e o UpdateItem — nesse caso o proprio Update ja gravou o valor correto.
+func backfillEmailNormalized(ctx context.Context, m *Migrator) error {
+	filter := expression.AttributeNotExists(expression.Name("email_normalized")).
+		And(expression.AttributeNotExists(expression.Name("item_type")))
+
+	expr, err := expression.NewBuilder().WithFilter(filter).Build()
+	if err != nil {
+		return fmt.Errorf("erro ao construir expressao: %w", err)
+	}
+
+	paginator := dynamodb.NewScanPaginator(m.client, &dynamodb.ScanInput{
+		TableName:                 aws.String(m.tableName),
+		FilterExpression:          expr.Filter(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("erro ao varrer usuarios para o indice: %w", err)
+		}
+
+		for _, item := range page.Items {
+			email, ok := item["email"].(*types.AttributeValueMemberS)
+			if !ok {
+				continue
+			}
+
+			normalized := strings.ToLower(strings.TrimSpace(email.Value))
+			upd, err := expression.NewBuilder().
+				WithUpdate(expression.Set(expression.Name("email_normalized"), expression.Value(normalized))).
+				WithCondition(expression.Name("email").Equal(expression.Value(email.Value))).
+				Build()
+			if err != nil {
+				return fmt.Errorf("erro ao construir expressao: %w", err)
+			}
+
+			_, err = m.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+				TableName:                 aws.String(m.tableName),
+				Key:                       map[string]types.AttributeValue{"id": item["id"]},
+				UpdateExpression:          upd.Update(),
+				ConditionExpression:       upd.Condition(),
+				ExpressionAttributeNames:  upd.Names(),
+				ExpressionAttributeValues: upd.Values(),
+			})
+			var ccf *types.ConditionalCheckFailedException
+			if err != nil && !errors.As(err, &ccf) {
+				return fmt.Errorf("erro ao preencher email_normalized: %w", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// createHistoryIndex cria o GSI que agrupa os eventos de historico por usuario.
+// history_pk ("USER#<id>") e a partition key e history_sk ("EVT#<timestamp>#<uuid>")
+// a sort key, para que a Query devolva os eventos em ordem cronologica.
+func createHistoryIndex(ctx context.Context, m *Migrator) error {
+	_, err := m.createIndex(ctx, types.GlobalSecondaryIndex{
+		IndexName: aws.String("history-index"),
+		KeySchema: []types.KeySchemaElement{
+			{
+				AttributeName: aws.String("history_pk"),
+				KeyType:       types.KeyTypeHash,
+			},
+			{
+				AttributeName: aws.String("history_sk"),
+				KeyType:       types.KeyTypeRange,
+			},
+		},
+		Projection: &types.Projection{
+			ProjectionType: types.ProjectionTypeAll,
+		},
+	})
+	return err
+}
+
+// Chaves da migracao 0006_tenant_keys. Ficam copiadas aqui, e nao importadas do
+// repository, porque uma migracao ja aplicada nao pode mudar se o formato das
+// chaves mudar no futuro.
+const (
+	legacyTenantPrefix = "TENANT#default#"
+	migratedFromAttr   = "migrated_from"
+)
+
+// migrateTenantKeys cria o GSI tenant-index (tenant_pk, tenant_sk) e move os itens
+// gravados antes do suporte a multi-tenant para o tenant "default".
+//
+// A chave de um item nao pode ser alterada com UpdateItem, entao mover um usuario
+// significa gravar uma copia na chave nova e apagar a antiga — as duas coisas na
+// mesma TransactWriteItems, junto com o sentinela do email. Os eventos de historico
+// mantem o id; so o history_pk (chave do GSI, que pode ser alterada) muda.
+//
+// A migracao e idempotente: os filtros so encontram itens ainda nao movidos, entao
+// uma execucao interrompida pode ser refeita.
+func migrateTenantKeys(ctx context.Context, m *Migrator) error {
+	_, err := m.createIndex(ctx, types.GlobalSecondaryIndex{
+		IndexName: aws.String("tenant-index"),
+		KeySchema: []types.KeySchemaElement{
+			{
+				AttributeName: aws.String("tenant_pk"),
+				KeyType:       types.KeyTypeHash,
+			},
+			{
+				AttributeName: aws.String("tenant_sk"),
+				KeyType:       types.KeyTypeRange,
+			},
+		},
+		Projection: &types.Projection{
+			ProjectionType: types.ProjectionTypeAll,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := moveLegacyUsers(ctx, m); err != nil {
+		return err
+	}
+	return moveLegacyEvents(ctx, m)
+}
+
+// moveLegacyUsers move cada usuario sem tenant_pk para a chave "TENANT#default#USER#<id>".
+//
+// A transacao de cada usuario:
+//  1. Put da copia na chave nova, com user_id, tenant_id, tenant_pk e tenant_sk;
+//  2. Delete do item antigo, condicionado a ele nao ter mudado desde o Scan;
+//  3. para usuarios ativos, Delete do sentinela antigo e Put do sentinela novo.
+//
+// O atributo migrated_from permite ao consumidor do stream ignorar o INSERT da copia.
+func moveLegacyUsers(ctx context.Context, m *Migrator) error {
+	filter := expression.AttributeNotExists(expression.Name("item_type")).
+		And(expression.AttributeNotExists(expression.Name("tenant_pk")))
+
+	return scanItems(ctx, m, filter, func(item map[string]types.AttributeValue) error {
+		id, ok := item["id"].(*types.AttributeValueMemberS)
+		if !ok {
+			return nil
+		}
+
+		moved := maps.Clone(item)
+		moved["id"] = &types.AttributeValueMemberS{Value: legacyTenantPrefix + "USER#" + id.Value}
+		moved["user_id"] = id
+		moved["tenant_id"] = &types.AttributeValueMemberS{Value: "default"}
+		moved["tenant_pk"] = &types.AttributeValueMemberS{Value: "TENANT#default"}
+		moved["tenant_sk"] = &types.AttributeValueMemberS{Value: "USER#" + id.Value}
+		moved[migratedFromAttr] = id
+
+		// O Delete so acontece se o item ainda e o que foi lido: mesma versao (ou
+		// ainda sem versao, para itens anteriores ao controle de concorrencia).
+		unchanged := expression.AttributeNotExists(expression.Name("version"))
+		if version, ok := item["version"]; ok {
+			unchanged = expression.Name("version").Equal(expression.Value(version))
+		}
+		del, err := expression.NewBuilder().WithCondition(unchanged).Build()
+		if err != nil {
+			return fmt.Errorf("erro ao construir expressao: %w", err)
+		}
+
+		items := []types.TransactWriteItem{
+			{Put: &types.Put{
+				TableName:           aws.String(m.tableName),
+				Item:                moved,
+				ConditionExpression: aws.String("attribute_not_exists(id)"),
+			}},
+			{Delete: &types.Delete{
+				TableName:                 aws.String(m.tableName),
+				Key:                       map[string]types.AttributeValue{"id": id},
+				ConditionExpression:       del.Condition(),
+				ExpressionAttributeNames:  del.Names(),
+				ExpressionAttributeValues: del.Values(),
+			}},
+		}
+
+		_, deleted := item["deleted_at"]
+		if email, ok := item["email"].(*types.AttributeValueMemberS); ok && !deleted && email.Value != "" {
+			items = append(items, moveEmailLock(m, strings.ToLower(strings.TrimSpace(email.Value)), id)...)
+		}
+
+		_, err = m.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: items})
+		if err != nil {
+			return fmt.Errorf("erro ao mover usuario %s para o tenant default: %w", id.Value, err)
+		}
+		return nil
+	})
+}
+
+// moveEmailLock monta o Delete do sentinela antigo ("EMAIL#<email>") e o Put do novo
+// ("TENANT#default#EMAIL#<email>"). O Delete tolera a ausencia do sentinela antigo
+// (usuarios criados antes dos sentinelas existirem).
+func moveEmailLock(m *Migrator, email string, userID types.AttributeValue) []types.TransactWriteItem {
+	return []types.TransactWriteItem{
+		{Delete: &types.Delete{
+			TableName:           aws.String(m.tableName),
+			Key:                 map[string]types.AttributeValue{"id": &types.AttributeValueMemberS{Value: "EMAIL#" + email}},
+			ConditionExpression: aws.String("attribute_not_exists(id) OR user_id = :user"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":user": userID,
+			},
+		}},
+		{Put: &types.Put{
+			TableName: aws.String(m.tableName),
+			Item: map[string]types.AttributeValue{
+				"id":        &types.AttributeValueMemberS{Value: legacyTenantPrefix + "EMAIL#" + email},
+				"item_type": &types.AttributeValueMemberS{Value: "email_lock"},
+				"user_id":   userID,
+			},
+			ConditionExpression: aws.String("attribute_not_exists(id)"),
+		}},
+	}
+}
+
+// moveLegacyEvents troca o history_pk dos eventos antigos de "USER#<id>" para
+// "TENANT#default#USER#<id>", a mesma chave do usuario movido.
+func moveLegacyEvents(ctx context.Context, m *Migrator) error {
+	filter := expression.Name("item_type").Equal(expression.Value("event")).
+		And(expression.Name("history_pk").BeginsWith("USER#"))
+
+	return scanItems(ctx, m, filter, func(item map[string]types.AttributeValue) error {
+		pk, ok := item["history_pk"].(*types.AttributeValueMemberS)
+		if !ok {
+			return nil
+		}
+
+		upd, err := expression.NewBuilder().
+			WithUpdate(expression.Set(expression.Name("history_pk"), expression.Value(legacyTenantPrefix+pk.Value))).
+			WithCondition(expression.Name("history_pk").Equal(expression.Value(pk.Value))).
+			Build()
+		if err != nil {
+			return fmt.Errorf("erro ao construir expressao: %w", err)
+		}
+
+		_, err = m.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+			TableName:                 aws.String(m.tableName),
+			Key:                       map[string]types.AttributeValue{"id": item["id"]},
+			UpdateExpression:          upd.Update(),
+			ConditionExpression:       upd.Condition(),
+			ExpressionAttributeNames:  upd.Names(),
+			ExpressionAttributeValues: upd.Values(),
+		})
+		var ccf *types.ConditionalCheckFailedException
+		if err != nil && !errors.As(err, &ccf) {
+			return fmt.Errorf("erro ao mover evento de historico: %w", err)
+		}
+		return nil
+	})
+}
+
+// createCreatedIndex cria o GSI que ordena os usuarios de cada tenant por created_at.
+//
+// A sort key e comparada como string, o que so funciona se todos os valores estiverem
+// no mesmo fuso. Usuarios antigos gravaram created_at no fuso local do servidor, entao
+// a migracao reescreve esses valores em UTC. Diferente do 0002, a conversao roda mesmo
+// quando o indice ja existia: uma execucao interrompida depois do CreateIndex continua
+// de onde parou.
+func createCreatedIndex(ctx context.Context, m *Migrator) error {
+	_, err := m.createIndex(ctx, types.GlobalSecondaryIndex{
+		IndexName: aws.String("created-index"),
+		KeySchema: []types.KeySchemaElement{
+			{
+				AttributeName: aws.String("tenant_pk"),
+				KeyType:       types.KeyTypeHash,
+			},
+			{
+				AttributeName: aws.String("created_at"),
+				KeyType:       types.KeyTypeRange,
+			},
+		},
+		Projection: &types.Projection{
+			ProjectionType: types.ProjectionTypeAll,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	filter := expression.AttributeNotExists(expression.Name("item_type")).
+		And(expression.AttributeExists(expression.Name("created_at")))
+
+	return scanItems(ctx, m, filter, func(item map[string]types.AttributeValue) error {
+		created, ok := item["created_at"].(*types.AttributeValueMemberS)
+		if !ok {
+			return nil
+		}
+		t, err := time.Parse(time.RFC3339, created.Value)
+		if err != nil {
+			return nil
+		}
+		utc := t.UTC().Format(time.RFC3339)
+		if utc == created.Value {
+			return nil
+		}
+		return updateIfUnchanged(ctx, m, item["id"], "created_at", created.Value, "created_at", utc)
+	})
+}
+
+// createNameIndex cria o GSI usado na busca por prefixo de nome e preenche
+// name_normalized nos usuarios gravados antes do atributo existir. Assim como em
+// createCreatedIndex, o preenchimento roda sempre; o filtro so encontra quem falta.
+func createNameIndex(ctx context.Context, m *Migrator) error {
+	_, err := m.createIndex(ctx, types.GlobalSecondaryIndex{
+		IndexName: aws.String("name-index"),
+		KeySchema: []types.KeySchemaElement{
+			{
+				AttributeName: aws.String("tenant_pk"),
+				KeyType:       types.KeyTypeHash,
+			},
+			{
+				AttributeName: aws.String("name_normalized"),
+				KeyType:       types.KeyTypeRange,
+			},
+		},
+		Projection: &types.Projection{
+			ProjectionType: types.ProjectionTypeAll,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	filter := expression.AttributeNotExists(expression.Name("item_type")).
+		And(expression.AttributeNotExists(expression.Name("name_normalized")))
+
+	return scanItems(ctx, m, filter, func(item map[string]types.AttributeValue) error {
+		name, ok := item["name"].(*types.AttributeValueMemberS)
+		if !ok {
+			return nil
+		}
+		normalized := normalizeName(name.Value)
+		if normalized == "" {
+			return nil
+		}
+		return updateIfUnchanged(ctx, m, item["id"], "name", name.Value, "name_normalized", normalized)
+	})
+}
+
+// normalizeName e a normalizacao de nomes da migracao 0008_name_index: remove acentos,
+// converte para minusculas e junta espacos repetidos. Fica copiada aqui, e nao
+// importada de model.NormalizeName, pelo mesmo motivo das chaves do 0006.
+func normalizeName(name string) string {
+	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	stripped, _, err := transform.String(t, name)
+	if err != nil {
+		stripped = name
+	}
+	return strings.Join(strings.Fields(strings.ToLower(stripped)), " ")
+}
+
+// sealedEmail e o formato do email cifrado no item (Map key_id/data_key/ciphertext),
+// copiado de repository/encryption.go pelo mesmo motivo das chaves do 0006.
+type sealedEmail struct {
+	KeyID      string `dynamodbav:"key_id"`
+	DataKey    []byte `dynamodbav:"data_key"`
+	Ciphertext []byte `dynamodbav:"ciphertext"`
+}
+
+// sealEmail cifra o email amarrado a owner, a chave do usuario dono dele.
+func sealEmail(ctx context.Context, m *Migrator, owner, email string) (sealedEmail, error) {
+	env, err := m.sealer.Seal(ctx, []byte(email), []byte(owner+"#email"))
+	if err != nil {
+		return sealedEmail{}, fmt.Errorf("erro ao cifrar email: %w", err)
+	}
+	return sealedEmail{KeyID: env.KeyID, DataKey: env.DataKey, Ciphertext: env.Ciphertext}, nil
+}
+
+// encryptEmails cria o GSI email-hash-index e troca o email em claro pelo email
+// cifrado (email_enc) e pelo blind index (email_hash) — veja internal/fieldcrypt.
+//
+// O GSI email-index do 0002 fica vazio depois da migracao, mas nao e removido: as
+// instancias da API que ainda nao foram atualizadas continuam consultando-o ate o
+// deploy terminar. Ele pode ser apagado numa migracao futura.
+//
+// Como nos anteriores, a cifragem roda mesmo quando o indice ja existia e os filtros
+// so encontram os itens que ainda tem email em claro.
+func encryptEmails(ctx context.Context, m *Migrator) error {
+	if m.sealer == nil {
+		return errors.New("0009_encrypt_email precisa das chaves de criptografia (migrate.WithSealer)")
+	}
+
+	_, err := m.createIndex(ctx, types.GlobalSecondaryIndex{
+		IndexName: aws.String("email-hash-index"),
+		KeySchema: []types.KeySchemaElement{
+			{
+				AttributeName: aws.String("email_hash"),
+				KeyType:       types.KeyTypeHash,
+			},
+		},
+		Projection: &types.Projection{
+			ProjectionType: types.ProjectionTypeAll,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := encryptUserEmails(ctx, m); err != nil {
+		return err
+	}
+	return encryptEventEmails(ctx, m)
+}
+
+// encryptUserEmails cifra o email de cada usuario. A transacao de cada usuario:
+//  1. Update do usuario: SET email_enc e email_hash, REMOVE email e email_normalized,
+//     condicionado ao email ainda ser o lido no Scan;
+//  2. para usuarios ativos, Delete do sentinela "TENANT#<t>#EMAIL#<email>" e Put do
+//     sentinela "TENANT#<t>#EMAIL#<email_hash>".
+func encryptUserEmails(ctx context.Context, m *Migrator) error {
+	filter := expression.AttributeNotExists(expression.Name("item_type")).
+		And(expression.AttributeExists(expression.Name("email")))
+
+	return scanItems(ctx, m, filter, func(item map[string]types.AttributeValue) error {
+		id, okID := item["id"].(*types.AttributeValueMemberS)
+		email, okEmail := item["email"].(*types.AttributeValueMemberS)
+		tenant, okTenant := item["tenant_id"].(*types.AttributeValueMemberS)
+		if !okID || !okEmail || !okTenant {
+			return nil
+		}
+
+		normalized := strings.ToLower(strings.TrimSpace(email.Value))
+		hash := m.sealer.BlindIndex(tenant.Value, normalized)
+		enc, err := sealEmail(ctx, m, id.Value, email.Value)
+		if err != nil {
+			return err
+		}
+
+		upd, err := expression.NewBuilder().
+			WithUpdate(expression.
+				Set(expression.Name("email_enc"), expression.Value(enc)).
+				Set(expression.Name("email_hash"), expression.Value(hash)).
+				Remove(expression.Name("email")).
+				Remove(expression.Name("email_normalized"))).
+			WithCondition(expression.Name("email").Equal(expression.Value(email.Value))).
+			Build()
+		if err != nil {
+			return fmt.Errorf("erro ao construir expressao: %w", err)
+		}
+
+		items := []types.TransactWriteItem{
+			{Update: &types.Update{
+				TableName:                 aws.String(m.tableName),
+				Key:                       map[string]types.AttributeValue{"id": id},
+				UpdateExpression:          upd.Update(),
+				ConditionExpression:       upd.Condition(),
+				ExpressionAttributeNames:  upd.Names(),
+				ExpressionAttributeValues: upd.Values(),
+			}},
+		}
+
+		_, deleted := item["deleted_at"]
+		if !deleted && normalized != "" {
+			userID := item["user_id"]
+			prefix := "TENANT#" + tenant.Value + "#EMAIL#"
+			items = append(items,
+				types.TransactWriteItem{Delete: &types.Delete{
+					TableName:           aws.String(m.tableName),
+					Key:                 map[string]types.AttributeValue{"id": &types.AttributeValueMemberS{Value: prefix + normalized}},
+					ConditionExpression: aws.String("attribute_not_exists(id) OR user_id = :user"),
+					ExpressionAttributeValues: map[string]types.AttributeValue{
+						":user": userID,
+					},
+				}},
+				types.TransactWriteItem{Put: &types.Put{
+					TableName: aws.String(m.tableName),
+					Item: map[string]types.AttributeValue{
+						"id":        &types.AttributeValueMemberS{Value: prefix + hash},
+						"item_type": &types.AttributeValueMemberS{Value: "email_lock"},
+						"user_id":   userID,
+					},
+					ConditionExpression: aws.String("attribute_not_exists(id)"),
+				}},
+			)
+		}
+
+		_, err = m.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: items})
+		var canceled *types.TransactionCanceledException
+		if errors.As(err, &canceled) && len(canceled.CancellationReasons) > 0 &&
+			aws.ToString(canceled.CancellationReasons[0].Code) == "ConditionalCheckFailed" {
+			// O usuario mudou depois do Scan: a escrita da API ja cifrou o email.
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("erro ao cifrar email do usuario %s: %w", id.Value, err)
+		}
+		return nil
+	})
+}
+
+// encryptEventEmails cifra o email dos snapshots before/after dos eventos de
+// historico, amarrado ao history_pk (a chave do usuario).
+func encryptEventEmails(ctx context.Context, m *Migrator) error {
+	filter := expression.Name("item_type").Equal(expression.Value("event")).
+		And(expression.AttributeExists(expression.Name("before.email")).
+			Or(expression.AttributeExists(expression.Name("after.email"))))
+
+	return scanItems(ctx, m, filter, func(item map[string]types.AttributeValue) error {
+		pk, ok := item["history_pk"].(*types.AttributeValueMemberS)
+		if !ok {
+			return nil
+		}
+
+		var (
+			update    expression.UpdateBuilder
+			condition expression.ConditionBuilder
+			changed   int
+		)
+		for _, side := range []string{"before", "after"} {
+			snapshot, ok := item[side].(*types.AttributeValueMemberM)
+			if !ok {
+				continue
+			}
+			email, ok := snapshot.Value["email"].(*types.AttributeValueMemberS)
+			if !ok {
+				continue
+			}
+
+			enc, err := sealEmail(ctx, m, pk.Value, email.Value)
+			if err != nil {
+				return err
+			}
+			update = update.
+				Set(expression.Name(side+".email_enc"), expression.Value(enc)).
+				Remove(expression.Name(side + ".email"))
+			unchanged := expression.Name(side + ".email").Equal(expression.Value(email.Value))
+			if changed == 0 {
+				condition = unchanged
+			} else {
+				condition = condition.And(unchanged)
+			}
+			changed++
+		}
+		if changed == 0 {
+			return nil
+		}
+
+		upd, err := expression.NewBuilder().WithUpdate(update).WithCondition(condition).Build()
+		if err != nil {
+			return fmt.Errorf("erro ao construir expressao: %w", err)
+		}
+
+		_, err = m.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+			TableName:                 aws.String(m.tableName),
+			Key:                       map[string]types.AttributeValue{"id": item["id"]},
+			UpdateExpression:          upd.Update(),
+			ConditionExpression:       upd.Condition(),
+			ExpressionAttributeNames:  upd.Names(),
+			ExpressionAttributeValues: upd.Values(),
+		})
+		var ccf *types.ConditionalCheckFailedException
+		if err != nil && !errors.As(err, &ccf) {
+			return fmt.Errorf("erro ao cifrar email do historico: %w", err)
+		}
+		return nil
+	})
+}
+
+// updateIfUnchanged grava attr = value no item, desde que o atributo guard ainda tenha
+// o valor lido no Scan. Se o item mudou nesse meio tempo, a propria escrita da API ja
+// gravou o valor correto, entao a falha da condicao e ignorada.
+func updateIfUnchanged(ctx context.Context, m *Migrator, id types.AttributeValue, guard, read, attr, value string) error {
+	upd, err := expression.NewBuilder().
+		WithUpdate(expression.Set(expression.Name(attr), expression.Value(value))).
+		WithCondition(expression.Name(guard).Equal(expression.Value(read))).
+		Build()
+	if err != nil {
+		return fmt.Errorf("erro ao construir expressao: %w", err)
+	}
+
+	_, err = m.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(m.tableName),
+		Key:                       map[string]types.AttributeValue{"id": id},
+		UpdateExpression:          upd.Update(),
+		ConditionExpression:       upd.Condition(),
+		ExpressionAttributeNames:  upd.Names(),
+		ExpressionAttributeValues: upd.Values(),
+	})
+	var ccf *types.ConditionalCheckFailedException
+	if err != nil && !errors.As(err, &ccf) {
+		return fmt.Errorf("erro ao preencher %s: %w", attr, err)
+	}
+	return nil
+}
+
+// scanItems percorre a tabela inteira chamando fn para cada item que passa no filtro.
+func scanItems(ctx context.Context, m *Migrator, filter expression.ConditionBuilder, fn func(map[string]types.AttributeValue) error) error {
+	expr, err := expression.NewBuilder().WithFilter(filter).Build()
+	if err != nil {
+		return fmt.Errorf("erro ao construir expressao: %w", err)
+	}
+
+	paginator := dynamodb.NewScanPaginator(m.client, &dynamodb.ScanInput{
+		TableName:                 aws.String(m.tableName),
+		FilterExpression:          expr.Filter(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("erro ao varrer a tabela: %w", err)
+		}
+		for _, item := range page.Items {
+			if err := fn(item); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Passos reutilizaveis pelas migracoes. Todos conferem o estado atual antes de
+// alterar a tabela, para que reaplicar uma migracao nao tenha efeito.
+
+// createTable cria a tabela caso ela ainda nao exista. ResourceInUseException
+// significa que a tabela ja existe.
+//
+// CreateTable e assincrono: a tabela nasce com status CREATING e so aceita
+// UpdateTable/UpdateTimeToLive quando fica ACTIVE — o Migrator espera por isso
+// depois de cada migracao (veja waitActive).
+func (m *Migrator) createTable(ctx context.Context, input *dynamodb.CreateTableInput) error {
+	input.TableName = aws.String(m.tableName)
+
+	_, err := m.client.CreateTable(ctx, input)
+	if err != nil {
+		var resourceInUse *types.ResourceInUseException
+		if !errors.As(err, &resourceInUse) {
+			return fmt.Errorf("erro ao criar tabela: %w", err)
+		}
+	}
+	return nil
+}
+
+// createIndex cria o GSI via UpdateTable, caso a tabela ainda nao o tenha.
+// Retorna created = true quando o indice acabou de ser solicitado.
+//
+// GSIs podem ser criados depois da tabela, sem downtime: o DynamoDB constroi o
+// indice em segundo plano (status CREATING -> ACTIVE) enquanto a tabela continua
+// aceitando leituras e escritas. So e permitido criar um GSI por chamada.
+func (m *Migrator) createIndex(ctx context.Context, gsi types.GlobalSecondaryIndex) (bool, error) {
+	desc, err := m.describeTable(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	for _, existing := range desc.GlobalSecondaryIndexes {
+		if aws.ToString(existing.IndexName) == aws.ToString(gsi.IndexName) {
+			return false, nil
+		}
+	}
+
+	_, err = m.client.UpdateTable(ctx, &dynamodb.UpdateTableInput{
+		TableName:            aws.String(m.tableName),
+		AttributeDefinitions: keyAttributeDefinitions(gsi.KeySchema),
+		GlobalSecondaryIndexUpdates: []types.GlobalSecondaryIndexUpdate{
+			{Create: &types.CreateGlobalSecondaryIndexAction{
+				IndexName:  gsi.IndexName,
+				KeySchema:  gsi.KeySchema,
+				Projection: gsi.Projection,
+			}},
+		},
+	})
+	if err != nil {
+		return false, fmt.Errorf("erro ao criar indice %s: %w", aws.ToString(gsi.IndexName), err)
+	}
+
+	return true, nil
+}
+
+// enableTTL habilita o TTL no atributo informado, caso ainda nao esteja.
+//
+// O TTL e configurado via UpdateTimeToLive. Chamar UpdateTimeToLive quando o TTL ja
+// esta habilitado retorna erro, por isso consultamos DescribeTimeToLive antes.
+func (m *Migrator) enableTTL(ctx context.Context, attr string) error {
+	desc, err := m.client.DescribeTimeToLive(ctx, &dynamodb.DescribeTimeToLiveInput{
+		TableName: aws.String(m.tableName),
+	})
+	if err != nil {
+		return fmt.Errorf("erro ao consultar TTL: %w", err)
+	}
+
+	if d := desc.TimeToLiveDescription; d != nil {
+		switch d.TimeToLiveStatus {
+		case types.TimeToLiveStatusEnabled, types.TimeToLiveStatusEnabling:
+			return nil
+		}
+	}
+
+	_, err = m.client.UpdateTimeToLive(ctx, &dynamodb.UpdateTimeToLiveInput{
+		TableName: aws.String(m.tableName),
+		TimeToLiveSpecification: &types.TimeToLiveSpecification{
+			AttributeName: aws.String(attr),
+			Enabled:       aws.Bool(true),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("erro ao habilitar TTL: %w", err)
+	}
+	return nil
+}
+
+// enableStream habilita o DynamoDB Stream com o view type informado.
+//
+// O view type de um stream nao pode ser alterado: para trocar, e preciso desabilitar
+// o stream e habilitar de novo, o que gera um novo ARN. Como isso descarta os
+// registros pendentes, nao fazemos automaticamente — apenas retornamos erro.
+func (m *Migrator) enableStream(ctx context.Context, viewType types.StreamViewType) error {
+	desc, err := m.describeTable(ctx)
+	if err != nil {
+		return err
+	}
+
+	if spec := desc.StreamSpecification; spec != nil && aws.ToBool(spec.StreamEnabled) {
+		if spec.StreamViewType != viewType {
+			return fmt.Errorf("stream da tabela usa view type %s, esperado %s", spec.StreamViewType, viewType)
+		}
+		return nil
+	}
+
+	_, err = m.client.UpdateTable(ctx, &dynamodb.UpdateTableInput{
+		TableName: aws.String(m.tableName),
+		StreamSpecification: &types.StreamSpecification{
+			StreamEnabled:  aws.Bool(true),
+			StreamViewType: viewType,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("erro ao habilitar stream: %w", err)
+	}
+	return nil
+}
+
+func (m *Migrator) describeTable(ctx context.Context) (*types.TableDescription, error) {
+	desc, err := m.client.DescribeTable(ctx, &dynamodb.DescribeTableInput{
+		TableName: aws.String(m.tableName),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("erro ao descrever tabela: %w", err)
+	}
+	return desc.Table, nil
+}
+
+// keyAttributeDefinitions declara os atributos de chave de um indice.
+// Todos os atributos de chave deste projeto sao strings ("S").
+func keyAttributeDefinitions(schema []types.KeySchemaElement) []types.AttributeDefinition {
+	defs := make([]types.AttributeDefinition, len(schema))
+	for i, k := range schema {
+		defs[i] = types.AttributeDefinition{
+			AttributeName: k.AttributeName,
+			AttributeType: types.ScalarAttributeTypeS,
+		}
+	}
+	return defs
+}
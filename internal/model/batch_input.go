@@ -0,0 +1,11 @@
+package model
+
+// BatchCreateInput e o DTO de entrada de POST /users:batchCreate.
+type BatchCreateInput struct {
+	Users []CreateUserInput `json:"users"`
+}
+
+// BatchIDsInput e o DTO de entrada de POST /users:batchGet e /users:batchDelete.
+type BatchIDsInput struct {
+	IDs []string `json:"ids"`
+}
@@ -0,0 +1,9 @@
+package model
+
+// BatchResult e o resultado de um item em uma operacao em lote.
+// Err vem preenchido quando o item falhou; os demais itens do lote seguem independentes.
+type BatchResult struct {
+	ID   string
+	User *User
+	Err  error
+}
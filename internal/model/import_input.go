@@ -0,0 +1,14 @@
+package model
+
+// ImportUserInput e uma linha de POST /admin/import.
+//
+// ID e CreatedAt sao opcionais. Informados (como no arquivo do export), o usuario e
+// criado com eles — um usuario movido de outro ambiente mantem o id e a data de
+// criacao; vazios, sao gerados como no POST /users. Version e DeletedAt nao fazem
+// parte da entrada: o usuario importado comeca ativo, na versao 1.
+type ImportUserInput struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Email     string `json:"email"`
+	CreatedAt string `json:"created_at"`
+}
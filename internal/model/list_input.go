@@ -0,0 +1,35 @@
+package model
+
+// Valores aceitos em ListUsersInput.Order.
+const (
+	OrderAsc  = "asc"
+	OrderDesc = "desc"
+)
+
+// ListUsersInput e o DTO de entrada para listagem paginada de usuarios.
+// Cursor e o token opaco devolvido em UserPage.NextCursor na pagina anterior.
+// IncludeDeleted inclui usuarios excluidos que ainda estao na janela de retencao.
+//
+// Os filtros de busca sao opcionais:
+//   - CreatedAfter/CreatedBefore limitam created_at (RFC3339 em UTC, inclusivos).
+//   - NamePrefix busca nomes que comecam com o prefixo, sem diferenciar maiusculas
+//     nem acentos (veja NormalizeName).
+//   - Order define a ordem: por nome quando ha NamePrefix, por created_at nos
+//     demais casos. Sem nenhum filtro nem Order, a ordem nao e definida.
+//
+// Um cursor so vale para a mesma combinacao de filtros que o gerou.
+type ListUsersInput struct {
+	Limit          int32
+	Cursor         string
+	IncludeDeleted bool
+
+	CreatedAfter  string
+	CreatedBefore string
+	NamePrefix    string
+	Order         string
+}
+
+// IsSearch indica se a listagem usa algum filtro ou ordenacao.
+func (in ListUsersInput) IsSearch() bool {
+	return in.CreatedAfter != "" || in.CreatedBefore != "" || in.NamePrefix != "" || in.Order != ""
+}
@@ -0,0 +1,24 @@
+package model
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// NormalizeName padroniza um nome para busca por prefixo: minusculas, sem acentos e
+// com espacos repetidos colapsados. "  Ána  Paula" e "ana paula" viram o mesmo valor.
+//
+// A remocao de acentos decompoe cada letra (NFD: "a" + acento combinante), descarta
+// as marcas combinantes (categoria Mn) e recompoe o resto (NFC).
+func NormalizeName(name string) string {
+	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	folded, _, err := transform.String(t, name)
+	if err != nil {
+		folded = name
+	}
+	return strings.ToLower(strings.Join(strings.Fields(folded), " "))
+}
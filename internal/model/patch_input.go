@@ -0,0 +1,43 @@
+package model
+
+import "encoding/json"
+
+// Nullable representa um campo de um documento JSON Merge Patch (RFC 7396), que
+// distingue tres estados:
+//   - campo ausente: Set = false — o atributo nao muda;
+//   - campo null:    Set = true, Null = true — pela RFC, remove o atributo; como
+//     name e email sao obrigatorios, o service recusa null (400);
+//   - campo com valor: Set = true — o atributo recebe Value.
+//
+// Um *string nao basta: ele nao diferencia "ausente" de "null".
+type Nullable[T any] struct {
+	Value T
+	Set   bool
+	Null  bool
+}
+
+// UnmarshalJSON so e chamado quando o campo aparece no documento — inclusive com
+// o literal null —, entao Set fica false para campos ausentes.
+func (n *Nullable[T]) UnmarshalJSON(data []byte) error {
+	n.Set = true
+	if string(data) == "null" {
+		n.Null = true
+		return nil
+	}
+	return json.Unmarshal(data, &n.Value)
+}
+
+// PatchUserInput e o DTO de entrada do PATCH /users/{id} (application/merge-patch+json).
+//
+// Campos desconhecidos e somente leitura (id, version, created_at) sao ignorados.
+// ExpectedVersion vem do header If-Match, como no UpdateUserInput.
+type PatchUserInput struct {
+	Name            Nullable[string] `json:"name"`
+	Email           Nullable[string] `json:"email"`
+	ExpectedVersion *int64           `json:"-"`
+}
+
+// IsEmpty indica um patch sem nenhum campo — pela RFC 7396, nao altera nada.
+func (p PatchUserInput) IsEmpty() bool {
+	return !p.Name.Set && !p.Email.Set
+}
@@ -1,7 +1,11 @@
 package model
 
 // UpdateUserInput e o DTO de entrada para atualizacao de usuario.
+//
+// ExpectedVersion vem do header If-Match (nao do corpo). Quando informado, a
+// atualizacao so acontece se o usuario ainda estiver nessa versao.
 type UpdateUserInput struct {
-	Name  string `json:"name"`
-	Email string `json:"email"`
+	Name            string `json:"name"`
+	Email           string `json:"email"`
+	ExpectedVersion *int64 `json:"-"`
 }
@@ -1,17 +1,25 @@
 package model
 
 import (
+	"log/slog"
 	"time"
 
 	"github.com/google/uuid"
 )
 
 // User e a entidade de dominio — representa um usuario na aplicacao.
+//
+// Version e incrementado a cada escrita e usado para controle de concorrencia otimista.
+// CreatedAt fica sempre em UTC: o GSI created-index compara o valor como string, entao
+// todos os usuarios precisam usar o mesmo fuso.
+// DeletedAt e preenchido quando o usuario foi excluido (soft delete) e ainda pode ser restaurado.
 type User struct {
 	ID        string
 	Name      string
 	Email     string
 	CreatedAt string
+	Version   int64
+	DeletedAt string
 }
 
 func NewUser(name, email string) User {
@@ -19,6 +27,21 @@ func NewUser(name, email string) User {
 		ID:        uuid.New().String(),
 		Name:      name,
 		Email:     email,
-		CreatedAt: time.Now().Format(time.RFC3339),
+		CreatedAt: time.Now().UTC().Format(time.RFC3339),
+		Version:   1,
 	}
 }
+
+// LogValue define como o usuario aparece nos logs estruturados (slog): so os campos
+// que nao identificam a pessoa. Nome e email ficam de fora.
+func (u User) LogValue() slog.Value {
+	attrs := []slog.Attr{
+		slog.String("id", u.ID),
+		slog.Int64("version", u.Version),
+		slog.String("created_at", u.CreatedAt),
+	}
+	if u.DeletedAt != "" {
+		attrs = append(attrs, slog.String("deleted_at", u.DeletedAt))
+	}
+	return slog.GroupValue(attrs...)
+}
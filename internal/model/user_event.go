@@ -0,0 +1,41 @@
+package model
+
+// Acoes registradas no historico de um usuario.
+const (
+	EventCreated  = "created"
+	EventUpdated  = "updated"
+	EventDeleted  = "deleted"
+	EventRestored = "restored"
+)
+
+// UserEvent e um registro imutavel do historico de alteracoes de um usuario.
+// Before vem nil na criacao e After vem nil quando nao ha estado posterior.
+type UserEvent struct {
+	ID     string
+	UserID string
+	Action string
+	Actor  string
+	At     string
+	Before *UserSnapshot
+	After  *UserSnapshot
+}
+
+// UserSnapshot e o estado dos campos de um usuario em um instante do historico.
+type UserSnapshot struct {
+	Name      string
+	Email     string
+	Version   int64
+	DeletedAt string
+}
+
+// UserEventPage e uma pagina do historico, do evento mais recente para o mais antigo.
+type UserEventPage struct {
+	Events     []UserEvent
+	NextCursor string
+}
+
+// ListHistoryInput e o DTO de entrada para a listagem paginada do historico.
+type ListHistoryInput struct {
+	Limit  int32
+	Cursor string
+}
@@ -0,0 +1,8 @@
+package model
+
+// UserPage e uma pagina de usuarios retornada pela listagem.
+// NextCursor vem vazio quando nao ha mais paginas.
+type UserPage struct {
+	Users      []User
+	NextCursor string
+}
@@ -0,0 +1,331 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/dowglassantana/golang-with-dynamodb/internal/logging"
+	"github.com/dowglassantana/golang-with-dynamodb/internal/model"
+	"golang.org/x/sync/errgroup"
+)
+
+// Operacoes em lote.
+//
+// BatchGetItem le ate 100 chaves em uma unica chamada, economizando round-trips. Em
+// troca, ela nao aceita a mesma chave repetida e pode devolver parte das chaves em
+// UnprocessedKeys quando a tabela esta sendo limitada (throttling); essas chaves
+// precisam ser reenviadas.
+//
+// BatchWriteItem (ate 25 Puts/Deletes) nao aceita ConditionExpression nem UpdateItem e
+// nao e transacional. Como a criacao precisa do email unico e a exclusao precisa da
+// versao lida, BatchCreate e BatchDelete gravam cada usuario com uma transacao, varias
+// em paralelo. O que nao tem condicao vai por BatchWriteItem: os eventos de historico,
+// que tem id novo e nunca conflitam, saem das transacoes e sao gravados depois, em
+// chunks de 25, reenviando UnprocessedItems.
+//
+// Para reenviar usamos backoff exponencial com "full jitter": cada tentativa espera
+// um tempo aleatorio entre zero e base*2^tentativa. O aleatorio evita que varios
+// clientes limitados ao mesmo tempo tentem de novo todos juntos.
+
+const (
+	batchGetLimit   = 100
+	batchWriteLimit = 25
+
+	// batchConcurrency limita as transacoes simultaneas de BatchCreate e BatchDelete.
+	batchConcurrency = 10
+
+	batchMaxAttempts = 8
+	batchBaseDelay   = 50 * time.Millisecond
+	batchMaxDelay    = 2 * time.Second
+)
+
+// BatchCreate insere varios usuarios, cada um com a TransactWriteItems do Create:
+// usuario e sentinela de email condicionados a attribute_not_exists(id). As transacoes
+// rodam em paralelo (ate batchConcurrency ao mesmo tempo); os eventos de historico dos
+// usuarios gravados vao depois, por BatchWriteItem (veja writeEvents).
+//
+// BatchWriteItem seria mais barato (uma transacao consome o dobro de WCU), mas nao
+// aceita condicoes: a unicidade de email dependeria de uma leitura previa dos
+// sentinelas, com uma janela em que uma criacao concorrente passaria despercebida, e um
+// id informado pelo chamador (import, seed) sobrescreveria um usuario existente. Com a
+// transacao, cada usuario e gravado por inteiro ou nao e gravado, e o resultado de cada
+// item diz o motivo: ErrEmailTaken, ErrIDTaken, ErrThrottled...
+//
+// Emails e ids repetidos dentro do proprio lote sao recusados antes de qualquer escrita:
+// as duas transacoes concorrentes disputariam o mesmo sentinela e a perdedora
+// voltaria com ErrTransactionConflict em vez de ErrEmailTaken.
+//
+// Se o contexto for cancelado no meio do lote, os usuarios ja gravados continuam no
+// resultado e os que nem comecaram voltam com o erro do contexto.
+func (r *DynamoUserRepository) BatchCreate(ctx context.Context, users []model.User) ([]model.BatchResult, error) {
+	tenant := tenantOf(ctx)
+	results := make([]model.BatchResult, len(users))
+	seenEmails := make(map[string]bool, len(users))
+	seenIDs := make(map[string]bool, len(users))
+
+	var pending []int
+	for i, u := range users {
+		results[i] = model.BatchResult{ID: u.ID}
+		lock := r.emailLock(tenant, u.Email)
+		switch {
+		case seenEmails[lock]:
+			results[i].Err = ErrEmailTaken
+		case seenIDs[u.ID]:
+			results[i].Err = ErrIDTaken
+		default:
+			seenEmails[lock] = true
+			seenIDs[u.ID] = true
+			pending = append(pending, i)
+		}
+	}
+
+	events := make([]map[string]types.AttributeValue, len(users))
+	forEachParallel(ctx, results, pending, func(i int) {
+		event, err := r.create(ctx, users[i], true)
+		if err != nil {
+			results[i].Err = err
+			return
+		}
+		events[i] = event
+		u := users[i]
+		results[i].User = &u
+	})
+	r.writeEvents(ctx, events)
+
+	return results, nil
+}
+
+// BatchGet busca varios usuarios pelo id usando BatchGetItem.
+// Ids inexistentes, excluidos ou repetidos voltam com User nil, como em GetByID.
+func (r *DynamoUserRepository) BatchGet(ctx context.Context, ids []string) ([]model.BatchResult, error) {
+	tenant := tenantOf(ctx)
+	keys := uniqueKeys(userKeys(tenant, ids))
+
+	items, err := r.batchGet(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]model.BatchResult, len(ids))
+	for i, id := range ids {
+		results[i] = model.BatchResult{ID: id}
+
+		item, ok := items[userKey(tenant, id)]
+		if !ok || isAuxItem(item) {
+			continue
+		}
+
+		dm, err := r.unmarshalUser(ctx, item)
+		if err != nil {
+			return nil, err
+		}
+		if dm.isDeleted() {
+			continue
+		}
+
+		user := dm.toUser()
+		results[i].User = &user
+	}
+
+	return results, nil
+}
+
+// BatchDelete exclui varios usuarios (soft delete), cada um com o mesmo caminho do
+// Delete: leitura consistente, UpdateItem condicionado a versao lida e liberacao do
+// sentinela de email na mesma transacao. As exclusoes rodam em paralelo (ate
+// batchConcurrency ao mesmo tempo); os eventos vao depois, como em BatchCreate.
+//
+// Regravar o item inteiro com BatchWriteItem (sem condicao) perderia um PATCH que
+// chegasse entre a leitura e a escrita; com a versao na condicao, a corrida e detectada
+// e a exclusao e refeita sobre o estado novo (veja retryOnConflict).
+//
+// Assim como Delete, e idempotente: ids inexistentes ou ja excluidos retornam sucesso
+// (ErrNotFound no modo estrito). Um id repetido no lote e excluido uma vez so. Um
+// cancelamento no meio do lote tambem preserva os resultados, como em BatchCreate.
+func (r *DynamoUserRepository) BatchDelete(ctx context.Context, ids []string) ([]model.BatchResult, error) {
+	results := make([]model.BatchResult, len(ids))
+	seen := make(map[string]bool, len(ids))
+
+	var pending []int
+	for i, id := range ids {
+		results[i] = model.BatchResult{ID: id}
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		pending = append(pending, i)
+	}
+
+	events := make([]map[string]types.AttributeValue, len(ids))
+	forEachParallel(ctx, results, pending, func(i int) {
+		events[i], results[i].Err = r.deleteUser(ctx, ids[i], true)
+	})
+	r.writeEvents(ctx, events)
+
+	return results, nil
+}
+
+// batchGet le as chaves informadas em chunks de 100, reenviando UnprocessedKeys.
+// Retorna os itens encontrados indexados pelo atributo id.
+func (r *DynamoUserRepository) batchGet(ctx context.Context, keys []map[string]types.AttributeValue) (map[string]map[string]types.AttributeValue, error) {
+	found := make(map[string]map[string]types.AttributeValue, len(keys))
+
+	for start := 0; start < len(keys); start += batchGetLimit {
+		end := min(start+batchGetLimit, len(keys))
+		pending := keys[start:end]
+
+		for attempt := 0; len(pending) > 0; attempt++ {
+			if attempt >= batchMaxAttempts {
+				return nil, fmt.Errorf("erro ao ler lote: %w", ErrUnprocessed)
+			}
+			if attempt > 0 {
+				if err := sleepBackoff(ctx, attempt); err != nil {
+					return nil, err
+				}
+			}
+
+			output, err := r.client.BatchGetItem(ctx, &dynamodb.BatchGetItemInput{
+				RequestItems: map[string]types.KeysAndAttributes{
+					r.tableName: {Keys: pending},
+				},
+			})
+			if err != nil {
+				return nil, dynamoError(ctx, "erro ao ler lote", err)
+			}
+
+			for _, item := range output.Responses[r.tableName] {
+				if id, ok := item["id"].(*types.AttributeValueMemberS); ok {
+					found[id.Value] = item
+				}
+			}
+
+			pending = output.UnprocessedKeys[r.tableName].Keys
+		}
+	}
+
+	return found, nil
+}
+
+// writeEvents grava os eventos de historico adiados por BatchCreate e BatchDelete (os
+// nil sao itens que nao foram gravados). Os usuarios ja estao gravados, entao o
+// cancelamento do contexto nao interrompe a gravacao; se ela falhar mesmo apos as
+// novas tentativas, o erro vai para o log com os ids afetados e o resultado do lote
+// nao muda.
+func (r *DynamoUserRepository) writeEvents(ctx context.Context, events []map[string]types.AttributeValue) {
+	var puts []types.WriteRequest
+	for _, event := range events {
+		if event != nil {
+			puts = append(puts, types.WriteRequest{PutRequest: &types.PutRequest{Item: event}})
+		}
+	}
+
+	ctx = context.WithoutCancel(ctx)
+	for start := 0; start < len(puts); start += batchWriteLimit {
+		chunk := puts[start:min(start+batchWriteLimit, len(puts))]
+		if err := r.batchWrite(ctx, chunk); err != nil {
+			ids := make([]string, len(chunk))
+			for i, put := range chunk {
+				if id, ok := put.PutRequest.Item["user_id"].(*types.AttributeValueMemberS); ok {
+					ids[i] = id.Value
+				}
+			}
+			logging.FromContext(ctx).ErrorContext(ctx, "erro ao gravar eventos de historico do lote",
+				"user_ids", ids, "error", err)
+		}
+	}
+}
+
+// batchWrite envia ate 25 escritas com BatchWriteItem, reenviando UnprocessedItems.
+func (r *DynamoUserRepository) batchWrite(ctx context.Context, pending []types.WriteRequest) error {
+	for attempt := 0; len(pending) > 0; attempt++ {
+		if attempt >= batchMaxAttempts {
+			return fmt.Errorf("erro ao gravar lote: %w", ErrUnprocessed)
+		}
+		if attempt > 0 {
+			if err := sleepBackoff(ctx, attempt); err != nil {
+				return err
+			}
+		}
+
+		output, err := r.client.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{
+			RequestItems: map[string][]types.WriteRequest{r.tableName: pending},
+		})
+		if err != nil {
+			return dynamoError(ctx, "erro ao gravar lote", err)
+		}
+
+		pending = output.UnprocessedItems[r.tableName]
+	}
+	return nil
+}
+
+// forEachParallel chama fn para cada indice, com no maximo batchConcurrency chamadas ao
+// mesmo tempo, e retorna quando todas terminam. fn registra o resultado do proprio
+// item. Depois que o contexto e cancelado, os indices que ainda nao comecaram nao
+// chamam fn: recebem o erro do contexto em results. Os que ja estavam em andamento
+// terminam e registram o proprio resultado.
+func forEachParallel(ctx context.Context, results []model.BatchResult, indices []int, fn func(i int)) {
+	var g errgroup.Group
+	g.SetLimit(batchConcurrency)
+	for _, i := range indices {
+		g.Go(func() error {
+			if err := ctx.Err(); err != nil {
+				results[i].Err = err
+				return nil
+			}
+			fn(i)
+			return nil
+		})
+	}
+	g.Wait()
+}
+
+// sleepBackoff espera um tempo aleatorio entre zero e base*2^attempt (full jitter),
+// limitado a batchMaxDelay, ou ate o contexto ser cancelado.
+func sleepBackoff(ctx context.Context, attempt int) error {
+	ceiling := min(batchBaseDelay<<attempt, batchMaxDelay)
+	timer := time.NewTimer(rand.N(ceiling))
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// userKeys converte os uuids recebidos pela API nas chaves do tenant.
+func userKeys(tenant string, ids []string) []string {
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = userKey(tenant, id)
+	}
+	return keys
+}
+
+// idKey monta a chave primaria (atributo id) de um item.
+func idKey(id string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"id": &types.AttributeValueMemberS{Value: id},
+	}
+}
+
+// uniqueKeys monta as chaves para BatchGetItem sem repeticoes — o DynamoDB
+// rejeita a chamada inteira se a mesma chave aparecer duas vezes.
+func uniqueKeys(ids []string) []map[string]types.AttributeValue {
+	seen := make(map[string]bool, len(ids))
+	keys := make([]map[string]types.AttributeValue, 0, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		keys = append(keys, idKey(id))
+	}
+	return keys
+}
@@ -0,0 +1,294 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dowglassantana/golang-with-dynamodb/internal/dynamotest"
+	"github.com/dowglassantana/golang-with-dynamodb/internal/fieldcrypt"
+	"github.com/dowglassantana/golang-with-dynamodb/internal/model"
+	"github.com/dowglassantana/golang-with-dynamodb/internal/requestctx"
+)
+
+func newTestRepository(t *testing.T) (*DynamoUserRepository, *dynamotest.Server) {
+	t.Helper()
+	kf, err := fieldcrypt.CreateKeyFile(filepath.Join(t.TempDir(), "keys.json"))
+	if err != nil {
+		t.Fatalf("CreateKeyFile: %v", err)
+	}
+	sealer, err := fieldcrypt.NewSealer(context.Background(), kf)
+	if err != nil {
+		t.Fatalf("NewSealer: %v", err)
+	}
+	srv := dynamotest.NewServer(t)
+	return NewUserRepository(srv.Client(), "Users", sealer), srv
+}
+
+func testUser(n int) model.User {
+	return model.User{
+		ID:        fmt.Sprintf("00000000-0000-0000-0000-%012d", n),
+		Name:      fmt.Sprintf("Usuario %d", n),
+		Email:     fmt.Sprintf("usuario%d@email.com", n),
+		CreatedAt: "2024-01-02T03:04:05Z",
+		Version:   1,
+	}
+}
+
+// transactItems devolve os itens ({"Put": ...}, {"Update": ...}) de uma TransactWriteItems.
+func transactItems(req dynamotest.Request) []map[string]any {
+	raw, _ := req["TransactItems"].([]any)
+	items := make([]map[string]any, len(raw))
+	for i, item := range raw {
+		items[i], _ = item.(map[string]any)
+	}
+	return items
+}
+
+// transactUserID devolve o id do usuario gravado pela transacao (o item 0 e sempre o
+// usuario: Put no create, Update no delete).
+func transactUserID(req dynamotest.Request) string {
+	first := transactItems(req)[0]
+	if put, ok := first["Put"].(map[string]any); ok {
+		return dynamotest.S(put["Item"], "user_id")
+	}
+	update, _ := first["Update"].(map[string]any)
+	key := dynamotest.S(update["Key"], "id")
+	return key[strings.LastIndex(key, "#")+1:]
+}
+
+// writeRequests devolve os PutRequests de uma BatchWriteItem na tabela Users.
+func writeRequests(req dynamotest.Request) []any {
+	tables, _ := req["RequestItems"].(map[string]any)
+	puts, _ := tables["Users"].([]any)
+	return puts
+}
+
+// eventUserIDs devolve o user_id de cada evento gravado por BatchWriteItem.
+func eventUserIDs(srv *dynamotest.Server) []string {
+	var ids []string
+	for _, call := range srv.Calls("BatchWriteItem") {
+		for _, put := range writeRequests(call) {
+			item := put.(map[string]any)["PutRequest"].(map[string]any)["Item"]
+			ids = append(ids, dynamotest.S(item, "user_id"))
+		}
+	}
+	return ids
+}
+
+func okBatchWrite(req dynamotest.Request) (any, error) {
+	return nil, nil
+}
+
+func TestBatchCreate(t *testing.T) {
+	repo, srv := newTestRepository(t)
+	users := []model.User{testUser(1), testUser(2), testUser(3), testUser(4), testUser(5), testUser(6)}
+	users[4].Email = "USUARIO1@email.com" // mesmo email de users[0]
+	users[5].ID = users[1].ID             // mesmo id de users[1]
+
+	srv.Handle("TransactWriteItems", func(req dynamotest.Request) (any, error) {
+		if n := len(transactItems(req)); n != 2 {
+			t.Errorf("transacao com %d itens, quer 2 (usuario e sentinela, sem o evento)", n)
+		}
+		switch transactUserID(req) {
+		case users[1].ID:
+			return nil, dynamotest.TransactionCanceled("None", "ConditionalCheckFailed")
+		case users[2].ID:
+			return nil, dynamotest.TransactionCanceled("ConditionalCheckFailed", "None")
+		case users[3].ID:
+			return nil, &dynamotest.Error{Type: "ProvisionedThroughputExceededException", Message: "limite"}
+		}
+		return nil, nil
+	})
+	srv.Handle("BatchWriteItem", okBatchWrite)
+
+	results, err := repo.BatchCreate(context.Background(), users)
+	if err != nil {
+		t.Fatalf("BatchCreate: %v", err)
+	}
+
+	wantErrs := []error{nil, ErrEmailTaken, ErrIDTaken, ErrThrottled, ErrEmailTaken, ErrIDTaken}
+	for i, want := range wantErrs {
+		if !errors.Is(results[i].Err, want) || (want != nil && results[i].Err == nil) {
+			t.Errorf("item %d: erro = %v, quer %v", i, results[i].Err, want)
+		}
+		if (results[i].User != nil) != (want == nil) {
+			t.Errorf("item %d: User = %v, quer preenchido so no sucesso", i, results[i].User)
+		}
+	}
+
+	// Os repetidos dentro do lote sao recusados sem chamada ao DynamoDB.
+	if n := len(srv.Calls("TransactWriteItems")); n != 4 {
+		t.Errorf("%d transacoes, quer 4", n)
+	}
+	if ids := eventUserIDs(srv); len(ids) != 1 || ids[0] != users[0].ID {
+		t.Errorf("eventos gravados para %v, quer so [%s]", ids, users[0].ID)
+	}
+}
+
+func TestBatchCreateChunksEventsAndRetriesUnprocessed(t *testing.T) {
+	repo, srv := newTestRepository(t)
+	users := make([]model.User, 30)
+	for i := range users {
+		users[i] = testUser(i + 1)
+	}
+
+	srv.Handle("TransactWriteItems", func(req dynamotest.Request) (any, error) {
+		return nil, nil
+	})
+	var calls atomic.Int32
+	srv.Handle("BatchWriteItem", func(req dynamotest.Request) (any, error) {
+		// A primeira chamada devolve o ultimo item como nao processado.
+		if calls.Add(1) == 1 {
+			puts := writeRequests(req)
+			return map[string]any{
+				"UnprocessedItems": map[string]any{"Users": puts[len(puts)-1:]},
+			}, nil
+		}
+		return nil, nil
+	})
+
+	results, err := repo.BatchCreate(context.Background(), users)
+	if err != nil {
+		t.Fatalf("BatchCreate: %v", err)
+	}
+	for i, res := range results {
+		if res.Err != nil {
+			t.Errorf("item %d: %v", i, res.Err)
+		}
+	}
+
+	var sizes []int
+	for _, call := range srv.Calls("BatchWriteItem") {
+		sizes = append(sizes, len(writeRequests(call)))
+	}
+	if fmt.Sprint(sizes) != "[25 1 5]" {
+		t.Errorf("tamanhos das chamadas BatchWriteItem = %v, quer [25 1 5]", sizes)
+	}
+
+	seen := make(map[string]bool)
+	for _, id := range eventUserIDs(srv) {
+		seen[id] = true
+	}
+	if len(seen) != len(users) {
+		t.Errorf("eventos de %d usuarios, quer %d", len(seen), len(users))
+	}
+}
+
+func TestBatchCreateCanceled(t *testing.T) {
+	repo, srv := newTestRepository(t)
+	users := make([]model.User, 20)
+	for i := range users {
+		users[i] = testUser(i + 1)
+	}
+
+	// As 5 primeiras transacoes sao gravadas; as seguintes ficam presas ate o fim do
+	// teste. Com batchConcurrency = 10, chegam 15 transacoes e as 5 ultimas esperam vaga.
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+	var calls atomic.Int32
+	srv.Handle("TransactWriteItems", func(req dynamotest.Request) (any, error) {
+		if calls.Add(1) > 5 {
+			<-release
+		}
+		return nil, nil
+	})
+	srv.Handle("BatchWriteItem", okBatchWrite)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		deadline := time.Now().Add(5 * time.Second)
+		for calls.Load() < 15 && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		cancel()
+	}()
+
+	results, err := repo.BatchCreate(ctx, users)
+	if err != nil {
+		t.Fatalf("BatchCreate: %v, quer os resultados parciais", err)
+	}
+
+	var created, canceled int
+	for i, res := range results {
+		switch {
+		case res.Err == nil && res.User != nil:
+			created++
+		case errors.Is(res.Err, context.Canceled):
+			canceled++
+		default:
+			t.Errorf("item %d: User = %v, erro = %v", i, res.User, res.Err)
+		}
+	}
+	if created != 5 || canceled != 15 {
+		t.Errorf("%d gravados e %d cancelados, quer 5 e 15", created, canceled)
+	}
+	if n := calls.Load(); n != 15 {
+		t.Errorf("%d transacoes enviadas, quer 15 (as que nao comecaram nao chamam o DynamoDB)", n)
+	}
+	// Os eventos dos usuarios gravados saem mesmo com o contexto cancelado.
+	if ids := eventUserIDs(srv); len(ids) != 5 {
+		t.Errorf("%d eventos gravados, quer 5", len(ids))
+	}
+}
+
+func TestBatchDelete(t *testing.T) {
+	repo, srv := newTestRepository(t)
+	ctx := context.Background()
+	active, throttled, missing := testUser(1), testUser(2), testUser(3)
+
+	stored := make(map[string]map[string]any)
+	for _, u := range []model.User{active, throttled} {
+		item, err := repo.marshalUser(ctx, toDynamo(requestctx.DefaultTenant, u))
+		if err != nil {
+			t.Fatalf("marshalUser: %v", err)
+		}
+		stored[userKey(requestctx.DefaultTenant, u.ID)] = dynamotest.Item(item)
+	}
+
+	srv.Handle("GetItem", func(req dynamotest.Request) (any, error) {
+		if item, ok := stored[dynamotest.S(req["Key"], "id")]; ok {
+			return map[string]any{"Item": item}, nil
+		}
+		return nil, nil
+	})
+	srv.Handle("TransactWriteItems", func(req dynamotest.Request) (any, error) {
+		if n := len(transactItems(req)); n != 2 {
+			t.Errorf("transacao com %d itens, quer 2 (usuario e sentinela, sem o evento)", n)
+		}
+		if transactUserID(req) == throttled.ID {
+			return nil, &dynamotest.Error{Type: "ProvisionedThroughputExceededException", Message: "limite"}
+		}
+		return nil, nil
+	})
+	srv.Handle("BatchWriteItem", okBatchWrite)
+
+	ids := []string{active.ID, missing.ID, throttled.ID, active.ID}
+	results, err := repo.BatchDelete(ctx, ids)
+	if err != nil {
+		t.Fatalf("BatchDelete: %v", err)
+	}
+
+	wantErrs := []error{nil, nil, ErrThrottled, nil}
+	for i, want := range wantErrs {
+		if !errors.Is(results[i].Err, want) || (want != nil && results[i].Err == nil) {
+			t.Errorf("item %d: erro = %v, quer %v", i, results[i].Err, want)
+		}
+	}
+
+	// O id repetido e lido e excluido uma vez so; o inexistente nao gera escrita.
+	if n := len(srv.Calls("GetItem")); n != 3 {
+		t.Errorf("%d leituras, quer 3", n)
+	}
+	if n := len(srv.Calls("TransactWriteItems")); n != 2 {
+		t.Errorf("%d transacoes, quer 2", n)
+	}
+	if got := eventUserIDs(srv); len(got) != 1 || got[0] != active.ID {
+		t.Errorf("eventos gravados para %v, quer so [%s]", got, active.ID)
+	}
+}
@@ -0,0 +1,321 @@
+package repository
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dowglassantana/golang-with-dynamodb/internal/model"
+	"golang.org/x/sync/singleflight"
+)
+
+// Valores padrao do cache. O TTL negativo e menor porque um id inexistente pode
+// passar a existir (Create em outra instancia da API) sem que este cache saiba.
+const (
+	DefaultCacheSize        = 1024
+	DefaultCacheTTL         = 30 * time.Second
+	DefaultCacheNegativeTTL = 5 * time.Second
+)
+
+// CachedUserRepository decora qualquer UserRepository com um cache LRU em memoria
+// para o GetByID (read-through: no miss, le do repository e guarda o resultado).
+//
+// Garantias e limites:
+//   - As entradas sao indexadas pela chave do usuario no tenant (userKey), entao um
+//     tenant nunca recebe a entrada de outro.
+//   - O cache e por processo. Escritas feitas por esta instancia invalidam a entrada
+//     na hora; escritas feitas por outras instancias da API so aparecem quando a
+//     entrada expira (TTL). Por isso o TTL deve ser curto.
+//   - "Nao encontrado" tambem e guardado (cache negativo), com um TTL proprio.
+//   - Erros nunca sao guardados.
+//   - Misses simultaneos para o mesmo id viram uma unica leitura (singleflight).
+//   - As demais leituras (GetByEmail, GetAll, History, BatchGet) vao direto ao
+//     repository decorado.
+//
+// A versao do usuario tambem vem do cache, entao um ETag pode estar atrasado ate o
+// TTL. Isso nao quebra o controle de concorrencia: o If-Match e validado pelo
+// repository decorado na escrita, nunca pelo cache.
+type CachedUserRepository struct {
+	next        UserRepository
+	ttl         time.Duration
+	negativeTTL time.Duration
+	now         func() time.Time
+
+	mu    sync.Mutex
+	size  int
+	order *list.List // frente = usado mais recentemente
+	items map[string]*list.Element
+
+	// generation e incrementada a cada invalidacao. Uma leitura so grava o resultado
+	// se a geracao nao mudou enquanto ela consultava o repository — assim uma leitura
+	// iniciada antes de um Update nao recoloca no cache o valor antigo.
+	generation uint64
+
+	group singleflight.Group
+
+	hits      atomic.Uint64
+	misses    atomic.Uint64
+	evictions atomic.Uint64
+}
+
+var _ UserRepository = (*CachedUserRepository)(nil)
+
+// cacheEntry e o valor guardado em cada elemento da lista. user nil = nao encontrado.
+type cacheEntry struct {
+	key       string
+	user      *model.User
+	expiresAt time.Time
+}
+
+// CacheStats sao os contadores do cache desde a criacao.
+type CacheStats struct {
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+	Size      int    `json:"size"`
+}
+
+// CacheOption configura o CachedUserRepository.
+type CacheOption func(*CachedUserRepository)
+
+// WithCacheSize define quantos usuarios o cache guarda antes de descartar os
+// usados ha mais tempo.
+func WithCacheSize(n int) CacheOption {
+	return func(c *CachedUserRepository) {
+		c.size = n
+	}
+}
+
+// WithCacheTTL define por quanto tempo um usuario encontrado fica no cache.
+func WithCacheTTL(d time.Duration) CacheOption {
+	return func(c *CachedUserRepository) {
+		c.ttl = d
+	}
+}
+
+// WithCacheNegativeTTL define por quanto tempo um "nao encontrado" fica no cache.
+// Zero desliga o cache negativo.
+func WithCacheNegativeTTL(d time.Duration) CacheOption {
+	return func(c *CachedUserRepository) {
+		c.negativeTTL = d
+	}
+}
+
+func NewCachedUserRepository(next UserRepository, opts ...CacheOption) *CachedUserRepository {
+	c := &CachedUserRepository{
+		next:        next,
+		size:        DefaultCacheSize,
+		ttl:         DefaultCacheTTL,
+		negativeTTL: DefaultCacheNegativeTTL,
+		now:         time.Now,
+		order:       list.New(),
+		items:       make(map[string]*list.Element),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Stats retorna os contadores de acertos, faltas e descartes do cache.
+func (c *CachedUserRepository) Stats() CacheStats {
+	c.mu.Lock()
+	size := c.order.Len()
+	c.mu.Unlock()
+
+	return CacheStats{
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+		Evictions: c.evictions.Load(),
+		Size:      size,
+	}
+}
+
+// GetByID devolve o usuario do cache ou, no miss, le do repository decorado.
+//
+// A leitura compartilhada pelo singleflight roda com context.WithoutCancel: se a
+// requisicao que disparou a leitura for cancelada, as outras que esperam o mesmo id
+// ainda recebem o resultado. Cada chamador continua respeitando o proprio contexto.
+func (c *CachedUserRepository) GetByID(ctx context.Context, id string) (*model.User, error) {
+	key := userKey(tenantOf(ctx), id)
+	if user, ok := c.lookup(key); ok {
+		c.hits.Add(1)
+		return user, nil
+	}
+	c.misses.Add(1)
+
+	ch := c.group.DoChan(key, func() (any, error) {
+		c.mu.Lock()
+		gen := c.generation
+		c.mu.Unlock()
+
+		user, err := c.next.GetByID(context.WithoutCancel(ctx), id)
+		if err != nil {
+			return nil, err
+		}
+		c.store(key, user, gen)
+		return user, nil
+	})
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-ch:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return cloneUser(res.Val.(*model.User)), nil
+	}
+}
+
+func (c *CachedUserRepository) Create(ctx context.Context, user model.User) error {
+	// Remove um possivel "nao encontrado" guardado para o id.
+	defer c.invalidate(userKey(tenantOf(ctx), user.ID))
+	return c.next.Create(ctx, user)
+}
+
+func (c *CachedUserRepository) GetByEmail(ctx context.Context, email string) (*model.User, error) {
+	return c.next.GetByEmail(ctx, email)
+}
+
+func (c *CachedUserRepository) GetAll(ctx context.Context, input model.ListUsersInput) (*model.UserPage, error) {
+	return c.next.GetAll(ctx, input)
+}
+
+// As escritas invalidam a entrada mesmo quando falham: uma falha (timeout, por
+// exemplo) nao garante que a escrita nao foi aplicada.
+
+func (c *CachedUserRepository) Update(ctx context.Context, id string, input model.UpdateUserInput) error {
+	defer c.invalidate(userKey(tenantOf(ctx), id))
+	return c.next.Update(ctx, id, input)
+}
+
+func (c *CachedUserRepository) Patch(ctx context.Context, id string, input model.PatchUserInput) (*model.User, error) {
+	defer c.invalidate(userKey(tenantOf(ctx), id))
+	return c.next.Patch(ctx, id, input)
+}
+
+func (c *CachedUserRepository) Delete(ctx context.Context, id string) error {
+	defer c.invalidate(userKey(tenantOf(ctx), id))
+	return c.next.Delete(ctx, id)
+}
+
+func (c *CachedUserRepository) Restore(ctx context.Context, id string) (*model.User, error) {
+	defer c.invalidate(userKey(tenantOf(ctx), id))
+	return c.next.Restore(ctx, id)
+}
+
+func (c *CachedUserRepository) BatchCreate(ctx context.Context, users []model.User) ([]model.BatchResult, error) {
+	ids := make([]string, len(users))
+	for i, u := range users {
+		ids[i] = u.ID
+	}
+	defer c.invalidate(userKeys(tenantOf(ctx), ids)...)
+	return c.next.BatchCreate(ctx, users)
+}
+
+func (c *CachedUserRepository) BatchGet(ctx context.Context, ids []string) ([]model.BatchResult, error) {
+	return c.next.BatchGet(ctx, ids)
+}
+
+func (c *CachedUserRepository) BatchDelete(ctx context.Context, ids []string) ([]model.BatchResult, error) {
+	defer c.invalidate(userKeys(tenantOf(ctx), ids)...)
+	return c.next.BatchDelete(ctx, ids)
+}
+
+func (c *CachedUserRepository) History(ctx context.Context, userID string, input model.ListHistoryInput) (*model.UserEventPage, error) {
+	return c.next.History(ctx, userID, input)
+}
+
+func (c *CachedUserRepository) Export(ctx context.Context, includeDeleted bool, fn func(model.User) error) error {
+	return c.next.Export(ctx, includeDeleted, fn)
+}
+
+func (c *CachedUserRepository) Count(ctx context.Context, includeDeleted bool) (int64, error) {
+	return c.next.Count(ctx, includeDeleted)
+}
+
+// lookup retorna a entrada valida da chave, movendo-a para a frente da lista (LRU).
+// Entradas expiradas sao removidas aqui mesmo.
+func (c *CachedUserRepository) lookup(key string) (*model.User, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := el.Value.(*cacheEntry)
+	if !c.now().Before(entry.expiresAt) {
+		c.order.Remove(el)
+		delete(c.items, key)
+		return nil, false
+	}
+
+	c.order.MoveToFront(el)
+	return cloneUser(entry.user), true
+}
+
+// store grava o resultado de uma leitura, desde que nenhuma invalidacao tenha
+// acontecido desde gen. Ao passar do tamanho maximo, descarta o item do fim da lista.
+func (c *CachedUserRepository) store(key string, user *model.User, gen uint64) {
+	ttl := c.ttl
+	if user == nil {
+		ttl = c.negativeTTL
+	}
+	if ttl <= 0 || c.size <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.generation != gen {
+		return
+	}
+
+	entry := &cacheEntry{key: key, user: cloneUser(user), expiresAt: c.now().Add(ttl)}
+	if el, ok := c.items[key]; ok {
+		el.Value = entry
+		c.order.MoveToFront(el)
+		return
+	}
+
+	c.items[key] = c.order.PushFront(entry)
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*cacheEntry).key)
+		c.evictions.Add(1)
+	}
+}
+
+// invalidate remove as chaves do cache e descarta leituras em andamento para elas.
+func (c *CachedUserRepository) invalidate(keys ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	for _, key := range keys {
+		if el, ok := c.items[key]; ok {
+			c.order.Remove(el)
+			delete(c.items, key)
+		}
+		// Chamadas de GetByID que chegarem depois daqui fazem uma leitura nova em
+		// vez de esperar a leitura em andamento, que pode ter visto o valor antigo.
+		c.group.Forget(key)
+	}
+}
+
+// cloneUser devolve uma copia para que quem recebe o usuario nao altere o valor
+// guardado no cache.
+func cloneUser(user *model.User) *model.User {
+	if user == nil {
+		return nil
+	}
+	u := *user
+	return &u
+}
@@ -0,0 +1,273 @@
+package repository
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dowglassantana/golang-with-dynamodb/internal/model"
+	"github.com/dowglassantana/golang-with-dynamodb/internal/requestctx"
+)
+
+// countingRepository conta as leituras de GetByID que chegam ao repository decorado.
+// Com hold, a primeira leitura espera o canal fechar depois de ler o valor, para o
+// teste escrever enquanto ela esta em andamento.
+type countingRepository struct {
+	UserRepository
+	gets atomic.Int32
+
+	hold chan struct{}
+	read chan struct{}
+}
+
+func (r *countingRepository) GetByID(ctx context.Context, id string) (*model.User, error) {
+	n := r.gets.Add(1)
+	user, err := r.UserRepository.GetByID(ctx, id)
+	if n == 1 && r.hold != nil {
+		close(r.read)
+		<-r.hold
+	}
+	return user, err
+}
+
+func newTestCache(t *testing.T, opts ...CacheOption) (*CachedUserRepository, *countingRepository, model.User) {
+	t.Helper()
+	next := &countingRepository{UserRepository: NewMemoryUserRepository()}
+	user := model.NewUser("Ana", "ana@email.com")
+	if err := next.Create(context.Background(), user); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	return NewCachedUserRepository(next, opts...), next, user
+}
+
+func mustGet(t *testing.T, c *CachedUserRepository, ctx context.Context, id string) *model.User {
+	t.Helper()
+	user, err := c.GetByID(ctx, id)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	return user
+}
+
+func TestCacheReadThrough(t *testing.T) {
+	c, next, user := newTestCache(t)
+	ctx := context.Background()
+
+	for range 3 {
+		if got := mustGet(t, c, ctx, user.ID); got == nil || got.Name != "Ana" {
+			t.Fatalf("GetByID = %+v", got)
+		}
+	}
+	if n := next.gets.Load(); n != 1 {
+		t.Errorf("%d leituras no repository, quer 1", n)
+	}
+
+	// Alterar o usuario devolvido nao altera o valor guardado.
+	mustGet(t, c, ctx, user.ID).Name = "alterado"
+	if got := mustGet(t, c, ctx, user.ID); got.Name != "Ana" {
+		t.Errorf("entrada do cache alterada pelo chamador: %+v", got)
+	}
+
+	if st := c.Stats(); st.Hits != 4 || st.Misses != 1 || st.Size != 1 {
+		t.Errorf("Stats = %+v", st)
+	}
+}
+
+// TestCacheWriteDuringLoad escreve enquanto uma leitura esta em andamento: a leitura
+// antiga nao pode gravar o valor que viu, e as novas leituras nao podem espera-la.
+func TestCacheWriteDuringLoad(t *testing.T) {
+	c, next, user := newTestCache(t)
+	next.hold, next.read = make(chan struct{}), make(chan struct{})
+	ctx := context.Background()
+
+	stale := make(chan *model.User)
+	go func() {
+		u, _ := c.GetByID(ctx, user.ID)
+		stale <- u
+	}()
+	<-next.read // a primeira leitura ja viu a versao 1
+
+	if err := c.Update(ctx, user.ID, model.UpdateUserInput{Name: "Ana B", Email: user.Email}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	// A leitura depois do Update nao entra no singleflight da leitura antiga.
+	if got := mustGet(t, c, ctx, user.ID); got.Name != "Ana B" {
+		t.Errorf("GetByID depois do Update = %+v, quer o nome novo", got)
+	}
+
+	close(next.hold)
+	if got := <-stale; got.Name != "Ana" {
+		t.Errorf("leitura em andamento = %+v, quer o valor que ela leu", got)
+	}
+
+	if got := mustGet(t, c, ctx, user.ID); got.Name != "Ana B" {
+		t.Errorf("cache com valor antigo depois da leitura em andamento terminar: %+v", got)
+	}
+	if n := next.gets.Load(); n != 2 {
+		t.Errorf("%d leituras no repository, quer 2", n)
+	}
+}
+
+func TestCacheWritesInvalidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		write func(c *CachedUserRepository, ctx context.Context, user model.User) error
+	}{
+		{"Update", func(c *CachedUserRepository, ctx context.Context, u model.User) error {
+			return c.Update(ctx, u.ID, model.UpdateUserInput{Name: "Ana B", Email: u.Email})
+		}},
+		{"Patch", func(c *CachedUserRepository, ctx context.Context, u model.User) error {
+			_, err := c.Patch(ctx, u.ID, model.PatchUserInput{Name: model.Nullable[string]{Value: "Ana B", Set: true}})
+			return err
+		}},
+		{"Delete", func(c *CachedUserRepository, ctx context.Context, u model.User) error {
+			return c.Delete(ctx, u.ID)
+		}},
+		{"Restore", func(c *CachedUserRepository, ctx context.Context, u model.User) error {
+			// A exclusao vai direto ao repository: o cache ainda tem o usuario ativo.
+			if err := c.next.Delete(ctx, u.ID); err != nil {
+				return err
+			}
+			_, err := c.Restore(ctx, u.ID)
+			return err
+		}},
+		{"BatchDelete", func(c *CachedUserRepository, ctx context.Context, u model.User) error {
+			_, err := c.BatchDelete(ctx, []string{u.ID})
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, next, user := newTestCache(t)
+			ctx := context.Background()
+			mustGet(t, c, ctx, user.ID)
+			if err := tt.write(c, ctx, user); err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+			want, _ := next.UserRepository.GetByID(ctx, user.ID)
+
+			got := mustGet(t, c, ctx, user.ID)
+			if n := next.gets.Load(); n != 2 {
+				t.Errorf("%d leituras no repository, quer 2 (a escrita deveria invalidar a entrada)", n)
+			}
+			if (got == nil) != (want == nil) || (got != nil && *got != *want) {
+				t.Errorf("GetByID = %+v, quer %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestCacheNegativeEntryClearedByCreate(t *testing.T) {
+	c, next, _ := newTestCache(t)
+	ctx := context.Background()
+	user := model.NewUser("Bia", "bia@email.com")
+
+	if got := mustGet(t, c, ctx, user.ID); got != nil {
+		t.Fatalf("GetByID de inexistente = %+v", got)
+	}
+	mustGet(t, c, ctx, user.ID) // "nao encontrado" vem do cache
+	if n := next.gets.Load(); n != 1 {
+		t.Errorf("%d leituras no repository, quer 1", n)
+	}
+
+	if err := c.Create(ctx, user); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if got := mustGet(t, c, ctx, user.ID); got == nil {
+		t.Error("Create deveria remover o \"nao encontrado\" do cache")
+	}
+}
+
+func TestCacheKeysByTenant(t *testing.T) {
+	c, next, user := newTestCache(t)
+	acme := requestctx.WithTenant(context.Background(), "acme")
+	if err := next.Create(acme, user); err != nil {
+		t.Fatalf("Create em acme: %v", err)
+	}
+	globex := requestctx.WithTenant(context.Background(), "globex")
+	next.gets.Store(0)
+
+	if mustGet(t, c, acme, user.ID) == nil {
+		t.Fatal("usuario nao encontrado em acme")
+	}
+	if got := mustGet(t, c, globex, user.ID); got != nil {
+		t.Errorf("globex recebeu o usuario de acme: %+v", got)
+	}
+	// A entrada negativa de globex nao afeta acme, e vice-versa.
+	if mustGet(t, c, acme, user.ID) == nil || mustGet(t, c, globex, user.ID) != nil {
+		t.Error("entradas de tenants diferentes se misturaram")
+	}
+	if n := next.gets.Load(); n != 2 {
+		t.Errorf("%d leituras no repository, quer 2 (uma por tenant)", n)
+	}
+
+	// Uma escrita em globex nao invalida a entrada de acme.
+	c.Delete(globex, user.ID)
+	mustGet(t, c, acme, user.ID)
+	if n := next.gets.Load(); n != 2 {
+		t.Errorf("escrita em globex invalidou acme (%d leituras)", n)
+	}
+}
+
+func TestCacheLRUAndTTL(t *testing.T) {
+	c, next, a := newTestCache(t, WithCacheSize(2), WithCacheTTL(time.Minute), WithCacheNegativeTTL(time.Second))
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	c.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	b, d := model.NewUser("Bia", "bia@email.com"), model.NewUser("Dani", "dani@email.com")
+	for _, u := range []model.User{b, d} {
+		if err := next.Create(ctx, u); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+	next.gets.Store(0)
+
+	mustGet(t, c, ctx, a.ID)
+	mustGet(t, c, ctx, b.ID)
+	mustGet(t, c, ctx, a.ID) // a passa a ser o mais recente
+	mustGet(t, c, ctx, d.ID) // descarta b
+	if st := c.Stats(); st.Evictions != 1 || st.Size != 2 {
+		t.Errorf("Stats = %+v, quer 1 descarte e 2 entradas", st)
+	}
+	mustGet(t, c, ctx, a.ID)
+	if n := next.gets.Load(); n != 3 {
+		t.Errorf("%d leituras, quer 3 (a continua no cache)", n)
+	}
+	mustGet(t, c, ctx, b.ID)
+	if n := next.gets.Load(); n != 4 {
+		t.Errorf("%d leituras, quer 4 (b foi descartado)", n)
+	}
+
+	now = now.Add(time.Minute)
+	mustGet(t, c, ctx, b.ID)
+	if n := next.gets.Load(); n != 5 {
+		t.Errorf("%d leituras, quer 5 (entrada expirada)", n)
+	}
+
+	// O "nao encontrado" expira com o TTL negativo, bem menor.
+	missing := model.NewUser("X", "x@email.com").ID
+	mustGet(t, c, ctx, missing)
+	mustGet(t, c, ctx, missing)
+	now = now.Add(time.Second)
+	mustGet(t, c, ctx, missing)
+	if n := next.gets.Load(); n != 7 {
+		t.Errorf("%d leituras, quer 7 (negativo guardado por 1s)", n)
+	}
+}
+
+func TestCacheSizeZeroStoresNothing(t *testing.T) {
+	c, next, user := newTestCache(t, WithCacheSize(0))
+	ctx := context.Background()
+
+	mustGet(t, c, ctx, user.ID)
+	mustGet(t, c, ctx, user.ID)
+	if n := next.gets.Load(); n != 2 {
+		t.Errorf("%d leituras, quer 2 (cache de tamanho 0 nao guarda nada)", n)
+	}
+	if st := c.Stats(); st.Size != 0 {
+		t.Errorf("Stats = %+v", st)
+	}
+}
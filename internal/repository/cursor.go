@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// cursorCodec transforma o LastEvaluatedKey do DynamoDB em um token opaco e vice-versa.
+//
+// O LastEvaluatedKey e a chave do ultimo item avaliado pelo Scan/Query. Para continuar
+// a leitura, basta envia-lo de volta como ExclusiveStartKey na proxima chamada.
+//
+// O token tem o formato "<payload>.<assinatura>", ambos em base64 URL-safe:
+//   - payload: o LastEvaluatedKey serializado em JSON.
+//   - assinatura: HMAC-SHA256 do payload com um segredo do servidor.
+//
+// A assinatura torna o cursor a prova de adulteracao: um cliente nao consegue montar
+// um ExclusiveStartKey arbitrario, porque nao conhece o segredo.
+type cursorCodec struct {
+	secret []byte
+}
+
+// newCursorCodec cria o codec. Sem segredo configurado, gera um aleatorio —
+// nesse caso os cursores deixam de valer quando o processo reinicia.
+func newCursorCodec(secret []byte) cursorCodec {
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		rand.Read(secret)
+	}
+	return cursorCodec{secret: secret}
+}
+
+func (c cursorCodec) encode(key map[string]types.AttributeValue) (string, error) {
+	if len(key) == 0 {
+		return "", nil
+	}
+
+	var raw map[string]any
+	if err := attributevalue.UnmarshalMap(key, &raw); err != nil {
+		return "", err
+	}
+
+	payload, err := json.Marshal(raw)
+	if err != nil {
+		return "", err
+	}
+
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString(payload) + "." + enc.EncodeToString(c.sign(payload)), nil
+}
+
+func (c cursorCodec) decode(token string) (map[string]types.AttributeValue, error) {
+	if token == "" {
+		return nil, nil
+	}
+
+	enc := base64.RawURLEncoding
+	payloadPart, sigPart, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, ErrInvalidCursor
+	}
+
+	payload, err := enc.DecodeString(payloadPart)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	sig, err := enc.DecodeString(sigPart)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	// hmac.Equal compara em tempo constante, evitando ataques de timing.
+	if !hmac.Equal(sig, c.sign(payload)) {
+		return nil, ErrInvalidCursor
+	}
+
+	var raw map[string]any
+	if err := json.Unmarshal(payload, &raw); err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	key, err := attributevalue.MarshalMap(raw)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return key, nil
+}
+
+func (c cursorCodec) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, c.secret)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
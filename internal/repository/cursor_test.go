@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"encoding/base64"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func testKey() map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"id":     &types.AttributeValueMemberS{Value: "TENANT#default#USER#1"},
+		"tenant": &types.AttributeValueMemberS{Value: "default"},
+	}
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	codec := newCursorCodec([]byte("segredo"))
+
+	token, err := codec.encode(testKey())
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	key, err := codec.decode(token)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if !reflect.DeepEqual(key, testKey()) {
+		t.Errorf("decode = %#v, quer %#v", key, testKey())
+	}
+}
+
+func TestCursorEmpty(t *testing.T) {
+	codec := newCursorCodec([]byte("segredo"))
+
+	if token, err := codec.encode(nil); token != "" || err != nil {
+		t.Errorf("encode(nil) = %q, %v; quer token vazio", token, err)
+	}
+	if key, err := codec.decode(""); key != nil || err != nil {
+		t.Errorf("decode(\"\") = %v, %v; quer nil", key, err)
+	}
+}
+
+func TestCursorRejectsTampering(t *testing.T) {
+	codec := newCursorCodec([]byte("segredo"))
+	token, err := codec.encode(testKey())
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	payload, sig, _ := strings.Cut(token, ".")
+
+	// Um payload trocado com a assinatura original: o cliente tenta ler outro usuario.
+	forged := base64.RawURLEncoding.EncodeToString([]byte(`{"id":"TENANT#outro#USER#1","tenant":"outro"}`))
+
+	otherToken, err := newCursorCodec([]byte("outro segredo")).encode(testKey())
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"sem assinatura", payload},
+		{"payload adulterado", forged + "." + sig},
+		{"assinatura de outro segredo", otherToken},
+		{"assinatura truncada", payload + "." + sig[:len(sig)-2]},
+		{"base64 invalido", "!!!." + sig},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := codec.decode(tt.token); !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("decode = %v, quer ErrInvalidCursor", err)
+			}
+		})
+	}
+}
+
+func TestCursorRandomSecret(t *testing.T) {
+	// Sem segredo configurado, cada processo gera o seu: o cursor de um nao vale no outro.
+	token, err := newCursorCodec(nil).encode(testKey())
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	if _, err := newCursorCodec(nil).decode(token); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("decode = %v, quer ErrInvalidCursor", err)
+	}
+}
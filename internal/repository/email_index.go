@@ -0,0 +1,22 @@
+package repository
+
+// GSI (Global Secondary Index) por email.
+//
+// Um GSI e uma "copia" da tabela organizada por outra chave. Aqui a partition key do
+// indice e email_hash, o blind index do email (veja encryption.go), o que permite
+// buscar um usuario por email com Query em vez de Scan — sem que o email em claro
+// esteja na tabela.
+//
+// O indice e esparso: so entram nele os itens que possuem o atributo email_hash.
+// Os sentinelas EMAIL#... nao tem esse atributo, entao nunca aparecem nas buscas.
+//
+// ProjectionType ALL copia todos os atributos do item para o indice, para que a Query
+// devolva o usuario completo sem precisar de um GetItem adicional.
+//
+// O indice e criado pela migracao 0009_encrypt_email (veja internal/migrate). Ele
+// substitui o email-index da migracao 0002, que tinha o email em claro como chave.
+
+const (
+	emailIndexName = "email-hash-index"
+	emailIndexKey  = emailHashAttr
+)
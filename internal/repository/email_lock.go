@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Unicidade de email com itens sentinela.
+//
+// O DynamoDB so garante unicidade da chave primaria — nao existe "UNIQUE constraint"
+// em outros atributos. O padrao para resolver isso e gravar um segundo item cuja
+// chave E o valor que queremos unico:
+//
+//	{ "id": "TENANT#<t>#EMAIL#<email_hash>", "item_type": "email_lock", "user_id": "<uuid>" }
+//
+// Esse item sentinela e gravado na mesma transacao (TransactWriteItems) que o usuario,
+// com ConditionExpression "attribute_not_exists(id)". Se outro usuario ja reservou o
+// email, a condicao falha e a transacao inteira e cancelada — nenhum dos dois itens
+// e gravado.
+//
+// O sentinela leva o tenant na chave, entao o email e unico dentro de cada tenant: dois
+// clientes diferentes podem ter um usuario com o mesmo email.
+//
+// No DynamoDB a chave usa o blind index do email (email_hash, veja encryption.go), e
+// nao o email em claro — a chave de um item nao pode ser cifrada, mas o HMAC tambem e
+// unico por email. O MemoryUserRepository usa o proprio email normalizado.
+//
+// O atributo item_type diferencia os itens auxiliares dos usuarios: o Scan filtra
+// com attribute_not_exists(item_type) e as leituras por id ignoram esses itens.
+
+const (
+	emailLockPrefix = "EMAIL#"
+	itemTypeAttr    = "item_type"
+	itemTypeEmail   = "email_lock"
+)
+
+// emailLockDynamo e a representacao do item sentinela no DynamoDB.
+type emailLockDynamo struct {
+	ID       string `dynamodbav:"id"`
+	ItemType string `dynamodbav:"item_type"`
+	UserID   string `dynamodbav:"user_id"`
+}
+
+// normalizeEmail padroniza o email para comparacao: "Joao@Email.com " e
+// "joao@email.com" devem reservar o mesmo sentinela.
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// emailLockKey monta a chave do sentinela a partir do email ja normalizado ou do seu
+// blind index.
+func emailLockKey(tenant, email string) string {
+	return tenantPK(tenant) + "#" + emailLockPrefix + email
+}
+
+func newEmailLock(key, userID string) emailLockDynamo {
+	return emailLockDynamo{
+		ID:       key,
+		ItemType: itemTypeEmail,
+		UserID:   userID,
+	}
+}
+
+// isAuxItem indica se o item lido e um item auxiliar (sentinela, etc.) e nao um usuario.
+func isAuxItem(item map[string]types.AttributeValue) bool {
+	_, ok := item[itemTypeAttr]
+	return ok
+}
+
+// transactionFailedAt verifica se a transacao foi cancelada por falha de condicao
+// no item de indice idx.
+//
+// Quando uma TransactWriteItems e cancelada, o DynamoDB retorna
+// TransactionCanceledException com uma lista CancellationReasons — uma entrada por
+// item, na mesma ordem em que foram enviados. O Code "ConditionalCheckFailed"
+// indica qual condicao impediu a transacao.
+func transactionFailedAt(err error, idx int) bool {
+	var canceled *types.TransactionCanceledException
+	if !errors.As(err, &canceled) {
+		return false
+	}
+	if idx >= len(canceled.CancellationReasons) {
+		return false
+	}
+	code := canceled.CancellationReasons[idx].Code
+	return code != nil && *code == "ConditionalCheckFailed"
+}
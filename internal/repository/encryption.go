@@ -0,0 +1,219 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/dowglassantana/golang-with-dynamodb/internal/fieldcrypt"
+	"github.com/dowglassantana/golang-with-dynamodb/internal/model"
+)
+
+// Email cifrado no item (veja internal/fieldcrypt).
+//
+// O email nunca e gravado em claro. No item do usuario ele vira dois atributos:
+//
+//	email_enc  = { key_id, data_key, ciphertext }  (Map, o envelope do AES-GCM)
+//	email_hash = HMAC(tenant, email normalizado)     (blind index)
+//
+// email_hash ocupa o lugar que era do email em claro: e a partition key do GSI
+// email-hash-index, usado por GetByEmail, e entra na chave do sentinela de unicidade
+// ("TENANT#<t>#EMAIL#<email_hash>"). O tenant entra no HMAC, entao o mesmo email gera
+// valores diferentes em tenants diferentes.
+//
+// O aad do envelope e a chave do usuario ("TENANT#<t>#USER#<id>"): um email_enc
+// copiado para outro usuario nao decifra. Os eventos de historico cifram o email dos
+// snapshots com o mesmo aad (history_pk e a chave do usuario).
+//
+// Dentro do processo o email continua em claro em userDynamo.Email; marshalUser e
+// unmarshalUser fazem a troca na fronteira com o DynamoDB.
+//
+// A migracao 0009_encrypt_email cria o GSI e cifra os itens gravados antes disso.
+
+const (
+	emailEncAttr  = "email_enc"
+	emailHashAttr = "email_hash"
+)
+
+// sealedField e um fieldcrypt.Envelope gravado como atributo do tipo Map.
+type sealedField struct {
+	KeyID      string `dynamodbav:"key_id"`
+	DataKey    []byte `dynamodbav:"data_key"`
+	Ciphertext []byte `dynamodbav:"ciphertext"`
+}
+
+func (f sealedField) envelope() fieldcrypt.Envelope {
+	return fieldcrypt.Envelope{KeyID: f.KeyID, DataKey: f.DataKey, Ciphertext: f.Ciphertext}
+}
+
+// emailAAD amarra o email cifrado ao usuario dono dele.
+func emailAAD(userKey string) []byte {
+	return []byte(userKey + "#email")
+}
+
+// emailHash e o blind index do email no tenant.
+func (r *DynamoUserRepository) emailHash(tenant, email string) string {
+	return r.sealer.BlindIndex(tenant, normalizeEmail(email))
+}
+
+// emailLock devolve a chave do sentinela do email no tenant.
+func (r *DynamoUserRepository) emailLock(tenant, email string) string {
+	return emailLockKey(tenant, r.emailHash(tenant, email))
+}
+
+func (r *DynamoUserRepository) sealEmail(ctx context.Context, userKey, email string) (*sealedField, error) {
+	env, err := r.sealer.Seal(ctx, []byte(email), emailAAD(userKey))
+	if err != nil {
+		return nil, fmt.Errorf("erro ao cifrar email: %w", err)
+	}
+	return &sealedField{KeyID: env.KeyID, DataKey: env.DataKey, Ciphertext: env.Ciphertext}, nil
+}
+
+func (r *DynamoUserRepository) openEmail(ctx context.Context, userKey string, f *sealedField) (string, error) {
+	email, err := r.sealer.Open(ctx, f.envelope(), emailAAD(userKey))
+	if err != nil {
+		return "", fmt.Errorf("erro ao decifrar email: %w", err)
+	}
+	return string(email), nil
+}
+
+// marshalUser serializa o usuario com o email cifrado. dm nao e alterado.
+func (r *DynamoUserRepository) marshalUser(ctx context.Context, dm userDynamo) (map[string]types.AttributeValue, error) {
+	if dm.Email != "" {
+		enc, err := r.sealEmail(ctx, dm.Key, dm.Email)
+		if err != nil {
+			return nil, err
+		}
+		dm.EmailEnc = enc
+		dm.EmailHash = r.emailHash(dm.TenantID, dm.Email)
+		dm.Email = ""
+	}
+
+	item, err := attributevalue.MarshalMap(dm)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao serializar usuario: %w", err)
+	}
+	return item, nil
+}
+
+// unmarshalUser desserializa um item de usuario e decifra o email. Um item cifrado
+// com uma chave mestra antiga e agendado para re-cifragem (veja key_rotation.go).
+// Itens ainda nao cifrados (gravados antes da migracao 0009) sao lidos como estao.
+func (r *DynamoUserRepository) unmarshalUser(ctx context.Context, item map[string]types.AttributeValue) (userDynamo, error) {
+	var dm userDynamo
+	if err := attributevalue.UnmarshalMap(item, &dm); err != nil {
+		return userDynamo{}, fmt.Errorf("erro ao desserializar usuario: %w", err)
+	}
+	if dm.EmailEnc == nil {
+		return dm, nil
+	}
+
+	email, err := r.openEmail(ctx, dm.Key, dm.EmailEnc)
+	if err != nil {
+		return userDynamo{}, err
+	}
+	dm.Email = email
+
+	if r.sealer.Stale(dm.EmailEnc.envelope()) {
+		r.rotator.schedule(ctx, dm.Key)
+	}
+	return dm, nil
+}
+
+// unmarshalUsers aplica unmarshalUser a uma lista de itens.
+func (r *DynamoUserRepository) unmarshalUsers(ctx context.Context, items []map[string]types.AttributeValue) ([]userDynamo, error) {
+	models := make([]userDynamo, 0, len(items))
+	for _, item := range items {
+		dm, err := r.unmarshalUser(ctx, item)
+		if err != nil {
+			return nil, err
+		}
+		models = append(models, dm)
+	}
+	return models, nil
+}
+
+// marshalEvent serializa o evento de historico com o email dos snapshots cifrado.
+func (r *DynamoUserRepository) marshalEvent(ctx context.Context, event userEventDynamo) (map[string]types.AttributeValue, error) {
+	for _, s := range []*userSnapshotDynamo{event.Before, event.After} {
+		if s == nil || s.Email == "" {
+			continue
+		}
+		sealed := *s
+		enc, err := r.sealEmail(ctx, event.HistoryPK, s.Email)
+		if err != nil {
+			return nil, err
+		}
+		sealed.EmailEnc, sealed.Email = enc, ""
+		if s == event.Before {
+			event.Before = &sealed
+		} else {
+			event.After = &sealed
+		}
+	}
+
+	item, err := attributevalue.MarshalMap(event)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao serializar evento: %w", err)
+	}
+	return item, nil
+}
+
+// unmarshalEvents desserializa eventos de historico e decifra o email dos snapshots.
+func (r *DynamoUserRepository) unmarshalEvents(ctx context.Context, items []map[string]types.AttributeValue) ([]userEventDynamo, error) {
+	var events []userEventDynamo
+	if err := attributevalue.UnmarshalListOfMaps(items, &events); err != nil {
+		return nil, fmt.Errorf("erro ao desserializar historico: %w", err)
+	}
+
+	for i := range events {
+		stale := false
+		for _, s := range []*userSnapshotDynamo{events[i].Before, events[i].After} {
+			if s == nil || s.EmailEnc == nil {
+				continue
+			}
+			email, err := r.openEmail(ctx, events[i].HistoryPK, s.EmailEnc)
+			if err != nil {
+				return nil, err
+			}
+			s.Email = email
+			stale = stale || r.sealer.Stale(s.EmailEnc.envelope())
+		}
+		if stale {
+			r.rotator.schedule(ctx, events[i].ID)
+		}
+	}
+	return events, nil
+}
+
+// DecodeUserItem converte um item cru da tabela (por exemplo, a imagem de um registro
+// do DynamoDB Streams) em model.User, decifrando o email com sealer. Itens auxiliares
+// — sentinelas de email e eventos de historico — retornam ok = false. tenant e o
+// tenant dono do usuario.
+func DecodeUserItem(ctx context.Context, sealer *fieldcrypt.Sealer, item map[string]types.AttributeValue) (user model.User, tenant string, ok bool, err error) {
+	if len(item) == 0 || isAuxItem(item) {
+		return model.User{}, "", false, nil
+	}
+
+	var dm userDynamo
+	if err := attributevalue.UnmarshalMap(item, &dm); err != nil {
+		return model.User{}, "", false, fmt.Errorf("erro ao desserializar usuario: %w", err)
+	}
+
+	// Itens gravados antes da migracao 0006_tenant_keys nao tem user_id. A propria
+	// migracao os remove ao move-los para o tenant padrao, e essa remocao nao e a
+	// exclusao de um usuario.
+	if dm.ID == "" {
+		return model.User{}, "", false, nil
+	}
+
+	if dm.EmailEnc != nil {
+		email, err := sealer.Open(ctx, dm.EmailEnc.envelope(), emailAAD(dm.Key))
+		if err != nil {
+			return model.User{}, "", false, fmt.Errorf("erro ao decifrar email: %w", err)
+		}
+		dm.Email = string(email)
+	}
+	return dm.toUser(), dm.TenantID, true, nil
+}
@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
+	"github.com/dowglassantana/golang-with-dynamodb/internal/logging"
+)
+
+var (
+	ErrInvalidCursor = errors.New("cursor invalido")
+	ErrEmailTaken    = errors.New("email ja cadastrado")
+	ErrIDTaken       = errors.New("id ja cadastrado")
+	ErrStaleVersion  = errors.New("usuario foi alterado por outra requisicao")
+	ErrUnprocessed   = errors.New("item nao processado pelo DynamoDB apos novas tentativas")
+)
+
+// Taxonomia dos erros do repository.
+//
+// Os erros do SDK sao tipos especificos do DynamoDB (ConditionalCheckFailedException,
+// ProvisionedThroughputExceededException, ...). Para que as camadas de cima nao
+// dependam do SDK, translateError classifica o erro em uma destas categorias. O erro
+// original continua na cadeia de Unwrap, entao errors.As com o tipo do SDK segue
+// funcionando.
+var (
+	// ErrNotFound: o usuario nao existe (ou foi excluido).
+	ErrNotFound = errors.New("usuario nao encontrado")
+	// ErrConditionFailed: uma ConditionExpression rejeitou a escrita.
+	ErrConditionFailed = errors.New("condicao da escrita nao foi satisfeita")
+	// ErrThrottled: a tabela excedeu a capacidade ou o limite de requisicoes da conta,
+	// mesmo depois das novas tentativas automaticas do SDK.
+	ErrThrottled = errors.New("limite de requisicoes do DynamoDB excedido")
+	// ErrValidation: o DynamoDB recusou a requisicao por ser invalida (item grande
+	// demais, expressao malformada, atributo de chave vazio, ...).
+	ErrValidation = errors.New("requisicao recusada pelo DynamoDB")
+	// ErrTransactionConflict: outra transacao estava alterando o mesmo item.
+	ErrTransactionConflict = errors.New("conflito com outra transacao em andamento")
+)
+
+// translateError associa um erro do SDK a uma categoria da taxonomia. Erros que nao
+// se encaixam em nenhuma sao devolvidos sem alteracao.
+//
+// Numa TransactWriteItems cancelada, o motivo de cada item vem em CancellationReasons;
+// usamos o primeiro motivo diferente de "None".
+func translateError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var canceled *types.TransactionCanceledException
+	if errors.As(err, &canceled) {
+		for _, reason := range canceled.CancellationReasons {
+			if kind := kindOf(aws.ToString(reason.Code)); kind != nil {
+				return fmt.Errorf("%w: %w", kind, err)
+			}
+		}
+		return err
+	}
+
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		if kind := kindOf(apiErr.ErrorCode()); kind != nil {
+			return fmt.Errorf("%w: %w", kind, err)
+		}
+	}
+	return err
+}
+
+// dynamoError traduz o erro de uma chamada ao DynamoDB (veja translateError), o
+// prefixa com msg e registra a falha no logger do contexto — que, numa requisicao
+// HTTP, ja traz o request_id e o tenant.
+//
+// O nivel segue a gravidade: condicao falha e cancelamento pelo cliente sao parte do
+// fluxo normal (DEBUG), throttling e conflito de transacao sao passageiros (WARN) e o
+// resto e um erro de verdade (ERROR). O texto do erro pode citar valores da requisicao;
+// emails sao mascarados pelo proprio logger.
+func dynamoError(ctx context.Context, msg string, err error) error {
+	err = fmt.Errorf("%s: %w", msg, translateError(err))
+
+	level := slog.LevelError
+	switch {
+	case errors.Is(err, ErrConditionFailed), errors.Is(err, context.Canceled):
+		level = slog.LevelDebug
+	case errors.Is(err, ErrThrottled), errors.Is(err, ErrTransactionConflict),
+		errors.Is(err, context.DeadlineExceeded):
+		level = slog.LevelWarn
+	}
+
+	attrs := []slog.Attr{slog.Any("error", err)}
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		attrs = append(attrs, slog.String("error_code", apiErr.ErrorCode()))
+	}
+	logging.FromContext(ctx).LogAttrs(ctx, level, "falha no DynamoDB", attrs...)
+
+	return err
+}
+
+// kindOf traduz os codigos de erro do DynamoDB — tanto os das excecoes quanto os
+// usados em CancellationReasons, que sao grafados sem o sufixo "Exception".
+func kindOf(code string) error {
+	switch code {
+	case "ConditionalCheckFailedException", "ConditionalCheckFailed":
+		return ErrConditionFailed
+	case "ProvisionedThroughputExceededException", "ProvisionedThroughputExceeded",
+		"RequestLimitExceeded", "ThrottlingException", "ThrottlingError":
+		return ErrThrottled
+	case "ValidationException", "ValidationError", "ItemCollectionSizeLimitExceeded":
+		return ErrValidation
+	case "TransactionConflictException", "TransactionConflict", "TransactionInProgressException":
+		return ErrTransactionConflict
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/dowglassantana/golang-with-dynamodb/internal/model"
+)
+
+// Export chama fn para cada usuario do tenant do contexto, pagina por pagina, sem
+// carregar a tabela inteira em memoria. Se fn retornar erro, a leitura para e o erro
+// e devolvido.
+//
+// Um Scan leria a tabela inteira — os usuarios de todos os tenants, mais sentinelas e
+// eventos — para descartar quase tudo no FilterExpression. Como o tenant-index e
+// esparso e particionado pelo tenant, a Query paginada nele le exatamente os usuarios
+// do tenant, em paginas de ate 1 MB.
+//
+// O paginator do SDK repete a Query com o LastEvaluatedKey da pagina anterior ate ele
+// vir vazio; nenhum cursor sai do repository.
+func (r *DynamoUserRepository) Export(ctx context.Context, includeDeleted bool, fn func(model.User) error) error {
+	query := listQueryFor(tenantOf(ctx), model.ListUsersInput{IncludeDeleted: includeDeleted})
+
+	expr, err := query.builder().Build()
+	if err != nil {
+		return fmt.Errorf("erro ao construir expressao: %w", err)
+	}
+
+	paginator := dynamodb.NewQueryPaginator(r.client, &dynamodb.QueryInput{
+		TableName:                 aws.String(r.tableName),
+		IndexName:                 aws.String(query.index),
+		KeyConditionExpression:    expr.KeyCondition(),
+		FilterExpression:          expr.Filter(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return dynamoError(ctx, "erro ao exportar usuarios", err)
+		}
+
+		models, err := r.unmarshalUsers(ctx, page.Items)
+		if err != nil {
+			return err
+		}
+		for _, m := range models {
+			if err := fn(m.toUser()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Count conta os usuarios do tenant do contexto com uma Query no tenant-index com
+// Select COUNT: o DynamoDB devolve apenas a contagem de cada pagina, sem os itens.
+// A leitura e cobrada do mesmo jeito — o indice e percorrido inteiro —, mas nada
+// trafega pela rede alem dos numeros.
+func (r *DynamoUserRepository) Count(ctx context.Context, includeDeleted bool) (int64, error) {
+	query := listQueryFor(tenantOf(ctx), model.ListUsersInput{IncludeDeleted: includeDeleted})
+
+	expr, err := query.builder().Build()
+	if err != nil {
+		return 0, fmt.Errorf("erro ao construir expressao: %w", err)
+	}
+
+	paginator := dynamodb.NewQueryPaginator(r.client, &dynamodb.QueryInput{
+		TableName:                 aws.String(r.tableName),
+		IndexName:                 aws.String(query.index),
+		KeyConditionExpression:    expr.KeyCondition(),
+		FilterExpression:          expr.Filter(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		Select:                    types.SelectCount,
+	})
+
+	var count int64
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return 0, dynamoError(ctx, "erro ao contar usuarios", err)
+		}
+		count += int64(page.Count)
+	}
+	return count, nil
+}
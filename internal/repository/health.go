@@ -0,0 +1,22 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+)
+
+// TableStatus consulta a tabela com DescribeTable e devolve o seu status (ACTIVE,
+// UPDATING, CREATING...). E a verificacao de prontidao da API: um DescribeTable so
+// funciona se a rede, as credenciais e a permissao ate o DynamoDB estiverem ok, e nao
+// consome capacidade de leitura da tabela.
+func (r *DynamoUserRepository) TableStatus(ctx context.Context) (string, error) {
+	output, err := r.client.DescribeTable(ctx, &dynamodb.DescribeTableInput{
+		TableName: aws.String(r.tableName),
+	})
+	if err != nil {
+		return "", dynamoError(ctx, "erro ao descrever a tabela", err)
+	}
+	return string(output.Table.TableStatus), nil
+}
@@ -0,0 +1,198 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/dowglassantana/golang-with-dynamodb/internal/model"
+	"github.com/dowglassantana/golang-with-dynamodb/internal/requestctx"
+	"github.com/google/uuid"
+)
+
+// Historico de alteracoes com itens de evento.
+//
+// Cada Create, Update, Delete e Restore grava um item de evento imutavel na mesma
+// TransactWriteItems da alteracao: ou os dois sao gravados, ou nenhum. Assim o
+// historico nunca fica sem um registro nem registra algo que nao aconteceu. A excecao
+// sao BatchCreate e BatchDelete: eles gravam os eventos logo depois das transacoes,
+// com BatchWriteItem (veja batch.go), e uma falha nessa etapa so vai para o log.
+//
+// Como a chave da tabela e apenas "id", o evento recebe um id proprio ("EVT#<uuid>")
+// e e agrupado por usuario no GSI history-index:
+//
+//	history_pk = "TENANT#<t>#USER#<id>"   (partition key do indice)
+//	history_sk = "EVT#<timestamp>#<uuid>" (sort key do indice)
+//
+// A sort key comeca com o timestamp em formato de largura fixa, entao a ordem
+// alfabetica e a ordem cronologica. Com ScanIndexForward = false, a Query devolve
+// os eventos do mais recente para o mais antigo. O indice e criado pela migracao
+// 0004_history_index (veja internal/migrate).
+
+const (
+	historyIndexName = "history-index"
+	historyPKAttr    = "history_pk"
+	historySKAttr    = "history_sk"
+	itemTypeEvent    = "event"
+
+	// eventTimeFormat tem largura fixa (sempre 6 casas decimais e sempre UTC) para
+	// que a comparacao de strings na sort key respeite a ordem cronologica.
+	eventTimeFormat = "2006-01-02T15:04:05.000000Z"
+)
+
+// userEventDynamo e a representacao de um evento de historico no DynamoDB.
+type userEventDynamo struct {
+	ID        string              `dynamodbav:"id"`
+	ItemType  string              `dynamodbav:"item_type"`
+	HistoryPK string              `dynamodbav:"history_pk"`
+	HistorySK string              `dynamodbav:"history_sk"`
+	UserID    string              `dynamodbav:"user_id"`
+	Action    string              `dynamodbav:"action"`
+	Actor     string              `dynamodbav:"actor"`
+	At        string              `dynamodbav:"at"`
+	Before    *userSnapshotDynamo `dynamodbav:"before,omitempty"`
+	After     *userSnapshotDynamo `dynamodbav:"after,omitempty"`
+}
+
+// userSnapshotDynamo e gravado como um atributo do tipo Map (M) dentro do evento.
+// Assim como no usuario, o email vai cifrado em EmailEnc (veja encryption.go).
+type userSnapshotDynamo struct {
+	Name      string       `dynamodbav:"name"`
+	Email     string       `dynamodbav:"email,omitempty"`
+	EmailEnc  *sealedField `dynamodbav:"email_enc,omitempty"`
+	Version   int64        `dynamodbav:"version"`
+	DeletedAt string       `dynamodbav:"deleted_at,omitempty"`
+}
+
+// historyPK e a mesma chave do usuario, entao o historico tambem fica isolado por tenant.
+func historyPK(tenant, userID string) string {
+	return userKey(tenant, userID)
+}
+
+// newUserEvent monta o evento de historico. O ator e o tenant vem do contexto da requisicao.
+func newUserEvent(ctx context.Context, userID, action string, before, after *userDynamo) userEventDynamo {
+	eventID := uuid.New().String()
+	at := time.Now().UTC().Format(eventTimeFormat)
+
+	return userEventDynamo{
+		ID:        "EVT#" + eventID,
+		ItemType:  itemTypeEvent,
+		HistoryPK: historyPK(tenantOf(ctx), userID),
+		HistorySK: "EVT#" + at + "#" + eventID,
+		UserID:    userID,
+		Action:    action,
+		Actor:     requestctx.Actor(ctx),
+		At:        at,
+		Before:    snapshotOf(before),
+		After:     snapshotOf(after),
+	}
+}
+
+func snapshotOf(dm *userDynamo) *userSnapshotDynamo {
+	if dm == nil {
+		return nil
+	}
+	return &userSnapshotDynamo{
+		Name:      dm.Name,
+		Email:     dm.Email,
+		Version:   dm.Version,
+		DeletedAt: dm.DeletedAt,
+	}
+}
+
+// putEvent monta o Put do evento para uso em transacao. A condicao
+// attribute_not_exists(id) garante que um evento nunca e sobrescrito.
+func (r *DynamoUserRepository) putEvent(ctx context.Context, event userEventDynamo) (types.TransactWriteItem, error) {
+	item, err := r.marshalEvent(ctx, event)
+	if err != nil {
+		return types.TransactWriteItem{}, err
+	}
+
+	return types.TransactWriteItem{Put: &types.Put{
+		TableName:           aws.String(r.tableName),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(id)"),
+	}}, nil
+}
+
+// History retorna uma pagina do historico de um usuario usando Query no history-index.
+//
+// A Query le apenas a particao "TENANT#<t>#USER#<id>" do indice, entao o custo e proporcional ao
+// numero de eventos do usuario — nao ao tamanho da tabela. A paginacao usa o mesmo
+// cursor assinado da listagem; em um GSI o LastEvaluatedKey inclui tanto a chave da
+// tabela (id) quanto as chaves do indice (history_pk, history_sk).
+func (r *DynamoUserRepository) History(ctx context.Context, userID string, input model.ListHistoryInput) (*model.UserEventPage, error) {
+	startKey, err := r.cursor.decode(input.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	pk := historyPK(tenantOf(ctx), userID)
+	if startKey != nil && stringAttr(startKey, historyPKAttr) != pk {
+		return nil, ErrInvalidCursor
+	}
+
+	keyCond := expression.Key(historyPKAttr).Equal(expression.Value(pk))
+	expr, err := expression.NewBuilder().WithKeyCondition(keyCond).Build()
+	if err != nil {
+		return nil, fmt.Errorf("erro ao construir expressao: %w", err)
+	}
+
+	output, err := r.client.Query(ctx, &dynamodb.QueryInput{
+		TableName:                 aws.String(r.tableName),
+		IndexName:                 aws.String(historyIndexName),
+		KeyConditionExpression:    expr.KeyCondition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		ScanIndexForward:          aws.Bool(false),
+		Limit:                     aws.Int32(input.Limit),
+		ExclusiveStartKey:         startKey,
+	})
+	if err != nil {
+		return nil, dynamoError(ctx, "erro ao buscar historico", err)
+	}
+
+	events, err := r.unmarshalEvents(ctx, output.Items)
+	if err != nil {
+		return nil, err
+	}
+
+	next, err := r.cursor.encode(output.LastEvaluatedKey)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao gerar cursor: %w", err)
+	}
+
+	page := &model.UserEventPage{Events: make([]model.UserEvent, len(events)), NextCursor: next}
+	for i, e := range events {
+		page.Events[i] = e.toEvent()
+	}
+	return page, nil
+}
+
+func (e userEventDynamo) toEvent() model.UserEvent {
+	return model.UserEvent{
+		ID:     e.ID,
+		UserID: e.UserID,
+		Action: e.Action,
+		Actor:  e.Actor,
+		At:     e.At,
+		Before: e.Before.toSnapshot(),
+		After:  e.After.toSnapshot(),
+	}
+}
+
+func (s *userSnapshotDynamo) toSnapshot() *model.UserSnapshot {
+	if s == nil {
+		return nil
+	}
+	return &model.UserSnapshot{
+		Name:      s.Name,
+		Email:     s.Email,
+		Version:   s.Version,
+		DeletedAt: s.DeletedAt,
+	}
+}
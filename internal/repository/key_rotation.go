@@ -0,0 +1,255 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/dowglassantana/golang-with-dynamodb/internal/logging"
+)
+
+// Rotacao da chave mestra.
+//
+// Quando a chave mestra ativa muda, os itens antigos continuam legiveis (o envelope
+// guarda o id da chave que o cifrou), mas devem ser re-cifrados com a chave nova para
+// que a antiga possa ser aposentada. Isso acontece de dois jeitos:
+//
+//   - Na leitura: unmarshalUser e unmarshalEvents percebem o envelope antigo e
+//     agendam a re-cifragem do item em segundo plano (rotator), sem atrasar a resposta.
+//   - Na varredura: RotateKeys percorre a tabela com um Scan e re-cifra tudo o que
+//     ainda usa outra chave. RunKeyRotation repete a varredura periodicamente, para
+//     alcancar os itens que ninguem le.
+//
+// A re-cifragem so troca o email_enc: o email em si nao muda, entao nem version nem o
+// historico sao alterados. O UpdateItem e condicionado ao key_id lido; se o item
+// mudou nesse meio tempo, a escrita que o alterou ja cifrou com a chave nova.
+//
+// Varias instancias podem varrer ao mesmo tempo — a condicao impede escritas em
+// dobro —, mas cada varredura le a tabela inteira; em producao, uma por vez basta.
+
+// sealedPaths sao os atributos que podem guardar envelopes, por tipo de item.
+var sealedPaths = map[string][]string{
+	"":            {emailEncAttr},
+	itemTypeEvent: {"before." + emailEncAttr, "after." + emailEncAttr},
+}
+
+// maxConcurrentRotations limita quantas re-cifragens agendadas na leitura rodam ao
+// mesmo tempo. Com o limite atingido, o agendamento e descartado: o item sera
+// re-cifrado na proxima leitura ou na proxima varredura.
+const maxConcurrentRotations = 4
+
+// rotator executa em segundo plano as re-cifragens agendadas na leitura.
+type rotator struct {
+	rotate   func(ctx context.Context, id string) error
+	slots    chan struct{}
+	inflight sync.Map // id -> struct{}
+}
+
+func newRotator(rotate func(ctx context.Context, id string) error) *rotator {
+	return &rotator{rotate: rotate, slots: make(chan struct{}, maxConcurrentRotations)}
+}
+
+// schedule agenda a re-cifragem do item. Nao bloqueia: o item ja em andamento ou sem
+// vaga livre e ignorado. A re-cifragem nao e cancelada com o fim da requisicao.
+func (rt *rotator) schedule(ctx context.Context, id string) {
+	if _, busy := rt.inflight.LoadOrStore(id, struct{}{}); busy {
+		return
+	}
+	select {
+	case rt.slots <- struct{}{}:
+	default:
+		rt.inflight.Delete(id)
+		return
+	}
+
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		defer func() {
+			<-rt.slots
+			rt.inflight.Delete(id)
+		}()
+		if err := rt.rotate(ctx, id); err != nil {
+			logging.FromContext(ctx).ErrorContext(ctx, "erro na rotacao de chave", "id", id, "error", err)
+		}
+	}()
+}
+
+// rotateItem le o item pelo id (chave fisica da tabela) e o re-cifra.
+func (r *DynamoUserRepository) rotateItem(ctx context.Context, id string) error {
+	output, err := r.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(r.tableName),
+		Key:       idKey(id),
+	})
+	if err != nil {
+		return dynamoError(ctx, "erro ao buscar item", err)
+	}
+	if output.Item == nil {
+		return nil
+	}
+	_, err = r.reencrypt(ctx, output.Item)
+	return err
+}
+
+// RotateKeys re-cifra com a chave mestra ativa todos os itens cifrados com outra
+// chave. Retorna quantos itens foram re-cifrados.
+func (r *DynamoUserRepository) RotateKeys(ctx context.Context) (int, error) {
+	active := expression.Value(r.sealer.ActiveKeyID())
+	stale := func(path string) expression.ConditionBuilder {
+		return expression.AttributeExists(expression.Name(path)).
+			And(expression.Name(path + ".key_id").NotEqual(active))
+	}
+	filter := stale(emailEncAttr).
+		Or(stale("before." + emailEncAttr)).
+		Or(stale("after." + emailEncAttr))
+
+	expr, err := expression.NewBuilder().WithFilter(filter).Build()
+	if err != nil {
+		return 0, fmt.Errorf("erro ao construir expressao: %w", err)
+	}
+
+	paginator := dynamodb.NewScanPaginator(r.client, &dynamodb.ScanInput{
+		TableName:                 aws.String(r.tableName),
+		FilterExpression:          expr.Filter(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	})
+
+	rotated := 0
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return rotated, dynamoError(ctx, "erro ao varrer a tabela", err)
+		}
+		for _, item := range page.Items {
+			ok, err := r.reencrypt(ctx, item)
+			if err != nil {
+				return rotated, err
+			}
+			if ok {
+				rotated++
+			}
+		}
+	}
+	return rotated, nil
+}
+
+// RunKeyRotation chama RotateKeys a cada intervalo, ate ctx ser cancelado. Falhas
+// sao apenas logadas: a proxima varredura tenta de novo.
+func (r *DynamoUserRepository) RunKeyRotation(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		rotated, err := r.RotateKeys(ctx)
+		switch {
+		case err != nil && ctx.Err() == nil:
+			logging.FromContext(ctx).ErrorContext(ctx, "erro na rotacao de chave", "error", err)
+		case rotated > 0:
+			logging.FromContext(ctx).InfoContext(ctx, "rotacao de chave concluida",
+				"rotated", rotated, "key_id", r.sealer.ActiveKeyID())
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// reencrypt troca os envelopes antigos do item por envelopes da chave ativa.
+// Retorna false quando nao havia nada a fazer ou o item mudou no meio do caminho.
+func (r *DynamoUserRepository) reencrypt(ctx context.Context, item map[string]types.AttributeValue) (bool, error) {
+	owner := stringAttr(item, "id")
+	itemType := stringAttr(item, itemTypeAttr)
+	if itemType == itemTypeEvent {
+		owner = stringAttr(item, historyPKAttr)
+	}
+
+	paths, ok := sealedPaths[itemType]
+	if !ok {
+		return false, nil
+	}
+
+	var (
+		update    expression.UpdateBuilder
+		condition expression.ConditionBuilder
+		changed   int
+	)
+	for _, path := range paths {
+		current, ok := sealedAt(item, path)
+		if !ok || !r.sealer.Stale(current.envelope()) {
+			continue
+		}
+
+		email, err := r.openEmail(ctx, owner, &current)
+		if err != nil {
+			return false, err
+		}
+		fresh, err := r.sealEmail(ctx, owner, email)
+		if err != nil {
+			return false, err
+		}
+		sameKey := expression.Name(path + ".key_id").Equal(expression.Value(current.KeyID))
+		update = update.Set(expression.Name(path), expression.Value(fresh))
+		if changed == 0 {
+			condition = sameKey
+		} else {
+			condition = condition.And(sameKey)
+		}
+		changed++
+	}
+	if changed == 0 {
+		return false, nil
+	}
+
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(condition).Build()
+	if err != nil {
+		return false, fmt.Errorf("erro ao construir expressao: %w", err)
+	}
+
+	_, err = r.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(r.tableName),
+		Key:                       map[string]types.AttributeValue{"id": item["id"]},
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	})
+	var ccf *types.ConditionalCheckFailedException
+	if errors.As(err, &ccf) {
+		return false, nil
+	}
+	if err != nil {
+		return false, dynamoError(ctx, "erro ao re-cifrar item", err)
+	}
+	return true, nil
+}
+
+// sealedAt le o envelope no caminho informado ("email_enc" ou "before.email_enc").
+func sealedAt(item map[string]types.AttributeValue, path string) (sealedField, bool) {
+	av := types.AttributeValue(&types.AttributeValueMemberM{Value: item})
+	for _, part := range strings.Split(path, ".") {
+		m, ok := av.(*types.AttributeValueMemberM)
+		if !ok {
+			return sealedField{}, false
+		}
+		if av, ok = m.Value[part]; !ok {
+			return sealedField{}, false
+		}
+	}
+
+	var f sealedField
+	if err := attributevalue.Unmarshal(av, &f); err != nil || f.KeyID == "" {
+		return sealedField{}, false
+	}
+	return f, true
+}
@@ -0,0 +1,430 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/dowglassantana/golang-with-dynamodb/internal/model"
+)
+
+// MemoryUserRepository e uma implementacao do UserRepository que guarda os usuarios
+// em memoria. Serve para rodar a API sem DynamoDB Local (ENV=memory) e como duble
+// de testes para UserService e UserHandler.
+//
+// A implementacao reproduz a semantica observavel do DynamoUserRepository:
+//   - GetByID/GetByEmail retornam nil, nil quando o usuario nao existe ou foi excluido.
+//   - Update em id inexistente retorna ErrNotFound.
+//   - Delete e idempotente (salvo com WithStrictDelete) e faz soft delete; Restore
+//     respeita a janela de retencao.
+//   - Emails sao unicos por tenant, sem diferenciar maiusculas (ErrEmailTaken).
+//   - Cada requisicao so enxerga os usuarios do tenant do contexto.
+//   - Toda escrita incrementa Version; ExpectedVersion divergente retorna ErrStaleVersion.
+//   - Toda escrita registra um evento de historico com o ator do contexto.
+//
+// Os mapas usam as mesmas chaves prefixadas pelo tenant que o DynamoDB (veja tenant.go).
+//
+// A listagem segue a ordem da sort key do indice que o DynamoDB usaria; sem filtros
+// nem order essa ordem nao faz parte do contrato, entao nenhum codigo deve depender dela.
+//
+// O mutex protege os mapas: a API atende requisicoes em goroutines concorrentes.
+type MemoryUserRepository struct {
+	mu           sync.RWMutex
+	users        map[string]userDynamo        // userKey -> usuario
+	emails       map[string]string            // emailLockKey -> id do dono
+	events       map[string][]userEventDynamo // historyPK -> eventos
+	cursor       cursorCodec
+	retention    time.Duration
+	strictDelete bool
+	now          func() time.Time
+}
+
+var _ UserRepository = (*MemoryUserRepository)(nil)
+
+func NewMemoryUserRepository(opts ...Option) *MemoryUserRepository {
+	o := newOptions(opts)
+	return &MemoryUserRepository{
+		users:        make(map[string]userDynamo),
+		emails:       make(map[string]string),
+		events:       make(map[string][]userEventDynamo),
+		cursor:       newCursorCodec(o.cursorSecret),
+		retention:    o.retention,
+		strictDelete: o.strictDelete,
+		now:          time.Now,
+	}
+}
+
+func (r *MemoryUserRepository) Create(ctx context.Context, user model.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Mesma ordem do DynamoDB: com email e id em uso, o erro e ErrEmailTaken.
+	tenant := tenantOf(ctx)
+	key := userKey(tenant, user.ID)
+	if _, taken := r.emails[emailLockKey(tenant, normalizeEmail(user.Email))]; taken {
+		return ErrEmailTaken
+	}
+	if existing, exists := r.users[key]; exists && !existing.isDeleted() {
+		return ErrIDTaken
+	}
+
+	dm := toDynamo(tenant, user)
+	r.users[key] = dm
+	r.emails[emailLockKey(tenant, normalizeEmail(user.Email))] = user.ID
+	r.record(ctx, model.EventCreated, nil, &dm)
+	return nil
+}
+
+func (r *MemoryUserRepository) GetByID(ctx context.Context, id string) (*model.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	dm, ok := r.users[userKey(tenantOf(ctx), id)]
+	if !ok || dm.isDeleted() {
+		return nil, nil
+	}
+
+	user := dm.toUser()
+	return &user, nil
+}
+
+func (r *MemoryUserRepository) GetByEmail(ctx context.Context, email string) (*model.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	tenant := tenantOf(ctx)
+	id, ok := r.emails[emailLockKey(tenant, normalizeEmail(email))]
+	if !ok {
+		return nil, nil
+	}
+
+	user := r.users[userKey(tenant, id)].toUser()
+	return &user, nil
+}
+
+// GetAll reproduz a Query da listagem: mesmo indice (sort key), mesma ordem e mesmo
+// formato de cursor que o DynamoUserRepository (veja listQueryFor).
+func (r *MemoryUserRepository) GetAll(ctx context.Context, input model.ListUsersInput) (*model.UserPage, error) {
+	startKey, err := r.cursor.decode(input.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	tenant := tenantOf(ctx)
+	query := listQueryFor(tenant, input)
+	if startKey != nil && !query.accepts(startKey, tenant) {
+		return nil, ErrInvalidCursor
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	limit := int(input.Limit)
+	if limit <= 0 {
+		limit = len(r.users)
+	}
+
+	prefix := model.NormalizeName(input.NamePrefix)
+	matches := make([]userDynamo, 0, len(r.users))
+	for key, dm := range r.users {
+		switch {
+		case !inTenant(key, tenant),
+			dm.isDeleted() && !input.IncludeDeleted,
+			!strings.HasPrefix(dm.NameNormalized, prefix),
+			input.CreatedAfter != "" && dm.CreatedAt < input.CreatedAfter,
+			input.CreatedBefore != "" && dm.CreatedAt > input.CreatedBefore:
+			continue
+		}
+		matches = append(matches, dm)
+	}
+
+	// Ordena pela sort key do indice e, no empate, pela chave da tabela.
+	compare := func(dm userDynamo, sortValue, key string) int {
+		c := cmp.Or(strings.Compare(dm.sortValue(query.sortKey), sortValue), strings.Compare(dm.Key, key))
+		if !query.forward {
+			c = -c
+		}
+		return c
+	}
+	slices.SortFunc(matches, func(a, b userDynamo) int {
+		return compare(a, b.sortValue(query.sortKey), b.Key)
+	})
+
+	if startKey != nil {
+		sortValue, key := stringAttr(startKey, query.sortKey), stringAttr(startKey, "id")
+		next := slices.IndexFunc(matches, func(dm userDynamo) bool {
+			return compare(dm, sortValue, key) > 0
+		})
+		if next < 0 {
+			next = len(matches)
+		}
+		matches = matches[next:]
+	}
+
+	page := &model.UserPage{Users: make([]model.User, 0, min(len(matches), limit))}
+	for _, dm := range matches {
+		if len(page.Users) >= limit {
+			last := matches[len(page.Users)-1]
+			next, err := r.cursor.encode(last.indexKey(query.sortKey))
+			if err != nil {
+				return nil, fmt.Errorf("erro ao gerar cursor: %w", err)
+			}
+			page.NextCursor = next
+			break
+		}
+		page.Users = append(page.Users, dm.toUser())
+	}
+
+	return page, nil
+}
+
+// Export segue a ordem do tenant-index (tenant_sk). Os usuarios sao copiados antes
+// de chamar fn, para nao segurar o mutex enquanto o cliente le a resposta.
+func (r *MemoryUserRepository) Export(ctx context.Context, includeDeleted bool, fn func(model.User) error) error {
+	tenant := tenantOf(ctx)
+
+	r.mu.RLock()
+	users := make([]userDynamo, 0, len(r.users))
+	for key, dm := range r.users {
+		if inTenant(key, tenant) && (includeDeleted || !dm.isDeleted()) {
+			users = append(users, dm)
+		}
+	}
+	r.mu.RUnlock()
+
+	slices.SortFunc(users, func(a, b userDynamo) int {
+		return strings.Compare(a.TenantSK, b.TenantSK)
+	})
+	for _, dm := range users {
+		if err := fn(dm.toUser()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *MemoryUserRepository) Count(ctx context.Context, includeDeleted bool) (int64, error) {
+	tenant := tenantOf(ctx)
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var count int64
+	for key, dm := range r.users {
+		if inTenant(key, tenant) && (includeDeleted || !dm.isDeleted()) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *MemoryUserRepository) Update(ctx context.Context, id string, input model.UpdateUserInput) error {
+	updated, err := r.Patch(ctx, id, fullPatch(input))
+	if err != nil {
+		return err
+	}
+	if updated == nil {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *MemoryUserRepository) Patch(ctx context.Context, id string, input model.PatchUserInput) (*model.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tenant := tenantOf(ctx)
+	dm, ok := r.users[userKey(tenant, id)]
+	exists := ok && !dm.isDeleted()
+
+	if !exists {
+		return nil, nil
+	}
+	if input.ExpectedVersion != nil && dm.Version != *input.ExpectedVersion {
+		return nil, ErrStaleVersion
+	}
+	if input.IsEmpty() {
+		user := dm.toUser()
+		return &user, nil
+	}
+
+	after := applyPatch(dm, input)
+	after.Version++
+
+	oldEmail, newEmail := normalizeEmail(dm.Email), normalizeEmail(after.Email)
+	if oldEmail != newEmail {
+		if owner, taken := r.emails[emailLockKey(tenant, newEmail)]; taken && owner != id {
+			return nil, ErrEmailTaken
+		}
+		delete(r.emails, emailLockKey(tenant, oldEmail))
+		r.emails[emailLockKey(tenant, newEmail)] = id
+	}
+
+	r.users[dm.Key] = after
+	r.record(ctx, model.EventUpdated, &dm, &after)
+
+	user := after.toUser()
+	return &user, nil
+}
+
+func (r *MemoryUserRepository) Delete(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.softDelete(ctx, id) && r.strictDelete {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *MemoryUserRepository) Restore(ctx context.Context, id string) (*model.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tenant := tenantOf(ctx)
+	dm, ok := r.users[userKey(tenant, id)]
+	if !ok {
+		return nil, nil
+	}
+	if !dm.isDeleted() {
+		user := dm.toUser()
+		return &user, nil
+	}
+	if dm.ExpiresAt <= r.now().Unix() {
+		return nil, nil
+	}
+	if _, taken := r.emails[emailLockKey(tenant, normalizeEmail(dm.Email))]; taken {
+		return nil, ErrEmailTaken
+	}
+
+	before := dm
+	dm.DeletedAt = ""
+	dm.ExpiresAt = 0
+	dm.Version++
+	r.users[dm.Key] = dm
+	r.emails[emailLockKey(tenant, normalizeEmail(dm.Email))] = id
+	r.record(ctx, model.EventRestored, &before, &dm)
+
+	user := dm.toUser()
+	return &user, nil
+}
+
+func (r *MemoryUserRepository) BatchCreate(ctx context.Context, users []model.User) ([]model.BatchResult, error) {
+	results := make([]model.BatchResult, len(users))
+	for i, u := range users {
+		results[i] = model.BatchResult{ID: u.ID}
+		if err := r.Create(ctx, u); err != nil {
+			results[i].Err = err
+			continue
+		}
+		results[i].User = &u
+	}
+	return results, nil
+}
+
+func (r *MemoryUserRepository) BatchGet(ctx context.Context, ids []string) ([]model.BatchResult, error) {
+	results := make([]model.BatchResult, len(ids))
+	for i, id := range ids {
+		user, _ := r.GetByID(ctx, id)
+		results[i] = model.BatchResult{ID: id, User: user}
+	}
+	return results, nil
+}
+
+func (r *MemoryUserRepository) BatchDelete(ctx context.Context, ids []string) ([]model.BatchResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	results := make([]model.BatchResult, len(ids))
+	seen := make(map[string]bool, len(ids))
+	for i, id := range ids {
+		results[i] = model.BatchResult{ID: id}
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		if !r.softDelete(ctx, id) && r.strictDelete {
+			results[i].Err = ErrNotFound
+		}
+	}
+	return results, nil
+}
+
+// softDelete marca o usuario como excluido e libera o email. Retorna false quando
+// nao havia usuario ativo para excluir. Deve ser chamado com o lock.
+func (r *MemoryUserRepository) softDelete(ctx context.Context, id string) bool {
+	tenant := tenantOf(ctx)
+	dm, ok := r.users[userKey(tenant, id)]
+	if !ok || dm.isDeleted() {
+		return false
+	}
+
+	before := dm
+	now := r.now().UTC()
+	dm.DeletedAt = now.Format(time.RFC3339)
+	dm.ExpiresAt = now.Add(r.retention).Unix()
+	dm.Version++
+	r.users[dm.Key] = dm
+
+	if lock := emailLockKey(tenant, normalizeEmail(dm.Email)); r.emails[lock] == id {
+		delete(r.emails, lock)
+	}
+	r.record(ctx, model.EventDeleted, &before, &dm)
+	return true
+}
+
+// record guarda um evento de historico. Deve ser chamado com o lock.
+func (r *MemoryUserRepository) record(ctx context.Context, action string, before, after *userDynamo) {
+	event := newUserEvent(ctx, after.ID, action, before, after)
+	r.events[event.HistoryPK] = append(r.events[event.HistoryPK], event)
+}
+
+// History devolve os eventos do mais recente para o mais antigo, como a Query
+// com ScanIndexForward = false no DynamoDB.
+func (r *MemoryUserRepository) History(ctx context.Context, userID string, input model.ListHistoryInput) (*model.UserEventPage, error) {
+	startKey, err := r.cursor.decode(input.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	events := r.events[historyPK(tenantOf(ctx), userID)]
+	end := len(events)
+	if startKey != nil {
+		id, ok := startKey["id"].(*types.AttributeValueMemberS)
+		if !ok {
+			return nil, ErrInvalidCursor
+		}
+		end = slices.IndexFunc(events, func(e userEventDynamo) bool { return e.ID == id.Value })
+		if end < 0 {
+			return nil, ErrInvalidCursor
+		}
+	}
+
+	limit := int(input.Limit)
+	if limit <= 0 {
+		limit = end
+	}
+
+	page := &model.UserEventPage{Events: make([]model.UserEvent, 0, min(end, limit))}
+	for i := end - 1; i >= 0 && len(page.Events) < limit; i-- {
+		page.Events = append(page.Events, events[i].toEvent())
+	}
+
+	if last := end - len(page.Events); last > 0 && len(page.Events) > 0 {
+		next, err := r.cursor.encode(idKey(page.Events[len(page.Events)-1].ID))
+		if err != nil {
+			return nil, fmt.Errorf("erro ao gerar cursor: %w", err)
+		}
+		page.NextCursor = next
+	}
+
+	return page, nil
+}
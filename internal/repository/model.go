@@ -1,25 +1,59 @@
 package repository
 
-import "github.com/dowglassantana/golang-with-dynamodb/internal/model"
+import (
+	"github.com/dowglassantana/golang-with-dynamodb/internal/model"
+)
 
 // userDynamo e a representacao do usuario no DynamoDB.
 // As tags `dynamodbav` mapeiam os campos para os atributos da tabela.
 // Esse model existe apenas na camada de repository — o restante da aplicacao
 // trabalha com model.User, que nao conhece DynamoDB.
+//
+// Key e a chave da tabela ("TENANT#<t>#USER#<id>") e ID o uuid exposto na API;
+// TenantPK e TenantSK sao as chaves do GSI tenant-index (veja tenant.go).
+//
+// Email so existe em claro dentro do processo: no DynamoDB o email vai cifrado em
+// EmailEnc, e EmailHash (o blind index) e a partition key do GSI email-hash-index
+// (veja encryption.go). O atributo "email" so aparece em itens gravados antes da
+// migracao 0009_encrypt_email. NameNormalized e a sort key do GSI name-index (veja
+// search.go).
+//
+// Version e o numero da versao do item, incrementado a cada escrita. Usuarios
+// gravados antes desse atributo existir sao lidos com Version = 0.
+//
+// DeletedAt e ExpiresAt implementam o soft delete: ExpiresAt e o atributo de TTL
+// (epoch em segundos), usado pelo DynamoDB para apagar o item de vez apos a retencao.
 type userDynamo struct {
-	ID        string `dynamodbav:"id"`
-	Name      string `dynamodbav:"name"`
-	Email     string `dynamodbav:"email"`
-	CreatedAt string `dynamodbav:"created_at"`
+	Key            string       `dynamodbav:"id"`
+	ID             string       `dynamodbav:"user_id"`
+	TenantID       string       `dynamodbav:"tenant_id"`
+	TenantPK       string       `dynamodbav:"tenant_pk"`
+	TenantSK       string       `dynamodbav:"tenant_sk"`
+	Name           string       `dynamodbav:"name"`
+	Email          string       `dynamodbav:"email,omitempty"`
+	EmailEnc       *sealedField `dynamodbav:"email_enc,omitempty"`
+	EmailHash      string       `dynamodbav:"email_hash,omitempty"`
+	NameNormalized string       `dynamodbav:"name_normalized,omitempty"`
+	CreatedAt      string       `dynamodbav:"created_at"`
+	Version        int64        `dynamodbav:"version"`
+	DeletedAt      string       `dynamodbav:"deleted_at,omitempty"`
+	ExpiresAt      int64        `dynamodbav:"expires_at,omitempty"`
 }
 
-// toDynamo converte model.User (dominio) para userDynamo (DynamoDB).
-func toDynamo(u model.User) userDynamo {
+// toDynamo converte model.User (dominio) para userDynamo (DynamoDB) no tenant informado.
+func toDynamo(tenant string, u model.User) userDynamo {
 	return userDynamo{
-		ID:        u.ID,
-		Name:      u.Name,
-		Email:     u.Email,
-		CreatedAt: u.CreatedAt,
+		Key:            userKey(tenant, u.ID),
+		ID:             u.ID,
+		TenantID:       tenant,
+		TenantPK:       tenantPK(tenant),
+		TenantSK:       userSK(u.ID),
+		Name:           u.Name,
+		Email:          u.Email,
+		NameNormalized: model.NormalizeName(u.Name),
+		CreatedAt:      u.CreatedAt,
+		Version:        u.Version,
+		DeletedAt:      u.DeletedAt,
 	}
 }
 
@@ -30,5 +64,11 @@ func (m userDynamo) toUser() model.User {
 		Name:      m.Name,
 		Email:     m.Email,
 		CreatedAt: m.CreatedAt,
+		Version:   m.Version,
+		DeletedAt: m.DeletedAt,
 	}
 }
+
+func (m userDynamo) isDeleted() bool {
+	return m.DeletedAt != ""
+}
@@ -0,0 +1,46 @@
+package repository
+
+import "time"
+
+// options reune os parametros opcionais aceitos pelas implementacoes de UserRepository.
+type options struct {
+	cursorSecret []byte
+	retention    time.Duration
+	strictDelete bool
+}
+
+// Option configura parametros opcionais do repository.
+type Option func(*options)
+
+// WithCursorSecret define o segredo usado para assinar os cursores de paginacao.
+// Em producao, todas as instancias devem compartilhar o mesmo segredo para que
+// um cursor gerado por uma task continue valido nas demais.
+func WithCursorSecret(secret []byte) Option {
+	return func(o *options) {
+		o.cursorSecret = secret
+	}
+}
+
+// WithRetention define por quanto tempo um usuario excluido pode ser restaurado
+// antes de o TTL do DynamoDB apaga-lo definitivamente.
+func WithRetention(d time.Duration) Option {
+	return func(o *options) {
+		o.retention = d
+	}
+}
+
+// WithStrictDelete faz o Delete retornar ErrNotFound para usuarios inexistentes ou
+// ja excluidos, em vez de tratar a exclusao como idempotente.
+func WithStrictDelete() Option {
+	return func(o *options) {
+		o.strictDelete = true
+	}
+}
+
+func newOptions(opts []Option) options {
+	o := options{retention: DefaultRetention}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
@@ -0,0 +1,163 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/dowglassantana/golang-with-dynamodb/internal/model"
+)
+
+// Patch aplica um JSON Merge Patch (RFC 7396) ao usuario e retorna o estado final.
+//
+// A UpdateExpression e montada dinamicamente, campo a campo:
+//   - campo presente -> SET #campo = :valor
+//   - campo ausente  -> nao entra na expressao
+//
+// O null da RFC 7396 (remover o campo) nao chega aqui: name e email sao obrigatorios e
+// o service recusa null com ErrInvalidInput.
+//
+// Exemplo: {"email": "novo@email.com"} gera apenas
+// "SET #email_enc = :enc, #email_hash = :hash, #version = ..." — o name nao e
+// reenviado nem sobrescrito, entao um patch concorrente em outro campo nao se perde.
+//
+// Quando o email muda, o sentinela antigo e liberado e o novo e
+// reservado na mesma transacao, exatamente como no Update. Um patch vazio nao grava
+// nada e apenas devolve o usuario atual.
+//
+// Retorna nil, nil quando o usuario nao existe ou foi excluido. Com ExpectedVersion
+// (If-Match) divergente, retorna ErrStaleVersion.
+func (r *DynamoUserRepository) Patch(ctx context.Context, id string, input model.PatchUserInput) (*model.User, error) {
+	var patched *userDynamo
+	err := retryOnConflict(func() error {
+		var err error
+		patched, err = r.patch(ctx, id, input)
+		return err
+	})
+	if err != nil || patched == nil {
+		return nil, err
+	}
+
+	user := patched.toUser()
+	return &user, nil
+}
+
+func (r *DynamoUserRepository) patch(ctx context.Context, id string, input model.PatchUserInput) (*userDynamo, error) {
+	current, err := r.getItem(ctx, id, true)
+	if err != nil {
+		return nil, err
+	}
+	if current != nil && current.isDeleted() {
+		current = nil
+	}
+
+	if current == nil {
+		return nil, nil
+	}
+	if input.ExpectedVersion != nil && current.Version != *input.ExpectedVersion {
+		return nil, ErrStaleVersion
+	}
+	if input.IsEmpty() {
+		return current, nil
+	}
+
+	after := applyPatch(*current, input)
+	after.Version++
+
+	update := expression.Set(expression.Name("version"), nextVersion())
+	if input.Name.Set {
+		update = update.Set(expression.Name("name"), expression.Value(after.Name))
+		// Um GSI nao aceita string vazia na chave: se o nome normalizado ficar vazio, o
+		// item sai do name-index em vez de a escrita ser recusada com ValidationException.
+		if after.NameNormalized == "" {
+			update = update.Remove(expression.Name(nameIndexKey))
+		} else {
+			update = update.Set(expression.Name(nameIndexKey), expression.Value(after.NameNormalized))
+		}
+	}
+	if input.Email.Set {
+		update, err = r.patchEmail(ctx, update, current, input.Email.Value)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	items, err := r.userWrite(ctx, current, update, model.EventUpdated, &after)
+	if err != nil {
+		return nil, err
+	}
+
+	emailChanged := normalizeEmail(after.Email) != normalizeEmail(current.Email)
+	if emailChanged {
+		releaseOld, err := r.releaseEmailLock(r.emailLock(current.TenantID, current.Email), id)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, releaseOld)
+
+		newLock, err := attributevalue.MarshalMap(newEmailLock(r.emailLock(current.TenantID, after.Email), id))
+		if err != nil {
+			return nil, fmt.Errorf("erro ao serializar sentinela de email: %w", err)
+		}
+		items = append(items, types.TransactWriteItem{Put: &types.Put{
+			TableName:           aws.String(r.tableName),
+			Item:                newLock,
+			ConditionExpression: aws.String("attribute_not_exists(id)"),
+		}})
+	}
+
+	_, err = r.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: items,
+	})
+	if err != nil {
+		if emailChanged && transactionFailedAt(err, 3) {
+			return nil, ErrEmailTaken
+		}
+		if transactionFailedAt(err, 0) {
+			return nil, errConcurrentWrite
+		}
+		return nil, dynamoError(ctx, "erro ao atualizar usuario", err)
+	}
+
+	return &after, nil
+}
+
+// patchEmail acrescenta ao UpdateBuilder a troca do email: o envelope cifrado e o
+// blind index novos. O REMOVE de email limpa o valor em claro de itens gravados antes
+// da migracao 0009_encrypt_email.
+func (r *DynamoUserRepository) patchEmail(ctx context.Context, update expression.UpdateBuilder, current *userDynamo, email string) (expression.UpdateBuilder, error) {
+	enc, err := r.sealEmail(ctx, current.Key, email)
+	if err != nil {
+		return update, err
+	}
+	return update.
+		Remove(expression.Name("email")).
+		Set(expression.Name(emailEncAttr), expression.Value(enc)).
+		Set(expression.Name(emailHashAttr), expression.Value(r.emailHash(current.TenantID, email))), nil
+}
+
+// applyPatch calcula o estado do usuario depois do patch. E usado no "depois" do
+// evento de historico e como retorno do Patch, sem precisar reler o item.
+func applyPatch(dm userDynamo, input model.PatchUserInput) userDynamo {
+	if input.Name.Set {
+		dm.Name = input.Name.Value
+		dm.NameNormalized = model.NormalizeName(input.Name.Value)
+	}
+	if input.Email.Set {
+		dm.Email = input.Email.Value
+	}
+	return dm
+}
+
+// fullPatch converte um UpdateUserInput (PUT) no patch equivalente, com os dois campos.
+func fullPatch(input model.UpdateUserInput) model.PatchUserInput {
+	return model.PatchUserInput{
+		Name:            model.Nullable[string]{Value: input.Name, Set: true},
+		Email:           model.Nullable[string]{Value: input.Email, Set: true},
+		ExpectedVersion: input.ExpectedVersion,
+	}
+}
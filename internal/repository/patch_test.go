@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/dowglassantana/golang-with-dynamodb/internal/dynamotest"
+	"github.com/dowglassantana/golang-with-dynamodb/internal/model"
+	"github.com/dowglassantana/golang-with-dynamodb/internal/requestctx"
+)
+
+// TestPatchNameIndexKey confere o atributo name_normalized (sort key do name-index) na
+// UpdateExpression: SET com o nome normalizado, ou REMOVE quando ele fica vazio — o
+// DynamoDB recusa a escrita de uma chave de indice vazia.
+func TestPatchNameIndexKey(t *testing.T) {
+	tests := []struct {
+		name       string
+		wantRemove bool
+	}{
+		{"A\u0301na Paula", false},
+		{"\u0301", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, srv := newTestRepository(t)
+			ctx := context.Background()
+			user := testUser(1)
+
+			item, err := repo.marshalUser(ctx, toDynamo(requestctx.DefaultTenant, user))
+			if err != nil {
+				t.Fatalf("marshalUser: %v", err)
+			}
+			srv.Handle("GetItem", func(req dynamotest.Request) (any, error) {
+				return map[string]any{"Item": dynamotest.Item(item)}, nil
+			})
+			srv.Handle("TransactWriteItems", func(req dynamotest.Request) (any, error) {
+				return nil, nil
+			})
+
+			input := model.PatchUserInput{Name: model.Nullable[string]{Value: tt.name, Set: true}}
+			if _, err := repo.Patch(ctx, user.ID, input); err != nil {
+				t.Fatalf("Patch: %v", err)
+			}
+
+			calls := srv.Calls("TransactWriteItems")
+			if len(calls) != 1 {
+				t.Fatalf("%d transacoes, quer 1", len(calls))
+			}
+			update, _ := transactItems(calls[0])[0]["Update"].(map[string]any)
+			expr, _ := update["UpdateExpression"].(string)
+			names, _ := update["ExpressionAttributeNames"].(map[string]any)
+
+			var alias string
+			for k, v := range names {
+				if v == nameIndexKey {
+					alias = k
+				}
+			}
+			if alias == "" {
+				t.Fatalf("UpdateExpression %q nao menciona %s", expr, nameIndexKey)
+			}
+			_, removePart, _ := strings.Cut(expr, "REMOVE")
+			if removed := strings.Contains(removePart, alias); removed != tt.wantRemove {
+				t.Errorf("UpdateExpression %q: REMOVE %s = %t, quer %t", expr, nameIndexKey, removed, tt.wantRemove)
+			}
+		})
+	}
+}
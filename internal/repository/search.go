@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/dowglassantana/golang-with-dynamodb/internal/model"
+)
+
+// Busca por janela de created_at e por prefixo de nome com GSIs.
+//
+// Os dois indices usam tenant_pk como partition key, entao toda busca le apenas a
+// particao do tenant. O que muda e a sort key, que define o que a KeyCondition
+// consegue filtrar e em que ordem os itens voltam:
+//
+//	created-index: tenant_pk + created_at      -> BETWEEN, >=, <=
+//	name-index:    tenant_pk + name_normalized -> begins_with
+//
+// A KeyCondition e aplicada ANTES da leitura: so pagamos pelos itens da janela ou do
+// prefixo, ao contrario de um Scan com FilterExpression, que le a tabela inteira.
+//
+// A KeyCondition aceita uma unica condicao sobre a sort key. Quando a busca combina
+// prefixo de nome e janela de datas, usamos o name-index e a janela vira
+// FilterExpression — o prefixo costuma ser o criterio mais seletivo.
+//
+// Os indices sao criados pelas migracoes 0007_created_index e 0008_name_index
+// (veja internal/migrate).
+
+const (
+	createdIndexName = "created-index"
+	createdIndexKey  = "created_at"
+	nameIndexName    = "name-index"
+	nameIndexKey     = "name_normalized"
+)
+
+// listQuery descreve a Query usada por uma listagem: qual indice, a KeyCondition,
+// o filtro e a ordem.
+type listQuery struct {
+	index   string
+	sortKey string // sort key do indice; todo cursor valido para a Query a contem
+	keyCond expression.KeyConditionBuilder
+	filter  []expression.ConditionBuilder
+	forward bool
+}
+
+// listQueryFor escolhe o indice da listagem:
+//   - com NamePrefix, o name-index (ordem por nome);
+//   - com janela de datas ou Order, o created-index (ordem por created_at);
+//   - sem filtros, o tenant-index (ordem por id).
+func listQueryFor(tenant string, input model.ListUsersInput) listQuery {
+	pk := expression.Key(tenantPKAttr).Equal(expression.Value(tenantPK(tenant)))
+	q := listQuery{forward: input.Order != model.OrderDesc}
+
+	switch {
+	case input.NamePrefix != "":
+		q.index, q.sortKey = nameIndexName, nameIndexKey
+		q.keyCond = pk.And(expression.Key(nameIndexKey).BeginsWith(model.NormalizeName(input.NamePrefix)))
+		if window, ok := createdWindow(input); ok {
+			q.filter = append(q.filter, window)
+		}
+
+	case input.IsSearch():
+		q.index, q.sortKey = createdIndexName, createdIndexKey
+		q.keyCond = pk
+		switch after, before := input.CreatedAfter, input.CreatedBefore; {
+		case after != "" && before != "":
+			q.keyCond = pk.And(expression.Key(createdIndexKey).Between(expression.Value(after), expression.Value(before)))
+		case after != "":
+			q.keyCond = pk.And(expression.Key(createdIndexKey).GreaterThanEqual(expression.Value(after)))
+		case before != "":
+			q.keyCond = pk.And(expression.Key(createdIndexKey).LessThanEqual(expression.Value(before)))
+		}
+
+	default:
+		q.index, q.sortKey = tenantIndexName, tenantSKAttr
+		q.keyCond = pk
+	}
+
+	if !input.IncludeDeleted {
+		q.filter = append(q.filter, expression.AttributeNotExists(expression.Name("deleted_at")))
+	}
+	return q
+}
+
+// builder monta as expressoes da Query. O FilterExpression so entra quando ha filtro:
+// o pacote expression recusa um filtro vazio.
+func (q listQuery) builder() expression.Builder {
+	b := expression.NewBuilder().WithKeyCondition(q.keyCond)
+	switch len(q.filter) {
+	case 0:
+	case 1:
+		b = b.WithFilter(q.filter[0])
+	default:
+		b = b.WithFilter(expression.And(q.filter[0], q.filter[1], q.filter[2:]...))
+	}
+	return b
+}
+
+// accepts indica se o cursor (ExclusiveStartKey) pertence a esta Query: mesmo tenant
+// e mesmo indice. Um cursor de outro indice faria o DynamoDB recusar a chamada.
+func (q listQuery) accepts(startKey map[string]types.AttributeValue, tenant string) bool {
+	_, hasSortKey := startKey[q.sortKey]
+	return hasSortKey && stringAttr(startKey, tenantPKAttr) == tenantPK(tenant)
+}
+
+// createdWindow monta a janela de created_at como condicao de filtro.
+func createdWindow(input model.ListUsersInput) (expression.ConditionBuilder, bool) {
+	created := expression.Name(createdIndexKey)
+	switch after, before := input.CreatedAfter, input.CreatedBefore; {
+	case after != "" && before != "":
+		return created.Between(expression.Value(after), expression.Value(before)), true
+	case after != "":
+		return created.GreaterThanEqual(expression.Value(after)), true
+	case before != "":
+		return created.LessThanEqual(expression.Value(before)), true
+	}
+	return expression.ConditionBuilder{}, false
+}
+
+// sortValue devolve o valor do item na sort key informada.
+func (m userDynamo) sortValue(sortKey string) string {
+	switch sortKey {
+	case createdIndexKey:
+		return m.CreatedAt
+	case nameIndexKey:
+		return m.NameNormalized
+	default:
+		return m.TenantSK
+	}
+}
+
+// indexKey monta o LastEvaluatedKey que uma Query no indice devolveria para o item:
+// a chave da tabela mais as chaves do indice.
+func (m userDynamo) indexKey(sortKey string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"id":         &types.AttributeValueMemberS{Value: m.Key},
+		tenantPKAttr: &types.AttributeValueMemberS{Value: m.TenantPK},
+		sortKey:      &types.AttributeValueMemberS{Value: m.sortValue(sortKey)},
+	}
+}
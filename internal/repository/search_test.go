@@ -0,0 +1,201 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/dowglassantana/golang-with-dynamodb/internal/dynamotest"
+	"github.com/dowglassantana/golang-with-dynamodb/internal/model"
+)
+
+var placeholder = regexp.MustCompile(`[#:][0-9]+`)
+
+// resolve troca os placeholders (#0, :1) da expressao pelos nomes e valores reais,
+// para o teste comparar a expressao como ela seria escrita a mao.
+func resolve(expr *string, names map[string]string, values map[string]types.AttributeValue) string {
+	if expr == nil {
+		return ""
+	}
+	return placeholder.ReplaceAllStringFunc(*expr, func(p string) string {
+		if p[0] == '#' {
+			return names[p]
+		}
+		if v, ok := values[p].(*types.AttributeValueMemberS); ok {
+			return "'" + v.Value + "'"
+		}
+		return p
+	})
+}
+
+func TestListQueryFor(t *testing.T) {
+	const notDeleted = "attribute_not_exists (deleted_at)"
+	tests := []struct {
+		name        string
+		input       model.ListUsersInput
+		wantIndex   string
+		wantKeyCond string
+		wantFilter  string
+		wantForward bool
+	}{
+		{
+			name:        "sem filtros",
+			input:       model.ListUsersInput{},
+			wantIndex:   tenantIndexName,
+			wantKeyCond: "tenant_pk = 'TENANT#acme'",
+			wantFilter:  notDeleted,
+			wantForward: true,
+		},
+		{
+			name:        "com excluidos",
+			input:       model.ListUsersInput{IncludeDeleted: true},
+			wantIndex:   tenantIndexName,
+			wantKeyCond: "tenant_pk = 'TENANT#acme'",
+			wantForward: true,
+		},
+		{
+			name:        "prefixo normalizado",
+			input:       model.ListUsersInput{NamePrefix: "  \u00c1NA ", IncludeDeleted: true},
+			wantIndex:   nameIndexName,
+			wantKeyCond: "(tenant_pk = 'TENANT#acme') AND (begins_with (name_normalized, 'ana'))",
+			wantForward: true,
+		},
+		{
+			name:        "prefixo e janela",
+			input:       model.ListUsersInput{NamePrefix: "ana", CreatedAfter: "2024-01-01T00:00:00Z"},
+			wantIndex:   nameIndexName,
+			wantKeyCond: "(tenant_pk = 'TENANT#acme') AND (begins_with (name_normalized, 'ana'))",
+			wantFilter:  "(created_at >= '2024-01-01T00:00:00Z') AND (" + notDeleted + ")",
+			wantForward: true,
+		},
+		{
+			name:        "janela completa",
+			input:       model.ListUsersInput{CreatedAfter: "2024-01-01T00:00:00Z", CreatedBefore: "2024-02-01T00:00:00Z", IncludeDeleted: true},
+			wantIndex:   createdIndexName,
+			wantKeyCond: "(tenant_pk = 'TENANT#acme') AND (created_at BETWEEN '2024-01-01T00:00:00Z' AND '2024-02-01T00:00:00Z')",
+			wantForward: true,
+		},
+		{
+			name:        "so inicio",
+			input:       model.ListUsersInput{CreatedAfter: "2024-01-01T00:00:00Z", IncludeDeleted: true},
+			wantIndex:   createdIndexName,
+			wantKeyCond: "(tenant_pk = 'TENANT#acme') AND (created_at >= '2024-01-01T00:00:00Z')",
+			wantForward: true,
+		},
+		{
+			name:        "so fim",
+			input:       model.ListUsersInput{CreatedBefore: "2024-02-01T00:00:00Z", IncludeDeleted: true},
+			wantIndex:   createdIndexName,
+			wantKeyCond: "(tenant_pk = 'TENANT#acme') AND (created_at <= '2024-02-01T00:00:00Z')",
+			wantForward: true,
+		},
+		{
+			name:        "ordem decrescente",
+			input:       model.ListUsersInput{Order: model.OrderDesc, IncludeDeleted: true},
+			wantIndex:   createdIndexName,
+			wantKeyCond: "tenant_pk = 'TENANT#acme'",
+		},
+		{
+			name:        "ordem crescente explicita",
+			input:       model.ListUsersInput{Order: model.OrderAsc, IncludeDeleted: true},
+			wantIndex:   createdIndexName,
+			wantKeyCond: "tenant_pk = 'TENANT#acme'",
+			wantForward: true,
+		},
+		{
+			name:        "prefixo decrescente",
+			input:       model.ListUsersInput{NamePrefix: "ana", Order: model.OrderDesc, IncludeDeleted: true},
+			wantIndex:   nameIndexName,
+			wantKeyCond: "(tenant_pk = 'TENANT#acme') AND (begins_with (name_normalized, 'ana'))",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := listQueryFor("acme", tt.input)
+			expr, err := q.builder().Build()
+			if err != nil {
+				t.Fatalf("Build: %v", err)
+			}
+			names := expr.Names()
+
+			if q.index != tt.wantIndex {
+				t.Errorf("indice = %s, quer %s", q.index, tt.wantIndex)
+			}
+			if got := resolve(expr.KeyCondition(), names, expr.Values()); got != tt.wantKeyCond {
+				t.Errorf("KeyCondition = %s\nquer           %s", got, tt.wantKeyCond)
+			}
+			if got := resolve(expr.Filter(), names, expr.Values()); got != tt.wantFilter {
+				t.Errorf("Filter = %s\nquer     %s", got, tt.wantFilter)
+			}
+			if q.forward != tt.wantForward {
+				t.Errorf("forward = %v, quer %v", q.forward, tt.wantForward)
+			}
+		})
+	}
+}
+
+// TestListQueryAccepts confere que um cursor so vale para a Query que o emitiu: outro
+// indice (sort key diferente) ou outro tenant e recusado.
+func TestListQueryAccepts(t *testing.T) {
+	dm := toDynamo("acme", model.User{ID: "1", Name: "Ana", CreatedAt: "2024-01-02T03:04:05Z"})
+
+	queries := map[string]listQuery{
+		tenantIndexName:  listQueryFor("acme", model.ListUsersInput{}),
+		createdIndexName: listQueryFor("acme", model.ListUsersInput{Order: model.OrderDesc}),
+		nameIndexName:    listQueryFor("acme", model.ListUsersInput{NamePrefix: "ana"}),
+	}
+	cursors := map[string]map[string]types.AttributeValue{
+		tenantIndexName:  dm.indexKey(tenantSKAttr),
+		createdIndexName: dm.indexKey(createdIndexKey),
+		nameIndexName:    dm.indexKey(nameIndexKey),
+	}
+
+	for qIndex, q := range queries {
+		for cIndex, cursor := range cursors {
+			want := qIndex == cIndex
+			if got := q.accepts(cursor, "acme"); got != want {
+				t.Errorf("Query no %s aceitou cursor do %s = %v, quer %v", qIndex, cIndex, got, want)
+			}
+		}
+		if q.accepts(cursors[qIndex], "globex") {
+			t.Errorf("Query no %s aceitou cursor de outro tenant", qIndex)
+		}
+	}
+}
+
+func TestGetAllQuery(t *testing.T) {
+	repo, srv := newTestRepository(t)
+	ctx := context.Background()
+	srv.Handle("Query", func(req dynamotest.Request) (any, error) {
+		return map[string]any{"Items": []any{}}, nil
+	})
+
+	_, err := repo.GetAll(ctx, model.ListUsersInput{Limit: 10, CreatedAfter: "2024-01-01T00:00:00Z", Order: model.OrderDesc})
+	if err != nil {
+		t.Fatalf("GetAll: %v", err)
+	}
+	calls := srv.Calls("Query")
+	if len(calls) != 1 {
+		t.Fatalf("%d chamadas Query, quer 1", len(calls))
+	}
+	if calls[0]["IndexName"] != createdIndexName || calls[0]["ScanIndexForward"] != false {
+		t.Errorf("Query = %v, quer created-index em ordem decrescente", calls[0])
+	}
+
+	// Um cursor da listagem sem filtros (tenant-index) nao serve para a busca por nome,
+	// e e recusado antes de chamar o DynamoDB.
+	dm := toDynamo("default", model.User{ID: "1", Name: "Ana", CreatedAt: "2024-01-02T03:04:05Z"})
+	cursor, err := repo.cursor.encode(dm.indexKey(tenantSKAttr))
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	_, err = repo.GetAll(ctx, model.ListUsersInput{Limit: 10, NamePrefix: "ana", Cursor: cursor})
+	if !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("GetAll com cursor de outro indice = %v, quer ErrInvalidCursor", err)
+	}
+	if n := len(srv.Calls("Query")); n != 1 {
+		t.Errorf("%d chamadas Query, quer 1", n)
+	}
+}
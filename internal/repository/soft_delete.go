@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/dowglassantana/golang-with-dynamodb/internal/model"
+)
+
+// DefaultRetention e a janela padrao em que um usuario excluido pode ser restaurado.
+const DefaultRetention = 30 * 24 * time.Hour
+
+// ttlAttr e o atributo de TTL (Time To Live) da tabela.
+//
+// Com o TTL habilitado, o DynamoDB compara periodicamente esse atributo (epoch em
+// segundos, tipo N) com o horario atual e apaga os itens vencidos. A remocao e
+// assincrona — pode levar ate alguns dias — e nao consome capacidade de escrita.
+// Itens sem o atributo nunca expiram. O TTL e habilitado pela migracao
+// 0003_soft_delete_ttl (veja internal/migrate).
+const ttlAttr = "expires_at"
+
+// Restore desfaz a exclusao de um usuario que ainda esta na janela de retencao.
+//
+// A restauracao remove deleted_at e expires_at (REMOVE na UpdateExpression) e volta
+// a reservar o sentinela do email, tudo na mesma transacao do evento de historico.
+// Se nesse meio tempo outro usuario passou a usar o email, o Put do sentinela falha
+// e retornamos ErrEmailTaken.
+//
+// Itens vencidos mas ainda nao apagados pelo TTL nao sao restaurados — o DynamoDB
+// nao remove itens no exato instante do vencimento, entao conferimos expires_at.
+//
+// Retorna nil, nil quando nao ha o que restaurar (usuario inexistente ou fora da
+// janela), no mesmo estilo de GetByID. Restaurar um usuario ativo e idempotente.
+func (r *DynamoUserRepository) Restore(ctx context.Context, id string) (*model.User, error) {
+	var restored *model.User
+	err := retryOnConflict(func() error {
+		var err error
+		restored, err = r.restore(ctx, id)
+		return err
+	})
+	return restored, err
+}
+
+func (r *DynamoUserRepository) restore(ctx context.Context, id string) (*model.User, error) {
+	current, err := r.getItem(ctx, id, true)
+	if err != nil || current == nil {
+		return nil, err
+	}
+	if !current.isDeleted() {
+		user := current.toUser()
+		return &user, nil
+	}
+	if current.ExpiresAt <= time.Now().Unix() {
+		return nil, nil
+	}
+
+	after := *current
+	after.DeletedAt = ""
+	after.ExpiresAt = 0
+	after.Version++
+
+	update := expression.
+		Remove(expression.Name("deleted_at")).
+		Remove(expression.Name(ttlAttr)).
+		Set(expression.Name("version"), nextVersion())
+
+	items, err := r.userWrite(ctx, current, update, model.EventRestored, &after)
+	if err != nil {
+		return nil, err
+	}
+
+	lock, err := attributevalue.MarshalMap(newEmailLock(r.emailLock(current.TenantID, current.Email), id))
+	if err != nil {
+		return nil, fmt.Errorf("erro ao serializar sentinela de email: %w", err)
+	}
+	items = append(items, types.TransactWriteItem{Put: &types.Put{
+		TableName:           aws.String(r.tableName),
+		Item:                lock,
+		ConditionExpression: aws.String("attribute_not_exists(id)"),
+	}})
+
+	_, err = r.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: items,
+	})
+	if err != nil {
+		if transactionFailedAt(err, 2) {
+			return nil, ErrEmailTaken
+		}
+		if transactionFailedAt(err, 0) {
+			return nil, errConcurrentWrite
+		}
+		return nil, dynamoError(ctx, "erro ao restaurar usuario", err)
+	}
+
+	user := after.toUser()
+	return &user, nil
+}
@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"context"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/dowglassantana/golang-with-dynamodb/internal/requestctx"
+)
+
+// Isolamento entre tenants (clientes) com chaves prefixadas.
+//
+// Uma mesma tabela atende varios tenants. Em vez de filtrar por um atributo "tenant"
+// depois da leitura — onde um filtro esquecido vaza dados —, o tenant faz parte da
+// propria chave de todo item:
+//
+//	usuario:   id = "TENANT#<t>#USER#<id>"
+//	sentinela: id = "TENANT#<t>#EMAIL#<email_hash>"
+//	historico: history_pk = "TENANT#<t>#USER#<id>"
+//
+// O tenant vem do contexto (requestctx.Tenant) e nunca do cliente diretamente, entao
+// um GetItem so consegue montar chaves do proprio tenant. O id exposto na API continua
+// sendo apenas o uuid; o prefixo fica restrito ao repository.
+//
+// A chave primaria da tabela nao pode ser alterada depois de criada, por isso a dupla
+// PK/SK "TENANT#<t>" / "USER#<id>" fica no GSI tenant-index:
+//
+//	tenant_pk = "TENANT#<t>" (partition key do indice)
+//	tenant_sk = "USER#<id>"  (sort key do indice)
+//
+// Listar os usuarios de um tenant vira uma Query em uma unica particao do indice, em
+// vez de um Scan na tabela inteira. O indice e esparso: so os usuarios tem tenant_pk.
+// Ele e criado pela migracao 0006_tenant_keys, que tambem move os dados antigos para
+// requestctx.DefaultTenant (veja internal/migrate).
+
+const (
+	tenantIndexName = "tenant-index"
+	tenantPKAttr    = "tenant_pk"
+	tenantSKAttr    = "tenant_sk"
+
+	tenantPrefix = "TENANT#"
+	userPrefix   = "USER#"
+)
+
+// tenantOf devolve o tenant da requisicao.
+func tenantOf(ctx context.Context) string {
+	return requestctx.Tenant(ctx)
+}
+
+func tenantPK(tenant string) string {
+	return tenantPrefix + tenant
+}
+
+func userSK(id string) string {
+	return userPrefix + id
+}
+
+// userKey monta o valor do atributo id (chave da tabela) de um usuario.
+func userKey(tenant, id string) string {
+	return tenantPK(tenant) + "#" + userSK(id)
+}
+
+// stringAttr le um atributo do tipo S, ou "" quando ele nao existe.
+func stringAttr(item map[string]types.AttributeValue, name string) string {
+	if v, ok := item[name].(*types.AttributeValueMemberS); ok {
+		return v.Value
+	}
+	return ""
+}
+
+// inTenant indica se a chave pertence ao tenant. Usado para validar cursores: um
+// cursor emitido para um tenant nao pode continuar a listagem de outro.
+func inTenant(key, tenant string) bool {
+	return strings.HasPrefix(key, tenantPK(tenant)+"#")
+}
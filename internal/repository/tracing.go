@@ -0,0 +1,142 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/dowglassantana/golang-with-dynamodb/internal/model"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/dowglassantana/golang-with-dynamodb/internal/repository"
+
+// TracedUserRepository decora qualquer UserRepository com um span OpenTelemetry por
+// metodo ("UserRepository.GetByID"). As chamadas ao DynamoDB feitas dentro do metodo
+// (instrumentadas pelo dynamo.WithTracing) viram filhas desse span, entao o trace
+// mostra quais operacoes cada metodo fez — um Create, por exemplo, e um unico
+// TransactWriteItems; um Patch de email, um GetItem seguido da transacao.
+//
+// Na API ele fica por dentro do CachedUserRepository: um GetByID atendido pelo cache
+// nao gera span do repository.
+type TracedUserRepository struct {
+	next UserRepository
+}
+
+var _ UserRepository = (*TracedUserRepository)(nil)
+
+func NewTracedUserRepository(next UserRepository) *TracedUserRepository {
+	return &TracedUserRepository{next: next}
+}
+
+func (t *TracedUserRepository) Create(ctx context.Context, user model.User) error {
+	ctx, span := startSpan(ctx, "Create")
+	err := t.next.Create(ctx, user)
+	endSpan(span, err)
+	return err
+}
+
+func (t *TracedUserRepository) GetByID(ctx context.Context, id string) (*model.User, error) {
+	ctx, span := startSpan(ctx, "GetByID")
+	user, err := t.next.GetByID(ctx, id)
+	endSpan(span, err)
+	return user, err
+}
+
+func (t *TracedUserRepository) GetByEmail(ctx context.Context, email string) (*model.User, error) {
+	ctx, span := startSpan(ctx, "GetByEmail")
+	user, err := t.next.GetByEmail(ctx, email)
+	endSpan(span, err)
+	return user, err
+}
+
+func (t *TracedUserRepository) GetAll(ctx context.Context, input model.ListUsersInput) (*model.UserPage, error) {
+	ctx, span := startSpan(ctx, "GetAll")
+	page, err := t.next.GetAll(ctx, input)
+	endSpan(span, err)
+	return page, err
+}
+
+func (t *TracedUserRepository) Update(ctx context.Context, id string, input model.UpdateUserInput) error {
+	ctx, span := startSpan(ctx, "Update")
+	err := t.next.Update(ctx, id, input)
+	endSpan(span, err)
+	return err
+}
+
+func (t *TracedUserRepository) Patch(ctx context.Context, id string, input model.PatchUserInput) (*model.User, error) {
+	ctx, span := startSpan(ctx, "Patch")
+	user, err := t.next.Patch(ctx, id, input)
+	endSpan(span, err)
+	return user, err
+}
+
+func (t *TracedUserRepository) Delete(ctx context.Context, id string) error {
+	ctx, span := startSpan(ctx, "Delete")
+	err := t.next.Delete(ctx, id)
+	endSpan(span, err)
+	return err
+}
+
+func (t *TracedUserRepository) Restore(ctx context.Context, id string) (*model.User, error) {
+	ctx, span := startSpan(ctx, "Restore")
+	user, err := t.next.Restore(ctx, id)
+	endSpan(span, err)
+	return user, err
+}
+
+func (t *TracedUserRepository) BatchCreate(ctx context.Context, users []model.User) ([]model.BatchResult, error) {
+	ctx, span := startSpan(ctx, "BatchCreate")
+	results, err := t.next.BatchCreate(ctx, users)
+	endSpan(span, err)
+	return results, err
+}
+
+func (t *TracedUserRepository) BatchGet(ctx context.Context, ids []string) ([]model.BatchResult, error) {
+	ctx, span := startSpan(ctx, "BatchGet")
+	results, err := t.next.BatchGet(ctx, ids)
+	endSpan(span, err)
+	return results, err
+}
+
+func (t *TracedUserRepository) BatchDelete(ctx context.Context, ids []string) ([]model.BatchResult, error) {
+	ctx, span := startSpan(ctx, "BatchDelete")
+	results, err := t.next.BatchDelete(ctx, ids)
+	endSpan(span, err)
+	return results, err
+}
+
+func (t *TracedUserRepository) History(ctx context.Context, userID string, input model.ListHistoryInput) (*model.UserEventPage, error) {
+	ctx, span := startSpan(ctx, "History")
+	page, err := t.next.History(ctx, userID, input)
+	endSpan(span, err)
+	return page, err
+}
+
+// Export cobre o export inteiro, incluindo o tempo gasto em fn (escrever na resposta).
+func (t *TracedUserRepository) Export(ctx context.Context, includeDeleted bool, fn func(model.User) error) error {
+	ctx, span := startSpan(ctx, "Export")
+	err := t.next.Export(ctx, includeDeleted, fn)
+	endSpan(span, err)
+	return err
+}
+
+func (t *TracedUserRepository) Count(ctx context.Context, includeDeleted bool) (int64, error) {
+	ctx, span := startSpan(ctx, "Count")
+	count, err := t.next.Count(ctx, includeDeleted)
+	endSpan(span, err)
+	return count, err
+}
+
+func startSpan(ctx context.Context, method string) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, "UserRepository."+method)
+}
+
+// endSpan marca o span com o erro, se houver, e o encerra.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
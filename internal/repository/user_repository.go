@@ -2,14 +2,15 @@ package repository
 
 import (
 	"context"
-	"errors"
 	"fmt"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/dowglassantana/golang-with-dynamodb/internal/fieldcrypt"
 	"github.com/dowglassantana/golang-with-dynamodb/internal/model"
 )
 
@@ -18,94 +19,135 @@ import (
 type UserRepository interface {
 	Create(ctx context.Context, user model.User) error
 	GetByID(ctx context.Context, id string) (*model.User, error)
-	GetAll(ctx context.Context) ([]model.User, error)
+	GetByEmail(ctx context.Context, email string) (*model.User, error)
+	GetAll(ctx context.Context, input model.ListUsersInput) (*model.UserPage, error)
 	Update(ctx context.Context, id string, input model.UpdateUserInput) error
+	Patch(ctx context.Context, id string, input model.PatchUserInput) (*model.User, error)
 	Delete(ctx context.Context, id string) error
+	Restore(ctx context.Context, id string) (*model.User, error)
+	BatchCreate(ctx context.Context, users []model.User) ([]model.BatchResult, error)
+	BatchGet(ctx context.Context, ids []string) ([]model.BatchResult, error)
+	BatchDelete(ctx context.Context, ids []string) ([]model.BatchResult, error)
+	History(ctx context.Context, userID string, input model.ListHistoryInput) (*model.UserEventPage, error)
+	Export(ctx context.Context, includeDeleted bool, fn func(model.User) error) error
+	Count(ctx context.Context, includeDeleted bool) (int64, error)
 }
 
 // DynamoUserRepository e a implementacao concreta do UserRepository usando DynamoDB.
+// O sealer cifra o email antes da gravacao (veja encryption.go).
 type DynamoUserRepository struct {
-	client    *dynamodb.Client
-	tableName string
+	client       *dynamodb.Client
+	tableName    string
+	sealer       *fieldcrypt.Sealer
+	rotator      *rotator
+	cursor       cursorCodec
+	retention    time.Duration
+	strictDelete bool
 }
 
-func NewUserRepository(client *dynamodb.Client, tableName string) *DynamoUserRepository {
-	return &DynamoUserRepository{client: client, tableName: tableName}
-}
+var _ UserRepository = (*DynamoUserRepository)(nil)
 
-// CreateTable cria a tabela no DynamoDB caso ela ainda nao exista.
-//
-// No DynamoDB, toda tabela precisa de pelo menos uma chave primaria (partition key).
-// Aqui usamos o campo "id" como partition key (HASH), o que significa que cada item
-// na tabela sera identificado unicamente pelo seu "id".
-//
-// KeySchema define a estrutura da chave:
-//   - HASH = partition key (obrigatoria) — distribui os dados entre as particoes internas.
-//
-// AttributeDefinitions descreve o tipo do atributo usado na chave:
-//   - "S" = String, "N" = Number, "B" = Binary.
-//
-// BillingMode PAY_PER_REQUEST = modo sob demanda (sem necessidade de provisionar capacidade).
-// Ideal para desenvolvimento local e cargas imprevisiveis.
-func (r *DynamoUserRepository) CreateTable(ctx context.Context) error {
-	_, err := r.client.CreateTable(ctx, &dynamodb.CreateTableInput{
-		TableName: aws.String(r.tableName),
-		KeySchema: []types.KeySchemaElement{
-			{
-				AttributeName: aws.String("id"),
-				KeyType:       types.KeyTypeHash,
-			},
-		},
-		AttributeDefinitions: []types.AttributeDefinition{
-			{
-				AttributeName: aws.String("id"),
-				AttributeType: types.ScalarAttributeTypeS,
-			},
-		},
-		BillingMode: types.BillingModePayPerRequest,
-	})
-	if err != nil {
-		// ResourceInUseException significa que a tabela ja existe — podemos ignorar.
-		var resourceInUse *types.ResourceInUseException
-		if errors.As(err, &resourceInUse) {
-			return nil
-		}
-		return fmt.Errorf("erro ao criar tabela: %w", err)
+func NewUserRepository(client *dynamodb.Client, tableName string, sealer *fieldcrypt.Sealer, opts ...Option) *DynamoUserRepository {
+	o := newOptions(opts)
+	r := &DynamoUserRepository{
+		client:       client,
+		tableName:    tableName,
+		sealer:       sealer,
+		cursor:       newCursorCodec(o.cursorSecret),
+		retention:    o.retention,
+		strictDelete: o.strictDelete,
 	}
-	return nil
+	r.rotator = newRotator(r.rotateItem)
+	return r
 }
 
-// Create insere um novo usuario na tabela usando PutItem.
-//
-// PutItem e a operacao basica de escrita do DynamoDB. Ela insere um item novo
-// ou substitui completamente um item existente que tenha a mesma chave primaria.
+// Create insere um novo usuario na tabela junto com o sentinela do seu email.
 //
 // attributevalue.MarshalMap converte a struct Go para o formato map[string]AttributeValue
 // que o DynamoDB espera. Ele usa as tags `dynamodbav` da struct para mapear os campos.
 //
-// Exemplo: model.User{ID: "123", Name: "Joao"} vira:
+// Exemplo: model.User{ID: "123", Name: "Joao"}, no tenant "acme", vira:
 //
 //	map[string]AttributeValue{
-//	    "id":   &types.AttributeValueMemberS{Value: "123"},
-//	    "name": &types.AttributeValueMemberS{Value: "Joao"},
+//	    "id":        &types.AttributeValueMemberS{Value: "TENANT#acme#USER#123"},
+//	    "user_id":   &types.AttributeValueMemberS{Value: "123"},
+//	    "name":      &types.AttributeValueMemberS{Value: "Joao"},
+//	    "email_enc": &types.AttributeValueMemberM{...},
+//	    ...
 //	}
+//
+// O tenant vem do contexto (veja tenant.go). O email e cifrado por marshalUser antes
+// da serializacao (veja encryption.go).
+//
+// Em vez de um PutItem simples, usamos TransactWriteItems com tres Puts:
+// o usuario, o item sentinela "TENANT#<t>#EMAIL#<email_hash>" (veja email_lock.go) e o evento
+// de historico (veja history.go).
+// TransactWriteItems e tudo-ou-nada: se a condicao de qualquer item falhar,
+// nenhum e gravado. Assim nunca fica um usuario sem sentinela, nem o contrario.
+//
+// O Put do usuario so grava se o id estiver livre ou pertencer a um usuario excluido
+// (soft delete), que ja nao aparece na API e ja liberou o email. Isso so acontece com
+// ids escolhidos pelo chamador (import, seed); um id em uso retorna ErrIDTaken.
 func (r *DynamoUserRepository) Create(ctx context.Context, user model.User) error {
-	dm := toDynamo(user)
+	_, err := r.create(ctx, user, false)
+	return err
+}
+
+// create grava o usuario e o sentinela. Com deferEvent, o evento de historico fica fora
+// da transacao e e devolvido ja serializado, para o chamador grava-lo depois (veja
+// BatchCreate); sem ele, o evento vai na transacao e o retorno e nil.
+func (r *DynamoUserRepository) create(ctx context.Context, user model.User, deferEvent bool) (map[string]types.AttributeValue, error) {
+	tenant := tenantOf(ctx)
+	dm := toDynamo(tenant, user)
+
+	item, err := r.marshalUser(ctx, dm)
+	if err != nil {
+		return nil, err
+	}
+
+	lock, err := attributevalue.MarshalMap(newEmailLock(r.emailLock(tenant, user.Email), user.ID))
+	if err != nil {
+		return nil, fmt.Errorf("erro ao serializar sentinela de email: %w", err)
+	}
 
-	item, err := attributevalue.MarshalMap(dm)
+	event, err := r.putEvent(ctx, newUserEvent(ctx, user.ID, model.EventCreated, nil, &dm))
 	if err != nil {
-		return fmt.Errorf("erro ao serializar usuario: %w", err)
+		return nil, err
+	}
+
+	items := []types.TransactWriteItem{
+		{Put: &types.Put{
+			TableName:           aws.String(r.tableName),
+			Item:                item,
+			ConditionExpression: aws.String("attribute_not_exists(id) OR attribute_exists(deleted_at)"),
+		}},
+		{Put: &types.Put{
+			TableName:           aws.String(r.tableName),
+			Item:                lock,
+			ConditionExpression: aws.String("attribute_not_exists(id)"),
+		}},
+	}
+	if !deferEvent {
+		items = append(items, event)
 	}
 
-	_, err = r.client.PutItem(ctx, &dynamodb.PutItemInput{
-		TableName: aws.String(r.tableName),
-		Item:      item,
+	_, err = r.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: items,
 	})
 	if err != nil {
-		return fmt.Errorf("erro ao inserir usuario: %w", err)
+		if transactionFailedAt(err, 1) {
+			return nil, ErrEmailTaken
+		}
+		if transactionFailedAt(err, 0) {
+			return nil, ErrIDTaken
+		}
+		return nil, dynamoError(ctx, "erro ao inserir usuario", err)
 	}
 
-	return nil
+	if deferEvent {
+		return event.Put.Item, nil
+	}
+	return nil, nil
 }
 
 // GetByID busca um usuario pelo ID usando GetItem.
@@ -115,76 +157,194 @@ func (r *DynamoUserRepository) Create(ctx context.Context, user model.User) erro
 // A complexidade e O(1) — nao importa quantos itens existam na tabela.
 //
 // O parametro Key recebe a chave primaria do item que queremos buscar.
-// Como nossa partition key e "id" do tipo String, passamos um AttributeValueMemberS.
+// Como nossa partition key e "id" do tipo String, passamos um AttributeValueMemberS
+// com o id prefixado pelo tenant do contexto ("TENANT#<t>#USER#<id>").
 //
 // Se o item nao for encontrado, GetItem retorna sem erro, mas output.Item vem nil.
 // Por isso verificamos se o resultado esta vazio antes de tentar desserializar.
+// Usuarios excluidos (soft delete) tambem sao tratados como nao encontrados.
 //
-// attributevalue.UnmarshalMap faz o caminho inverso do MarshalMap:
-// converte o map[string]AttributeValue de volta para a struct Go.
+// unmarshalUser faz o caminho inverso do marshalUser: converte o
+// map[string]AttributeValue de volta para a struct Go e decifra o email.
 func (r *DynamoUserRepository) GetByID(ctx context.Context, id string) (*model.User, error) {
-	output, err := r.client.GetItem(ctx, &dynamodb.GetItemInput{
-		TableName: aws.String(r.tableName),
-		Key: map[string]types.AttributeValue{
-			"id": &types.AttributeValueMemberS{Value: id},
-		},
+	dm, err := r.getItem(ctx, id, false)
+	if err != nil || dm == nil || dm.isDeleted() {
+		return nil, err
+	}
+
+	user := dm.toUser()
+	return &user, nil
+}
+
+// GetByEmail busca um usuario pelo email usando Query no GSI email-hash-index.
+//
+// Query e a forma eficiente de buscar por um atributo que nao e a chave primaria:
+// em vez de varrer a tabela (Scan), o DynamoDB vai direto a particao do indice
+// cuja partition key e o blind index do email (veja encryption.go).
+//
+// KeyConditionExpression define qual particao ler: "email_hash = :hash".
+// Diferente do FilterExpression, a KeyCondition e aplicada ANTES da leitura,
+// entao so pagamos pelos itens que realmente correspondem.
+//
+// O FilterExpression descarta usuarios excluidos: depois de um soft delete o email
+// fica livre e pode pertencer a um novo usuario, enquanto o antigo ainda esta no indice.
+// Tambem descarta usuarios de outros tenants, que podem ter o mesmo email.
+//
+// Leituras em GSI sao sempre eventualmente consistentes — um usuario recem-criado
+// pode levar alguns milissegundos para aparecer no indice.
+func (r *DynamoUserRepository) GetByEmail(ctx context.Context, email string) (*model.User, error) {
+	tenant := tenantOf(ctx)
+	keyCond := expression.Key(emailIndexKey).Equal(expression.Value(r.emailHash(tenant, email)))
+	filter := expression.AttributeNotExists(expression.Name("deleted_at")).
+		And(expression.Name(tenantPKAttr).Equal(expression.Value(tenantPK(tenant))))
+
+	expr, err := expression.NewBuilder().
+		WithKeyCondition(keyCond).
+		WithFilter(filter).
+		Build()
+	if err != nil {
+		return nil, fmt.Errorf("erro ao construir expressao: %w", err)
+	}
+
+	output, err := r.client.Query(ctx, &dynamodb.QueryInput{
+		TableName:                 aws.String(r.tableName),
+		IndexName:                 aws.String(emailIndexName),
+		KeyConditionExpression:    expr.KeyCondition(),
+		FilterExpression:          expr.Filter(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
 	})
 	if err != nil {
-		return nil, fmt.Errorf("erro ao buscar usuario: %w", err)
+		return nil, dynamoError(ctx, "erro ao buscar usuario por email", err)
 	}
 
-	if output.Item == nil {
+	if len(output.Items) == 0 {
 		return nil, nil
 	}
 
-	var dm userDynamo
-	err = attributevalue.UnmarshalMap(output.Item, &dm)
+	dm, err := r.unmarshalUser(ctx, output.Items[0])
 	if err != nil {
-		return nil, fmt.Errorf("erro ao desserializar usuario: %w", err)
+		return nil, err
 	}
 
 	user := dm.toUser()
 	return &user, nil
 }
 
-// GetAll retorna todos os usuarios da tabela usando Scan.
+// getItem le o item de usuario pelo id, no tenant do contexto. Itens auxiliares
+// (sentinelas) sao tratados como inexistentes, para que nada interno vaze pela API.
+//
+// ConsistentRead = true garante que lemos a versao mais recente do item. Por padrao o
+// GetItem e eventualmente consistente (mais barato, mas pode devolver um dado de
+// alguns milissegundos atras) — antes de uma escrita condicional queremos o valor atual.
+func (r *DynamoUserRepository) getItem(ctx context.Context, id string, consistent bool) (*userDynamo, error) {
+	output, err := r.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(r.tableName),
+		Key:            idKey(userKey(tenantOf(ctx), id)),
+		ConsistentRead: aws.Bool(consistent),
+	})
+	if err != nil {
+		return nil, dynamoError(ctx, "erro ao buscar usuario", err)
+	}
+
+	if output.Item == nil || isAuxItem(output.Item) {
+		return nil, nil
+	}
+
+	dm, err := r.unmarshalUser(ctx, output.Item)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dm, nil
+}
+
+// GetAll retorna uma pagina de usuarios do tenant usando Query em um GSI.
 //
-// Scan percorre TODOS os itens da tabela e retorna cada um deles.
-// Diferente de GetItem (que busca por chave), o Scan faz uma varredura completa.
+// Como todos os usuarios de um tenant ficam na particao "TENANT#<t>" dos indices, a
+// listagem e uma Query e nao um Scan: o DynamoDB le apenas os itens do tenant e o
+// custo nao depende do tamanho da tabela. Itens de outros tenants nunca sao lidos —
+// nem mesmo para serem filtrados. Sem filtros a Query usa o tenant-index; com busca
+// por nome ou por janela de created_at, o name-index ou o created-index (veja
+// search.go). ScanIndexForward = false inverte a ordem da sort key (order=desc).
 //
-// ATENCAO: Scan e uma operacao custosa! Ele le cada item da tabela, o que consome
-// muitas unidades de leitura (RCU). Em tabelas grandes, isso pode ser lento e caro.
-// Para producao, prefira usar Query com indices (GSI/LSI) quando possivel.
+// A Query retorna no maximo 1MB de dados por chamada (ou Limit itens, o que vier
+// primeiro). Quando ha mais dados, a resposta traz LastEvaluatedKey — a chave do
+// ultimo item lido. Para continuar de onde parou, enviamos essa chave como
+// ExclusiveStartKey. Em um GSI, o LastEvaluatedKey traz as chaves da tabela (id) e do
+// indice (tenant_pk e a sort key).
 //
-// Scan retorna no maximo 1MB de dados por chamada. Para tabelas maiores,
-// seria necessario usar paginacao com LastEvaluatedKey, mas para este exemplo
-// basico uma unica chamada e suficiente.
+// O LastEvaluatedKey nunca e exposto cru ao cliente: ele vira um cursor opaco e
+// assinado (veja cursorCodec). Um cursor emitido para outro tenant, ou para outro
+// indice, e recusado com ErrInvalidCursor. Se a pagina do DynamoDB voltar com menos
+// itens que o limite pedido (por causa do teto de 1MB ou do filtro), repetimos a
+// Query ate completar a pagina ou chegar ao fim da particao.
 //
-// attributevalue.UnmarshalListOfMaps converte a lista de items retornada pelo
-// DynamoDB para um slice de structs Go.
-func (r *DynamoUserRepository) GetAll(ctx context.Context) ([]model.User, error) {
-	output, err := r.client.Scan(ctx, &dynamodb.ScanInput{
-		TableName: aws.String(r.tableName),
-	})
+// Salvo quando input.IncludeDeleted, o FilterExpression attribute_not_exists(deleted_at)
+// esconde os excluidos. Atencao: o filtro e aplicado DEPOIS da leitura — os excluidos
+// ainda consomem RCU e contam no Limit. Os indices sao esparsos (so usuarios tem
+// tenant_pk), entao sentinelas e eventos nao aparecem aqui.
+//
+// unmarshalUsers converte a lista de items retornada pelo DynamoDB para um slice de
+// structs Go, decifrando o email de cada um.
+func (r *DynamoUserRepository) GetAll(ctx context.Context, input model.ListUsersInput) (*model.UserPage, error) {
+	startKey, err := r.cursor.decode(input.Cursor)
 	if err != nil {
-		return nil, fmt.Errorf("erro ao listar usuarios: %w", err)
+		return nil, err
+	}
+
+	tenant := tenantOf(ctx)
+	query := listQueryFor(tenant, input)
+	if startKey != nil && !query.accepts(startKey, tenant) {
+		return nil, ErrInvalidCursor
 	}
 
-	var models []userDynamo
-	err = attributevalue.UnmarshalListOfMaps(output.Items, &models)
+	expr, err := query.builder().Build()
 	if err != nil {
-		return nil, fmt.Errorf("erro ao desserializar usuarios: %w", err)
+		return nil, fmt.Errorf("erro ao construir expressao: %w", err)
+	}
+
+	users := make([]model.User, 0, input.Limit)
+	for {
+		output, err := r.client.Query(ctx, &dynamodb.QueryInput{
+			TableName:                 aws.String(r.tableName),
+			IndexName:                 aws.String(query.index),
+			KeyConditionExpression:    expr.KeyCondition(),
+			FilterExpression:          expr.Filter(),
+			ExpressionAttributeNames:  expr.Names(),
+			ExpressionAttributeValues: expr.Values(),
+			ScanIndexForward:          aws.Bool(query.forward),
+			Limit:                     aws.Int32(input.Limit - int32(len(users))),
+			ExclusiveStartKey:         startKey,
+		})
+		if err != nil {
+			return nil, dynamoError(ctx, "erro ao listar usuarios", err)
+		}
+
+		models, err := r.unmarshalUsers(ctx, output.Items)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, m := range models {
+			users = append(users, m.toUser())
+		}
+
+		startKey = output.LastEvaluatedKey
+		if len(startKey) == 0 || int32(len(users)) >= input.Limit {
+			break
+		}
 	}
 
-	users := make([]model.User, len(models))
-	for i, m := range models {
-		users[i] = m.toUser()
+	next, err := r.cursor.encode(startKey)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao gerar cursor: %w", err)
 	}
 
-	return users, nil
+	return &model.UserPage{Users: users, NextCursor: next}, nil
 }
 
-// Update atualiza os campos name e email de um usuario usando UpdateItem.
+// Update atualiza os campos name e email de um usuario.
 //
 // UpdateItem modifica atributos especificos de um item existente SEM substituir
 // o item inteiro (diferente de PutItem que sobrescreve tudo).
@@ -192,9 +352,9 @@ func (r *DynamoUserRepository) GetAll(ctx context.Context) ([]model.User, error)
 //
 // Usamos o pacote expression para construir a UpdateExpression de forma segura.
 // Ele gera automaticamente:
-//   - UpdateExpression: "SET #name = :name, #email = :email"
-//   - ExpressionAttributeNames: {"#name": "name", "#email": "email"}
-//   - ExpressionAttributeValues: {":name": "Joao", ":email": "joao@email.com"}
+//   - UpdateExpression: "SET #name = :name, #email_enc = :enc, #email_hash = :hash"
+//   - ExpressionAttributeNames: {"#name": "name", "#email_enc": "email_enc"}
+//   - ExpressionAttributeValues: {":name": "Joao", ":hash": "q3Zx..."}
 //
 // Por que usar ExpressionAttributeNames (#name)?
 // Porque "name" e uma palavra reservada do DynamoDB. Se usarmos "name" diretamente
@@ -204,62 +364,180 @@ func (r *DynamoUserRepository) GetAll(ctx context.Context) ([]model.User, error)
 // Para evitar injecao de expressoes e separar a logica dos valores, similar
 // a prepared statements em SQL.
 //
-// ConditionExpression "attribute_exists(id)" garante que so atualizamos um item
-// que ja existe. Se o id nao for encontrado, o DynamoDB retorna ConditionalCheckFailedException.
+// O Update vai dentro de uma TransactWriteItems junto com:
+//   - o Delete do sentinela antigo e o Put do novo, quando o email muda;
+//   - o Put do evento de historico, com o estado antes e depois da alteracao.
+//
+// Update e um Patch com name e email presentes — a transacao e montada em patch.go.
+//
+// Controle de concorrencia otimista: toda escrita faz "SET version = version + 1"
+// e e condicionada a versao que acabamos de ler. Quando input.ExpectedVersion e
+// informado (If-Match) e difere da versao atual, retornamos ErrStaleVersion em vez
+// de sobrescrever a mudanca alheia. Sem ExpectedVersion, uma corrida com outra
+// escrita apenas faz a operacao ser refeita (veja retryOnConflict).
 func (r *DynamoUserRepository) Update(ctx context.Context, id string, input model.UpdateUserInput) error {
+	return retryOnConflict(func() error {
+		return r.update(ctx, id, input)
+	})
+}
+
+// update e um patch com os dois campos presentes. Diferente do Patch, que devolve
+// nil, nil, atualizar um usuario inexistente retorna ErrNotFound.
+func (r *DynamoUserRepository) update(ctx context.Context, id string, input model.UpdateUserInput) error {
+	updated, err := r.patch(ctx, id, fullPatch(input))
+	if err != nil {
+		return err
+	}
+	if updated == nil {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Delete exclui um usuario (soft delete) e libera o sentinela do seu email.
+//
+// Em vez de DeleteItem, marcamos o item com deleted_at e expires_at usando UpdateItem.
+// O item continua na tabela e pode ser restaurado (veja Restore) durante a janela de
+// retencao. expires_at e o atributo de TTL da tabela: quando esse instante passa, o
+// proprio DynamoDB apaga o item em segundo plano, sem consumir WCU.
+//
+// O email e liberado na mesma TransactWriteItems, para que um novo usuario possa usa-lo
+// imediatamente, e o evento de historico tambem vai junto.
+//
+// Assim como o DeleteItem, a operacao e idempotente: excluir um usuario inexistente
+// ou ja excluido retorna sem erro. No modo estrito (WithStrictDelete), esses casos
+// retornam ErrNotFound. A escrita e condicionada a attribute_exists(id) (veja
+// userWrite): se o item sumir entre a leitura e a escrita — o TTL pode apaga-lo a
+// qualquer momento —, a transacao falha, a operacao e refeita e a nova leitura ja
+// nao encontra o usuario.
+func (r *DynamoUserRepository) Delete(ctx context.Context, id string) error {
+	_, err := r.deleteUser(ctx, id, false)
+	return err
+}
+
+// deleteUser e o Delete com a opcao deferEvent de create: o evento de historico da
+// exclusao e devolvido em vez de ir na transacao. Sem exclusao (usuario inexistente
+// ou ja excluido), o evento e nil.
+func (r *DynamoUserRepository) deleteUser(ctx context.Context, id string, deferEvent bool) (map[string]types.AttributeValue, error) {
+	var event map[string]types.AttributeValue
+	err := retryOnConflict(func() error {
+		var err error
+		event, err = r.delete(ctx, id, deferEvent)
+		return err
+	})
+	return event, err
+}
+
+func (r *DynamoUserRepository) delete(ctx context.Context, id string, deferEvent bool) (map[string]types.AttributeValue, error) {
+	current, err := r.getItem(ctx, id, true)
+	if err != nil {
+		return nil, err
+	}
+	if current == nil || current.isDeleted() {
+		if r.strictDelete {
+			return nil, ErrNotFound
+		}
+		return nil, nil
+	}
+
+	now := time.Now().UTC()
+	after := *current
+	after.DeletedAt = now.Format(time.RFC3339)
+	after.ExpiresAt = now.Add(r.retention).Unix()
+	after.Version++
+
 	update := expression.
-		Set(expression.Name("name"), expression.Value(input.Name)).
-		Set(expression.Name("email"), expression.Value(input.Email))
+		Set(expression.Name("deleted_at"), expression.Value(after.DeletedAt)).
+		Set(expression.Name(ttlAttr), expression.Value(after.ExpiresAt)).
+		Set(expression.Name("version"), nextVersion())
+
+	items, err := r.userWrite(ctx, current, update, model.EventDeleted, &after)
+	if err != nil {
+		return nil, err
+	}
+
+	// O evento e o item 1 (veja userWrite); o indice 0 continua sendo o usuario.
+	var event map[string]types.AttributeValue
+	if deferEvent {
+		event = items[1].Put.Item
+		items = items[:1]
+	}
+
+	releaseLock, err := r.releaseEmailLock(r.emailLock(current.TenantID, current.Email), id)
+	if err != nil {
+		return nil, err
+	}
+	items = append(items, releaseLock)
+
+	_, err = r.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: items,
+	})
+	if err != nil {
+		if transactionFailedAt(err, 0) {
+			return nil, errConcurrentWrite
+		}
+		return nil, dynamoError(ctx, "erro ao deletar usuario", err)
+	}
+
+	return event, nil
+}
 
-	condition := expression.AttributeExists(expression.Name("id"))
+// userWrite monta os dois primeiros itens de toda transacao que altera um usuario:
+//  0. o Update do usuario, condicionado a versao lida (current.Version);
+//  1. o Put do evento de historico com o estado antes (current) e depois (after).
+//
+// Os chamadores acrescentam os itens de sentinela depois desses dois, entao o
+// indice 0 em CancellationReasons sempre se refere ao usuario.
+func (r *DynamoUserRepository) userWrite(ctx context.Context, current *userDynamo, update expression.UpdateBuilder, action string, after *userDynamo) ([]types.TransactWriteItem, error) {
+	condition := expression.AttributeExists(expression.Name("id")).
+		And(expression.AttributeNotExists(expression.Name(itemTypeAttr))).
+		And(versionEquals(current.Version))
 
 	expr, err := expression.NewBuilder().
 		WithUpdate(update).
 		WithCondition(condition).
 		Build()
 	if err != nil {
-		return fmt.Errorf("erro ao construir expressao: %w", err)
+		return nil, fmt.Errorf("erro ao construir expressao: %w", err)
 	}
 
-	_, err = r.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
-		TableName: aws.String(r.tableName),
-		Key: map[string]types.AttributeValue{
-			"id": &types.AttributeValueMemberS{Value: id},
-		},
-		UpdateExpression:          expr.Update(),
-		ExpressionAttributeNames:  expr.Names(),
-		ExpressionAttributeValues: expr.Values(),
-		ConditionExpression:       expr.Condition(),
-	})
+	event, err := r.putEvent(ctx, newUserEvent(ctx, current.ID, action, current, after))
 	if err != nil {
-		return fmt.Errorf("erro ao atualizar usuario: %w", err)
+		return nil, err
 	}
 
-	return nil
+	return []types.TransactWriteItem{
+		{Update: &types.Update{
+			TableName:                 aws.String(r.tableName),
+			Key:                       idKey(current.Key),
+			UpdateExpression:          expr.Update(),
+			ConditionExpression:       expr.Condition(),
+			ExpressionAttributeNames:  expr.Names(),
+			ExpressionAttributeValues: expr.Values(),
+		}},
+		event,
+	}, nil
 }
 
-// Delete remove um usuario da tabela pelo ID usando DeleteItem.
-//
-// DeleteItem remove um unico item com base na chave primaria informada.
-// Assim como GetItem, a operacao acessa diretamente a particao correta.
+// releaseEmailLock monta o Delete do sentinela de um email para uso em transacao.
 //
-// Por padrao, DeleteItem NAO retorna erro se o item nao existir — ele simplesmente
-// nao faz nada (operacao idempotente). Isso e util para garantir que
-// chamadas repetidas de delete nao causem falhas.
-//
-// Se quisermos garantir que o item existia antes de deletar, podemos adicionar
-// ConditionExpression: aws.String("attribute_exists(id)"), que faria o DynamoDB
-// retornar erro caso o item nao fosse encontrado.
-func (r *DynamoUserRepository) Delete(ctx context.Context, id string) error {
-	_, err := r.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
-		TableName: aws.String(r.tableName),
-		Key: map[string]types.AttributeValue{
-			"id": &types.AttributeValueMemberS{Value: id},
-		},
-	})
+// A condicao "attribute_not_exists(id) OR user_id = :id" permite liberar o sentinela
+// apenas se ele pertence ao usuario — e tolera usuarios antigos, criados antes da
+// existencia dos sentinelas, que nao tem nenhum item reservado.
+func (r *DynamoUserRepository) releaseEmailLock(lockKey, userID string) (types.TransactWriteItem, error) {
+	condition := expression.AttributeNotExists(expression.Name("id")).
+		Or(expression.Name("user_id").Equal(expression.Value(userID)))
+
+	expr, err := expression.NewBuilder().WithCondition(condition).Build()
 	if err != nil {
-		return fmt.Errorf("erro ao deletar usuario: %w", err)
+		return types.TransactWriteItem{}, fmt.Errorf("erro ao construir expressao: %w", err)
 	}
 
-	return nil
+	return types.TransactWriteItem{Delete: &types.Delete{
+		TableName:                 aws.String(r.tableName),
+		Key:                       idKey(lockKey),
+		ConditionExpression:       expr.Condition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	}}, nil
 }
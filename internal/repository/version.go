@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+)
+
+// nextVersion monta o operando "if_not_exists(version, 0) + 1".
+//
+// if_not_exists devolve o valor atual do atributo ou o padrao informado quando ele
+// nao existe — assim usuarios antigos, sem o atributo version, passam para a versao 1.
+func nextVersion() expression.SetValueBuilder {
+	return expression.Plus(
+		expression.IfNotExists(expression.Name("version"), expression.Value(0)),
+		expression.Value(1),
+	)
+}
+
+// versionEquals monta a condicao de concorrencia otimista para a versao esperada.
+// A versao 0 representa um usuario antigo, que ainda nao possui o atributo.
+func versionEquals(expected int64) expression.ConditionBuilder {
+	if expected == 0 {
+		return expression.AttributeNotExists(expression.Name("version"))
+	}
+	return expression.Name("version").Equal(expression.Value(expected))
+}
+
+// errConcurrentWrite indica que o item mudou entre a leitura e a escrita condicional.
+var errConcurrentWrite = errors.New("usuario alterado durante a escrita")
+
+// maxConflictRetries limita quantas vezes uma escrita e refeita apos errConcurrentWrite.
+const maxConflictRetries = 3
+
+// retryOnConflict executa fn de novo quando a escrita perde a corrida para outra
+// requisicao. As escritas que registram historico sao condicionadas a versao que
+// foi lida (para que o "antes" do evento seja exato); se alguem gravou no meio do
+// caminho, basta ler de novo e tentar outra vez. Esgotadas as tentativas, o
+// conflito e reportado como ErrStaleVersion.
+func retryOnConflict(fn func() error) error {
+	for range maxConflictRetries {
+		if err := fn(); !errors.Is(err, errConcurrentWrite) {
+			return err
+		}
+	}
+	return ErrStaleVersion
+}
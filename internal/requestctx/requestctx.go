@@ -0,0 +1,77 @@
+// Package requestctx guarda no context.Context os dados da requisicao que precisam
+// chegar ate as camadas internas (service, repository) sem poluir as assinaturas.
+package requestctx
+
+import "context"
+
+// AnonymousActor e o ator registrado quando a requisicao nao se identifica.
+const AnonymousActor = "anonymous"
+
+// DefaultTenant e o tenant usado quando o contexto nao carrega nenhum. Os dados
+// gravados antes do suporte a multi-tenant pertencem a ele.
+const DefaultTenant = "default"
+
+// maxTenantLength limita o tamanho do tenant, que faz parte das chaves da tabela.
+const maxTenantLength = 64
+
+type actorKey struct{}
+
+type tenantKey struct{}
+
+type requestIDKey struct{}
+
+// WithActor retorna um contexto que carrega quem esta executando a requisicao.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// Actor devolve o ator do contexto, ou AnonymousActor se nenhum foi definido.
+func Actor(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorKey{}).(string); ok && actor != "" {
+		return actor
+	}
+	return AnonymousActor
+}
+
+// WithTenant retorna um contexto que carrega o tenant (cliente) dono dos dados da
+// requisicao. O repository monta todas as chaves a partir dele.
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// Tenant devolve o tenant do contexto, ou DefaultTenant se nenhum foi definido.
+func Tenant(ctx context.Context) string {
+	if tenant, ok := ctx.Value(tenantKey{}).(string); ok && tenant != "" {
+		return tenant
+	}
+	return DefaultTenant
+}
+
+// WithRequestID retorna um contexto que carrega o identificador da requisicao
+// (header X-Request-ID), usado para ligar as linhas de log de uma mesma requisicao.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID devolve o identificador da requisicao, ou "" fora de uma requisicao HTTP.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// ValidTenant indica se o tenant pode ser usado em uma chave. So letras, digitos,
+// "-" e "_" sao aceitos: um "#" permitiria montar a chave de outro tenant
+// (ex: "a#USER#x").
+func ValidTenant(tenant string) bool {
+	if tenant == "" || len(tenant) > maxTenantLength {
+		return false
+	}
+	for _, c := range tenant {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_':
+		default:
+			return false
+		}
+	}
+	return true
+}
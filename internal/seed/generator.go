@@ -0,0 +1,79 @@
+package seed
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"github.com/dowglassantana/golang-with-dynamodb/internal/model"
+	"github.com/google/uuid"
+)
+
+// Os nomes tem acentos de proposito: exercitam a normalizacao do name-index
+// ("Joao" encontra "João").
+var (
+	firstNames = []string{
+		"Ana", "Beatriz", "Bruno", "Camila", "Carlos", "Cecília", "Daniel", "Eduardo",
+		"Fernanda", "Gabriel", "Helena", "Igor", "Isabela", "João", "Júlia", "Larissa",
+		"Lucas", "Luíza", "Marcos", "Mariana", "Mateus", "Natália", "Otávio", "Paula",
+		"Pedro", "Rafael", "Renata", "Sérgio", "Tatiane", "Thiago", "Vitória", "Yuri",
+	}
+	lastNames = []string{
+		"Almeida", "Araújo", "Barbosa", "Cardoso", "Carvalho", "Castro", "Conceição", "Costa",
+		"Dias", "Fernandes", "Ferreira", "Gomes", "Gonçalves", "Lima", "Lopes", "Martins",
+		"Melo", "Monteiro", "Moreira", "Nascimento", "Oliveira", "Pereira", "Ribeiro", "Rocha",
+		"Rodrigues", "Santos", "Silva", "Soares", "Sousa", "Teixeira", "Vieira", "Xavier",
+	}
+)
+
+// namespace dos uuids (v5) gerados pelo seed.
+var namespace = uuid.NewSHA1(uuid.NameSpaceURL, []byte("golang-with-dynamodb/seed"))
+
+// Generator produz usuarios ficticios de forma deterministica.
+//
+// Cada usuario depende apenas de (Seed, indice): o indice i tem um gerador
+// pseudoaleatorio proprio (PCG com as sementes Seed e i). Por isso o mesmo comando
+// sempre gera os mesmos usuarios, em qualquer ordem e com qualquer concorrencia, e
+// "-n 2000" repete os 1000 usuarios de "-n 1000" e acrescenta outros 1000.
+//
+// O id e um UUID v5 derivado de (Seed, indice), entao o wipe recalcula os ids sem
+// precisar ler a tabela. O email leva o indice e a semente ("joao.silva.42@seed7.example.com"):
+// nunca repete dentro de um conjunto nem colide com outra semente.
+type Generator struct {
+	Seed uint64
+	From time.Time
+	To   time.Time
+}
+
+// User gera o usuario de indice i. CreatedAt fica distribuido uniformemente em
+// [From, To), com precisao de segundos, como o model.NewUser grava.
+func (g Generator) User(i int) model.User {
+	rng := rand.New(rand.NewPCG(g.Seed, uint64(i)))
+
+	first := firstNames[rng.IntN(len(firstNames))]
+	last := lastNames[rng.IntN(len(lastNames))]
+
+	createdAt := g.From
+	if span := g.To.Sub(g.From); span > 0 {
+		createdAt = createdAt.Add(time.Duration(rng.Int64N(int64(span))))
+	}
+
+	return model.User{
+		ID:        g.ID(i),
+		Name:      first + " " + last,
+		Email:     fmt.Sprintf("%s.%s.%d@seed%d.example.com", emailPart(first), emailPart(last), i, g.Seed),
+		CreatedAt: createdAt.UTC().Truncate(time.Second).Format(time.RFC3339),
+		Version:   1,
+	}
+}
+
+// ID e o id do usuario de indice i.
+func (g Generator) ID(i int) string {
+	return uuid.NewSHA1(namespace, fmt.Appendf(nil, "%d/%d", g.Seed, i)).String()
+}
+
+// emailPart tira acentos e espacos de um nome: "Conceição" vira "conceicao".
+func emailPart(name string) string {
+	return strings.ReplaceAll(model.NormalizeName(name), " ", ".")
+}
@@ -0,0 +1,115 @@
+// Package seed gera usuarios ficticios para demonstracoes e testes de carga.
+//
+// Os usuarios sao deterministicos (veja Generator): a mesma semente sempre produz os
+// mesmos ids, nomes, emails e datas de criacao. Isso permite:
+//   - repetir um teste de carga com exatamente os mesmos dados;
+//   - rodar o seed de novo sem duplicar nada — os emails ja gravados voltam como
+//     ErrEmailTaken e sao contados como "ja existiam";
+//   - desfazer o seed (Wipe) recalculando os ids, sem Scan na tabela.
+//
+// A gravacao passa pelo caminho em lote do repository (BatchCreate/BatchDelete), com
+// as mesmas regras da API: sentinela de email, email cifrado e evento de historico.
+// Os lotes sao enviados por varias goroutines, limitadas por workers.
+package seed
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+
+	"github.com/dowglassantana/golang-with-dynamodb/internal/model"
+	"github.com/dowglassantana/golang-with-dynamodb/internal/repository"
+	"golang.org/x/sync/errgroup"
+)
+
+// chunkSize e quantos usuarios vao em cada chamada ao repository. O repository ainda
+// grava os usuarios de cada chamada em transacoes paralelas.
+const chunkSize = 100
+
+// Stats conta o resultado de Load ou Wipe. Written sao os usuarios gravados (Load)
+// ou excluidos (Wipe); Skipped, os que ja existiam (so no Load); Failed, os que
+// falharam por outro motivo (throttling, conflito de transacao...).
+type Stats struct {
+	Written int64
+	Skipped int64
+	Failed  int64
+}
+
+// Load grava os usuarios de indice [0, n) do gerador.
+func Load(ctx context.Context, repo repository.UserRepository, gen Generator, n, workers int) (Stats, error) {
+	var written, skipped, failed atomic.Int64
+
+	err := forEachChunk(ctx, n, workers, func(ctx context.Context, start, end int) error {
+		users := make([]model.User, 0, end-start)
+		for i := start; i < end; i++ {
+			users = append(users, gen.User(i))
+		}
+
+		results, err := repo.BatchCreate(ctx, users)
+		if err != nil {
+			return err
+		}
+		for _, res := range results {
+			switch {
+			case res.Err == nil:
+				written.Add(1)
+			case errors.Is(res.Err, repository.ErrEmailTaken):
+				skipped.Add(1)
+			default:
+				failed.Add(1)
+			}
+		}
+		return nil
+	})
+
+	return Stats{Written: written.Load(), Skipped: skipped.Load(), Failed: failed.Load()}, err
+}
+
+// Wipe exclui (soft delete) os usuarios de indice [0, n) do gerador. Como em
+// DELETE /users/{id}, os itens ficam na tabela ate o TTL remove-los, mas o email
+// volta a ficar livre — rodar o Load de novo recria o mesmo conjunto.
+func Wipe(ctx context.Context, repo repository.UserRepository, gen Generator, n, workers int) (Stats, error) {
+	var written, failed atomic.Int64
+
+	err := forEachChunk(ctx, n, workers, func(ctx context.Context, start, end int) error {
+		ids := make([]string, 0, end-start)
+		for i := start; i < end; i++ {
+			ids = append(ids, gen.ID(i))
+		}
+
+		results, err := repo.BatchDelete(ctx, ids)
+		if err != nil {
+			return err
+		}
+		for _, res := range results {
+			if res.Err != nil {
+				failed.Add(1)
+				continue
+			}
+			written.Add(1)
+		}
+		return nil
+	})
+
+	return Stats{Written: written.Load(), Failed: failed.Load()}, err
+}
+
+// forEachChunk divide [0, n) em chunks de chunkSize e chama fn para cada um, com no
+// maximo workers chamadas ao mesmo tempo. O primeiro erro cancela as demais.
+func forEachChunk(ctx context.Context, n, workers int, fn func(ctx context.Context, start, end int) error) error {
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(max(workers, 1))
+
+	for start := 0; start < n && gctx.Err() == nil; start += chunkSize {
+		end := min(start+chunkSize, n)
+		g.Go(func() error {
+			return fn(gctx, start, end)
+		})
+	}
+
+	if err := g.Wait(); err != nil {
+		return err
+	}
+	// Interrompido (Ctrl+C) entre dois chunks: nenhum fn falhou, mas nem tudo foi gravado.
+	return ctx.Err()
+}
@@ -0,0 +1,152 @@
+package seed
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/dowglassantana/golang-with-dynamodb/internal/model"
+	"github.com/dowglassantana/golang-with-dynamodb/internal/repository"
+)
+
+func testGenerator(seed uint64) Generator {
+	return Generator{
+		Seed: seed,
+		From: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		To:   time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+// TestGeneratorGolden fixa os usuarios gerados: se o algoritmo mudar, os ids de um
+// seed antigo deixam de bater e o wipe nao encontra mais os usuarios.
+func TestGeneratorGolden(t *testing.T) {
+	gen := testGenerator(1)
+	want := []model.User{
+		{ID: "374b5bc0-9904-59a4-99ba-3a791fa3b64f", Name: "Camila Ribeiro", Email: "camila.ribeiro.0@seed1.example.com", CreatedAt: "2024-09-18T19:41:27Z", Version: 1},
+		{ID: "121f6d82-07ac-5cbf-855e-8fc3d4c43855", Name: "Carlos Nascimento", Email: "carlos.nascimento.42@seed1.example.com", CreatedAt: "2024-02-02T05:24:24Z", Version: 1},
+	}
+	for i, idx := range []int{0, 42} {
+		if got := gen.User(idx); got != want[i] {
+			t.Errorf("User(%d) = %+v\nquer      %+v", idx, got, want[i])
+		}
+	}
+}
+
+func TestGeneratorDeterministic(t *testing.T) {
+	const n = 1000
+	gen := testGenerator(7)
+	email := regexp.MustCompile(`^[a-z]+\.[a-z]+\.[0-9]+@seed7\.example\.com$`)
+
+	forward := make([]model.User, n)
+	for i := range n {
+		forward[i] = gen.User(i)
+	}
+
+	ids := make(map[string]bool, n)
+	emails := make(map[string]bool, n)
+	for i := n - 1; i >= 0; i-- { // ordem inversa: cada usuario depende so do indice
+		u := gen.User(i)
+		if u != forward[i] {
+			t.Fatalf("User(%d) mudou entre chamadas: %+v e %+v", i, forward[i], u)
+		}
+		if u.ID != gen.ID(i) {
+			t.Errorf("User(%d).ID = %s, quer ID(%d) = %s", i, u.ID, i, gen.ID(i))
+		}
+		ids[u.ID], emails[u.Email] = true, true
+
+		if !email.MatchString(u.Email) {
+			t.Errorf("email %q fora do formato (sem acentos, com indice e semente)", u.Email)
+		}
+		created, err := time.Parse(time.RFC3339, u.CreatedAt)
+		if err != nil || created.Before(gen.From) || !created.Before(gen.To) || created.Nanosecond() != 0 {
+			t.Errorf("created_at %q fora de [From, To) ou com fracao de segundo", u.CreatedAt)
+		}
+	}
+	if len(ids) != n || len(emails) != n {
+		t.Errorf("%d ids e %d emails distintos, quer %d", len(ids), len(emails), n)
+	}
+
+	// Outra semente gera outro conjunto, sem colisao de id nem de email.
+	other := testGenerator(8)
+	for i := range n {
+		if u := other.User(i); ids[u.ID] || emails[u.Email] {
+			t.Fatalf("semente 8 repetiu o usuario %+v da semente 7", u)
+		}
+	}
+}
+
+func TestGeneratorEmptyRange(t *testing.T) {
+	gen := Generator{Seed: 1, From: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)}
+	if got := gen.User(3).CreatedAt; got != "2024-05-01T12:00:00Z" {
+		t.Errorf("CreatedAt = %s, quer From quando To nao e depois de From", got)
+	}
+}
+
+func TestEmailPart(t *testing.T) {
+	tests := map[string]string{
+		"Conceição": "conceicao",
+		"João":      "joao",
+		"Ana Paula": "ana.paula",
+	}
+	for in, want := range tests {
+		if got := emailPart(in); got != want {
+			t.Errorf("emailPart(%q) = %q, quer %q", in, got, want)
+		}
+	}
+}
+
+func TestLoadAndWipe(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryUserRepository()
+	gen := testGenerator(1)
+	const n = 250 // tres chunks: 100, 100 e 50
+
+	stats, err := Load(ctx, repo, gen, n, 3)
+	if err != nil || stats != (Stats{Written: n}) {
+		t.Fatalf("Load = %+v, %v; quer %d gravados", stats, err, n)
+	}
+	if u, err := repo.GetByID(ctx, gen.ID(123)); err != nil || u == nil || *u != gen.User(123) {
+		t.Errorf("GetByID = %+v, %v; quer %+v", u, err, gen.User(123))
+	}
+
+	// Rodar de novo nao duplica nada.
+	stats, err = Load(ctx, repo, gen, n, 3)
+	if err != nil || stats != (Stats{Skipped: n}) {
+		t.Errorf("segundo Load = %+v, %v; quer %d ja existentes", stats, err, n)
+	}
+
+	// Um n maior acrescenta so os novos indices.
+	stats, err = Load(ctx, repo, gen, n+10, 3)
+	if err != nil || stats != (Stats{Written: 10, Skipped: n}) {
+		t.Errorf("Load com n maior = %+v, %v; quer 10 gravados", stats, err)
+	}
+
+	stats, err = Wipe(ctx, repo, gen, n+10, 2)
+	if err != nil || stats != (Stats{Written: n + 10}) {
+		t.Fatalf("Wipe = %+v, %v; quer %d excluidos", stats, err, n+10)
+	}
+	if count, _ := repo.Count(ctx, false); count != 0 {
+		t.Errorf("%d usuarios ativos depois do Wipe, quer 0", count)
+	}
+
+	// O Wipe libera os emails: o mesmo conjunto pode ser gravado de novo.
+	stats, err = Load(ctx, repo, gen, n, 3)
+	if err != nil || stats != (Stats{Written: n}) {
+		t.Errorf("Load depois do Wipe = %+v, %v; quer %d gravados", stats, err, n)
+	}
+}
+
+func TestLoadCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	stats, err := Load(ctx, repository.NewMemoryUserRepository(), testGenerator(1), 500, 2)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Load com contexto cancelado = %v, quer context.Canceled", err)
+	}
+	if stats.Written != 0 {
+		t.Errorf("%d gravados com o contexto ja cancelado", stats.Written)
+	}
+}
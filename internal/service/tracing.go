@@ -0,0 +1,153 @@
+package service
+
+import (
+	"context"
+
+	"github.com/dowglassantana/golang-with-dynamodb/internal/model"
+	"github.com/dowglassantana/golang-with-dynamodb/internal/requestctx"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/dowglassantana/golang-with-dynamodb/internal/service"
+
+// tracedUserService decora um UserService com um span OpenTelemetry por metodo
+// ("UserService.Create"), com o tenant da requisicao no atributo tenant.id. O span
+// fica entre o span HTTP (criado pelo otelhttp) e os spans do repository, entao o
+// trace separa o tempo gasto nas regras de negocio do tempo gasto no DynamoDB.
+type tracedUserService struct {
+	next UserService
+}
+
+// NewTracedUserService devolve next com tracing.
+func NewTracedUserService(next UserService) UserService {
+	return &tracedUserService{next: next}
+}
+
+func (t *tracedUserService) Create(ctx context.Context, input model.CreateUserInput) (*model.User, error) {
+	ctx, span := startSpan(ctx, "Create")
+	user, err := t.next.Create(ctx, input)
+	endSpan(span, err)
+	return user, err
+}
+
+func (t *tracedUserService) GetByID(ctx context.Context, id string) (*model.User, error) {
+	ctx, span := startSpan(ctx, "GetByID")
+	user, err := t.next.GetByID(ctx, id)
+	endSpan(span, err)
+	return user, err
+}
+
+func (t *tracedUserService) GetByEmail(ctx context.Context, email string) (*model.User, error) {
+	ctx, span := startSpan(ctx, "GetByEmail")
+	user, err := t.next.GetByEmail(ctx, email)
+	endSpan(span, err)
+	return user, err
+}
+
+func (t *tracedUserService) GetAll(ctx context.Context, input model.ListUsersInput) (*model.UserPage, error) {
+	ctx, span := startSpan(ctx, "GetAll")
+	page, err := t.next.GetAll(ctx, input)
+	endSpan(span, err)
+	return page, err
+}
+
+func (t *tracedUserService) Update(ctx context.Context, id string, input model.UpdateUserInput) error {
+	ctx, span := startSpan(ctx, "Update")
+	err := t.next.Update(ctx, id, input)
+	endSpan(span, err)
+	return err
+}
+
+func (t *tracedUserService) Patch(ctx context.Context, id string, input model.PatchUserInput) (*model.User, error) {
+	ctx, span := startSpan(ctx, "Patch")
+	user, err := t.next.Patch(ctx, id, input)
+	endSpan(span, err)
+	return user, err
+}
+
+func (t *tracedUserService) Delete(ctx context.Context, id string) error {
+	ctx, span := startSpan(ctx, "Delete")
+	err := t.next.Delete(ctx, id)
+	endSpan(span, err)
+	return err
+}
+
+func (t *tracedUserService) Restore(ctx context.Context, id string) (*model.User, error) {
+	ctx, span := startSpan(ctx, "Restore")
+	user, err := t.next.Restore(ctx, id)
+	endSpan(span, err)
+	return user, err
+}
+
+func (t *tracedUserService) BatchCreate(ctx context.Context, inputs []model.CreateUserInput) ([]model.BatchResult, error) {
+	ctx, span := startSpan(ctx, "BatchCreate")
+	span.SetAttributes(attribute.Int("batch.size", len(inputs)))
+	results, err := t.next.BatchCreate(ctx, inputs)
+	endSpan(span, err)
+	return results, err
+}
+
+func (t *tracedUserService) BatchGet(ctx context.Context, ids []string) ([]model.BatchResult, error) {
+	ctx, span := startSpan(ctx, "BatchGet")
+	span.SetAttributes(attribute.Int("batch.size", len(ids)))
+	results, err := t.next.BatchGet(ctx, ids)
+	endSpan(span, err)
+	return results, err
+}
+
+func (t *tracedUserService) BatchDelete(ctx context.Context, ids []string) ([]model.BatchResult, error) {
+	ctx, span := startSpan(ctx, "BatchDelete")
+	span.SetAttributes(attribute.Int("batch.size", len(ids)))
+	results, err := t.next.BatchDelete(ctx, ids)
+	endSpan(span, err)
+	return results, err
+}
+
+func (t *tracedUserService) Import(ctx context.Context, inputs []model.ImportUserInput) ([]model.BatchResult, error) {
+	ctx, span := startSpan(ctx, "Import")
+	span.SetAttributes(attribute.Int("batch.size", len(inputs)))
+	results, err := t.next.Import(ctx, inputs)
+	endSpan(span, err)
+	return results, err
+}
+
+func (t *tracedUserService) History(ctx context.Context, id string, input model.ListHistoryInput) (*model.UserEventPage, error) {
+	ctx, span := startSpan(ctx, "History")
+	page, err := t.next.History(ctx, id, input)
+	endSpan(span, err)
+	return page, err
+}
+
+func (t *tracedUserService) Export(ctx context.Context, includeDeleted bool, fn func(model.User) error) error {
+	ctx, span := startSpan(ctx, "Export")
+	err := t.next.Export(ctx, includeDeleted, fn)
+	endSpan(span, err)
+	return err
+}
+
+func (t *tracedUserService) Count(ctx context.Context, includeDeleted bool) (int64, error) {
+	ctx, span := startSpan(ctx, "Count")
+	count, err := t.next.Count(ctx, includeDeleted)
+	endSpan(span, err)
+	return count, err
+}
+
+func startSpan(ctx context.Context, method string) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, "UserService."+method,
+		trace.WithAttributes(attribute.String("tenant.id", requestctx.Tenant(ctx))),
+	)
+}
+
+// endSpan marca o span com o erro, se houver, e o encerra. Erros de validacao e
+// "nao encontrado" tambem marcam o span: o status HTTP da resposta, no span pai,
+// diz se a falha foi do cliente.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
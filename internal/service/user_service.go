@@ -4,23 +4,60 @@ import (
 	"context"
 	"errors"
 	"strings"
+	"time"
 
 	"github.com/dowglassantana/golang-with-dynamodb/internal/model"
 	"github.com/dowglassantana/golang-with-dynamodb/internal/repository"
+	"github.com/google/uuid"
 )
 
 var (
-	ErrUserNotFound    = errors.New("usuario nao encontrado")
-	ErrInvalidInput    = errors.New("name e email sao obrigatorios")
+	ErrUserNotFound  = repository.ErrNotFound
+	ErrInvalidInput  = errors.New("name e email sao obrigatorios")
+	ErrInvalidCursor = repository.ErrInvalidCursor
+	ErrEmailTaken    = repository.ErrEmailTaken
+	ErrIDTaken       = repository.ErrIDTaken
+	ErrStaleVersion  = repository.ErrStaleVersion
+	ErrUnprocessed   = repository.ErrUnprocessed
+	ErrBatchTooLarge = errors.New("lote excede o tamanho maximo permitido")
+
+	// Erros de uma linha do import (veja Import).
+	ErrInvalidID        = errors.New("id invalido: use um UUID")
+	ErrInvalidCreatedAt = errors.New("created_at invalido: use uma data RFC3339")
+
+	// Categorias de falha do DynamoDB (veja repository.translateError).
+	ErrConditionFailed     = repository.ErrConditionFailed
+	ErrThrottled           = repository.ErrThrottled
+	ErrValidation          = repository.ErrValidation
+	ErrTransactionConflict = repository.ErrTransactionConflict
+)
+
+const (
+	// DefaultPageSize e o tamanho de pagina usado quando o cliente nao informa limit.
+	DefaultPageSize = 20
+	// MaxPageSize limita o tamanho de pagina para manter o custo de cada Scan previsivel.
+	MaxPageSize = 100
+	// MaxBatchSize limita quantos itens uma unica chamada em lote pode enviar.
+	MaxBatchSize = 1000
 )
 
 // UserService define o contrato de regras de negocio de usuarios.
 type UserService interface {
 	Create(ctx context.Context, input model.CreateUserInput) (*model.User, error)
 	GetByID(ctx context.Context, id string) (*model.User, error)
-	GetAll(ctx context.Context) ([]model.User, error)
+	GetByEmail(ctx context.Context, email string) (*model.User, error)
+	GetAll(ctx context.Context, input model.ListUsersInput) (*model.UserPage, error)
 	Update(ctx context.Context, id string, input model.UpdateUserInput) error
+	Patch(ctx context.Context, id string, input model.PatchUserInput) (*model.User, error)
 	Delete(ctx context.Context, id string) error
+	Restore(ctx context.Context, id string) (*model.User, error)
+	BatchCreate(ctx context.Context, inputs []model.CreateUserInput) ([]model.BatchResult, error)
+	BatchGet(ctx context.Context, ids []string) ([]model.BatchResult, error)
+	BatchDelete(ctx context.Context, ids []string) ([]model.BatchResult, error)
+	Import(ctx context.Context, inputs []model.ImportUserInput) ([]model.BatchResult, error)
+	History(ctx context.Context, id string, input model.ListHistoryInput) (*model.UserEventPage, error)
+	Export(ctx context.Context, includeDeleted bool, fn func(model.User) error) error
+	Count(ctx context.Context, includeDeleted bool) (int64, error)
 }
 
 type userServiceImpl struct {
@@ -32,8 +69,8 @@ func NewUserService(repo repository.UserRepository) UserService {
 }
 
 func (s *userServiceImpl) Create(ctx context.Context, input model.CreateUserInput) (*model.User, error) {
-	if strings.TrimSpace(input.Name) == "" || strings.TrimSpace(input.Email) == "" {
-		return nil, ErrInvalidInput
+	if err := validateUser(input.Name, input.Email); err != nil {
+		return nil, err
 	}
 
 	user := model.NewUser(input.Name, input.Email)
@@ -56,17 +93,232 @@ func (s *userServiceImpl) GetByID(ctx context.Context, id string) (*model.User,
 	return user, nil
 }
 
-func (s *userServiceImpl) GetAll(ctx context.Context) ([]model.User, error) {
-	return s.repo.GetAll(ctx)
+func (s *userServiceImpl) GetByEmail(ctx context.Context, email string) (*model.User, error) {
+	if strings.TrimSpace(email) == "" {
+		return nil, ErrUserNotFound
+	}
+	user, err := s.repo.GetByEmail(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	return user, nil
+}
+
+func (s *userServiceImpl) GetAll(ctx context.Context, input model.ListUsersInput) (*model.UserPage, error) {
+	if input.Limit <= 0 {
+		input.Limit = DefaultPageSize
+	}
+	if input.Limit > MaxPageSize {
+		input.Limit = MaxPageSize
+	}
+	// Um prefixo que normaliza para vazio ("  ") nao filtra nada.
+	if model.NormalizeName(input.NamePrefix) == "" {
+		input.NamePrefix = ""
+	}
+	return s.repo.GetAll(ctx, input)
 }
 
 func (s *userServiceImpl) Update(ctx context.Context, id string, input model.UpdateUserInput) error {
-	if strings.TrimSpace(input.Name) == "" || strings.TrimSpace(input.Email) == "" {
-		return ErrInvalidInput
+	if err := validateUser(input.Name, input.Email); err != nil {
+		return err
 	}
 	return s.repo.Update(ctx, id, input)
 }
 
+// Patch valida apenas os campos presentes no documento. name e email continuam
+// obrigatorios: enviar null (remover) ou um valor vazio para eles e invalido.
+func (s *userServiceImpl) Patch(ctx context.Context, id string, input model.PatchUserInput) (*model.User, error) {
+	if err := validatePatchField(input.Name); err != nil {
+		return nil, err
+	}
+	if input.Name.Set && blankName(input.Name.Value) {
+		return nil, ErrInvalidInput
+	}
+	if err := validatePatchField(input.Email); err != nil {
+		return nil, err
+	}
+
+	user, err := s.repo.Patch(ctx, id, input)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	return user, nil
+}
+
 func (s *userServiceImpl) Delete(ctx context.Context, id string) error {
 	return s.repo.Delete(ctx, id)
 }
+
+func (s *userServiceImpl) Restore(ctx context.Context, id string) (*model.User, error) {
+	user, err := s.repo.Restore(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	return user, nil
+}
+
+// BatchCreate valida cada item individualmente: itens invalidos recebem ErrInvalidInput
+// no resultado e os validos seguem para o repository.
+func (s *userServiceImpl) BatchCreate(ctx context.Context, inputs []model.CreateUserInput) ([]model.BatchResult, error) {
+	if len(inputs) > MaxBatchSize {
+		return nil, ErrBatchTooLarge
+	}
+
+	results := make([]model.BatchResult, len(inputs))
+	users := make([]model.User, 0, len(inputs))
+	positions := make([]int, 0, len(inputs))
+
+	for i, input := range inputs {
+		if err := validateUser(input.Name, input.Email); err != nil {
+			results[i].Err = err
+			continue
+		}
+		users = append(users, model.NewUser(input.Name, input.Email))
+		positions = append(positions, i)
+	}
+
+	return s.batchCreate(ctx, results, users, positions)
+}
+
+// Import cria usuarios como o BatchCreate, mas preserva o id e o created_at de cada
+// linha quando eles vem preenchidos. O id precisa ser um UUID (ErrInvalidID) e o
+// created_at uma data RFC3339, gravada em UTC (ErrInvalidCreatedAt).
+//
+// A gravacao e a mesma transacao condicional do Create: um id que ja pertence a um
+// usuario ativo do tenant falha com ErrIDTaken (409) em vez de sobrescreve-lo;
+// um usuario excluido com o mesmo id e substituido pelo importado.
+func (s *userServiceImpl) Import(ctx context.Context, inputs []model.ImportUserInput) ([]model.BatchResult, error) {
+	if len(inputs) > MaxBatchSize {
+		return nil, ErrBatchTooLarge
+	}
+
+	results := make([]model.BatchResult, len(inputs))
+	users := make([]model.User, 0, len(inputs))
+	positions := make([]int, 0, len(inputs))
+
+	for i, input := range inputs {
+		user, err := importedUser(input)
+		if err != nil {
+			results[i] = model.BatchResult{ID: input.ID, Err: err}
+			continue
+		}
+		users = append(users, user)
+		positions = append(positions, i)
+	}
+
+	return s.batchCreate(ctx, results, users, positions)
+}
+
+// batchCreate grava os usuarios validos e coloca cada resultado na posicao do item
+// de entrada (positions[j] e a posicao de users[j]).
+func (s *userServiceImpl) batchCreate(ctx context.Context, results []model.BatchResult, users []model.User, positions []int) ([]model.BatchResult, error) {
+	created, err := s.repo.BatchCreate(ctx, users)
+	if err != nil {
+		return nil, err
+	}
+	for j, res := range created {
+		results[positions[j]] = res
+	}
+	return results, nil
+}
+
+// importedUser monta o usuario de uma linha do import.
+func importedUser(input model.ImportUserInput) (model.User, error) {
+	if err := validateUser(input.Name, input.Email); err != nil {
+		return model.User{}, err
+	}
+
+	user := model.NewUser(input.Name, input.Email)
+	if id := strings.TrimSpace(input.ID); id != "" {
+		parsed, err := uuid.Parse(id)
+		if err != nil {
+			return model.User{}, ErrInvalidID
+		}
+		user.ID = parsed.String()
+	}
+	if createdAt := strings.TrimSpace(input.CreatedAt); createdAt != "" {
+		parsed, err := time.Parse(time.RFC3339, createdAt)
+		if err != nil {
+			return model.User{}, ErrInvalidCreatedAt
+		}
+		// Sempre em UTC: o GSI created-index compara created_at como string.
+		user.CreatedAt = parsed.UTC().Format(time.RFC3339)
+	}
+	return user, nil
+}
+
+func (s *userServiceImpl) BatchGet(ctx context.Context, ids []string) ([]model.BatchResult, error) {
+	if len(ids) > MaxBatchSize {
+		return nil, ErrBatchTooLarge
+	}
+
+	results, err := s.repo.BatchGet(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for i := range results {
+		if results[i].User == nil && results[i].Err == nil {
+			results[i].Err = ErrUserNotFound
+		}
+	}
+	return results, nil
+}
+
+func (s *userServiceImpl) BatchDelete(ctx context.Context, ids []string) ([]model.BatchResult, error) {
+	if len(ids) > MaxBatchSize {
+		return nil, ErrBatchTooLarge
+	}
+	return s.repo.BatchDelete(ctx, ids)
+}
+
+func (s *userServiceImpl) History(ctx context.Context, id string, input model.ListHistoryInput) (*model.UserEventPage, error) {
+	if input.Limit <= 0 {
+		input.Limit = DefaultPageSize
+	}
+	if input.Limit > MaxPageSize {
+		input.Limit = MaxPageSize
+	}
+	return s.repo.History(ctx, id, input)
+}
+
+// Export percorre todos os usuarios do tenant, sem paginacao para o chamador: fn e
+// chamada uma vez por usuario, enquanto o repository le a tabela aos poucos.
+func (s *userServiceImpl) Export(ctx context.Context, includeDeleted bool, fn func(model.User) error) error {
+	return s.repo.Export(ctx, includeDeleted, fn)
+}
+
+func (s *userServiceImpl) Count(ctx context.Context, includeDeleted bool) (int64, error) {
+	return s.repo.Count(ctx, includeDeleted)
+}
+
+func validateUser(name, email string) error {
+	if blankName(name) || strings.TrimSpace(email) == "" {
+		return ErrInvalidInput
+	}
+	return nil
+}
+
+// blankName indica se o nome fica vazio depois de normalizado: so espacos ou so marcas
+// combinantes soltas, como "\u0301". Ele nao seria encontrado pela busca por prefixo e
+// nao pode ser a sort key do name-index.
+func blankName(name string) bool {
+	return model.NormalizeName(name) == ""
+}
+
+// validatePatchField recusa null e texto em branco em um campo presente no patch: name
+// e email sao obrigatorios, entao nenhum dos dois pode ser removido.
+func validatePatchField(field model.Nullable[string]) error {
+	if field.Set && (field.Null || strings.TrimSpace(field.Value) == "") {
+		return ErrInvalidInput
+	}
+	return nil
+}
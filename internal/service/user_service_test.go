@@ -0,0 +1,330 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/dowglassantana/golang-with-dynamodb/internal/model"
+	"github.com/dowglassantana/golang-with-dynamodb/internal/repository"
+	"github.com/dowglassantana/golang-with-dynamodb/internal/requestctx"
+)
+
+// Os testes usam o MemoryUserRepository, que reproduz a semantica do DynamoDB.
+func newTestService() UserService {
+	return NewUserService(repository.NewMemoryUserRepository())
+}
+
+func mustCreate(t *testing.T, svc UserService, ctx context.Context, name, email string) *model.User {
+	t.Helper()
+	user, err := svc.Create(ctx, model.CreateUserInput{Name: name, Email: email})
+	if err != nil {
+		t.Fatalf("Create(%q): %v", email, err)
+	}
+	return user
+}
+
+func TestValidatePatchField(t *testing.T) {
+	tests := []struct {
+		name  string
+		field model.Nullable[string]
+		want  error
+	}{
+		{"ausente", model.Nullable[string]{}, nil},
+		{"com valor", model.Nullable[string]{Value: "Ana", Set: true}, nil},
+		{"null", model.Nullable[string]{Set: true, Null: true}, ErrInvalidInput},
+		{"vazio", model.Nullable[string]{Value: "", Set: true}, ErrInvalidInput},
+		{"so espacos", model.Nullable[string]{Value: "   ", Set: true}, ErrInvalidInput},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validatePatchField(tt.field); !errors.Is(err, tt.want) {
+				t.Errorf("validatePatchField = %v, quer %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreate(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService()
+
+	user := mustCreate(t, svc, ctx, "Ana", "ana@email.com")
+	if user.ID == "" || user.Version != 1 || user.CreatedAt == "" {
+		t.Errorf("usuario criado incompleto: %+v", user)
+	}
+
+	got, err := svc.GetByID(ctx, user.ID)
+	if err != nil || got.Email != "ana@email.com" {
+		t.Fatalf("GetByID = %+v, %v", got, err)
+	}
+
+	// O email e unico sem diferenciar maiusculas.
+	if _, err := svc.Create(ctx, model.CreateUserInput{Name: "Outra", Email: "ANA@email.com"}); !errors.Is(err, ErrEmailTaken) {
+		t.Errorf("Create com email repetido = %v, quer ErrEmailTaken", err)
+	}
+	if _, err := svc.Create(ctx, model.CreateUserInput{Name: " ", Email: "b@email.com"}); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("Create sem nome = %v, quer ErrInvalidInput", err)
+	}
+	if byEmail, err := svc.GetByEmail(ctx, "Ana@Email.com"); err != nil || byEmail.ID != user.ID {
+		t.Errorf("GetByEmail = %+v, %v", byEmail, err)
+	}
+	if _, err := svc.GetByID(ctx, "inexistente"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("GetByID inexistente = %v, quer ErrUserNotFound", err)
+	}
+}
+
+func TestUpdateExpectedVersion(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService()
+	user := mustCreate(t, svc, ctx, "Ana", "ana@email.com")
+
+	stale := int64(5)
+	err := svc.Update(ctx, user.ID, model.UpdateUserInput{Name: "Ana B", Email: "ana@email.com", ExpectedVersion: &stale})
+	if !errors.Is(err, ErrStaleVersion) {
+		t.Fatalf("Update com versao antiga = %v, quer ErrStaleVersion", err)
+	}
+
+	current := user.Version
+	if err := svc.Update(ctx, user.ID, model.UpdateUserInput{Name: "Ana B", Email: "ana.b@email.com", ExpectedVersion: &current}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	got, _ := svc.GetByID(ctx, user.ID)
+	if got.Name != "Ana B" || got.Version != 2 {
+		t.Errorf("depois do Update: %+v", got)
+	}
+
+	// O email antigo foi liberado.
+	mustCreate(t, svc, ctx, "Outra", "ana@email.com")
+
+	if err := svc.Update(ctx, "inexistente", model.UpdateUserInput{Name: "x", Email: "x@email.com"}); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("Update inexistente = %v, quer ErrUserNotFound", err)
+	}
+}
+
+func TestPatch(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService()
+	user := mustCreate(t, svc, ctx, "Ana", "ana@email.com")
+
+	patched, err := svc.Patch(ctx, user.ID, model.PatchUserInput{Name: model.Nullable[string]{Value: "Ana Paula", Set: true}})
+	if err != nil {
+		t.Fatalf("Patch: %v", err)
+	}
+	if patched.Name != "Ana Paula" || patched.Email != "ana@email.com" || patched.Version != 2 {
+		t.Errorf("Patch = %+v; so o nome deveria mudar", patched)
+	}
+
+	if _, err := svc.Patch(ctx, user.ID, model.PatchUserInput{Email: model.Nullable[string]{Set: true, Null: true}}); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("Patch com email null = %v, quer ErrInvalidInput", err)
+	}
+	if _, err := svc.Patch(ctx, "inexistente", model.PatchUserInput{Name: model.Nullable[string]{Value: "x", Set: true}}); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("Patch inexistente = %v, quer ErrUserNotFound", err)
+	}
+}
+
+func TestDeleteAndRestore(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService()
+	user := mustCreate(t, svc, ctx, "Ana", "ana@email.com")
+
+	if err := svc.Delete(ctx, user.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := svc.GetByID(ctx, user.ID); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("GetByID de excluido = %v, quer ErrUserNotFound", err)
+	}
+	// Delete e idempotente.
+	if err := svc.Delete(ctx, user.ID); err != nil {
+		t.Errorf("segundo Delete: %v", err)
+	}
+
+	restored, err := svc.Restore(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if restored.DeletedAt != "" || restored.Email != "ana@email.com" {
+		t.Errorf("Restore = %+v", restored)
+	}
+}
+
+func TestBatchCreate(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService()
+	mustCreate(t, svc, ctx, "Existente", "existente@email.com")
+
+	results, err := svc.BatchCreate(ctx, []model.CreateUserInput{
+		{Name: "Ana", Email: "ana@email.com"},
+		{Name: "", Email: "vazio@email.com"},
+		{Name: "Ana de novo", Email: "ANA@email.com"},
+		{Name: "Outro", Email: "existente@email.com"},
+		{Name: "Bia", Email: "bia@email.com"},
+	})
+	if err != nil {
+		t.Fatalf("BatchCreate: %v", err)
+	}
+
+	want := []error{nil, ErrInvalidInput, ErrEmailTaken, ErrEmailTaken, nil}
+	for i, res := range results {
+		if !errors.Is(res.Err, want[i]) {
+			t.Errorf("item %d: erro %v, quer %v", i, res.Err, want[i])
+		}
+		if want[i] == nil && (res.User == nil || res.ID == "") {
+			t.Errorf("item %d: criado sem usuario: %+v", i, res)
+		}
+	}
+
+	if _, err := svc.BatchCreate(ctx, make([]model.CreateUserInput, MaxBatchSize+1)); !errors.Is(err, ErrBatchTooLarge) {
+		t.Errorf("lote grande demais = %v, quer ErrBatchTooLarge", err)
+	}
+}
+
+func TestBatchDelete(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService()
+	a := mustCreate(t, svc, ctx, "Ana", "ana@email.com")
+	b := mustCreate(t, svc, ctx, "Bia", "bia@email.com")
+
+	results, err := svc.BatchDelete(ctx, []string{a.ID, b.ID, a.ID})
+	if err != nil {
+		t.Fatalf("BatchDelete: %v", err)
+	}
+	for i, res := range results {
+		if res.Err != nil {
+			t.Errorf("item %d: %v", i, res.Err)
+		}
+	}
+
+	got, err := svc.BatchGet(ctx, []string{a.ID, b.ID})
+	if err != nil {
+		t.Fatalf("BatchGet: %v", err)
+	}
+	for i, res := range got {
+		if !errors.Is(res.Err, ErrUserNotFound) {
+			t.Errorf("BatchGet item %d depois do delete: %+v", i, res)
+		}
+	}
+}
+
+func TestImport(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService()
+	existing := mustCreate(t, svc, ctx, "Existente", "existente@email.com")
+
+	const id = "0b9f1c7e-3d52-4a8e-9a43-2f6c1d8e5b70"
+	results, err := svc.Import(ctx, []model.ImportUserInput{
+		{ID: id, Name: "Ana", Email: "ana@email.com", CreatedAt: "2024-01-02T03:04:05-03:00"},
+		{Name: "Bia", Email: "bia@email.com"},
+		{ID: "nao-e-uuid", Name: "Caio", Email: "caio@email.com"},
+		{Name: "Dani", Email: "dani@email.com", CreatedAt: "02/01/2024"},
+		{ID: existing.ID, Name: "Edu", Email: "edu@email.com"},
+		{ID: id, Name: "Fabi", Email: "fabi@email.com"},
+	})
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+
+	want := []error{nil, nil, ErrInvalidID, ErrInvalidCreatedAt, ErrIDTaken, ErrIDTaken}
+	for i, res := range results {
+		if !errors.Is(res.Err, want[i]) {
+			t.Errorf("item %d: erro %v, quer %v", i, res.Err, want[i])
+		}
+	}
+
+	ana, err := svc.GetByID(ctx, id)
+	if err != nil {
+		t.Fatalf("GetByID do importado: %v", err)
+	}
+	if ana.CreatedAt != "2024-01-02T06:04:05Z" || ana.Version != 1 {
+		t.Errorf("importado = %+v; quer created_at em UTC e versao 1", ana)
+	}
+
+	// Um usuario excluido com o mesmo id e substituido pelo importado.
+	if err := svc.Delete(ctx, existing.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	results, err = svc.Import(ctx, []model.ImportUserInput{{ID: existing.ID, Name: "Edu", Email: "edu@email.com"}})
+	if err != nil || results[0].Err != nil {
+		t.Fatalf("Import sobre excluido = %+v, %v", results, err)
+	}
+	if got, err := svc.GetByID(ctx, existing.ID); err != nil || got.Name != "Edu" {
+		t.Errorf("GetByID = %+v, %v", got, err)
+	}
+}
+
+func TestGetAllPageSize(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService()
+	for _, email := range []string{"a@email.com", "b@email.com", "c@email.com"} {
+		mustCreate(t, svc, ctx, "Usuario", email)
+	}
+
+	page, err := svc.GetAll(ctx, model.ListUsersInput{Limit: 2})
+	if err != nil {
+		t.Fatalf("GetAll: %v", err)
+	}
+	if len(page.Users) != 2 || page.NextCursor == "" {
+		t.Fatalf("primeira pagina: %d usuarios, cursor %q", len(page.Users), page.NextCursor)
+	}
+
+	next, err := svc.GetAll(ctx, model.ListUsersInput{Limit: 2, Cursor: page.NextCursor})
+	if err != nil {
+		t.Fatalf("GetAll com cursor: %v", err)
+	}
+	if len(next.Users) != 1 || next.NextCursor != "" {
+		t.Errorf("segunda pagina: %d usuarios, cursor %q", len(next.Users), next.NextCursor)
+	}
+
+	if _, err := svc.GetAll(ctx, model.ListUsersInput{Cursor: "adulterado"}); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("GetAll com cursor invalido = %v, quer ErrInvalidCursor", err)
+	}
+}
+
+// TestBlankName cobre nomes que so ficam vazios depois de normalizados: um acento
+// combinante solto nao tem letra nenhuma e seria uma chave vazia no name-index.
+func TestBlankName(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService()
+	user := mustCreate(t, svc, ctx, "Ana", "ana@email.com")
+
+	for _, name := range []string{"\u0301", " \u0301\u0300 "} {
+		if _, err := svc.Create(ctx, model.CreateUserInput{Name: name, Email: "b@email.com"}); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("Create(%q) = %v, quer ErrInvalidInput", name, err)
+		}
+		if err := svc.Update(ctx, user.ID, model.UpdateUserInput{Name: name, Email: user.Email}); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("Update(%q) = %v, quer ErrInvalidInput", name, err)
+		}
+		patch := model.PatchUserInput{Name: model.Nullable[string]{Value: name, Set: true}}
+		if _, err := svc.Patch(ctx, user.ID, patch); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("Patch(%q) = %v, quer ErrInvalidInput", name, err)
+		}
+	}
+
+	// Acentos sobre letras continuam validos.
+	mustCreate(t, svc, ctx, "A\u0301na", "c@email.com")
+}
+
+func TestTenantIsolation(t *testing.T) {
+	svc := newTestService()
+	acme := requestctx.WithTenant(context.Background(), "acme")
+	globex := requestctx.WithTenant(context.Background(), "globex")
+
+	user := mustCreate(t, svc, acme, "Ana", "ana@email.com")
+
+	if _, err := svc.GetByID(globex, user.ID); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("GetByID em outro tenant = %v, quer ErrUserNotFound", err)
+	}
+	if _, err := svc.GetByEmail(globex, "ana@email.com"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("GetByEmail em outro tenant = %v, quer ErrUserNotFound", err)
+	}
+	page, err := svc.GetAll(globex, model.ListUsersInput{})
+	if err != nil || len(page.Users) != 0 {
+		t.Errorf("GetAll em outro tenant = %+v, %v; quer vazio", page, err)
+	}
+	if n, err := svc.Count(globex, true); err != nil || n != 0 {
+		t.Errorf("Count em outro tenant = %d, %v; quer 0", n, err)
+	}
+
+	// O mesmo email pode existir em tenants diferentes.
+	mustCreate(t, svc, globex, "Ana", "ana@email.com")
+}
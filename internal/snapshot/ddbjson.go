@@ -0,0 +1,159 @@
+package snapshot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Formato DynamoDB JSON, o mesmo dos arquivos do export da AWS para o S3.
+//
+// Cada linha do arquivo e um objeto com o item em "Item". Cada atributo vira um
+// objeto com uma unica chave, o tipo do DynamoDB, e o valor:
+//
+//	{"Item":{"id":{"S":"TENANT#default#USER#1"},"version":{"N":"3"},"email_enc":{"M":{...}}}}
+//
+// Numeros ficam em string (N, NS), para nao perder precisao, e binarios em base64
+// (B, BS). O SDK nao converte types.AttributeValue para esse formato, entao a conversao
+// fica aqui.
+
+// line e uma linha do arquivo de dados.
+type line struct {
+	Item map[string]any `json:"Item"`
+}
+
+// encodeItem converte o item para DynamoDB JSON.
+func encodeItem(item map[string]types.AttributeValue) (map[string]any, error) {
+	out := make(map[string]any, len(item))
+	for name, av := range item {
+		v, err := encodeValue(av)
+		if err != nil {
+			return nil, fmt.Errorf("atributo %s: %w", name, err)
+		}
+		out[name] = v
+	}
+	return out, nil
+}
+
+func encodeValue(av types.AttributeValue) (map[string]any, error) {
+	switch v := av.(type) {
+	case *types.AttributeValueMemberS:
+		return map[string]any{"S": v.Value}, nil
+	case *types.AttributeValueMemberN:
+		return map[string]any{"N": v.Value}, nil
+	case *types.AttributeValueMemberB:
+		return map[string]any{"B": v.Value}, nil
+	case *types.AttributeValueMemberBOOL:
+		return map[string]any{"BOOL": v.Value}, nil
+	case *types.AttributeValueMemberNULL:
+		return map[string]any{"NULL": v.Value}, nil
+	case *types.AttributeValueMemberSS:
+		return map[string]any{"SS": v.Value}, nil
+	case *types.AttributeValueMemberNS:
+		return map[string]any{"NS": v.Value}, nil
+	case *types.AttributeValueMemberBS:
+		return map[string]any{"BS": v.Value}, nil
+	case *types.AttributeValueMemberM:
+		m, err := encodeItem(v.Value)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"M": m}, nil
+	case *types.AttributeValueMemberL:
+		l := make([]any, len(v.Value))
+		for i, elem := range v.Value {
+			e, err := encodeValue(elem)
+			if err != nil {
+				return nil, err
+			}
+			l[i] = e
+		}
+		return map[string]any{"L": l}, nil
+	default:
+		return nil, fmt.Errorf("tipo de atributo desconhecido: %T", av)
+	}
+}
+
+// jsonValue e um atributo lido do arquivo. Exatamente um campo vem preenchido; os
+// ponteiros e a diferenca entre slice nil e vazio distinguem "ausente" de "vazio".
+type jsonValue struct {
+	S    *string               `json:"S"`
+	N    *string               `json:"N"`
+	B    *[]byte               `json:"B"`
+	BOOL *bool                 `json:"BOOL"`
+	NULL *bool                 `json:"NULL"`
+	SS   []string              `json:"SS"`
+	NS   []string              `json:"NS"`
+	BS   [][]byte              `json:"BS"`
+	M    map[string]*jsonValue `json:"M"`
+	L    []*jsonValue          `json:"L"`
+}
+
+// decodeLine le uma linha do arquivo de dados.
+func decodeLine(data []byte) (map[string]types.AttributeValue, error) {
+	var raw struct {
+		Item map[string]*jsonValue `json:"Item"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	if len(raw.Item) == 0 {
+		return nil, errors.New(`linha sem "Item"`)
+	}
+	return decodeItem(raw.Item)
+}
+
+func decodeItem(m map[string]*jsonValue) (map[string]types.AttributeValue, error) {
+	item := make(map[string]types.AttributeValue, len(m))
+	for name, v := range m {
+		av, err := v.attributeValue()
+		if err != nil {
+			return nil, fmt.Errorf("atributo %s: %w", name, err)
+		}
+		item[name] = av
+	}
+	return item, nil
+}
+
+func (v *jsonValue) attributeValue() (types.AttributeValue, error) {
+	switch {
+	case v == nil:
+		return nil, errors.New("valor vazio")
+	case v.S != nil:
+		return &types.AttributeValueMemberS{Value: *v.S}, nil
+	case v.N != nil:
+		return &types.AttributeValueMemberN{Value: *v.N}, nil
+	case v.B != nil:
+		return &types.AttributeValueMemberB{Value: *v.B}, nil
+	case v.BOOL != nil:
+		return &types.AttributeValueMemberBOOL{Value: *v.BOOL}, nil
+	case v.NULL != nil:
+		return &types.AttributeValueMemberNULL{Value: *v.NULL}, nil
+	case v.SS != nil:
+		return &types.AttributeValueMemberSS{Value: v.SS}, nil
+	case v.NS != nil:
+		return &types.AttributeValueMemberNS{Value: v.NS}, nil
+	case v.BS != nil:
+		return &types.AttributeValueMemberBS{Value: v.BS}, nil
+	case v.M != nil:
+		m, err := decodeItem(v.M)
+		if err != nil {
+			return nil, err
+		}
+		return &types.AttributeValueMemberM{Value: m}, nil
+	case v.L != nil:
+		l := make([]types.AttributeValue, len(v.L))
+		for i, elem := range v.L {
+			av, err := elem.attributeValue()
+			if err != nil {
+				return nil, err
+			}
+			l[i] = av
+		}
+		return &types.AttributeValueMemberL{Value: l}, nil
+	default:
+		return nil, errors.New("valor sem tipo")
+	}
+}
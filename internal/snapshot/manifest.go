@@ -0,0 +1,164 @@
+package snapshot
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// ManifestFile e o nome do manifesto dentro do diretorio do snapshot.
+const ManifestFile = "manifest.json"
+
+// formatDynamoDBJSON identifica o formato dos arquivos de dados, com o mesmo nome
+// usado pelo export da AWS.
+const formatDynamoDBJSON = "DYNAMODB_JSON"
+
+// Manifest descreve um snapshot: de onde veio, o schema da tabela e, para cada
+// arquivo de dados, quantos itens ele tem e o SHA-256 do arquivo .gz. O restore
+// confere tudo isso antes de gravar o primeiro item.
+type Manifest struct {
+	Table     string     `json:"table"`
+	CreatedAt string     `json:"created_at"`
+	Format    string     `json:"format"`
+	ItemCount int64      `json:"item_count"`
+	Schema    Schema     `json:"schema"`
+	Files     []DataFile `json:"files"`
+}
+
+// DataFile e um arquivo de dados do snapshot, com o caminho relativo ao diretorio.
+type DataFile struct {
+	Path      string `json:"path"`
+	ItemCount int64  `json:"item_count"`
+	SHA256    string `json:"sha256"`
+}
+
+// Schema e o que o restore precisa para recriar a tabela: chaves, GSIs, TTL e stream.
+type Schema struct {
+	KeySchema            []KeyElement `json:"key_schema"`
+	AttributeDefinitions []Attribute  `json:"attribute_definitions"`
+	Indexes              []Index      `json:"global_secondary_indexes,omitempty"`
+	TTLAttribute         string       `json:"ttl_attribute,omitempty"`
+	StreamViewType       string       `json:"stream_view_type,omitempty"`
+}
+
+// KeyElement e um elemento de chave (HASH ou RANGE).
+type KeyElement struct {
+	AttributeName string `json:"attribute_name"`
+	KeyType       string `json:"key_type"`
+}
+
+// Attribute e a definicao de tipo de um atributo usado em chaves (S, N ou B).
+type Attribute struct {
+	AttributeName string `json:"attribute_name"`
+	AttributeType string `json:"attribute_type"`
+}
+
+// Index e um GSI da tabela.
+type Index struct {
+	IndexName        string       `json:"index_name"`
+	KeySchema        []KeyElement `json:"key_schema"`
+	ProjectionType   string       `json:"projection_type"`
+	NonKeyAttributes []string     `json:"non_key_attributes,omitempty"`
+}
+
+// schemaOf extrai o Schema da descricao da tabela.
+func schemaOf(table *types.TableDescription, ttl *types.TimeToLiveDescription) Schema {
+	s := Schema{KeySchema: keyElements(table.KeySchema)}
+	for _, a := range table.AttributeDefinitions {
+		s.AttributeDefinitions = append(s.AttributeDefinitions, Attribute{
+			AttributeName: aws.ToString(a.AttributeName),
+			AttributeType: string(a.AttributeType),
+		})
+	}
+	for _, gsi := range table.GlobalSecondaryIndexes {
+		idx := Index{IndexName: aws.ToString(gsi.IndexName), KeySchema: keyElements(gsi.KeySchema)}
+		if gsi.Projection != nil {
+			idx.ProjectionType = string(gsi.Projection.ProjectionType)
+			idx.NonKeyAttributes = gsi.Projection.NonKeyAttributes
+		}
+		s.Indexes = append(s.Indexes, idx)
+	}
+	if ttl != nil && ttl.TimeToLiveStatus == types.TimeToLiveStatusEnabled {
+		s.TTLAttribute = aws.ToString(ttl.AttributeName)
+	}
+	if spec := table.StreamSpecification; spec != nil && aws.ToBool(spec.StreamEnabled) {
+		s.StreamViewType = string(spec.StreamViewType)
+	}
+	return s
+}
+
+func keyElements(schema []types.KeySchemaElement) []KeyElement {
+	out := make([]KeyElement, len(schema))
+	for i, k := range schema {
+		out[i] = KeyElement{AttributeName: aws.ToString(k.AttributeName), KeyType: string(k.KeyType)}
+	}
+	return out
+}
+
+func sdkKeySchema(schema []KeyElement) []types.KeySchemaElement {
+	out := make([]types.KeySchemaElement, len(schema))
+	for i, k := range schema {
+		out[i] = types.KeySchemaElement{AttributeName: aws.String(k.AttributeName), KeyType: types.KeyType(k.KeyType)}
+	}
+	return out
+}
+
+// hashKey e o nome da partition key da tabela.
+func (s Schema) hashKey() string {
+	for _, k := range s.KeySchema {
+		if k.KeyType == string(types.KeyTypeHash) {
+			return k.AttributeName
+		}
+	}
+	return ""
+}
+
+// ReadManifest le o manifesto do diretorio do snapshot.
+func ReadManifest(dir string) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, ManifestFile))
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler manifesto: %w", err)
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("manifesto invalido: %w", err)
+	}
+	if m.Format != formatDynamoDBJSON {
+		return nil, fmt.Errorf("formato de snapshot nao suportado: %q", m.Format)
+	}
+	return &m, nil
+}
+
+func writeManifest(dir string, m *Manifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("erro ao serializar manifesto: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ManifestFile), append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("erro ao gravar manifesto: %w", err)
+	}
+	return nil
+}
+
+// fileChecksum calcula o SHA-256 do arquivo, em hexadecimal.
+func fileChecksum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
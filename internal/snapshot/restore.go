@@ -0,0 +1,282 @@
+package snapshot
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	// batchWriteLimit e o maximo de itens por BatchWriteItem.
+	batchWriteLimit = 25
+
+	// Reenvio de UnprocessedItems com backoff exponencial e full jitter, como em
+	// repository/batch.go.
+	batchMaxAttempts = 8
+	batchBaseDelay   = 50 * time.Millisecond
+	batchMaxDelay    = 2 * time.Second
+
+	// maxLineSize limita uma linha do arquivo de dados. Um item do DynamoDB tem no
+	// maximo 400 KB; em JSON, com base64, ele cresce um pouco.
+	maxLineSize = 1 << 20
+
+	tableWaitTimeout = 5 * time.Minute
+)
+
+// Verify confere o snapshot sem tocar no DynamoDB: o checksum de cada arquivo, se
+// cada linha e um item valido e se as contagens batem com o manifesto.
+func Verify(dir string, m *Manifest) error {
+	var total int64
+	for _, f := range m.Files {
+		path := filepath.Join(dir, filepath.FromSlash(f.Path))
+
+		sum, err := fileChecksum(path)
+		if err != nil {
+			return fmt.Errorf("erro ao ler %s: %w", f.Path, err)
+		}
+		if sum != f.SHA256 {
+			return fmt.Errorf("%s: checksum nao confere com o manifesto", f.Path)
+		}
+
+		count, err := readItems(path, func(map[string]types.AttributeValue) error { return nil })
+		if err != nil {
+			return err
+		}
+		if count != f.ItemCount {
+			return fmt.Errorf("%s: %d itens, o manifesto diz %d", f.Path, count, f.ItemCount)
+		}
+		total += count
+	}
+	if total != m.ItemCount {
+		return fmt.Errorf("snapshot com %d itens, o manifesto diz %d", total, m.ItemCount)
+	}
+	return nil
+}
+
+// Restore grava o snapshot de dir na tabela informada, que pode ter outro nome que a
+// de origem. Se a tabela nao existe, ela e criada com o schema do manifesto (chaves,
+// GSIs, TTL e stream). Se existe, precisa ter a mesma partition key e estar vazia: o
+// restore nao mistura dados nem sobrescreve itens.
+//
+// O snapshot e verificado (Verify) antes da primeira escrita. Os arquivos sao
+// gravados em paralelo, ate workers por vez, com BatchWriteItem. Retorna quantos
+// itens foram gravados.
+//
+// Se a tabela tem stream, cada item restaurado gera um INSERT para os consumidores.
+func Restore(ctx context.Context, client *dynamodb.Client, dir, table string, workers int) (int64, error) {
+	if workers < 1 {
+		return 0, errors.New("workers deve ser pelo menos 1")
+	}
+
+	m, err := ReadManifest(dir)
+	if err != nil {
+		return 0, err
+	}
+	if err := Verify(dir, m); err != nil {
+		return 0, fmt.Errorf("snapshot invalido: %w", err)
+	}
+	if err := prepareTable(ctx, client, table, m.Schema); err != nil {
+		return 0, err
+	}
+
+	var written atomic.Int64
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(workers)
+	for _, f := range m.Files {
+		g.Go(func() error {
+			batch := make([]map[string]types.AttributeValue, 0, batchWriteLimit)
+			flush := func() error {
+				if len(batch) == 0 {
+					return nil
+				}
+				if err := batchWrite(gctx, client, table, batch); err != nil {
+					return err
+				}
+				written.Add(int64(len(batch)))
+				batch = batch[:0]
+				return nil
+			}
+
+			_, err := readItems(filepath.Join(dir, filepath.FromSlash(f.Path)), func(item map[string]types.AttributeValue) error {
+				batch = append(batch, item)
+				if len(batch) == batchWriteLimit {
+					return flush()
+				}
+				return nil
+			})
+			if err != nil {
+				return fmt.Errorf("%s: %w", f.Path, err)
+			}
+			return flush()
+		})
+	}
+	err = g.Wait()
+	return written.Load(), err
+}
+
+// prepareTable cria a tabela de destino a partir do schema ou confere que a tabela
+// existente pode receber o snapshot.
+func prepareTable(ctx context.Context, client *dynamodb.Client, table string, schema Schema) error {
+	desc, err := client.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(table)})
+	var notFound *types.ResourceNotFoundException
+	if errors.As(err, &notFound) {
+		return createTable(ctx, client, table, schema)
+	}
+	if err != nil {
+		return fmt.Errorf("erro ao descrever a tabela %s: %w", table, err)
+	}
+
+	if got := schemaOf(desc.Table, nil).hashKey(); got != schema.hashKey() {
+		return fmt.Errorf("tabela %s tem partition key %q, o snapshot usa %q", table, got, schema.hashKey())
+	}
+
+	scan, err := client.Scan(ctx, &dynamodb.ScanInput{
+		TableName: aws.String(table),
+		Limit:     aws.Int32(1),
+	})
+	if err != nil {
+		return fmt.Errorf("erro ao ler a tabela %s: %w", table, err)
+	}
+	if len(scan.Items) > 0 {
+		return fmt.Errorf("tabela %s nao esta vazia", table)
+	}
+	return nil
+}
+
+// createTable cria a tabela com o schema do snapshot, em modo sob demanda, e espera
+// ela ficar ACTIVE.
+func createTable(ctx context.Context, client *dynamodb.Client, table string, schema Schema) error {
+	input := &dynamodb.CreateTableInput{
+		TableName:   aws.String(table),
+		KeySchema:   sdkKeySchema(schema.KeySchema),
+		BillingMode: types.BillingModePayPerRequest,
+	}
+	for _, a := range schema.AttributeDefinitions {
+		input.AttributeDefinitions = append(input.AttributeDefinitions, types.AttributeDefinition{
+			AttributeName: aws.String(a.AttributeName),
+			AttributeType: types.ScalarAttributeType(a.AttributeType),
+		})
+	}
+	for _, idx := range schema.Indexes {
+		projection := &types.Projection{ProjectionType: types.ProjectionType(idx.ProjectionType)}
+		if len(idx.NonKeyAttributes) > 0 {
+			projection.NonKeyAttributes = idx.NonKeyAttributes
+		}
+		input.GlobalSecondaryIndexes = append(input.GlobalSecondaryIndexes, types.GlobalSecondaryIndex{
+			IndexName:  aws.String(idx.IndexName),
+			KeySchema:  sdkKeySchema(idx.KeySchema),
+			Projection: projection,
+		})
+	}
+	if schema.StreamViewType != "" {
+		input.StreamSpecification = &types.StreamSpecification{
+			StreamEnabled:  aws.Bool(true),
+			StreamViewType: types.StreamViewType(schema.StreamViewType),
+		}
+	}
+
+	if _, err := client.CreateTable(ctx, input); err != nil {
+		return fmt.Errorf("erro ao criar a tabela %s: %w", table, err)
+	}
+
+	waiter := dynamodb.NewTableExistsWaiter(client)
+	if err := waiter.Wait(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(table)}, tableWaitTimeout); err != nil {
+		return fmt.Errorf("erro ao aguardar a tabela %s: %w", table, err)
+	}
+
+	if schema.TTLAttribute != "" {
+		_, err := client.UpdateTimeToLive(ctx, &dynamodb.UpdateTimeToLiveInput{
+			TableName: aws.String(table),
+			TimeToLiveSpecification: &types.TimeToLiveSpecification{
+				AttributeName: aws.String(schema.TTLAttribute),
+				Enabled:       aws.Bool(true),
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("erro ao habilitar o TTL da tabela %s: %w", table, err)
+		}
+	}
+	return nil
+}
+
+// readItems descomprime o arquivo de dados e chama fn para cada item. Retorna quantos
+// itens foram lidos.
+func readItems(path string, fn func(map[string]types.AttributeValue) error) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("erro ao abrir arquivo de dados: %w", err)
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(bufio.NewReader(f))
+	if err != nil {
+		return 0, fmt.Errorf("%s: arquivo gzip invalido: %w", filepath.Base(path), err)
+	}
+	defer gz.Close()
+
+	scanner := bufio.NewScanner(gz)
+	scanner.Buffer(make([]byte, 0, 64<<10), maxLineSize)
+
+	var count int64
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		item, err := decodeLine(scanner.Bytes())
+		if err != nil {
+			return count, fmt.Errorf("%s: linha %d invalida: %w", filepath.Base(path), count+1, err)
+		}
+		if err := fn(item); err != nil {
+			return count, err
+		}
+		count++
+	}
+	if err := scanner.Err(); err != nil {
+		return count, fmt.Errorf("erro ao ler %s: %w", filepath.Base(path), err)
+	}
+	return count, nil
+}
+
+// batchWrite grava ate batchWriteLimit itens, reenviando os UnprocessedItems.
+func batchWrite(ctx context.Context, client *dynamodb.Client, table string, items []map[string]types.AttributeValue) error {
+	requests := make([]types.WriteRequest, len(items))
+	for i, item := range items {
+		requests[i] = types.WriteRequest{PutRequest: &types.PutRequest{Item: item}}
+	}
+
+	for attempt := 0; ; attempt++ {
+		output, err := client.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{
+			RequestItems: map[string][]types.WriteRequest{table: requests},
+		})
+		if err != nil {
+			return fmt.Errorf("erro ao gravar lote: %w", err)
+		}
+
+		requests = output.UnprocessedItems[table]
+		if len(requests) == 0 {
+			return nil
+		}
+		if attempt+1 >= batchMaxAttempts {
+			return fmt.Errorf("%d itens nao processados apos %d tentativas", len(requests), batchMaxAttempts)
+		}
+
+		delay := min(batchBaseDelay<<attempt, batchMaxDelay)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(rand.N(delay)):
+		}
+	}
+}
@@ -0,0 +1,282 @@
+package snapshot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/dowglassantana/golang-with-dynamodb/internal/dynamotest"
+)
+
+func TestDynamoDBJSONRoundTrip(t *testing.T) {
+	item := map[string]types.AttributeValue{
+		"id":      &types.AttributeValueMemberS{Value: "TENANT#default#USER#1"},
+		"version": &types.AttributeValueMemberN{Value: "12345678901234567890"},
+		"hash":    &types.AttributeValueMemberB{Value: []byte{0, 1, 0xff}},
+		"vazio":   &types.AttributeValueMemberB{Value: []byte{}},
+		"ativo":   &types.AttributeValueMemberBOOL{Value: false},
+		"nulo":    &types.AttributeValueMemberNULL{Value: true},
+		"tags":    &types.AttributeValueMemberSS{Value: []string{"a", "b"}},
+		"notas":   &types.AttributeValueMemberNS{Value: []string{"1", "2.5"}},
+		"chaves":  &types.AttributeValueMemberBS{Value: [][]byte{{1}, {2}}},
+		"email_enc": &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{
+			"key_id": &types.AttributeValueMemberS{Value: "k1"},
+			"vazio":  &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{}},
+		}},
+		"applied": &types.AttributeValueMemberL{Value: []types.AttributeValue{
+			&types.AttributeValueMemberS{Value: "0001"},
+			&types.AttributeValueMemberL{Value: []types.AttributeValue{}},
+		}},
+	}
+
+	encoded, err := encodeItem(item)
+	if err != nil {
+		t.Fatalf("encodeItem: %v", err)
+	}
+	data, err := json.Marshal(line{Item: encoded})
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if !strings.Contains(string(data), `"version":{"N":"12345678901234567890"}`) {
+		t.Errorf("numero deveria ficar em string: %s", data)
+	}
+
+	got, err := decodeLine(data)
+	if err != nil {
+		t.Fatalf("decodeLine: %v", err)
+	}
+	if !reflect.DeepEqual(got, item) {
+		t.Errorf("decodeLine(encodeItem(item)) = %#v\nquer %#v", got, item)
+	}
+}
+
+func TestDecodeLineErrors(t *testing.T) {
+	for _, data := range []string{
+		`{nao e json`,
+		`{}`,
+		`{"Item":{}}`,
+		`{"Item":{"id":{}}}`,
+		`{"Item":{"id":null}}`,
+		`{"Item":{"m":{"M":{"x":{"Z":"1"}}}}}`,
+		`{"Item":{"l":{"L":[{}]}}}`,
+	} {
+		if _, err := decodeLine([]byte(data)); err == nil {
+			t.Errorf("decodeLine(%s) deveria falhar", data)
+		}
+	}
+}
+
+// newSourceTable cria a tabela de origem com um GSI, stream, TTL e n itens.
+func newSourceTable(t *testing.T, n int) (*dynamotest.Server, *dynamotest.Table) {
+	t.Helper()
+	srv := dynamotest.NewServer(t)
+	table := dynamotest.NewTable(srv, "Users")
+	client := srv.Client()
+	ctx := context.Background()
+
+	_, err := client.CreateTable(ctx, &dynamodb.CreateTableInput{
+		TableName:            aws.String("Users"),
+		KeySchema:            []types.KeySchemaElement{{AttributeName: aws.String("id"), KeyType: types.KeyTypeHash}},
+		AttributeDefinitions: []types.AttributeDefinition{{AttributeName: aws.String("id"), AttributeType: types.ScalarAttributeTypeS}},
+		BillingMode:          types.BillingModePayPerRequest,
+		GlobalSecondaryIndexes: []types.GlobalSecondaryIndex{{
+			IndexName:  aws.String("email-index"),
+			KeySchema:  []types.KeySchemaElement{{AttributeName: aws.String("email_hash"), KeyType: types.KeyTypeHash}},
+			Projection: &types.Projection{ProjectionType: types.ProjectionTypeAll},
+		}},
+		StreamSpecification: &types.StreamSpecification{StreamEnabled: aws.Bool(true), StreamViewType: types.StreamViewTypeNewAndOldImages},
+	})
+	if err != nil {
+		t.Fatalf("CreateTable: %v", err)
+	}
+	_, err = client.UpdateTimeToLive(ctx, &dynamodb.UpdateTimeToLiveInput{
+		TableName:               aws.String("Users"),
+		TimeToLiveSpecification: &types.TimeToLiveSpecification{AttributeName: aws.String("expires_at"), Enabled: aws.Bool(true)},
+	})
+	if err != nil {
+		t.Fatalf("UpdateTimeToLive: %v", err)
+	}
+
+	for i := range n {
+		table.Seed(map[string]any{
+			"id":        map[string]any{"S": fmt.Sprintf("TENANT#default#USER#%03d", i)},
+			"name":      map[string]any{"S": fmt.Sprintf("Usuario %d", i)},
+			"version":   map[string]any{"N": "1"},
+			"email_enc": map[string]any{"M": map[string]any{"ciphertext": map[string]any{"B": "AAEC"}}},
+		})
+	}
+	return srv, table
+}
+
+func TestCreateAndRestore(t *testing.T) {
+	ctx := context.Background()
+	src, srcTable := newSourceTable(t, 60)
+	dir := filepath.Join(t.TempDir(), "snap")
+
+	m, err := Create(ctx, src.Client(), "Users", dir, 2)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if m.ItemCount != 60 || len(m.Files) != 2 || m.Files[0].ItemCount+m.Files[1].ItemCount != 60 {
+		t.Errorf("manifesto = %+v, quer 60 itens em 2 arquivos", m)
+	}
+	wantSchema := Schema{
+		KeySchema:            []KeyElement{{AttributeName: "id", KeyType: "HASH"}},
+		AttributeDefinitions: []Attribute{{AttributeName: "id", AttributeType: "S"}},
+		Indexes:              []Index{{IndexName: "email-index", KeySchema: []KeyElement{}}},
+		TTLAttribute:         "expires_at",
+		StreamViewType:       "NEW_AND_OLD_IMAGES",
+	}
+	if !reflect.DeepEqual(m.Schema, wantSchema) {
+		t.Errorf("schema = %+v\nquer     %+v", m.Schema, wantSchema)
+	}
+	for _, call := range src.Calls("Scan") {
+		if call["ConsistentRead"] != true || call["TotalSegments"] != float64(2) {
+			t.Errorf("Scan = %v, quer leitura consistente em 2 segmentos", call)
+		}
+	}
+
+	read, err := ReadManifest(dir)
+	if err != nil {
+		t.Fatalf("ReadManifest: %v", err)
+	}
+	if !reflect.DeepEqual(read, m) {
+		t.Errorf("ReadManifest = %+v, quer %+v", read, m)
+	}
+	if err := Verify(dir, read); err != nil {
+		t.Errorf("Verify: %v", err)
+	}
+	if _, err := Create(ctx, src.Client(), "Users", dir, 2); err == nil {
+		t.Error("Create sobre um snapshot existente deveria falhar")
+	}
+
+	// Restore numa tabela que nao existe: ela e criada com o schema do manifesto.
+	dst := dynamotest.NewServer(t)
+	dstTable := dynamotest.NewTable(dst, "Copia")
+	written, err := Restore(ctx, dst.Client(), dir, "Copia", 2)
+	if err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if written != 60 {
+		t.Errorf("%d itens gravados, quer 60", written)
+	}
+	if !reflect.DeepEqual(dstTable.Items(), srcTable.Items()) {
+		t.Error("itens restaurados diferentes da origem")
+	}
+	if dstTable.TTL() != "expires_at" || dstTable.StreamViewType() != "NEW_AND_OLD_IMAGES" ||
+		!reflect.DeepEqual(dstTable.Indexes(), []string{"email-index"}) {
+		t.Errorf("tabela criada com TTL %q, stream %q, indices %v", dstTable.TTL(), dstTable.StreamViewType(), dstTable.Indexes())
+	}
+	for _, call := range dst.Calls("BatchWriteItem") {
+		if n := len(call["RequestItems"].(map[string]any)["Copia"].([]any)); n > batchWriteLimit {
+			t.Errorf("BatchWriteItem com %d itens, limite %d", n, batchWriteLimit)
+		}
+	}
+
+	// A tabela agora tem dados: um segundo restore e recusado antes de gravar.
+	calls := len(dst.Calls("BatchWriteItem"))
+	if _, err := Restore(ctx, dst.Client(), dir, "Copia", 2); err == nil || !strings.Contains(err.Error(), "nao esta vazia") {
+		t.Errorf("Restore em tabela com dados = %v, quer erro", err)
+	}
+	if n := len(dst.Calls("BatchWriteItem")); n != calls {
+		t.Errorf("Restore recusado gravou %d lotes", n-calls)
+	}
+}
+
+func TestRestoreRejectsOtherPartitionKey(t *testing.T) {
+	ctx := context.Background()
+	src, _ := newSourceTable(t, 3)
+	dir := t.TempDir()
+	if _, err := Create(ctx, src.Client(), "Users", dir, 1); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	dst := dynamotest.NewServer(t)
+	dst.Handle("DescribeTable", func(req dynamotest.Request) (any, error) {
+		return map[string]any{"Table": map[string]any{
+			"TableName":   "Outra",
+			"TableStatus": "ACTIVE",
+			"KeySchema":   []any{map[string]any{"AttributeName": "pk", "KeyType": "HASH"}},
+		}}, nil
+	})
+	_, err := Restore(ctx, dst.Client(), dir, "Outra", 1)
+	if err == nil || !strings.Contains(err.Error(), "partition key") {
+		t.Errorf("Restore = %v, quer erro de partition key", err)
+	}
+}
+
+func TestVerifyDetectsTampering(t *testing.T) {
+	ctx := context.Background()
+	src, _ := newSourceTable(t, 10)
+
+	tests := []struct {
+		name    string
+		tamper  func(t *testing.T, dir string, m *Manifest)
+		wantErr string
+	}{
+		{"contagem do arquivo", func(t *testing.T, dir string, m *Manifest) {
+			m.Files[0].ItemCount++
+			m.ItemCount++
+		}, "itens, o manifesto diz"},
+		{"contagem total", func(t *testing.T, dir string, m *Manifest) {
+			m.ItemCount++
+		}, "snapshot com 10 itens"},
+		{"arquivo alterado", func(t *testing.T, dir string, m *Manifest) {
+			path := filepath.Join(dir, m.Files[0].Path)
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data[len(data)-1] ^= 0xff
+			if err := os.WriteFile(path, data, 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}, "checksum"},
+		{"arquivo ausente", func(t *testing.T, dir string, m *Manifest) {
+			if err := os.Remove(filepath.Join(dir, m.Files[1].Path)); err != nil {
+				t.Fatal(err)
+			}
+		}, "erro ao ler"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			m, err := Create(ctx, src.Client(), "Users", dir, 2)
+			if err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+			tt.tamper(t, dir, m)
+			if err := writeManifest(dir, m); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := Verify(dir, m); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Verify = %v, quer erro com %q", err, tt.wantErr)
+			}
+
+			// O restore verifica antes de tocar no DynamoDB.
+			dst := dynamotest.NewServer(t)
+			if _, err := Restore(ctx, dst.Client(), dir, "Copia", 1); err == nil || !strings.Contains(err.Error(), "snapshot invalido") {
+				t.Errorf("Restore = %v, quer snapshot invalido", err)
+			}
+		})
+	}
+}
+
+func TestReadManifestRejectsUnknownFormat(t *testing.T) {
+	dir := t.TempDir()
+	if err := writeManifest(dir, &Manifest{Table: "Users", Format: "ION"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadManifest(dir); err == nil || !strings.Contains(err.Error(), "nao suportado") {
+		t.Errorf("ReadManifest = %v, quer formato nao suportado", err)
+	}
+}
@@ -0,0 +1,138 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Checkpoint e o progresso do consumidor em um shard.
+// SequenceNumber e o ultimo registro processado; Finished indica que o shard foi
+// fechado e lido ate o fim, liberando os shards filhos.
+type Checkpoint struct {
+	SequenceNumber string
+	Finished       bool
+}
+
+// CheckpointStore guarda o progresso do consumidor por shard.
+type CheckpointStore interface {
+	Load(ctx context.Context, streamArn string) (map[string]Checkpoint, error)
+	Save(ctx context.Context, streamArn, shardID string, cp Checkpoint) error
+}
+
+// DynamoCheckpointStore guarda os checkpoints em uma tabela DynamoDB propria.
+//
+// A tabela e separada da tabela de usuarios de proposito: como o stream registra
+// toda escrita, gravar checkpoints na tabela observada geraria novos registros a
+// cada checkpoint — e o consumidor nunca ficaria ocioso.
+//
+// Chave composta: stream_arn (HASH) + shard_id (RANGE). Assim um unico Query
+// carrega o progresso de todos os shards de um stream.
+type DynamoCheckpointStore struct {
+	client    *dynamodb.Client
+	tableName string
+}
+
+type checkpointDynamo struct {
+	StreamArn      string `dynamodbav:"stream_arn"`
+	ShardID        string `dynamodbav:"shard_id"`
+	SequenceNumber string `dynamodbav:"sequence_number,omitempty"`
+	Finished       bool   `dynamodbav:"finished"`
+	UpdatedAt      string `dynamodbav:"updated_at"`
+}
+
+func NewDynamoCheckpointStore(client *dynamodb.Client, tableName string) *DynamoCheckpointStore {
+	return &DynamoCheckpointStore{client: client, tableName: tableName}
+}
+
+// CreateTable cria a tabela de checkpoints caso ela ainda nao exista.
+func (s *DynamoCheckpointStore) CreateTable(ctx context.Context) error {
+	_, err := s.client.CreateTable(ctx, &dynamodb.CreateTableInput{
+		TableName: aws.String(s.tableName),
+		KeySchema: []types.KeySchemaElement{
+			{AttributeName: aws.String("stream_arn"), KeyType: types.KeyTypeHash},
+			{AttributeName: aws.String("shard_id"), KeyType: types.KeyTypeRange},
+		},
+		AttributeDefinitions: []types.AttributeDefinition{
+			{AttributeName: aws.String("stream_arn"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("shard_id"), AttributeType: types.ScalarAttributeTypeS},
+		},
+		BillingMode: types.BillingModePayPerRequest,
+	})
+	if err != nil {
+		var resourceInUse *types.ResourceInUseException
+		if !errors.As(err, &resourceInUse) {
+			return fmt.Errorf("erro ao criar tabela de checkpoints: %w", err)
+		}
+	}
+
+	waiter := dynamodb.NewTableExistsWaiter(s.client)
+	err = waiter.Wait(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(s.tableName)}, 2*time.Minute)
+	if err != nil {
+		return fmt.Errorf("erro ao aguardar tabela de checkpoints: %w", err)
+	}
+	return nil
+}
+
+func (s *DynamoCheckpointStore) Load(ctx context.Context, streamArn string) (map[string]Checkpoint, error) {
+	keyCond := expression.Key("stream_arn").Equal(expression.Value(streamArn))
+	expr, err := expression.NewBuilder().WithKeyCondition(keyCond).Build()
+	if err != nil {
+		return nil, fmt.Errorf("erro ao construir expressao: %w", err)
+	}
+
+	paginator := dynamodb.NewQueryPaginator(s.client, &dynamodb.QueryInput{
+		TableName:                 aws.String(s.tableName),
+		KeyConditionExpression:    expr.KeyCondition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		ConsistentRead:            aws.Bool(true),
+	})
+
+	checkpoints := make(map[string]Checkpoint)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao carregar checkpoints: %w", err)
+		}
+
+		var items []checkpointDynamo
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &items); err != nil {
+			return nil, fmt.Errorf("erro ao desserializar checkpoints: %w", err)
+		}
+		for _, it := range items {
+			checkpoints[it.ShardID] = Checkpoint{SequenceNumber: it.SequenceNumber, Finished: it.Finished}
+		}
+	}
+
+	return checkpoints, nil
+}
+
+func (s *DynamoCheckpointStore) Save(ctx context.Context, streamArn, shardID string, cp Checkpoint) error {
+	item, err := attributevalue.MarshalMap(checkpointDynamo{
+		StreamArn:      streamArn,
+		ShardID:        shardID,
+		SequenceNumber: cp.SequenceNumber,
+		Finished:       cp.Finished,
+		UpdatedAt:      time.Now().UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		return fmt.Errorf("erro ao serializar checkpoint: %w", err)
+	}
+
+	_, err = s.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(s.tableName),
+		Item:      item,
+	})
+	if err != nil {
+		return fmt.Errorf("erro ao gravar checkpoint: %w", err)
+	}
+	return nil
+}
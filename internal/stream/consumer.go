@@ -0,0 +1,441 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodbstreams"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodbstreams/types"
+	"github.com/dowglassantana/golang-with-dynamodb/internal/fieldcrypt"
+	"github.com/dowglassantana/golang-with-dynamodb/internal/logging"
+	"github.com/dowglassantana/golang-with-dynamodb/internal/repository"
+)
+
+// Um DynamoDB Stream e dividido em shards. Cada shard guarda uma sequencia ordenada
+// de registros e, depois de algumas horas (ou quando a tabela reparticiona), e
+// fechado e da origem a shards filhos. A ordem das alteracoes de um item so e
+// garantida se o shard pai for lido ate o fim antes dos filhos.
+//
+// O Consumer faz polling dos shards:
+//  1. DescribeTable descobre o ARN do stream atual (LatestStreamArn).
+//  2. DescribeStream lista os shards; um filho so e lido quando o pai terminou
+//     (ou ja expirou do stream, apos 24 horas).
+//  3. GetShardIterator posiciona a leitura logo depois do ultimo checkpoint
+//     (AFTER_SEQUENCE_NUMBER) ou no inicio do shard (TRIM_HORIZON).
+//  4. GetRecords le os registros, que sao convertidos em eventos e despachados.
+//  5. Depois dos handlers, o ultimo SequenceNumber processado vira checkpoint.
+//
+// O Consumer foi feito para rodar como uma unica instancia: nao ha lease nem
+// coordenacao entre processos. Duas instancias com o mesmo CheckpointStore
+// entregariam cada evento duas vezes.
+
+const (
+	defaultPollInterval = time.Second
+	defaultBatchSize    = 100
+	// defaultMaxAttempts e quantas vezes um registro e tentado antes de ser descartado.
+	defaultMaxAttempts = 5
+)
+
+// DeadLetterFunc recebe o registro descartado depois de esgotadas as tentativas, com
+// o ultimo erro. Serve para guardar o registro (fila, tabela, S3) e reprocessa-lo a mao.
+type DeadLetterFunc func(ctx context.Context, shardID string, record types.Record, err error)
+
+// Consumer le o stream da tabela de usuarios e despacha os eventos para o Dispatcher.
+// As imagens do stream trazem o email cifrado; o sealer o decifra para os eventos.
+type Consumer struct {
+	dynamo       *dynamodb.Client
+	streams      *dynamodbstreams.Client
+	tableName    string
+	sealer       *fieldcrypt.Sealer
+	checkpoints  CheckpointStore
+	dispatcher   *Dispatcher
+	pollInterval time.Duration
+	batchSize    int32
+	maxAttempts  int
+	deadLetter   DeadLetterFunc
+}
+
+// ConsumerOption configura um Consumer.
+type ConsumerOption func(*Consumer)
+
+// WithPollInterval define a pausa entre dois ciclos de leitura dos shards.
+func WithPollInterval(d time.Duration) ConsumerOption {
+	return func(c *Consumer) {
+		if d > 0 {
+			c.pollInterval = d
+		}
+	}
+}
+
+// WithBatchSize define o maximo de registros por chamada de GetRecords (ate 1000).
+func WithBatchSize(n int32) ConsumerOption {
+	return func(c *Consumer) {
+		if n > 0 && n <= 1000 {
+			c.batchSize = n
+		}
+	}
+}
+
+// WithMaxAttempts define quantas vezes um registro que falha (na decodificacao ou em um
+// handler) e tentado antes de ser descartado. O padrao e 5.
+func WithMaxAttempts(n int) ConsumerOption {
+	return func(c *Consumer) {
+		if n > 0 {
+			c.maxAttempts = n
+		}
+	}
+}
+
+// WithDeadLetter registra quem recebe os registros descartados. Sem ele, o registro
+// descartado so aparece no log, com nivel ERROR.
+func WithDeadLetter(fn DeadLetterFunc) ConsumerOption {
+	return func(c *Consumer) {
+		c.deadLetter = fn
+	}
+}
+
+func NewConsumer(dynamoClient *dynamodb.Client, streamsClient *dynamodbstreams.Client, tableName string, sealer *fieldcrypt.Sealer, checkpoints CheckpointStore, dispatcher *Dispatcher, opts ...ConsumerOption) *Consumer {
+	c := &Consumer{
+		dynamo:       dynamoClient,
+		streams:      streamsClient,
+		tableName:    tableName,
+		sealer:       sealer,
+		checkpoints:  checkpoints,
+		dispatcher:   dispatcher,
+		pollInterval: defaultPollInterval,
+		batchSize:    defaultBatchSize,
+		maxAttempts:  defaultMaxAttempts,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// shardState e o estado em memoria de um shard durante a execucao. failedSeq e
+// failures contam as tentativas do registro que esta falhando; o contador fica so em
+// memoria, entao reiniciar o processo da ao registro novas tentativas.
+type shardState struct {
+	checkpoint Checkpoint
+	iterator   string
+	failedSeq  string
+	failures   int
+}
+
+// Run le o stream ate o contexto ser cancelado. Cancelamento nao e erro: Run
+// retorna nil. Erros de um shard (inclusive de handlers) sao logados e o shard e
+// relido a partir do ultimo checkpoint no proximo ciclo — ate o registro que falha
+// esgotar as tentativas e ser descartado (veja readShard).
+func (c *Consumer) Run(ctx context.Context) error {
+	streamArn, err := c.latestStreamArn(ctx)
+	if err != nil {
+		return err
+	}
+
+	checkpoints, err := c.checkpoints.Load(ctx, streamArn)
+	if err != nil {
+		return err
+	}
+
+	states := make(map[string]*shardState, len(checkpoints))
+	for id, cp := range checkpoints {
+		states[id] = &shardState{checkpoint: cp}
+	}
+
+	logger := logging.FromContext(ctx)
+	logger.InfoContext(ctx, "consumindo stream", "stream_arn", streamArn)
+
+	for {
+		if err := c.poll(ctx, streamArn, states); err != nil && ctx.Err() == nil {
+			logger.ErrorContext(ctx, "erro ao ler o stream", "stream_arn", streamArn, "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(c.pollInterval):
+		}
+	}
+}
+
+func (c *Consumer) latestStreamArn(ctx context.Context) (string, error) {
+	desc, err := c.dynamo.DescribeTable(ctx, &dynamodb.DescribeTableInput{
+		TableName: aws.String(c.tableName),
+	})
+	if err != nil {
+		return "", fmt.Errorf("erro ao descrever tabela: %w", err)
+	}
+	if desc.Table.LatestStreamArn == nil {
+		return "", fmt.Errorf("tabela %s nao tem stream habilitado", c.tableName)
+	}
+	return aws.ToString(desc.Table.LatestStreamArn), nil
+}
+
+// poll faz um ciclo: lista os shards e le todos os que estao liberados.
+func (c *Consumer) poll(ctx context.Context, streamArn string, states map[string]*shardState) error {
+	shards, err := c.listShards(ctx, streamArn)
+	if err != nil {
+		return err
+	}
+
+	listed := make(map[string]bool, len(shards))
+	for _, s := range shards {
+		listed[aws.ToString(s.ShardId)] = true
+	}
+
+	for _, shard := range shards {
+		id := aws.ToString(shard.ShardId)
+		state, ok := states[id]
+		if !ok {
+			state = &shardState{}
+			states[id] = state
+		}
+		if state.checkpoint.Finished {
+			continue
+		}
+
+		// Um pai que nao aparece mais na listagem ja expirou do stream; um pai listado
+		// precisa terminar antes de o filho ser lido.
+		if parent := aws.ToString(shard.ParentShardId); parent != "" && listed[parent] {
+			if p, ok := states[parent]; !ok || !p.checkpoint.Finished {
+				continue
+			}
+		}
+
+		if err := c.readShard(ctx, streamArn, id, state); err != nil {
+			logging.FromContext(ctx).ErrorContext(ctx, "erro ao ler o shard", "shard", id, "error", err)
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// listShards percorre todas as paginas de DescribeStream. Os shards vem do mais
+// antigo para o mais novo, entao um pai aparece antes dos seus filhos.
+func (c *Consumer) listShards(ctx context.Context, streamArn string) ([]types.Shard, error) {
+	var shards []types.Shard
+	var start *string
+	for {
+		out, err := c.streams.DescribeStream(ctx, &dynamodbstreams.DescribeStreamInput{
+			StreamArn:             aws.String(streamArn),
+			ExclusiveStartShardId: start,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("erro ao descrever stream: %w", err)
+		}
+
+		shards = append(shards, out.StreamDescription.Shards...)
+		start = out.StreamDescription.LastEvaluatedShardId
+		if start == nil {
+			return shards, nil
+		}
+	}
+}
+
+// readShard le o shard ate alcancar o fim dos registros disponiveis.
+//
+// O checkpoint so avanca depois que os handlers concluem. Se um registro falha,
+// gravamos o checkpoint do ultimo registro entregue com sucesso e descartamos o
+// iterador — no proximo ciclo a leitura recomeca exatamente no registro que falhou.
+//
+// Um registro que falha maxAttempts vezes seguidas e descartado: ele vai para o log
+// (ERROR) e para o DeadLetterFunc, e o checkpoint passa por cima dele. Sem esse limite,
+// um unico registro que nunca decodifica (ou que um handler sempre recusa) pararia o
+// shard inteiro ate sair da retencao de 24 horas. O custo e que uma falha longa de um
+// handler (ex: o destino fora do ar) tambem descarta registros; o dead letter permite
+// reprocessa-los depois.
+func (c *Consumer) readShard(ctx context.Context, streamArn, shardID string, state *shardState) error {
+	for {
+		if state.iterator == "" {
+			iterator, err := c.shardIterator(ctx, streamArn, shardID, state.checkpoint)
+			if err != nil {
+				return err
+			}
+			state.iterator = iterator
+		}
+
+		out, err := c.streams.GetRecords(ctx, &dynamodbstreams.GetRecordsInput{
+			ShardIterator: aws.String(state.iterator),
+			Limit:         aws.Int32(c.batchSize),
+		})
+		if err != nil {
+			// Iteradores expiram 15 minutos apos emitidos; pedimos um novo no proximo ciclo.
+			var expired *types.ExpiredIteratorException
+			if errors.As(err, &expired) {
+				state.iterator = ""
+				return nil
+			}
+			return fmt.Errorf("erro ao ler registros: %w", err)
+		}
+
+		var last string
+		for _, record := range out.Records {
+			event, err := c.toEvent(ctx, record)
+			if err == nil && event != nil {
+				err = c.dispatcher.dispatch(ctx, event)
+			}
+			if err != nil && c.giveUp(ctx, shardID, state, record, err) {
+				err = nil
+			}
+			if err != nil {
+				state.iterator = ""
+				if last != "" {
+					state.checkpoint.SequenceNumber = last
+					if saveErr := c.checkpoints.Save(ctx, streamArn, shardID, state.checkpoint); saveErr != nil {
+						return saveErr
+					}
+				}
+				return fmt.Errorf("erro ao processar registro %s: %w", aws.ToString(record.Dynamodb.SequenceNumber), err)
+			}
+			last = aws.ToString(record.Dynamodb.SequenceNumber)
+		}
+
+		// NextShardIterator nulo indica que o shard foi fechado e lido ate o fim.
+		finished := out.NextShardIterator == nil
+		if last != "" || finished {
+			if last != "" {
+				state.checkpoint.SequenceNumber = last
+			}
+			state.checkpoint.Finished = finished
+			if err := c.checkpoints.Save(ctx, streamArn, shardID, state.checkpoint); err != nil {
+				return err
+			}
+		}
+
+		if finished {
+			state.iterator = ""
+			return nil
+		}
+		state.iterator = aws.ToString(out.NextShardIterator)
+
+		if len(out.Records) == 0 {
+			return nil
+		}
+	}
+}
+
+// giveUp conta mais uma falha do registro e diz se ele deve ser descartado. Quando
+// sim, registra o descarte e entrega o registro ao dead letter.
+func (c *Consumer) giveUp(ctx context.Context, shardID string, state *shardState, record types.Record, err error) bool {
+	seq := aws.ToString(record.Dynamodb.SequenceNumber)
+	if state.failedSeq != seq {
+		state.failedSeq = seq
+		state.failures = 0
+	}
+	state.failures++
+	if state.failures < c.maxAttempts {
+		return false
+	}
+
+	logging.FromContext(ctx).ErrorContext(ctx, "registro do stream descartado",
+		"shard", shardID,
+		"sequence_number", seq,
+		"event_name", string(record.EventName),
+		"attempts", state.failures,
+		"error", err,
+	)
+	if c.deadLetter != nil {
+		c.deadLetter(ctx, shardID, record, err)
+	}
+	state.failedSeq = ""
+	state.failures = 0
+	return true
+}
+
+// shardIterator posiciona a leitura logo depois do checkpoint, ou no registro mais
+// antigo ainda disponivel quando o shard nunca foi lido.
+//
+// Se o checkpoint ja saiu da janela de 24 horas do stream, AFTER_SEQUENCE_NUMBER
+// falha com TrimmedDataAccessException. Os registros perdidos nao podem ser
+// recuperados; logamos e seguimos a partir do mais antigo disponivel.
+func (c *Consumer) shardIterator(ctx context.Context, streamArn, shardID string, cp Checkpoint) (string, error) {
+	input := &dynamodbstreams.GetShardIteratorInput{
+		StreamArn:         aws.String(streamArn),
+		ShardId:           aws.String(shardID),
+		ShardIteratorType: types.ShardIteratorTypeTrimHorizon,
+	}
+	if cp.SequenceNumber != "" {
+		input.ShardIteratorType = types.ShardIteratorTypeAfterSequenceNumber
+		input.SequenceNumber = aws.String(cp.SequenceNumber)
+	}
+
+	out, err := c.streams.GetShardIterator(ctx, input)
+	var trimmed *types.TrimmedDataAccessException
+	if errors.As(err, &trimmed) {
+		logging.FromContext(ctx).WarnContext(ctx, "checkpoint fora da retencao, lendo o shard do inicio",
+			"shard", shardID,
+			"sequence_number", cp.SequenceNumber,
+		)
+		input.ShardIteratorType = types.ShardIteratorTypeTrimHorizon
+		input.SequenceNumber = nil
+		out, err = c.streams.GetShardIterator(ctx, input)
+	}
+	if err != nil {
+		return "", fmt.Errorf("erro ao obter iterador do shard: %w", err)
+	}
+	return aws.ToString(out.ShardIterator), nil
+}
+
+// migratedFromAttr marca os itens regravados pela migracao 0006_tenant_keys.
+const migratedFromAttr = "migrated_from"
+
+// toEvent converte um registro do stream no evento correspondente. Registros de
+// itens auxiliares (sentinelas de email e eventos de historico) e escritas que nao
+// mudam nenhum campo do usuario (como o backfill do email-index ou a re-cifragem do
+// email com outra chave) retornam nil.
+//
+//   - INSERT                            -> UserCreated
+//   - MODIFY que preenche deleted_at    -> UserDeleted{Permanent: false}
+//   - MODIFY                            -> UserUpdated
+//   - REMOVE (expiracao pelo TTL)       -> UserDeleted{Permanent: true}
+func (c *Consumer) toEvent(ctx context.Context, record types.Record) (any, error) {
+	data := record.Dynamodb
+	if data == nil {
+		return nil, nil
+	}
+
+	at := time.Now().UTC()
+	if data.ApproximateCreationDateTime != nil {
+		at = data.ApproximateCreationDateTime.UTC()
+	}
+
+	newUser, newTenant, hasNew, err := repository.DecodeUserItem(ctx, c.sealer, toItem(data.NewImage))
+	if err != nil {
+		return nil, err
+	}
+	oldUser, oldTenant, hasOld, err := repository.DecodeUserItem(ctx, c.sealer, toItem(data.OldImage))
+	if err != nil {
+		return nil, err
+	}
+
+	switch record.EventName {
+	case types.OperationTypeInsert:
+		// A migracao 0006_tenant_keys regrava os usuarios antigos em uma chave nova;
+		// o item movido nao e um usuario novo.
+		if _, moved := data.NewImage[migratedFromAttr]; moved {
+			return nil, nil
+		}
+		if hasNew {
+			return UserCreated{Tenant: newTenant, User: newUser, At: at}, nil
+		}
+	case types.OperationTypeModify:
+		if !hasNew || !hasOld || oldUser == newUser {
+			return nil, nil
+		}
+		if oldUser.DeletedAt == "" && newUser.DeletedAt != "" {
+			return UserDeleted{Tenant: newTenant, User: newUser, At: at}, nil
+		}
+		return UserUpdated{Tenant: newTenant, Old: oldUser, New: newUser, At: at}, nil
+	case types.OperationTypeRemove:
+		if hasOld {
+			return UserDeleted{Tenant: oldTenant, User: oldUser, Permanent: true, At: at}, nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,48 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodbstreams/types"
+)
+
+func testRecord(seq string) types.Record {
+	return types.Record{
+		EventName: types.OperationTypeModify,
+		Dynamodb:  &types.StreamRecord{SequenceNumber: aws.String(seq)},
+	}
+}
+
+func TestGiveUp(t *testing.T) {
+	var dead []string
+	c := NewConsumer(nil, nil, "Users", nil, nil, NewDispatcher(),
+		WithMaxAttempts(3),
+		WithDeadLetter(func(ctx context.Context, shardID string, record types.Record, err error) {
+			dead = append(dead, shardID+"/"+aws.ToString(record.Dynamodb.SequenceNumber))
+		}),
+	)
+	ctx := context.Background()
+	state := &shardState{}
+	errHandler := errors.New("handler falhou")
+
+	// As duas primeiras falhas do registro 1 sao novas tentativas; a terceira o descarta.
+	for attempt, want := range []bool{false, false, true} {
+		if got := c.giveUp(ctx, "shard-1", state, testRecord("1"), errHandler); got != want {
+			t.Fatalf("tentativa %d: giveUp = %t, quer %t", attempt+1, got, want)
+		}
+	}
+	if len(dead) != 1 || dead[0] != "shard-1/1" {
+		t.Errorf("dead letter = %v, quer [shard-1/1]", dead)
+	}
+
+	// O registro seguinte comeca a contagem do zero.
+	if c.giveUp(ctx, "shard-1", state, testRecord("2"), errHandler) {
+		t.Error("primeira falha do registro 2 nao deveria descarta-lo")
+	}
+	if c.giveUp(ctx, "shard-1", state, testRecord("3"), errHandler) || state.failures != 1 {
+		t.Errorf("falha de outro registro deveria reiniciar a contagem (failures=%d)", state.failures)
+	}
+}
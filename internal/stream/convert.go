@@ -0,0 +1,52 @@
+package stream
+
+import (
+	dbtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	streamtypes "github.com/aws/aws-sdk-go-v2/service/dynamodbstreams/types"
+)
+
+// O SDK gera tipos AttributeValue separados para o servico dynamodbstreams, embora
+// o formato seja identico ao da tabela. Convertemos as imagens do stream para os
+// tipos do pacote dynamodb para reaproveitar o attributevalue e o model do repository.
+
+func toItem(image map[string]streamtypes.AttributeValue) map[string]dbtypes.AttributeValue {
+	if image == nil {
+		return nil
+	}
+	item := make(map[string]dbtypes.AttributeValue, len(image))
+	for k, v := range image {
+		item[k] = toAttributeValue(v)
+	}
+	return item
+}
+
+func toAttributeValue(av streamtypes.AttributeValue) dbtypes.AttributeValue {
+	switch v := av.(type) {
+	case *streamtypes.AttributeValueMemberS:
+		return &dbtypes.AttributeValueMemberS{Value: v.Value}
+	case *streamtypes.AttributeValueMemberN:
+		return &dbtypes.AttributeValueMemberN{Value: v.Value}
+	case *streamtypes.AttributeValueMemberB:
+		return &dbtypes.AttributeValueMemberB{Value: v.Value}
+	case *streamtypes.AttributeValueMemberBOOL:
+		return &dbtypes.AttributeValueMemberBOOL{Value: v.Value}
+	case *streamtypes.AttributeValueMemberNULL:
+		return &dbtypes.AttributeValueMemberNULL{Value: v.Value}
+	case *streamtypes.AttributeValueMemberSS:
+		return &dbtypes.AttributeValueMemberSS{Value: v.Value}
+	case *streamtypes.AttributeValueMemberNS:
+		return &dbtypes.AttributeValueMemberNS{Value: v.Value}
+	case *streamtypes.AttributeValueMemberBS:
+		return &dbtypes.AttributeValueMemberBS{Value: v.Value}
+	case *streamtypes.AttributeValueMemberM:
+		return &dbtypes.AttributeValueMemberM{Value: toItem(v.Value)}
+	case *streamtypes.AttributeValueMemberL:
+		list := make([]dbtypes.AttributeValue, len(v.Value))
+		for i, e := range v.Value {
+			list[i] = toAttributeValue(e)
+		}
+		return &dbtypes.AttributeValueMemberL{Value: list}
+	default:
+		return &dbtypes.AttributeValueMemberNULL{Value: true}
+	}
+}
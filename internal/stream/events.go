@@ -0,0 +1,111 @@
+package stream
+
+import (
+	"context"
+	"time"
+
+	"github.com/dowglassantana/golang-with-dynamodb/internal/logging"
+	"github.com/dowglassantana/golang-with-dynamodb/internal/model"
+	"github.com/dowglassantana/golang-with-dynamodb/internal/requestctx"
+)
+
+// UserCreated e disparado quando um usuario e inserido na tabela.
+type UserCreated struct {
+	Tenant string
+	User   model.User
+	At     time.Time
+}
+
+// UserUpdated e disparado quando os dados de um usuario mudam — inclusive quando um
+// usuario excluido e restaurado (Old.DeletedAt preenchido, New.DeletedAt vazio).
+type UserUpdated struct {
+	Tenant string
+	Old    model.User
+	New    model.User
+	At     time.Time
+}
+
+// UserDeleted e disparado no soft delete (Permanent = false) e quando o TTL remove
+// o item da tabela de vez (Permanent = true).
+type UserDeleted struct {
+	Tenant    string
+	User      model.User
+	Permanent bool
+	At        time.Time
+}
+
+// Dispatcher guarda os handlers registrados para cada tipo de evento.
+//
+// Um handler que retorna erro faz o Consumer parar de avancar naquele shard: o lote
+// e reentregue no proximo ciclo. A entrega e "pelo menos uma vez", entao os handlers
+// devem ser idempotentes.
+type Dispatcher struct {
+	created []func(context.Context, UserCreated) error
+	updated []func(context.Context, UserUpdated) error
+	deleted []func(context.Context, UserDeleted) error
+}
+
+func NewDispatcher() *Dispatcher {
+	return &Dispatcher{}
+}
+
+func (d *Dispatcher) OnUserCreated(fn func(context.Context, UserCreated) error) {
+	d.created = append(d.created, fn)
+}
+
+func (d *Dispatcher) OnUserUpdated(fn func(context.Context, UserUpdated) error) {
+	d.updated = append(d.updated, fn)
+}
+
+func (d *Dispatcher) OnUserDeleted(fn func(context.Context, UserDeleted) error) {
+	d.deleted = append(d.deleted, fn)
+}
+
+// dispatch chama os handlers com o tenant do evento no contexto, entao um handler que
+// usa o repository le e grava no tenant do usuario que mudou.
+func (d *Dispatcher) dispatch(ctx context.Context, event any) error {
+	switch e := event.(type) {
+	case UserCreated:
+		return run(requestctx.WithTenant(ctx, e.Tenant), d.created, e)
+	case UserUpdated:
+		return run(requestctx.WithTenant(ctx, e.Tenant), d.updated, e)
+	case UserDeleted:
+		return run(requestctx.WithTenant(ctx, e.Tenant), d.deleted, e)
+	}
+	return nil
+}
+
+func run[E any](ctx context.Context, handlers []func(context.Context, E) error, event E) error {
+	for _, h := range handlers {
+		if err := h(ctx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LogEvents registra handlers que apenas escrevem cada evento no log.
+// Serve de exemplo e de ponto de partida para integracoes reais.
+func LogEvents(d *Dispatcher) {
+	d.OnUserCreated(func(ctx context.Context, e UserCreated) error {
+		logging.FromContext(ctx).InfoContext(ctx, "usuario criado", "tenant", e.Tenant, "id", e.User.ID)
+		return nil
+	})
+	d.OnUserUpdated(func(ctx context.Context, e UserUpdated) error {
+		logging.FromContext(ctx).InfoContext(ctx, "usuario atualizado",
+			"tenant", e.Tenant,
+			"id", e.New.ID,
+			"old_version", e.Old.Version,
+			"new_version", e.New.Version,
+		)
+		return nil
+	})
+	d.OnUserDeleted(func(ctx context.Context, e UserDeleted) error {
+		logging.FromContext(ctx).InfoContext(ctx, "usuario excluido",
+			"tenant", e.Tenant,
+			"id", e.User.ID,
+			"permanent", e.Permanent,
+		)
+		return nil
+	})
+}
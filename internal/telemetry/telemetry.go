@@ -0,0 +1,91 @@
+// Package telemetry configura o tracing OpenTelemetry do processo.
+//
+// Um trace de uma requisicao tem, de fora para dentro:
+//
+//	GET /users/{id}                 span HTTP (otelhttp, em volta do ServeMux)
+//	  UserService.GetByID           service.NewTracedUserService
+//	    UserRepository.GetByID      repository.NewTracedUserRepository
+//	      GetItem Users             middleware do SDK (dynamo.WithTracing)
+//
+// O contexto do trace chega e sai pelo header W3C traceparent: se quem chamou a API
+// ja faz parte de um trace, os spans da API entram nele.
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.40.0"
+)
+
+// Exportadores aceitos em OTEL_TRACES_EXPORTER.
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+// Setup configura o TracerProvider e o propagador globais a partir das variaveis de
+// ambiente padrao do OpenTelemetry:
+//
+//	OTEL_TRACES_EXPORTER           none (padrao) | stdout | otlp
+//	OTEL_SERVICE_NAME              nome do servico nos traces (padrao: serviceName)
+//	OTEL_EXPORTER_OTLP_ENDPOINT    coletor OTLP/HTTP (padrao http://localhost:4318)
+//	OTEL_TRACES_SAMPLER            amostragem (padrao parentbased_always_on)
+//
+// O propagador W3C (traceparent e baggage) e configurado mesmo com "none": a API
+// continua repassando o trace de quem a chamou, so nao grava spans proprios.
+//
+// O retorno encerra o provider, enviando os spans que ainda estao no buffer; chame-o
+// no shutdown, depois de parar o servidor HTTP.
+func Setup(ctx context.Context, serviceName string) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+
+	switch name := os.Getenv("OTEL_TRACES_EXPORTER"); name {
+	case "", ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	case ExporterOTLP:
+		exporter, err = otlptracehttp.New(ctx)
+	default:
+		return nil, fmt.Errorf("OTEL_TRACES_EXPORTER invalido: %q (use none, stdout ou otlp)", name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("erro ao criar exportador de traces: %w", err)
+	}
+
+	// WithFromEnv vem depois do nome padrao, entao OTEL_SERVICE_NAME e
+	// OTEL_RESOURCE_ATTRIBUTES o sobrescrevem.
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceName(serviceName)),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao montar o resource dos traces: %w", err)
+	}
+
+	// WithBatcher envia os spans em lotes, em background: a requisicao nunca espera
+	// o exportador. O sampler vem de OTEL_TRACES_SAMPLER.
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
@@ -2,41 +2,286 @@ package dynamo
 
 import (
 	"context"
+	"fmt"
+	"net/http"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodbstreams"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go/middleware"
 )
 
-// NewClient cria um client DynamoDB para uso na AWS.
-// Nao precisa de credenciais hardcoded — o SDK automaticamente usa as credenciais
-// do ambiente: IAM Role (no ECS/EC2), variavies de ambiente, ou ~/.aws/credentials.
-func NewClient(ctx context.Context, region string) (*dynamodb.Client, error) {
-	cfg, err := config.LoadDefaultConfig(ctx,
-		config.WithRegion(region),
-	)
+// LocalEndpoint e o endereco padrao do DynamoDB Local (Docker). O mesmo endpoint
+// atende a API da tabela e a API de streams.
+const LocalEndpoint = "http://localhost:8000"
+
+// settings reune a configuracao dos clients. Os campos vazios mantem o
+// comportamento padrao do SDK.
+type settings struct {
+	region   string
+	endpoint string
+	profile  string
+
+	accessKeyID     string
+	secretAccessKey string
+	sessionToken    string
+
+	roleARN          string
+	webIdentityToken string
+	roleSessionName  string
+
+	retryMode      aws.RetryMode
+	maxAttempts    int
+	attemptTimeout time.Duration
+
+	httpClient      aws.HTTPClient
+	maxConnsPerHost int
+	idleConnTimeout time.Duration
+
+	metrics *Metrics
+	tracing bool
+}
+
+// Option configura os clients criados por New e NewStreams.
+type Option func(*settings)
+
+// WithRegion define a regiao AWS.
+func WithRegion(region string) Option {
+	return func(s *settings) { s.region = region }
+}
+
+// WithEndpoint aponta o client para outro endpoint — o DynamoDB Local, por exemplo.
+// Dentro do docker-compose, o host e o nome do servico (http://dynamodb-local:8000),
+// nao localhost.
+func WithEndpoint(endpoint string) Option {
+	return func(s *settings) { s.endpoint = endpoint }
+}
+
+// WithProfile usa um perfil do ~/.aws/config e ~/.aws/credentials.
+func WithProfile(profile string) Option {
+	return func(s *settings) { s.profile = profile }
+}
+
+// WithStaticCredentials usa chaves fixas. Em producao prefira IAM Role ou web identity.
+func WithStaticCredentials(accessKeyID, secretAccessKey, sessionToken string) Option {
+	return func(s *settings) {
+		s.accessKeyID = accessKeyID
+		s.secretAccessKey = secretAccessKey
+		s.sessionToken = sessionToken
+	}
+}
+
+// WithWebIdentity assume a role informada trocando um token OIDC (arquivo em
+// tokenFile) por credenciais temporarias via STS AssumeRoleWithWebIdentity.
+// E o mecanismo do IRSA no EKS e do OIDC em pipelines de CI.
+func WithWebIdentity(roleARN, tokenFile, sessionName string) Option {
+	return func(s *settings) {
+		s.roleARN = roleARN
+		s.webIdentityToken = tokenFile
+		s.roleSessionName = sessionName
+	}
+}
+
+// WithRetryMode escolhe a estrategia de novas tentativas: "standard" (backoff
+// exponencial com jitter) ou "adaptive" (standard + limite de taxa no cliente
+// quando o DynamoDB comeca a responder com throttling).
+func WithRetryMode(mode aws.RetryMode) Option {
+	return func(s *settings) { s.retryMode = mode }
+}
+
+// WithMaxAttempts define o total de tentativas por operacao, incluindo a primeira.
+func WithMaxAttempts(n int) Option {
+	return func(s *settings) { s.maxAttempts = n }
+}
+
+// WithAttemptTimeout limita a duracao de cada tentativa. Diferente de um timeout no
+// contexto (que vale para a operacao inteira), uma tentativa lenta e abortada e a
+// proxima ainda pode ser feita dentro do limite de MaxAttempts.
+func WithAttemptTimeout(d time.Duration) Option {
+	return func(s *settings) { s.attemptTimeout = d }
+}
+
+// WithHTTPClient substitui o client HTTP. Tem precedencia sobre WithConnectionPool.
+func WithHTTPClient(client aws.HTTPClient) Option {
+	return func(s *settings) { s.httpClient = client }
+}
+
+// WithConnectionPool ajusta o pool de conexoes do client HTTP.
+//
+// O SDK mantem no maximo 10 conexoes ociosas por host; acima disso, cada requisicao
+// concorrente abre uma nova conexao TLS. Uma API que atende muitas requisicoes em
+// paralelo se beneficia de um pool maior.
+func WithConnectionPool(maxConnsPerHost int, idleConnTimeout time.Duration) Option {
+	return func(s *settings) {
+		s.maxConnsPerHost = maxConnsPerHost
+		s.idleConnTimeout = idleConnTimeout
+	}
+}
+
+// Local configura o client para o DynamoDB Local: endpoint localhost:8000, regiao
+// fixa e credenciais fake (o DynamoDB Local aceita qualquer valor). Opcoes passadas
+// depois de Local sobrescrevem esses valores.
+func Local() Option {
+	return func(s *settings) {
+		s.region = "us-east-1"
+		s.endpoint = LocalEndpoint
+		s.accessKeyID, s.secretAccessKey, s.sessionToken = "local", "local", "local"
+	}
+}
+
+// New cria um client DynamoDB com as opcoes informadas.
+//
+// Sem opcoes de credenciais, o SDK usa a cadeia padrao do ambiente: IAM Role (no
+// ECS/EC2), variaveis de ambiente, ou ~/.aws/credentials.
+func New(ctx context.Context, opts ...Option) (*dynamodb.Client, error) {
+	cfg, s, err := loadConfig(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return dynamodb.NewFromConfig(cfg, func(o *dynamodb.Options) {
+		if s.endpoint != "" {
+			o.BaseEndpoint = aws.String(s.endpoint)
+		}
+	}), nil
+}
+
+// NewStreams cria um client do DynamoDB Streams com as mesmas opcoes de New.
+// Streams e um servico separado no SDK (outro endpoint, outros tipos), por isso
+// tem um client proprio.
+func NewStreams(ctx context.Context, opts ...Option) (*dynamodbstreams.Client, error) {
+	cfg, s, err := loadConfig(ctx, opts)
 	if err != nil {
 		return nil, err
 	}
 
-	return dynamodb.NewFromConfig(cfg), nil
+	return dynamodbstreams.NewFromConfig(cfg, func(o *dynamodbstreams.Options) {
+		if s.endpoint != "" {
+			o.BaseEndpoint = aws.String(s.endpoint)
+		}
+	}), nil
+}
+
+// NewClient cria um client DynamoDB para uso na AWS.
+func NewClient(ctx context.Context, region string) (*dynamodb.Client, error) {
+	return New(ctx, WithRegion(region))
 }
 
 // NewLocalClient cria um client DynamoDB apontando para o DynamoDB Local (Docker).
-// Usa credenciais fake porque o DynamoDB Local aceita qualquer valor.
 func NewLocalClient(ctx context.Context) (*dynamodb.Client, error) {
-	cfg, err := config.LoadDefaultConfig(ctx,
-		config.WithRegion("us-east-1"),
-		config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider("local", "local", "local")),
-	)
+	return New(ctx, Local())
+}
+
+// NewStreamsClient cria um client do DynamoDB Streams para uso na AWS.
+func NewStreamsClient(ctx context.Context, region string) (*dynamodbstreams.Client, error) {
+	return NewStreams(ctx, WithRegion(region))
+}
+
+// NewLocalStreamsClient cria um client do DynamoDB Streams apontando para o DynamoDB Local.
+func NewLocalStreamsClient(ctx context.Context) (*dynamodbstreams.Client, error) {
+	return NewStreams(ctx, Local())
+}
+
+func loadConfig(ctx context.Context, opts []Option) (aws.Config, settings, error) {
+	var s settings
+	for _, opt := range opts {
+		opt(&s)
+	}
+
+	var loadOpts []func(*config.LoadOptions) error
+	if s.region != "" {
+		loadOpts = append(loadOpts, config.WithRegion(s.region))
+	}
+	if s.profile != "" {
+		loadOpts = append(loadOpts, config.WithSharedConfigProfile(s.profile))
+	}
+	if s.accessKeyID != "" {
+		loadOpts = append(loadOpts, config.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(s.accessKeyID, s.secretAccessKey, s.sessionToken),
+		))
+	}
+	if s.retryMode != "" {
+		loadOpts = append(loadOpts, config.WithRetryMode(s.retryMode))
+	}
+	if s.maxAttempts > 0 {
+		loadOpts = append(loadOpts, config.WithRetryMaxAttempts(s.maxAttempts))
+	}
+	if client := s.buildHTTPClient(); client != nil {
+		loadOpts = append(loadOpts, config.WithHTTPClient(client))
+	}
+	var apiOpts []func(*middleware.Stack) error
+	if s.attemptTimeout > 0 {
+		apiOpts = append(apiOpts, attemptTimeout(s.attemptTimeout))
+	}
+	if s.metrics != nil {
+		apiOpts = append(apiOpts, s.metrics.middlewares)
+	}
+	if s.tracing {
+		apiOpts = append(apiOpts, tracingMiddleware)
+	}
+	if len(apiOpts) > 0 {
+		loadOpts = append(loadOpts, config.WithAPIOptions(apiOpts))
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx, loadOpts...)
 	if err != nil {
-		return nil, err
+		return aws.Config{}, s, fmt.Errorf("erro ao carregar configuracao AWS: %w", err)
 	}
 
-	client := dynamodb.NewFromConfig(cfg, func(o *dynamodb.Options) {
-		o.BaseEndpoint = aws.String("http://localhost:8000")
+	// A web identity precisa de um client STS, que por sua vez precisa da configuracao
+	// base (regiao, client HTTP) — por isso o provider e montado depois do load.
+	if s.roleARN != "" {
+		provider := stscreds.NewWebIdentityRoleProvider(
+			sts.NewFromConfig(cfg),
+			s.roleARN,
+			stscreds.IdentityTokenFile(s.webIdentityToken),
+			func(o *stscreds.WebIdentityRoleOptions) {
+				o.RoleSessionName = s.roleSessionName
+			},
+		)
+		cfg.Credentials = aws.NewCredentialsCache(provider)
+	}
+
+	return cfg, s, nil
+}
+
+func (s settings) buildHTTPClient() aws.HTTPClient {
+	if s.httpClient != nil {
+		return s.httpClient
+	}
+	if s.maxConnsPerHost <= 0 && s.idleConnTimeout <= 0 {
+		return nil
+	}
+
+	return awshttp.NewBuildableClient().WithTransportOptions(func(t *http.Transport) {
+		if s.maxConnsPerHost > 0 {
+			t.MaxIdleConns = s.maxConnsPerHost
+			t.MaxIdleConnsPerHost = s.maxConnsPerHost
+		}
+		if s.idleConnTimeout > 0 {
+			t.IdleConnTimeout = s.idleConnTimeout
+		}
 	})
+}
 
-	return client, nil
+// attemptTimeout registra um middleware logo depois do middleware de retry.
+// Tudo que fica depois dele na pilha (assinatura, envio HTTP, leitura da resposta)
+// roda uma vez por tentativa — entao o contexto com timeout criado aqui vale para
+// uma unica tentativa.
+func attemptTimeout(d time.Duration) func(*middleware.Stack) error {
+	return func(stack *middleware.Stack) error {
+		return stack.Finalize.Insert(middleware.FinalizeMiddlewareFunc("AttemptTimeout",
+			func(ctx context.Context, in middleware.FinalizeInput, next middleware.FinalizeHandler) (middleware.FinalizeOutput, middleware.Metadata, error) {
+				ctx, cancel := context.WithTimeout(ctx, d)
+				defer cancel()
+				return next.HandleFinalize(ctx, in)
+			},
+		), "Retry", middleware.After)
+	}
 }
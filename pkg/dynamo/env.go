@@ -0,0 +1,109 @@
+package dynamo
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// FromEnv monta as opcoes do client a partir das variaveis de ambiente.
+//
+// env e o valor de ENV: com "aws" o client usa a cadeia padrao de credenciais do SDK;
+// com qualquer outro valor, parte de Local() (DynamoDB Local). As variaveis abaixo
+// sobrescrevem os dois casos:
+//
+//	AWS_REGION                     regiao (padrao us-east-1)
+//	DYNAMO_ENDPOINT                endpoint (ex: http://dynamodb-local:8000 no docker-compose)
+//	DYNAMO_PROFILE                 perfil do ~/.aws/config
+//	DYNAMO_ACCESS_KEY_ID           credenciais estaticas, junto com
+//	DYNAMO_SECRET_ACCESS_KEY       ...
+//	DYNAMO_SESSION_TOKEN           (opcional)
+//	DYNAMO_ROLE_ARN                role assumida via web identity, junto com
+//	DYNAMO_WEB_IDENTITY_TOKEN_FILE arquivo com o token OIDC e
+//	DYNAMO_ROLE_SESSION_NAME       (opcional)
+//	DYNAMO_RETRY_MODE              standard | adaptive
+//	DYNAMO_MAX_ATTEMPTS            tentativas por operacao (ex: 5)
+//	DYNAMO_ATTEMPT_TIMEOUT         timeout de cada tentativa (ex: 2s)
+//	DYNAMO_MAX_CONNS               conexoes por host no pool HTTP (ex: 100)
+//	DYNAMO_IDLE_CONN_TIMEOUT       tempo de vida de conexoes ociosas (ex: 90s)
+func FromEnv(env string) ([]Option, error) {
+	var opts []Option
+	if env != "aws" {
+		opts = append(opts, Local())
+	}
+
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = "us-east-1"
+	}
+	opts = append(opts, WithRegion(region))
+
+	if v := os.Getenv("DYNAMO_ENDPOINT"); v != "" {
+		opts = append(opts, WithEndpoint(v))
+	}
+	if v := os.Getenv("DYNAMO_PROFILE"); v != "" {
+		opts = append(opts, WithProfile(v))
+	}
+
+	if key, secret := os.Getenv("DYNAMO_ACCESS_KEY_ID"), os.Getenv("DYNAMO_SECRET_ACCESS_KEY"); key != "" || secret != "" {
+		if key == "" || secret == "" {
+			return nil, fmt.Errorf("DYNAMO_ACCESS_KEY_ID e DYNAMO_SECRET_ACCESS_KEY devem ser informados juntos")
+		}
+		opts = append(opts, WithStaticCredentials(key, secret, os.Getenv("DYNAMO_SESSION_TOKEN")))
+	}
+
+	if role, token := os.Getenv("DYNAMO_ROLE_ARN"), os.Getenv("DYNAMO_WEB_IDENTITY_TOKEN_FILE"); role != "" || token != "" {
+		if role == "" || token == "" {
+			return nil, fmt.Errorf("DYNAMO_ROLE_ARN e DYNAMO_WEB_IDENTITY_TOKEN_FILE devem ser informados juntos")
+		}
+		opts = append(opts, WithWebIdentity(role, token, os.Getenv("DYNAMO_ROLE_SESSION_NAME")))
+	}
+
+	switch mode := os.Getenv("DYNAMO_RETRY_MODE"); mode {
+	case "":
+	case string(aws.RetryModeStandard), string(aws.RetryModeAdaptive):
+		opts = append(opts, WithRetryMode(aws.RetryMode(mode)))
+	default:
+		return nil, fmt.Errorf("DYNAMO_RETRY_MODE invalido: %q (use standard ou adaptive)", mode)
+	}
+
+	if v := os.Getenv("DYNAMO_MAX_ATTEMPTS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("DYNAMO_MAX_ATTEMPTS invalido: %q", v)
+		}
+		opts = append(opts, WithMaxAttempts(n))
+	}
+
+	if v := os.Getenv("DYNAMO_ATTEMPT_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("DYNAMO_ATTEMPT_TIMEOUT invalido: %q", v)
+		}
+		opts = append(opts, WithAttemptTimeout(d))
+	}
+
+	maxConns, idleTimeout := 0, time.Duration(0)
+	if v := os.Getenv("DYNAMO_MAX_CONNS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("DYNAMO_MAX_CONNS invalido: %q", v)
+		}
+		maxConns = n
+	}
+	if v := os.Getenv("DYNAMO_IDLE_CONN_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("DYNAMO_IDLE_CONN_TIMEOUT invalido: %q", v)
+		}
+		idleTimeout = d
+	}
+	if maxConns > 0 || idleTimeout > 0 {
+		opts = append(opts, WithConnectionPool(maxConns, idleTimeout))
+	}
+
+	return opts, nil
+}
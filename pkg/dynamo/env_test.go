@@ -0,0 +1,137 @@
+package dynamo
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+var envVars = []string{
+	"AWS_REGION", "DYNAMO_ENDPOINT", "DYNAMO_PROFILE",
+	"DYNAMO_ACCESS_KEY_ID", "DYNAMO_SECRET_ACCESS_KEY", "DYNAMO_SESSION_TOKEN",
+	"DYNAMO_ROLE_ARN", "DYNAMO_WEB_IDENTITY_TOKEN_FILE", "DYNAMO_ROLE_SESSION_NAME",
+	"DYNAMO_RETRY_MODE", "DYNAMO_MAX_ATTEMPTS", "DYNAMO_ATTEMPT_TIMEOUT",
+	"DYNAMO_MAX_CONNS", "DYNAMO_IDLE_CONN_TIMEOUT",
+}
+
+// settingsFromEnv limpa as variaveis do ambiente do teste, aplica env e devolve as
+// settings resultantes de FromEnv.
+func settingsFromEnv(t *testing.T, appEnv string, env map[string]string) (settings, error) {
+	t.Helper()
+	for _, k := range envVars {
+		t.Setenv(k, env[k])
+	}
+
+	opts, err := FromEnv(appEnv)
+	if err != nil {
+		return settings{}, err
+	}
+	var s settings
+	for _, opt := range opts {
+		opt(&s)
+	}
+	return s, nil
+}
+
+func TestFromEnvDefaults(t *testing.T) {
+	local, err := settingsFromEnv(t, "local", nil)
+	if err != nil {
+		t.Fatalf("FromEnv(local): %v", err)
+	}
+	if local.endpoint != LocalEndpoint || local.region != "us-east-1" || local.accessKeyID != "local" {
+		t.Errorf("local = %+v, quer DynamoDB Local", local)
+	}
+
+	aws, err := settingsFromEnv(t, "aws", nil)
+	if err != nil {
+		t.Fatalf("FromEnv(aws): %v", err)
+	}
+	if aws.endpoint != "" || aws.accessKeyID != "" || aws.region != "us-east-1" {
+		t.Errorf("aws = %+v, quer a cadeia padrao do SDK sem endpoint", aws)
+	}
+}
+
+func TestFromEnvOverrides(t *testing.T) {
+	s, err := settingsFromEnv(t, "aws", map[string]string{
+		"AWS_REGION":                     "sa-east-1",
+		"DYNAMO_ENDPOINT":                "http://dynamodb-local:8000",
+		"DYNAMO_PROFILE":                 "dev",
+		"DYNAMO_ACCESS_KEY_ID":           "AKIA",
+		"DYNAMO_SECRET_ACCESS_KEY":       "segredo",
+		"DYNAMO_SESSION_TOKEN":           "sessao",
+		"DYNAMO_ROLE_ARN":                "arn:aws:iam::123456789012:role/api",
+		"DYNAMO_WEB_IDENTITY_TOKEN_FILE": "/var/run/token",
+		"DYNAMO_ROLE_SESSION_NAME":       "api",
+		"DYNAMO_RETRY_MODE":              "adaptive",
+		"DYNAMO_MAX_ATTEMPTS":            "5",
+		"DYNAMO_ATTEMPT_TIMEOUT":         "2s",
+		"DYNAMO_MAX_CONNS":               "100",
+		"DYNAMO_IDLE_CONN_TIMEOUT":       "90s",
+	})
+	if err != nil {
+		t.Fatalf("FromEnv: %v", err)
+	}
+
+	want := settings{
+		region:           "sa-east-1",
+		endpoint:         "http://dynamodb-local:8000",
+		profile:          "dev",
+		accessKeyID:      "AKIA",
+		secretAccessKey:  "segredo",
+		sessionToken:     "sessao",
+		roleARN:          "arn:aws:iam::123456789012:role/api",
+		webIdentityToken: "/var/run/token",
+		roleSessionName:  "api",
+		retryMode:        "adaptive",
+		maxAttempts:      5,
+		attemptTimeout:   2 * time.Second,
+		maxConnsPerHost:  100,
+		idleConnTimeout:  90 * time.Second,
+	}
+	if s != want {
+		t.Errorf("settings = %+v\nquer       %+v", s, want)
+	}
+
+	// Variaveis informadas sobrescrevem o DynamoDB Local.
+	s, err = settingsFromEnv(t, "local", map[string]string{"DYNAMO_ENDPOINT": "http://dynamodb-local:8000"})
+	if err != nil || s.endpoint != "http://dynamodb-local:8000" {
+		t.Errorf("endpoint = %q, %v", s.endpoint, err)
+	}
+}
+
+func TestFromEnvErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantErr string
+	}{
+		{"so access key", map[string]string{"DYNAMO_ACCESS_KEY_ID": "AKIA"}, "DYNAMO_SECRET_ACCESS_KEY"},
+		{"so secret", map[string]string{"DYNAMO_SECRET_ACCESS_KEY": "x"}, "DYNAMO_ACCESS_KEY_ID"},
+		{"so role", map[string]string{"DYNAMO_ROLE_ARN": "arn"}, "DYNAMO_WEB_IDENTITY_TOKEN_FILE"},
+		{"so token", map[string]string{"DYNAMO_WEB_IDENTITY_TOKEN_FILE": "/t"}, "DYNAMO_ROLE_ARN"},
+		{"retry mode", map[string]string{"DYNAMO_RETRY_MODE": "legacy"}, "DYNAMO_RETRY_MODE"},
+		{"attempts texto", map[string]string{"DYNAMO_MAX_ATTEMPTS": "cinco"}, "DYNAMO_MAX_ATTEMPTS"},
+		{"attempts zero", map[string]string{"DYNAMO_MAX_ATTEMPTS": "0"}, "DYNAMO_MAX_ATTEMPTS"},
+		{"timeout sem unidade", map[string]string{"DYNAMO_ATTEMPT_TIMEOUT": "2"}, "DYNAMO_ATTEMPT_TIMEOUT"},
+		{"timeout negativo", map[string]string{"DYNAMO_ATTEMPT_TIMEOUT": "-1s"}, "DYNAMO_ATTEMPT_TIMEOUT"},
+		{"conns", map[string]string{"DYNAMO_MAX_CONNS": "-3"}, "DYNAMO_MAX_CONNS"},
+		{"idle", map[string]string{"DYNAMO_IDLE_CONN_TIMEOUT": "0s"}, "DYNAMO_IDLE_CONN_TIMEOUT"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := settingsFromEnv(t, "aws", tt.env)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("erro = %v, quer mencao a %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBuildHTTPClient(t *testing.T) {
+	if (settings{}).buildHTTPClient() != nil {
+		t.Error("sem pool configurado, o client HTTP padrao do SDK deveria ser mantido")
+	}
+	if (settings{idleConnTimeout: time.Minute}).buildHTTPClient() == nil {
+		t.Error("com DYNAMO_IDLE_CONN_TIMEOUT, deveria montar um client HTTP proprio")
+	}
+}
@@ -0,0 +1,206 @@
+package dynamo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
+	"github.com/aws/smithy-go/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Metrics sao os coletores Prometheus das chamadas ao DynamoDB, preenchidos por um
+// middleware na pilha do SDK (veja WithMetrics). Todas as series tem o label
+// operation (GetItem, Query, TransactWriteItems, GetRecords...):
+//
+//	dynamodb_operation_duration_seconds   duracao da operacao, incluindo as novas tentativas
+//	dynamodb_operation_errors_total       operacoes que falharam, por codigo de erro
+//	dynamodb_throttles_total              tentativas recusadas por throttling, mesmo as
+//	                                      que o retry do SDK resolveu depois
+//	dynamodb_consumed_capacity_total      unidades de capacidade consumidas, por tabela
+//
+// Os throttles sao contados por tentativa porque o retry os esconde: uma operacao
+// que so passou na terceira tentativa aparece como sucesso na duracao, mas deixa
+// dois throttles aqui — o primeiro sinal de que a tabela precisa de mais capacidade.
+type Metrics struct {
+	duration  *prometheus.HistogramVec
+	errors    *prometheus.CounterVec
+	throttles *prometheus.CounterVec
+	capacity  *prometheus.CounterVec
+}
+
+// NewMetrics cria os coletores e os registra em reg. Crie um Metrics por processo e
+// passe o mesmo para todos os clients (New e NewStreams) — registrar as mesmas
+// metricas duas vezes causa panic.
+func NewMetrics(reg prometheus.Registerer) *Metrics {
+	factory := promauto.With(reg)
+	return &Metrics{
+		duration: factory.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "dynamodb_operation_duration_seconds",
+			Help:    "Duracao das operacoes no DynamoDB, incluindo novas tentativas.",
+			Buckets: []float64{.002, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+		}, []string{"operation"}),
+		errors: factory.NewCounterVec(prometheus.CounterOpts{
+			Name: "dynamodb_operation_errors_total",
+			Help: "Operacoes no DynamoDB que terminaram com erro, por codigo de erro.",
+		}, []string{"operation", "code"}),
+		throttles: factory.NewCounterVec(prometheus.CounterOpts{
+			Name: "dynamodb_throttles_total",
+			Help: "Tentativas recusadas pelo DynamoDB por throttling.",
+		}, []string{"operation"}),
+		capacity: factory.NewCounterVec(prometheus.CounterOpts{
+			Name: "dynamodb_consumed_capacity_total",
+			Help: "Unidades de capacidade consumidas (ReturnConsumedCapacity=TOTAL).",
+		}, []string{"operation", "table"}),
+	}
+}
+
+// WithMetrics registra as metricas de m em todas as operacoes do client.
+//
+// O middleware tambem pede ReturnConsumedCapacity=TOTAL nas operacoes que aceitam o
+// parametro (quando quem chama nao informou outro valor). O DynamoDB entao devolve
+// ConsumedCapacity na resposta, sem custo extra — e a unica forma de saber quanto
+// cada operacao realmente consumiu.
+func WithMetrics(m *Metrics) Option {
+	return func(s *settings) { s.metrics = m }
+}
+
+// middlewares instala os dois middlewares de metricas:
+//   - no Initialize (uma vez por operacao): ReturnConsumedCapacity, duracao, erros e
+//     capacidade consumida;
+//   - no Finalize, logo depois do Retry (uma vez por tentativa): throttles.
+func (m *Metrics) middlewares(stack *middleware.Stack) error {
+	err := stack.Initialize.Add(middleware.InitializeMiddlewareFunc("Metrics",
+		func(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+			operation := awsmiddleware.GetOperationName(ctx)
+			requestConsumedCapacity(in.Parameters)
+
+			start := time.Now()
+			out, metadata, err := next.HandleInitialize(ctx, in)
+			m.duration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+
+			if err != nil {
+				m.errors.WithLabelValues(operation, errorCode(err)).Inc()
+				return out, metadata, err
+			}
+			for _, c := range consumedCapacity(out.Result) {
+				if c.CapacityUnits != nil && c.TableName != nil {
+					m.capacity.WithLabelValues(operation, *c.TableName).Add(*c.CapacityUnits)
+				}
+			}
+			return out, metadata, nil
+		},
+	), middleware.After) // depois do RegisterServiceMetadata, que define o nome da operacao
+	if err != nil {
+		return err
+	}
+
+	return stack.Finalize.Insert(middleware.FinalizeMiddlewareFunc("ThrottleMetrics",
+		func(ctx context.Context, in middleware.FinalizeInput, next middleware.FinalizeHandler) (middleware.FinalizeOutput, middleware.Metadata, error) {
+			out, metadata, err := next.HandleFinalize(ctx, in)
+			if err != nil && isThrottle(err) {
+				m.throttles.WithLabelValues(awsmiddleware.GetOperationName(ctx)).Inc()
+			}
+			return out, metadata, err
+		},
+	), "Retry", middleware.After)
+}
+
+// requestConsumedCapacity pede ReturnConsumedCapacity=TOTAL nas operacoes que
+// aceitam o parametro. Os inputs dos outros servicos (ex: Streams) ficam como estao.
+func requestConsumedCapacity(params any) {
+	var field *types.ReturnConsumedCapacity
+	switch in := params.(type) {
+	case *dynamodb.GetItemInput:
+		field = &in.ReturnConsumedCapacity
+	case *dynamodb.PutItemInput:
+		field = &in.ReturnConsumedCapacity
+	case *dynamodb.UpdateItemInput:
+		field = &in.ReturnConsumedCapacity
+	case *dynamodb.DeleteItemInput:
+		field = &in.ReturnConsumedCapacity
+	case *dynamodb.QueryInput:
+		field = &in.ReturnConsumedCapacity
+	case *dynamodb.ScanInput:
+		field = &in.ReturnConsumedCapacity
+	case *dynamodb.BatchGetItemInput:
+		field = &in.ReturnConsumedCapacity
+	case *dynamodb.BatchWriteItemInput:
+		field = &in.ReturnConsumedCapacity
+	case *dynamodb.TransactGetItemsInput:
+		field = &in.ReturnConsumedCapacity
+	case *dynamodb.TransactWriteItemsInput:
+		field = &in.ReturnConsumedCapacity
+	default:
+		return
+	}
+	if *field == "" {
+		*field = types.ReturnConsumedCapacityTotal
+	}
+}
+
+// consumedCapacity extrai o ConsumedCapacity da resposta. As operacoes em lote e as
+// transacoes devolvem um valor por tabela; as demais, um so.
+func consumedCapacity(result any) []types.ConsumedCapacity {
+	var single *types.ConsumedCapacity
+	switch out := result.(type) {
+	case *dynamodb.GetItemOutput:
+		single = out.ConsumedCapacity
+	case *dynamodb.PutItemOutput:
+		single = out.ConsumedCapacity
+	case *dynamodb.UpdateItemOutput:
+		single = out.ConsumedCapacity
+	case *dynamodb.DeleteItemOutput:
+		single = out.ConsumedCapacity
+	case *dynamodb.QueryOutput:
+		single = out.ConsumedCapacity
+	case *dynamodb.ScanOutput:
+		single = out.ConsumedCapacity
+	case *dynamodb.BatchGetItemOutput:
+		return out.ConsumedCapacity
+	case *dynamodb.BatchWriteItemOutput:
+		return out.ConsumedCapacity
+	case *dynamodb.TransactGetItemsOutput:
+		return out.ConsumedCapacity
+	case *dynamodb.TransactWriteItemsOutput:
+		return out.ConsumedCapacity
+	}
+	if single == nil {
+		return nil
+	}
+	return []types.ConsumedCapacity{*single}
+}
+
+// errorCode e o codigo do erro da API (ex: ConditionalCheckFailedException), ou uma
+// categoria para erros que nao vieram do DynamoDB.
+func errorCode(err error) string {
+	var apiErr smithy.APIError
+	switch {
+	case errors.As(err, &apiErr):
+		return apiErr.ErrorCode()
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	default:
+		return "other"
+	}
+}
+
+func isThrottle(err error) bool {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	switch apiErr.ErrorCode() {
+	case "ProvisionedThroughputExceededException", "ProvisionedThroughputExceeded",
+		"RequestLimitExceeded", "ThrottlingException", "ThrottlingError":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,138 @@
+package dynamo
+
+import (
+	"context"
+	"slices"
+
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/smithy-go/middleware"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.40.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName identifica os spans criados por este pacote.
+const tracerName = "github.com/dowglassantana/golang-with-dynamodb/pkg/dynamo"
+
+// WithTracing cria um span OpenTelemetry para cada operacao do client, filho do span
+// que estiver no contexto — o da requisicao HTTP, por exemplo. Assim um trace mostra
+// quanto tempo de cada requisicao foi gasto em cada chamada ao DynamoDB.
+//
+// O span se chama "<operacao> <tabela>" ("GetItem Users") e leva os atributos da
+// convencao semantica de banco de dados: db.system.name, db.operation.name,
+// aws.dynamodb.table_names e aws.request_id, alem de aws.attempts (tentativas, com
+// as novas tentativas do retry). O TracerProvider e o global (otel.SetTracerProvider):
+// sem ele configurado, os spans nao sao gravados e o custo e desprezivel.
+func WithTracing() Option {
+	return func(s *settings) { s.tracing = true }
+}
+
+// tracingMiddleware roda no fim do Initialize (uma vez por operacao), depois do
+// RegisterServiceMetadata, que define o nome da operacao.
+func tracingMiddleware(stack *middleware.Stack) error {
+	return stack.Initialize.Add(middleware.InitializeMiddlewareFunc("Tracing",
+		func(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+			operation := awsmiddleware.GetOperationName(ctx)
+			tables := tableNames(in.Parameters)
+
+			name := operation
+			if len(tables) == 1 {
+				name += " " + tables[0]
+			}
+
+			attrs := []attribute.KeyValue{
+				semconv.DBSystemNameAWSDynamoDB,
+				semconv.DBOperationName(operation),
+			}
+			if len(tables) > 0 {
+				attrs = append(attrs, semconv.AWSDynamoDBTableNames(tables...))
+			}
+			if region := awsmiddleware.GetRegion(ctx); region != "" {
+				attrs = append(attrs, semconv.CloudRegion(region))
+			}
+
+			ctx, span := otel.Tracer(tracerName).Start(ctx, name,
+				trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(attrs...),
+			)
+			defer span.End()
+
+			out, metadata, err := next.HandleInitialize(ctx, in)
+
+			if requestID, ok := awsmiddleware.GetRequestIDMetadata(metadata); ok {
+				span.SetAttributes(semconv.AWSRequestID(requestID))
+			}
+			if attempts, ok := retry.GetAttemptResults(metadata); ok {
+				span.SetAttributes(attribute.Int("aws.attempts", len(attempts.Results)))
+			}
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, errorCode(err))
+			}
+			return out, metadata, err
+		},
+	), middleware.After)
+}
+
+// tableNames extrai as tabelas do input. Operacoes em lote e transacoes podem
+// envolver mais de uma; as operacoes do Streams nao tem tabela (usam o ARN do stream).
+func tableNames(params any) []string {
+	var tables []string
+	switch in := params.(type) {
+	case *dynamodb.GetItemInput:
+		tables = appendTable(tables, in.TableName)
+	case *dynamodb.PutItemInput:
+		tables = appendTable(tables, in.TableName)
+	case *dynamodb.UpdateItemInput:
+		tables = appendTable(tables, in.TableName)
+	case *dynamodb.DeleteItemInput:
+		tables = appendTable(tables, in.TableName)
+	case *dynamodb.QueryInput:
+		tables = appendTable(tables, in.TableName)
+	case *dynamodb.ScanInput:
+		tables = appendTable(tables, in.TableName)
+	case *dynamodb.DescribeTableInput:
+		tables = appendTable(tables, in.TableName)
+	case *dynamodb.BatchGetItemInput:
+		for table := range in.RequestItems {
+			tables = append(tables, table)
+		}
+	case *dynamodb.BatchWriteItemInput:
+		for table := range in.RequestItems {
+			tables = append(tables, table)
+		}
+	case *dynamodb.TransactGetItemsInput:
+		for _, item := range in.TransactItems {
+			if item.Get != nil {
+				tables = appendTable(tables, item.Get.TableName)
+			}
+		}
+	case *dynamodb.TransactWriteItemsInput:
+		for _, item := range in.TransactItems {
+			switch {
+			case item.Put != nil:
+				tables = appendTable(tables, item.Put.TableName)
+			case item.Update != nil:
+				tables = appendTable(tables, item.Update.TableName)
+			case item.Delete != nil:
+				tables = appendTable(tables, item.Delete.TableName)
+			case item.ConditionCheck != nil:
+				tables = appendTable(tables, item.ConditionCheck.TableName)
+			}
+		}
+	}
+
+	slices.Sort(tables)
+	return slices.Compact(tables)
+}
+
+func appendTable(tables []string, table *string) []string {
+	if table == nil || *table == "" {
+		return tables
+	}
+	return append(tables, *table)
+}